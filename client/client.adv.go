@@ -0,0 +1,128 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync/atomic"
+
+	"github.com/BurntSushi/toml"
+	"github.com/magiconair/properties"
+	"github.com/meidoworks/nekoq-component/configure/configclient"
+	"gopkg.in/yaml.v3"
+)
+
+// ContainerValidator checks a newly decoded container before it is published.
+// Returning an error rejects the update and the container keeps the previous value.
+type ContainerValidator func(container any) error
+
+// ClientAdv provides auto updated configure containers on top of the upstream ClientAdv, which registers the
+// requirements. As upstream, registering is allowed after the client is started by suspending the client meanwhile.
+type ClientAdv struct {
+	*configclient.ClientAdv
+	keys *PrivateKeys
+}
+
+func NewClientAdv(c *Client) *ClientAdv {
+	return &ClientAdv{ClientAdv: configclient.NewClientAdv(c)}
+}
+
+// NewClientAdvWithKeys creates the ClientAdv whose containers open the sealed configurations with the keys.
+// The containers of NewClientAdv drop the sealed configurations instead of decoding them.
+func NewClientAdvWithKeys(c *Client, keys *PrivateKeys) *ClientAdv {
+	return &ClientAdv{ClientAdv: configclient.NewClientAdv(c), keys: keys}
+}
+
+// RegisterJsonContainer will register auto updated configure container with json configure support
+// Note: the behavior is the same as Register method
+func (c *ClientAdv) RegisterJsonContainer(group, key string, container any, validators ...ContainerValidator) (*atomic.Value, error) {
+	return c.Register(group, key, json.Unmarshal, container, validators...)
+}
+
+// RegisterYamlContainer will register auto updated configure container with yaml configure support
+// Note: the behavior is the same as Register method
+func (c *ClientAdv) RegisterYamlContainer(group, key string, container any, validators ...ContainerValidator) (*atomic.Value, error) {
+	return c.Register(group, key, yaml.Unmarshal, container, validators...)
+}
+
+// RegisterTomlContainer will register auto updated configure container with toml configure support
+// Note: the behavior is the same as Register method
+func (c *ClientAdv) RegisterTomlContainer(group, key string, container any, validators ...ContainerValidator) (*atomic.Value, error) {
+	return c.Register(group, key, toml.Unmarshal, container, validators...)
+}
+
+// RegisterPropertiesContainer will register auto updated configure container with java properties configure support.
+// Fields of the container are mapped using the `properties` struct tag.
+// Note: the behavior is the same as Register method
+func (c *ClientAdv) RegisterPropertiesContainer(group, key string, container any, validators ...ContainerValidator) (*atomic.Value, error) {
+	return c.Register(group, key, PropertiesUnmarshal, container, validators...)
+}
+
+// Register will register auto updated configure container with the same type as the container provided.
+// Every update is decoded into a new instance and checked by the validators in order. The instance is swapped into
+// the returned container only when decoding and all validators succeed, otherwise the previous value is kept.
+// Note1: any further update has to be accessed via responded container rather than the container provided in the parameter
+// Note2: registering after the client is started waits for the ongoing poll of the client to complete
+func (c *ClientAdv) Register(group, key string, unmarshaler Unmarshaler, container any, validators ...ContainerValidator) (*atomic.Value, error) {
+	if unmarshaler == nil {
+		return nil, errors.New("unmarshaler should not be nil")
+	}
+	if _, err := containerStructType(container); err != nil {
+		return nil, err
+	}
+	keys := c.keys
+	return registerAdv(c.ClientAdv, group, key, container, func(data []byte, v any) error {
+		data, err := openSealed(keys, data)
+		if err != nil {
+			return fmt.Errorf("container update rejected, group: %s, key: %s, error: %w", group, key, err)
+		}
+		if err := decodeContainer(data, unmarshaler, v, validators); err != nil {
+			return fmt.Errorf("container update rejected, group: %s, key: %s, error: %w", group, key, err)
+		}
+		return nil
+	})
+}
+
+// PropertiesUnmarshal decodes java properties content into the struct pointed by v using the `properties` struct tag
+func PropertiesUnmarshal(data []byte, v any) error {
+	p, err := properties.Load(data, properties.UTF8)
+	if err != nil {
+		return err
+	}
+	return p.Decode(v)
+}
+
+func decodeContainer(data []byte, unmarshaler Unmarshaler, container any, validators []ContainerValidator) error {
+	if err := unmarshaler(data, container); err != nil {
+		return fmt.Errorf("unmarshal failed: %w", err)
+	}
+	for _, validator := range validators {
+		if validator == nil {
+			continue
+		}
+		if err := validator(container); err != nil {
+			return fmt.Errorf("validation failed: %w", err)
+		}
+	}
+	return nil
+}
+
+func containerStructType(container any) (reflect.Type, error) {
+	t := reflect.TypeOf(container)
+	if t == nil || t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct {
+		return nil, errors.New("container parameter should be '*struct' type")
+	}
+	return t.Elem(), nil
+}
+
+// registerAdv registers the container by the upstream ClientAdv and converts the panics, e.g. duplicate requirement,
+// into errors. The upstream ClientAdv logs the errors of the unmarshaler and keeps the previous value.
+func registerAdv(adv *configclient.ClientAdv, group, key string, container any, unmarshaler Unmarshaler) (result *atomic.Value, rerr error) {
+	defer func() {
+		if r := recover(); r != nil {
+			rerr = fmt.Errorf("add configuration requirement failed: %v", r)
+		}
+	}()
+	return adv.Register(group, key, unmarshaler, container)
+}
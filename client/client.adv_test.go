@@ -0,0 +1,68 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+type FormatContainer struct {
+	Str  string `json:"str" yaml:"str" toml:"str" properties:"str"`
+	Int  int    `json:"int" yaml:"int" toml:"int" properties:"int"`
+	Bool bool   `json:"bool" yaml:"bool" toml:"bool" properties:"bool"`
+}
+
+func TestDecodeContainerFormats(t *testing.T) {
+	cases := []struct {
+		Name        string
+		Unmarshaler Unmarshaler
+		Data        string
+	}{
+		{Name: "json", Unmarshaler: json.Unmarshal, Data: `{"str":"test string","int":112233,"bool":true}`},
+		{Name: "yaml", Unmarshaler: yaml.Unmarshal, Data: "str: test string\nint: 112233\nbool: true\n"},
+		{Name: "toml", Unmarshaler: toml.Unmarshal, Data: "str = \"test string\"\nint = 112233\nbool = true\n"},
+		{Name: "properties", Unmarshaler: PropertiesUnmarshal, Data: "str=test string\nint=112233\nbool=true\n"},
+	}
+	for _, c := range cases {
+		container := new(FormatContainer)
+		if err := decodeContainer([]byte(c.Data), c.Unmarshaler, container, nil); err != nil {
+			t.Fatal(c.Name, err)
+		}
+		if container.Str != "test string" || container.Int != 112233 || !container.Bool {
+			t.Fatal(c.Name, "unexpected value:", *container)
+		}
+	}
+}
+
+func TestDecodeContainerValidation(t *testing.T) {
+	validator := func(container any) error {
+		if container.(*FormatContainer).Int <= 0 {
+			return errors.New("int should be positive")
+		}
+		return nil
+	}
+	if err := decodeContainer([]byte(`{"int":1}`), json.Unmarshal, new(FormatContainer), []ContainerValidator{validator}); err != nil {
+		t.Fatal(err)
+	}
+	if err := decodeContainer([]byte(`{"int":-1}`), json.Unmarshal, new(FormatContainer), []ContainerValidator{validator}); err == nil {
+		t.Fatal("validation error expected")
+	}
+	if err := decodeContainer([]byte(`{"int":`), json.Unmarshal, new(FormatContainer), []ContainerValidator{validator}); err == nil {
+		t.Fatal("unmarshal error expected")
+	}
+}
+
+func TestContainerStructType(t *testing.T) {
+	if _, err := containerStructType(FormatContainer{}); err == nil {
+		t.Fatal("struct value should be rejected")
+	}
+	if _, err := containerStructType(nil); err == nil {
+		t.Fatal("nil should be rejected")
+	}
+	if _, err := containerStructType(new(FormatContainer)); err != nil {
+		t.Fatal(err)
+	}
+}
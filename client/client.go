@@ -12,10 +12,4 @@ func NewClient(serverList []string, opt ClientOptions) *Client {
 	return configclient.NewClient(serverList, opt)
 }
 
-type ClientAdv = configclient.ClientAdv
-
 type Unmarshaler = configclient.Unmarshaler
-
-func NewClientAdv(c *Client) *ClientAdv {
-	return configclient.NewClientAdv(c)
-}
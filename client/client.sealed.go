@@ -0,0 +1,40 @@
+package client
+
+import (
+	"log"
+
+	"github.com/meidoworks/nekoq-component/configure/configapi"
+
+	"github.com/goodplayer/onlyconfig/secrets"
+)
+
+// PrivateKeys opens the secret configurations sealed for the recipient key of the application in web manager
+type PrivateKeys = secrets.PrivateKeys
+
+// LoadPrivateKeys loads the private keys from the files containing the keys in hex.
+// Both the old and the new keys should be provided while the recipient key of the application is being rotated.
+func LoadPrivateKeys(paths ...string) (*PrivateKeys, error) {
+	return secrets.LoadPrivateKeyFiles(paths...)
+}
+
+// Decrypt wraps the callback of RequiredConfig to receive the sealed configurations opened by the keys.
+// The other configurations are passed as is, while the ones failed to open are dropped.
+func Decrypt(keys *PrivateKeys, callback func(cfg configapi.Configuration)) func(cfg configapi.Configuration) {
+	return func(cfg configapi.Configuration) {
+		plaintext, err := openSealed(keys, cfg.Value)
+		if err != nil {
+			log.Println("[ERROR] open sealed configuration failed, keep previous value. group:", cfg.Group, "key:", cfg.Key, "version:", cfg.Version, "error:", err)
+			return
+		}
+		cfg.Value = plaintext
+		callback(cfg)
+	}
+}
+
+// openSealed opens the sealed value with the keys, the others are returned as is
+func openSealed(keys *PrivateKeys, value []byte) ([]byte, error) {
+	if !secrets.IsSealed(string(value)) {
+		return value, nil
+	}
+	return keys.Open(string(value))
+}
@@ -0,0 +1,201 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+
+	"github.com/BurntSushi/toml"
+	"github.com/meidoworks/nekoq-component/configure/configclient"
+	"gopkg.in/yaml.v3"
+)
+
+// ContentType is the content type of configuration which decides how the value is decoded
+// The values are the same as the content types in web manager
+type ContentType string
+
+const (
+	ContentTypeGeneral    ContentType = "general"
+	ContentTypeJson       ContentType = "json"
+	ContentTypeYaml       ContentType = "yaml"
+	ContentTypeToml       ContentType = "toml"
+	ContentTypeProperties ContentType = "properties"
+)
+
+// UnmarshalerOf returns the Unmarshaler of the content type
+// For ContentTypeGeneral, the target should be *string or *[]byte
+func UnmarshalerOf(ct ContentType) (Unmarshaler, error) {
+	switch ct {
+	case ContentTypeGeneral:
+		return generalUnmarshal, nil
+	case ContentTypeJson:
+		return json.Unmarshal, nil
+	case ContentTypeYaml:
+		return yaml.Unmarshal, nil
+	case ContentTypeToml:
+		return toml.Unmarshal, nil
+	case ContentTypeProperties:
+		return PropertiesUnmarshal, nil
+	default:
+		return nil, errors.New("unknown content type:" + string(ct))
+	}
+}
+
+func generalUnmarshal(data []byte, v any) error {
+	switch val := v.(type) {
+	case *string:
+		*val = string(data)
+	case *[]byte:
+		*val = append([]byte(nil), data...)
+	default:
+		return errors.New("general content type requires *string or *[]byte")
+	}
+	return nil
+}
+
+// Value is a type-safe handle of a configuration with auto refresh support
+type Value[T any] struct {
+	unmarshaler Unmarshaler
+	validators  []func(T) error
+
+	val atomic.Pointer[T]
+
+	lock        sync.Mutex
+	nextSubId   int64
+	subscribers map[int64]func(old, new T)
+}
+
+// NewValue registers the configuration of group and key to the client and returns the handle of it.
+// Every update is decoded according to the content type and checked by the validators in order.
+// Failed updates are dropped and the previous value is kept.
+// The sealed configurations are dropped, see NewValueWithKeys.
+// Note: registering after the client is started is allowed the same as ClientAdv.Register
+func NewValue[T any](c *Client, group, key string, ct ContentType, validators ...func(T) error) (*Value[T], error) {
+	return NewValueWithKeys[T](c, nil, group, key, ct, validators...)
+}
+
+// NewValueWithKeys is the same as NewValue except that the sealed configurations are opened with the keys
+func NewValueWithKeys[T any](c *Client, keys *PrivateKeys, group, key string, ct ContentType, validators ...func(T) error) (*Value[T], error) {
+	unmarshaler, err := UnmarshalerOf(ct)
+	if err != nil {
+		return nil, err
+	}
+	v := &Value[T]{
+		unmarshaler: unmarshaler,
+		validators:  validators,
+		subscribers: map[int64]func(old, new T){},
+	}
+	v.val.Store(new(T))
+
+	// registered as a container of the upstream ClientAdv in order to be allowed after the client is started,
+	// while the updates are applied to the value rather than the container
+	if _, err := registerAdv(configclient.NewClientAdv(c), group, key, new(valueHolder), func(data []byte, _ any) error {
+		data, err := openSealed(keys, data)
+		if err == nil {
+			err = v.apply(data)
+		}
+		if err != nil {
+			return fmt.Errorf("value update rejected, group: %s, key: %s, error: %w", group, key, err)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// valueHolder is the container registered for a Value
+type valueHolder struct{}
+
+// Get returns the current value. Zero value is returned before the configuration is loaded.
+func (v *Value[T]) Get() T {
+	return *v.val.Load()
+}
+
+// Subscribe registers the function to be called with the old and new value on every accepted update.
+// The returned function cancels the subscription.
+// Note: the function is invoked in the update routine of the client and should return quickly
+func (v *Value[T]) Subscribe(fn func(old, new T)) (cancel func()) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	v.nextSubId++
+	id := v.nextSubId
+	v.subscribers[id] = fn
+	return func() {
+		v.lock.Lock()
+		defer v.lock.Unlock()
+		delete(v.subscribers, id)
+	}
+}
+
+// Changes returns a channel receiving the new value on every accepted update until ctx is done, which closes the
+// channel and releases the subscription.
+// Only the latest value is retained if the receiver is slower than the updates.
+// Note: ctx should be cancelled once the channel is not used, otherwise the subscription is kept for the lifetime of the value
+func (v *Value[T]) Changes(ctx context.Context) <-chan T {
+	ch := make(chan T, 1)
+	var chLock sync.Mutex
+	var closed bool
+	unsubscribe := v.Subscribe(func(old, new T) {
+		chLock.Lock()
+		defer chLock.Unlock()
+		if closed {
+			return
+		}
+		// drop the pending value which is out of date
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- new
+	})
+	// no goroutine is kept waiting for ctx
+	context.AfterFunc(ctx, func() {
+		unsubscribe()
+		chLock.Lock()
+		defer chLock.Unlock()
+		closed = true
+		close(ch)
+	})
+	return ch
+}
+
+func (v *Value[T]) apply(data []byte) error {
+	newVal := new(T)
+	if err := v.unmarshaler(data, newVal); err != nil {
+		return err
+	}
+	for _, validator := range v.validators {
+		if validator == nil {
+			continue
+		}
+		if err := validator(*newVal); err != nil {
+			return err
+		}
+	}
+	old := v.val.Swap(newVal)
+
+	v.lock.Lock()
+	subscribers := make([]func(old, new T), 0, len(v.subscribers))
+	for _, fn := range v.subscribers {
+		subscribers = append(subscribers, fn)
+	}
+	v.lock.Unlock()
+	for _, fn := range subscribers {
+		notifySubscriber(fn, *old, *newVal)
+	}
+	return nil
+}
+
+func notifySubscriber[T any](fn func(old, new T), old, new T) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Println("[ERROR] recover from panic while notifying value subscriber:", r)
+		}
+	}()
+	fn(old, new)
+}
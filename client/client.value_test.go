@@ -0,0 +1,121 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func newTestValue[T any](t *testing.T, ct ContentType, validators ...func(T) error) *Value[T] {
+	c := NewClient([]string{"http://127.0.0.1:8800"}, ClientOptions{
+		SelectorDatacenter: "dc1",
+	})
+	v, err := NewValue[T](c, "group", "key", ct, validators...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestValueGeneral(t *testing.T) {
+	v := newTestValue[string](t, ContentTypeGeneral)
+	if v.Get() != "" {
+		t.Fatal("zero value expected before loaded")
+	}
+	if err := v.apply([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if v.Get() != "hello" {
+		t.Fatal("unexpected value:", v.Get())
+	}
+
+	i := newTestValue[int](t, ContentTypeGeneral)
+	if err := i.apply([]byte("1")); err == nil {
+		t.Fatal("general content type should be rejected for int")
+	}
+}
+
+func TestValueValidationAndSubscribe(t *testing.T) {
+	v := newTestValue[FormatContainer](t, ContentTypeYaml, func(c FormatContainer) error {
+		if c.Int <= 0 {
+			return errors.New("int should be positive")
+		}
+		return nil
+	})
+	var notified []int
+	cancel := v.Subscribe(func(old, new FormatContainer) {
+		notified = append(notified, old.Int, new.Int)
+	})
+	if err := v.apply([]byte("int: 1\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.apply([]byte("int: -1\n")); err == nil {
+		t.Fatal("validation error expected")
+	}
+	if v.Get().Int != 1 {
+		t.Fatal("previous value should be kept")
+	}
+	cancel()
+	if err := v.apply([]byte("int: 2\n")); err != nil {
+		t.Fatal(err)
+	}
+	if len(notified) != 2 || notified[0] != 0 || notified[1] != 1 {
+		t.Fatal("unexpected notifications:", notified)
+	}
+}
+
+func TestValueChanges(t *testing.T) {
+	v := newTestValue[FormatContainer](t, ContentTypeJson)
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := v.Changes(ctx)
+	if err := v.apply([]byte(`{"int":1}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.apply([]byte(`{"int":2}`)); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case c := <-ch:
+		if c.Int != 2 {
+			t.Fatal("latest value expected:", c.Int)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout")
+	}
+	cancel()
+	select {
+	case _, ok := <-ch:
+		if ok {
+			t.Fatal("channel should be closed")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout")
+	}
+}
+
+func TestValueChangesCancel(t *testing.T) {
+	v := newTestValue[FormatContainer](t, ContentTypeJson)
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := v.Changes(ctx)
+	cancel()
+	if _, ok := <-ch; ok {
+		t.Fatal("channel should be closed")
+	}
+	v.lock.Lock()
+	subscribers := len(v.subscribers)
+	v.lock.Unlock()
+	if subscribers != 0 {
+		t.Fatal("subscription should be released")
+	}
+	if err := v.apply([]byte(`{"int":1}`)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUnknownContentType(t *testing.T) {
+	c := NewClient([]string{"http://127.0.0.1:8800"}, ClientOptions{})
+	if _, err := NewValue[string](c, "group", "key", "xml"); err == nil {
+		t.Fatal("unknown content type should be rejected")
+	}
+}
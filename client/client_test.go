@@ -2,14 +2,27 @@ package client
 
 import (
 	"context"
+	"os"
+	"path/filepath"
 	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/meidoworks/nekoq-component/configure/configapi"
+
+	"github.com/goodplayer/onlyconfig/onlyconfigtest"
+	"github.com/goodplayer/onlyconfig/secrets"
 )
 
+const testJsonValue = `{"str":"test string","int":112233,"bool":true}`
+
 func TestClientBasic(t *testing.T) {
-	c := NewClient([]string{"http://127.0.0.1:8800"}, ClientOptions{
+	srv := onlyconfigtest.NewServer(t)
+	if err := srv.Set("app=app1,dc=dc1,env=env1", "group_json", "key_json", []byte(testJsonValue)); err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewClient([]string{srv.URL()}, ClientOptions{
 		SelectorApp:         "app1",
 		SelectorEnvironment: "env1",
 		SelectorDatacenter:  "dc1",
@@ -48,7 +61,12 @@ type Container struct {
 }
 
 func TestClientAdvBasic(t *testing.T) {
-	c := NewClient([]string{"http://127.0.0.1:8800"}, ClientOptions{
+	srv := onlyconfigtest.NewServer(t)
+	if err := srv.Set("dc=dc1", "group_json", "key_json", []byte(testJsonValue)); err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewClient([]string{srv.URL()}, ClientOptions{
 		SelectorDatacenter: "dc1",
 	})
 
@@ -75,4 +93,127 @@ func TestClientAdvBasic(t *testing.T) {
 		t.Fatal(err)
 	}
 	t.Log(*newContainer.Load().(*Container))
+	if loaded := newContainer.Load().(*Container); loaded.Str != "test string" || loaded.Int != 112233 || !loaded.Bool {
+		t.Fatal("unexpected container:", *loaded)
+	}
+}
+
+func TestClientAdvRegisterAfterStart(t *testing.T) {
+	srv := onlyconfigtest.NewServer(t)
+	for _, key := range []string{"key_json", "key_json2", "key_json3"} {
+		if err := srv.Set("dc=dc1", "group_json", key, []byte(testJsonValue)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c := NewClient([]string{srv.URL()}, ClientOptions{
+		SelectorDatacenter: "dc1",
+	})
+	ca := NewClientAdv(c)
+	if _, err := ca.RegisterJsonContainer("group_json", "key_json", new(Container)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ca.RegisterJsonContainer("group_json", "key_json", new(Container)); err == nil {
+		t.Fatal("duplicate registering should fail")
+	}
+	if err := c.StartClient(); err != nil {
+		t.Fatal(err)
+	}
+	defer func(c *Client) {
+		err := c.StopClient()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}(c)
+	if err := c.WaitStartupConfigureLoaded(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// the registering waits for the ongoing poll, which returns on the update
+	type registered struct {
+		container *atomic.Value
+		value     *Value[Container]
+		err       error
+	}
+	resultCh := make(chan registered, 1)
+	go func() {
+		var r registered
+		if r.container, r.err = ca.RegisterJsonContainer("group_json", "key_json2", new(Container)); r.err == nil {
+			r.value, r.err = NewValue[Container](c, "group_json", "key_json3", ContentTypeJson)
+		}
+		resultCh <- r
+	}()
+	if err := srv.Set("dc=dc1", "group_json", "key_json", []byte(testJsonValue)); err != nil {
+		t.Fatal(err)
+	}
+	r := <-resultCh
+	if r.err != nil {
+		t.Fatal("registering after start should be allowed:", r.err)
+	}
+	deadline := time.Now().Add(30 * time.Second)
+	for r.container.Load().(*Container).Str != "test string" || r.value.Get().Str != "test string" {
+		if time.Now().After(deadline) {
+			t.Fatal("configurations registered after start should be loaded")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestClientSealed(t *testing.T) {
+	privateKey, publicKey, err := secrets.GenerateRecipientKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := secrets.SealFor(publicKey, []byte(testJsonValue))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := onlyconfigtest.NewServer(t)
+	if err := srv.Set("dc=dc1", "group_json", "key_json", []byte(sealed)); err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.Set("dc=dc1", "group_json", "key_value", []byte(sealed)); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "app.key")
+	if err := os.WriteFile(path, []byte(privateKey), 0600); err != nil {
+		t.Fatal(err)
+	}
+	keys, err := LoadPrivateKeys(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	load := func(keys *PrivateKeys) (*Container, Container) {
+		c := NewClient([]string{srv.URL()}, ClientOptions{
+			SelectorDatacenter: "dc1",
+		})
+		container, err := NewClientAdvWithKeys(c, keys).RegisterJsonContainer("group_json", "key_json", new(Container))
+		if err != nil {
+			t.Fatal(err)
+		}
+		value, err := NewValueWithKeys[Container](c, keys, "group_json", "key_value", ContentTypeJson)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := c.StartClient(); err != nil {
+			t.Fatal(err)
+		}
+		defer func(c *Client) {
+			if err := c.StopClient(); err != nil {
+				t.Fatal(err)
+			}
+		}(c)
+		if err := c.WaitStartupConfigureLoaded(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		return container.Load().(*Container), value.Get()
+	}
+	if loaded, value := load(keys); loaded.Str != "test string" || loaded.Int != 112233 || !loaded.Bool || value != *loaded {
+		t.Fatal("unexpected container:", *loaded, value)
+	}
+	// dropped without the private key
+	if loaded, value := load(nil); *loaded != (Container{}) || value != (Container{}) {
+		t.Fatal("sealed configuration should be dropped:", *loaded, value)
+	}
 }
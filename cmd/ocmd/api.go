@@ -0,0 +1,369 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrUnauthorized = errors.New("unauthorized, please login with: ocmd login")
+
+// ApiError is returned when the web manager responds with unexpected status
+type ApiError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *ApiError) Error() string {
+	if e.Body == "" {
+		return fmt.Sprint("request failed with status ", e.StatusCode, " ", http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprint("request failed with status ", e.StatusCode, " ", http.StatusText(e.StatusCode), ": ", e.Body)
+}
+
+// ConflictError is returned when the configuration has been changed since the version the change is based on
+type ConflictError struct {
+	Message string
+	Current *Configure
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprint(e.Message, ", the current version is ", e.Current.Version)
+}
+
+// conflictError converts the 409 response carrying the current configuration to ConflictError
+func conflictError(err error) error {
+	var apiErr *ApiError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusConflict {
+		return err
+	}
+	resp := struct {
+		Error   string     `json:"error"`
+		Current *Configure `json:"current"`
+	}{}
+	if json.Unmarshal([]byte(apiErr.Body), &resp) != nil || resp.Current == nil {
+		return err
+	}
+	return &ConflictError{Message: resp.Error, Current: resp.Current}
+}
+
+type Organization struct {
+	OrgId     string   `json:"org_id"`
+	OrgName   string   `json:"org_name"`
+	OwnerList []string `json:"owner_list"`
+	UserList  []string `json:"user_list"`
+}
+
+type Application struct {
+	AppId           int64  `json:"app_id"`
+	AppName         string `json:"app_name"`
+	AppDesc         string `json:"app_desc"`
+	AppOwnerOrgId   string `json:"app_owner_org_id"`
+	AppOwnerOrgName string `json:"app_owner_org_name"`
+	RecipientKey    string `json:"app_recipient_key"`
+	EnvAndDc        []struct {
+		Env    string   `json:"env"`
+		DcList []string `json:"dc_list"`
+	} `json:"env_and_dc"`
+	TimeUpdated int64 `json:"time_updated"`
+}
+
+type NamespaceConfigures struct {
+	Namespace  string `json:"namespace"`
+	ConfigList []struct {
+		Key      string `json:"key"`
+		ConfigId string `json:"configure_id"`
+	} `json:"configure_list"`
+}
+
+type Configure struct {
+	ConfigId     string `json:"cfg_id"`
+	ConfigKey    string `json:"cfg_key"`
+	ConfigNs     string `json:"cfg_ns"`
+	ConfigEnv    string `json:"cfg_env"`
+	ConfigDc     string `json:"cfg_dc"`
+	ConfigStatus int64  `json:"cfg_status"`
+	ContentType  string `json:"cfg_ct"`
+	Content      string `json:"cfg_content"`
+	Version      string `json:"cfg_version"`
+	HasDraft     bool   `json:"cfg_has_draft"`
+	Secret       bool   `json:"cfg_secret"`
+}
+
+// ApiClient calls the web manager api
+type ApiClient struct {
+	Server string
+	Token  string
+
+	client *http.Client
+}
+
+func NewApiClient(server, token string) *ApiClient {
+	return &ApiClient{
+		Server: strings.TrimSuffix(server, "/"),
+		Token:  token,
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (a *ApiClient) do(method, path string, reqBody, respBody any) error {
+	var body []byte
+	if reqBody != nil {
+		data, err := json.Marshal(reqBody)
+		if err != nil {
+			return err
+		}
+		body = data
+	}
+	data, err := a.send(method, path, "application/json", body)
+	if err != nil {
+		return err
+	}
+	if respBody == nil {
+		return nil
+	}
+	return json.Unmarshal(data, respBody)
+}
+
+// send sends the body as is and returns the response body
+func (a *ApiClient) send(method, path, contentType string, reqBody []byte) ([]byte, error) {
+	var body io.Reader
+	if reqBody != nil {
+		body = bytes.NewReader(reqBody)
+	}
+	req, err := http.NewRequest(method, a.Server+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if reqBody != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if a.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+a.Token)
+	}
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func(body io.ReadCloser) {
+		_ = body.Close()
+	}(resp.Body)
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		return nil, ErrUnauthorized
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, &ApiError{StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(data))}
+	}
+	return data, nil
+}
+
+// p builds the path with escaped segments
+func p(segments ...string) string {
+	var sb strings.Builder
+	for _, s := range segments {
+		sb.WriteByte('/')
+		sb.WriteString(url.PathEscape(s))
+	}
+	return sb.String()
+}
+
+func (a *ApiClient) Login(username, password string) (string, error) {
+	resp := struct {
+		Token string `json:"token"`
+	}{}
+	err := a.do(http.MethodPost, "/auth/user/login", map[string]string{
+		"username": username,
+		"password": password,
+	}, &resp)
+	if errors.Is(err, ErrUnauthorized) {
+		return "", errors.New("login failed: invalid username or password")
+	}
+	return resp.Token, err
+}
+
+func (a *ApiClient) Organizations() ([]Organization, error) {
+	resp := struct {
+		Result []Organization `json:"result"`
+	}{}
+	err := a.do(http.MethodGet, "/user/organizations", nil, &resp)
+	return resp.Result, err
+}
+
+func (a *ApiClient) Applications() ([]Application, error) {
+	resp := struct {
+		List []Application `json:"list"`
+	}{}
+	err := a.do(http.MethodGet, "/configures/applications", nil, &resp)
+	return resp.List, err
+}
+
+// Application finds the application by name
+func (a *ApiClient) Application(appName string) (*Application, error) {
+	apps, err := a.Applications()
+	if err != nil {
+		return nil, err
+	}
+	for i := range apps {
+		if apps[i].AppName == appName {
+			return &apps[i], nil
+		}
+	}
+	return nil, errors.New("application not found: " + appName)
+}
+
+func (a *ApiClient) CreateApplication(orgId, appName string) error {
+	return a.do(http.MethodPut, "/configures/application"+p(orgId, appName), nil, nil)
+}
+
+func (a *ApiClient) LinkEnvAndDc(appId int64, env, dc string) error {
+	return a.do(http.MethodPut, "/configures/application"+p(strconv.FormatInt(appId, 10), env, dc), nil, nil)
+}
+
+func (a *ApiClient) EnvDcList() (envs []string, dcs []string, err error) {
+	resp := struct {
+		Env []string `json:"env"`
+		Dc  []string `json:"dc"`
+	}{}
+	err = a.do(http.MethodGet, "/configures/env_dc_list", nil, &resp)
+	return resp.Env, resp.Dc, err
+}
+
+// AddEnvOrDc adds env or dc by addType
+func (a *ApiClient) AddEnvOrDc(addType, name string) error {
+	return a.do(http.MethodPut, "/configures/env_and_dc"+p(addType, name), nil, nil)
+}
+
+func (a *ApiClient) Namespaces(appId int64) ([]string, error) {
+	resp := struct {
+		Result []string `json:"result"`
+	}{}
+	err := a.do(http.MethodGet, "/configures/namespaces"+p(strconv.FormatInt(appId, 10)), nil, &resp)
+	return resp.Result, err
+}
+
+func (a *ApiClient) AddNamespace(appId int64, nsName, nsType string) error {
+	return a.do(http.MethodPut, "/configures/namespace"+p(strconv.FormatInt(appId, 10), nsName, nsType), nil, nil)
+}
+
+func (a *ApiClient) ConfigureList(appId int64, env, dc string) ([]NamespaceConfigures, error) {
+	resp := struct {
+		Result []NamespaceConfigures `json:"result"`
+	}{}
+	err := a.do(http.MethodGet, "/configures/configure_list"+p(strconv.FormatInt(appId, 10), env, dc), nil, &resp)
+	return resp.Result, err
+}
+
+// FindConfigureId returns the id of the configuration, empty if not found
+func (a *ApiClient) FindConfigureId(appId int64, env, dc, ns, key string) (string, error) {
+	list, err := a.ConfigureList(appId, env, dc)
+	if err != nil {
+		return "", err
+	}
+	for _, nsCfg := range list {
+		if nsCfg.Namespace != ns {
+			continue
+		}
+		for _, cfg := range nsCfg.ConfigList {
+			if cfg.Key == key {
+				return cfg.ConfigId, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// Configure returns the configuration, the content of a secret one is masked unless revealed
+func (a *ApiClient) Configure(cfgId string, reveal bool) (*Configure, error) {
+	resp := struct {
+		Result *Configure `json:"result"`
+	}{}
+	path := "/configures/configure" + p(cfgId)
+	if reveal {
+		path += "?reveal=true"
+	}
+	err := a.do(http.MethodGet, path, nil, &resp)
+	return resp.Result, err
+}
+
+func (a *ApiClient) AddConfigure(appId int64, env, dc, ns, key, contentType, content string, secret bool) error {
+	return a.do(http.MethodPost, "/configures/configure"+p(strconv.FormatInt(appId, 10), env, dc, ns, key), map[string]any{
+		"content_type": contentType,
+		"content":      content,
+		"secret":       secret,
+	}, nil)
+}
+
+// UpdateConfigure updates and publishes the configuration if it is still at the version.
+// secret turns the configuration into secret, a secret one cannot be turned back.
+func (a *ApiClient) UpdateConfigure(cfgId, contentType, content, version string, secret bool) error {
+	return conflictError(a.do(http.MethodPut, "/configures/configure"+p(cfgId), map[string]any{
+		"ct":          contentType,
+		"content":     content,
+		"cfg_version": version,
+		"secret":      secret,
+	}, nil))
+}
+
+type ImportItem struct {
+	Namespace string `json:"namespace"`
+	Env       string `json:"env,omitempty"`
+	Dc        string `json:"dc,omitempty"`
+	Key       string `json:"key,omitempty"`
+	Action    string `json:"action"`
+}
+
+type ImportResult struct {
+	DryRun bool         `json:"dry_run"`
+	Items  []ImportItem `json:"items"`
+}
+
+// ExportApplication returns the archive of the application in json or yaml format.
+// envDcList is in ENV:DC format, all linked env and dc are exported if empty.
+func (a *ApiClient) ExportApplication(appId int64, format string, envDcList []string) ([]byte, error) {
+	q := url.Values{}
+	q.Set("format", format)
+	for _, item := range envDcList {
+		q.Add("env_dc", item)
+	}
+	return a.send(http.MethodGet, "/configures/export"+p(strconv.FormatInt(appId, 10))+"?"+q.Encode(), "", nil)
+}
+
+// ImportApplication imports the archive to the application.
+// The result of conflicts is returned together with the error if the import is rejected due to conflicts.
+func (a *ApiClient) ImportApplication(appId int64, format, strategy string, dryRun bool, archive []byte) (*ImportResult, error) {
+	q := url.Values{}
+	q.Set("format", format)
+	q.Set("strategy", strategy)
+	q.Set("dry_run", strconv.FormatBool(dryRun))
+	contentType := "application/json"
+	if format == "yaml" {
+		contentType = "application/yaml"
+	}
+	data, err := a.send(http.MethodPost, "/configures/import"+p(strconv.FormatInt(appId, 10))+"?"+q.Encode(), contentType, archive)
+	var apiErr *ApiError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusConflict {
+		result := new(ImportResult)
+		if json.Unmarshal([]byte(apiErr.Body), result) == nil {
+			return result, errors.New("import rejected due to conflicts, use -strategy skip or overwrite")
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	result := new(ImportResult)
+	if err := json.Unmarshal(data, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func appExportCmd(c *cmdContext, args []string) error {
+	fs, format := c.flags()
+	appName := fs.String("app", "", "application name")
+	archiveFormat := fs.String("format", "json", "archive format: json or yaml")
+	file := fs.String("file", "", "output file, default stdout")
+	var envDcList stringList
+	fs.Var(&envDcList, "envdc", "ENV:DC to export, can be repeated, default all linked env and dc")
+	if err := c.parse(fs, format, args); err != nil {
+		return err
+	}
+	if *appName == "" {
+		return errors.New("-app required")
+	}
+	api, err := c.api()
+	if err != nil {
+		return err
+	}
+	app, err := api.Application(*appName)
+	if err != nil {
+		return err
+	}
+	data, err := api.ExportApplication(app.AppId, *archiveFormat, envDcList)
+	if err != nil {
+		return err
+	}
+	if *file == "" {
+		_, err := c.out.Write(data)
+		return err
+	}
+	if err := os.WriteFile(*file, data, 0600); err != nil {
+		return err
+	}
+	return c.printer.Message("Exported to " + *file + ".")
+}
+
+func appImportCmd(c *cmdContext, args []string) error {
+	fs, format := c.flags()
+	appName := fs.String("app", "", "application name")
+	file := fs.String("file", "", "archive file, default stdin")
+	archiveFormat := fs.String("format", "", "archive format: json or yaml, default by file extension or json")
+	strategy := fs.String("strategy", "fail", "conflict strategy for existing configurations: skip, overwrite or fail")
+	dryRun := fs.Bool("dryrun", false, "show what would be changed without applying")
+	if err := c.parse(fs, format, args); err != nil {
+		return err
+	}
+	if *appName == "" {
+		return errors.New("-app required")
+	}
+	if *archiveFormat == "" {
+		*archiveFormat = archiveFormatOf(*file)
+	}
+	var data []byte
+	var err error
+	if *file == "" {
+		data, err = io.ReadAll(c.in)
+	} else {
+		data, err = os.ReadFile(*file)
+	}
+	if err != nil {
+		return err
+	}
+	api, err := c.api()
+	if err != nil {
+		return err
+	}
+	app, err := api.Application(*appName)
+	if err != nil {
+		return err
+	}
+	result, importErr := api.ImportApplication(app.AppId, *archiveFormat, *strategy, *dryRun, data)
+	if result != nil {
+		var rows [][]string
+		for _, item := range result.Items {
+			typ := "configure"
+			if item.Key == "" {
+				typ = "namespace"
+			}
+			rows = append(rows, []string{typ, item.Namespace, item.Env, item.Dc, item.Key, item.Action})
+		}
+		if err := c.printer.Print(result, []string{"TYPE", "NAMESPACE", "ENV", "DC", "KEY", "ACTION"}, rows); err != nil {
+			return err
+		}
+	}
+	return importErr
+}
+
+func archiveFormatOf(file string) string {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml":
+		return "yaml"
+	default:
+		return "json"
+	}
+}
@@ -0,0 +1,540 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+const defaultContentType = "general"
+
+func loginCmd(c *cmdContext, args []string) error {
+	fs, format := c.flags()
+	server := fs.String("server", "", "web manager address, e.g. http://127.0.0.1:8880, default the previous logged in one")
+	username := fs.String("username", "", "user name")
+	password := fs.String("password", "", "password, default from OCMD_PASSWORD environment variable or stdin")
+	if err := c.parse(fs, format, args); err != nil {
+		return err
+	}
+	cred, err := loadCredential()
+	if err != nil {
+		return err
+	}
+	if *server == "" {
+		*server = cred.Server
+	}
+	if *server == "" {
+		return errors.New("-server required")
+	}
+	if *username == "" {
+		return errors.New("-username required")
+	}
+	if *password == "" {
+		*password = os.Getenv("OCMD_PASSWORD")
+	}
+	if *password == "" {
+		_, _ = fmt.Fprint(c.err, "Password: ")
+		line, err := bufio.NewReader(c.in).ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+		*password = strings.TrimRight(line, "\r\n")
+	}
+
+	token, err := NewApiClient(*server, "").Login(*username, *password)
+	if err != nil {
+		return err
+	}
+	if err := saveCredential(&Credential{Server: *server, Username: *username, Token: token}); err != nil {
+		return err
+	}
+	return c.printer.Message("Login succeeded.")
+}
+
+func logoutCmd(c *cmdContext, args []string) error {
+	fs, format := c.flags()
+	if err := c.parse(fs, format, args); err != nil {
+		return err
+	}
+	if err := removeCredential(); err != nil {
+		return err
+	}
+	return c.printer.Message("Logged out.")
+}
+
+func orgListCmd(c *cmdContext, args []string) error {
+	fs, format := c.flags()
+	if err := c.parse(fs, format, args); err != nil {
+		return err
+	}
+	api, err := c.api()
+	if err != nil {
+		return err
+	}
+	orgs, err := api.Organizations()
+	if err != nil {
+		return err
+	}
+	var rows [][]string
+	for _, org := range orgs {
+		rows = append(rows, []string{org.OrgId, org.OrgName, strings.Join(org.OwnerList, ","), strings.Join(org.UserList, ",")})
+	}
+	return c.printer.Print(orgs, []string{"ID", "NAME", "OWNERS", "USERS"}, rows)
+}
+
+func appListCmd(c *cmdContext, args []string) error {
+	fs, format := c.flags()
+	if err := c.parse(fs, format, args); err != nil {
+		return err
+	}
+	api, err := c.api()
+	if err != nil {
+		return err
+	}
+	apps, err := api.Applications()
+	if err != nil {
+		return err
+	}
+	var rows [][]string
+	for _, app := range apps {
+		var envDc []string
+		for _, item := range app.EnvAndDc {
+			envDc = append(envDc, item.Env+":"+strings.Join(item.DcList, "|"))
+		}
+		rows = append(rows, []string{strconv.FormatInt(app.AppId, 10), app.AppName, app.AppOwnerOrgName, strings.Join(envDc, ",")})
+	}
+	return c.printer.Print(apps, []string{"ID", "NAME", "ORGANIZATION", "ENV:DC"}, rows)
+}
+
+func appCreateCmd(c *cmdContext, args []string) error {
+	fs, format := c.flags()
+	orgName := fs.String("org", "", "owner organization name")
+	appName := fs.String("name", "", "application name")
+	if err := c.parse(fs, format, args); err != nil {
+		return err
+	}
+	if *orgName == "" || *appName == "" {
+		return errors.New("-org and -name required")
+	}
+	api, err := c.api()
+	if err != nil {
+		return err
+	}
+	orgs, err := api.Organizations()
+	if err != nil {
+		return err
+	}
+	orgId := ""
+	for _, org := range orgs {
+		if org.OrgName == *orgName {
+			orgId = org.OrgId
+		}
+	}
+	if orgId == "" {
+		return errors.New("organization not found: " + *orgName)
+	}
+	if err := api.CreateApplication(orgId, *appName); err != nil {
+		return err
+	}
+	return c.printer.Message("Application created.")
+}
+
+func appLinkCmd(c *cmdContext, args []string) error {
+	fs, format := c.flags()
+	appName := fs.String("app", "", "application name")
+	env := fs.String("env", "", "environment")
+	dc := fs.String("dc", "", "datacenter")
+	if err := c.parse(fs, format, args); err != nil {
+		return err
+	}
+	if *appName == "" || *env == "" || *dc == "" {
+		return errors.New("-app, -env and -dc required")
+	}
+	api, err := c.api()
+	if err != nil {
+		return err
+	}
+	app, err := api.Application(*appName)
+	if err != nil {
+		return err
+	}
+	if err := api.LinkEnvAndDc(app.AppId, *env, *dc); err != nil {
+		return err
+	}
+	return c.printer.Message("Environment and datacenter linked.")
+}
+
+// envDcListCmd lists env or dc by listType
+func envDcListCmd(listType string) func(c *cmdContext, args []string) error {
+	return func(c *cmdContext, args []string) error {
+		fs, format := c.flags()
+		if err := c.parse(fs, format, args); err != nil {
+			return err
+		}
+		api, err := c.api()
+		if err != nil {
+			return err
+		}
+		envs, dcs, err := api.EnvDcList()
+		if err != nil {
+			return err
+		}
+		list := envs
+		if listType == "dc" {
+			list = dcs
+		}
+		if list == nil {
+			list = []string{}
+		}
+		var rows [][]string
+		for _, name := range list {
+			rows = append(rows, []string{name})
+		}
+		return c.printer.Print(list, []string{strings.ToUpper(listType)}, rows)
+	}
+}
+
+// envDcCreateCmd creates env or dc by addType
+func envDcCreateCmd(addType string) func(c *cmdContext, args []string) error {
+	return func(c *cmdContext, args []string) error {
+		fs, format := c.flags()
+		if err := c.parse(fs, format, args); err != nil {
+			return err
+		}
+		if fs.NArg() != 1 {
+			return errors.New("name required: ocmd " + c.name + " NAME")
+		}
+		api, err := c.api()
+		if err != nil {
+			return err
+		}
+		if err := api.AddEnvOrDc(addType, fs.Arg(0)); err != nil {
+			return err
+		}
+		return c.printer.Message(strings.ToUpper(addType) + " created.")
+	}
+}
+
+func nsListCmd(c *cmdContext, args []string) error {
+	fs, format := c.flags()
+	appName := fs.String("app", "", "application name")
+	if err := c.parse(fs, format, args); err != nil {
+		return err
+	}
+	if *appName == "" {
+		return errors.New("-app required")
+	}
+	api, err := c.api()
+	if err != nil {
+		return err
+	}
+	app, err := api.Application(*appName)
+	if err != nil {
+		return err
+	}
+	list, err := api.Namespaces(app.AppId)
+	if err != nil {
+		return err
+	}
+	if list == nil {
+		list = []string{}
+	}
+	var rows [][]string
+	for _, name := range list {
+		rows = append(rows, []string{name})
+	}
+	return c.printer.Print(list, []string{"NAMESPACE"}, rows)
+}
+
+func nsCreateCmd(c *cmdContext, args []string) error {
+	fs, format := c.flags()
+	appName := fs.String("app", "", "application name")
+	nsName := fs.String("name", "", "namespace name")
+	nsType := fs.String("type", "application", "namespace type: application or public")
+	if err := c.parse(fs, format, args); err != nil {
+		return err
+	}
+	if *appName == "" || *nsName == "" {
+		return errors.New("-app and -name required")
+	}
+	api, err := c.api()
+	if err != nil {
+		return err
+	}
+	app, err := api.Application(*appName)
+	if err != nil {
+		return err
+	}
+	if err := api.AddNamespace(app.AppId, *nsName, *nsType); err != nil {
+		return err
+	}
+	return c.printer.Message("Namespace created.")
+}
+
+func configListCmd(c *cmdContext, args []string) error {
+	fs, format := c.flags()
+	appName := fs.String("app", "", "application name")
+	env := fs.String("env", "", "environment")
+	dc := fs.String("dc", "", "datacenter")
+	if err := c.parse(fs, format, args); err != nil {
+		return err
+	}
+	if *appName == "" || *env == "" || *dc == "" {
+		return errors.New("-app, -env and -dc required")
+	}
+	api, err := c.api()
+	if err != nil {
+		return err
+	}
+	app, err := api.Application(*appName)
+	if err != nil {
+		return err
+	}
+	list, err := api.ConfigureList(app.AppId, *env, *dc)
+	if err != nil {
+		return err
+	}
+	if list == nil {
+		list = []NamespaceConfigures{}
+	}
+	var rows [][]string
+	for _, ns := range list {
+		for _, cfg := range ns.ConfigList {
+			rows = append(rows, []string{ns.Namespace, cfg.Key, cfg.ConfigId})
+		}
+	}
+	return c.printer.Print(list, []string{"NAMESPACE", "KEY", "ID"}, rows)
+}
+
+// configLocation is the flags locating a configuration
+type configLocation struct {
+	app, env, dc, ns, key *string
+}
+
+func locationFlags(fs *flag.FlagSet) *configLocation {
+	return &configLocation{
+		app: fs.String("app", "", "application name"),
+		env: fs.String("env", "", "environment"),
+		dc:  fs.String("dc", "", "datacenter"),
+		ns:  fs.String("ns", "", "namespace"),
+		key: fs.String("key", "", "configuration key"),
+	}
+}
+
+// resolve returns the application id and the configuration id, empty configuration id if not found
+func (l *configLocation) resolve(api *ApiClient) (int64, string, error) {
+	if *l.app == "" || *l.env == "" || *l.dc == "" || *l.ns == "" || *l.key == "" {
+		return 0, "", errors.New("-app, -env, -dc, -ns and -key required")
+	}
+	app, err := api.Application(*l.app)
+	if err != nil {
+		return 0, "", err
+	}
+	cfgId, err := api.FindConfigureId(app.AppId, *l.env, *l.dc, *l.ns, *l.key)
+	if err != nil {
+		return 0, "", err
+	}
+	return app.AppId, cfgId, nil
+}
+
+func (l *configLocation) String() string {
+	return fmt.Sprint(*l.app, "/", *l.env, "/", *l.dc, "/", *l.ns, "/", *l.key)
+}
+
+func configGetCmd(c *cmdContext, args []string) error {
+	fs, format := c.flags()
+	loc := locationFlags(fs)
+	reveal := fs.Bool("reveal", false, "reveal the content of a secret configuration, requires organization owner and is audited")
+	if err := c.parse(fs, format, args); err != nil {
+		return err
+	}
+	api, err := c.api()
+	if err != nil {
+		return err
+	}
+	_, cfgId, err := loc.resolve(api)
+	if err != nil {
+		return err
+	}
+	if cfgId == "" {
+		return errors.New("configuration not found: " + loc.String())
+	}
+	cfg, err := api.Configure(cfgId, *reveal)
+	if err != nil {
+		return err
+	}
+	if c.printer.format == outputJson {
+		return c.printer.JSON(cfg)
+	}
+	// print the raw content in order to be redirected to file
+	_, err = io.WriteString(c.out, cfg.Content)
+	return err
+}
+
+func configSetCmd(c *cmdContext, args []string) error {
+	fs, format := c.flags()
+	loc := locationFlags(fs)
+	value := fs.String("value", "", "configuration content")
+	file := fs.String("file", "", "file of configuration content")
+	ct := fs.String("ct", defaultContentType, "content type")
+	draft := fs.Bool("draft", false, "save as draft without publishing")
+	version := fs.String("version", "", "version the change is based on, rejected if changed since then. defaults to the current version")
+	secret := fs.Bool("secret", false, "encrypt the configuration at rest and mask it unless revealed, cannot be turned back")
+	if err := c.parse(fs, format, args); err != nil {
+		return err
+	}
+	var content string
+	switch {
+	case *value != "" && *file != "":
+		return errors.New("-value and -file cannot be used together")
+	case *value != "":
+		content = *value
+	case *file != "":
+		data, err := os.ReadFile(*file)
+		if err != nil {
+			return err
+		}
+		content = string(data)
+	default:
+		data, err := io.ReadAll(c.in)
+		if err != nil {
+			return err
+		}
+		content = string(data)
+	}
+	api, err := c.api()
+	if err != nil {
+		return err
+	}
+	appId, cfgId, err := loc.resolve(api)
+	if err != nil {
+		return err
+	}
+	if *secret && *draft {
+		return errors.New("-secret cannot be used with -draft")
+	}
+	if cfgId == "" && *draft {
+		return errors.New("configuration not found, draft can only be saved for existing configuration: " + loc.String())
+	}
+	if *draft {
+		if err := api.SaveDraft(cfgId, *ct, content, *version); err != nil {
+			return err
+		}
+		return c.printer.Message("Draft saved.")
+	}
+	if cfgId == "" {
+		if err := api.AddConfigure(appId, *loc.env, *loc.dc, *loc.ns, *loc.key, *ct, content, *secret); err != nil {
+			return err
+		}
+		return c.printer.Message("Configuration created.")
+	}
+	if *version == "" {
+		cfg, err := api.Configure(cfgId, false)
+		if err != nil {
+			return err
+		}
+		*version = cfg.Version
+	}
+	if err := api.UpdateConfigure(cfgId, *ct, content, *version, *secret); err != nil {
+		return err
+	}
+	return c.printer.Message("Configuration updated.")
+}
+
+func configEditCmd(c *cmdContext, args []string) error {
+	fs, format := c.flags()
+	loc := locationFlags(fs)
+	if err := c.parse(fs, format, args); err != nil {
+		return err
+	}
+	api, err := c.api()
+	if err != nil {
+		return err
+	}
+	appId, cfgId, err := loc.resolve(api)
+	if err != nil {
+		return err
+	}
+	var origin []byte
+	var version string
+	ct := defaultContentType
+	if cfgId != "" {
+		cfg, err := api.Configure(cfgId, false)
+		if err != nil {
+			return err
+		}
+		// the content of a secret configuration is revealed for editing
+		if cfg.Secret {
+			if cfg, err = api.Configure(cfgId, true); err != nil {
+				return err
+			}
+		}
+		origin = []byte(cfg.Content)
+		ct = cfg.ContentType
+		version = cfg.Version
+	}
+
+	edited, err := editContent(*loc.ns+"-"+*loc.key, origin, c.out, c.err)
+	if err != nil {
+		return err
+	}
+	if cfgId != "" && bytes.Equal(origin, edited) {
+		return c.printer.Message("No change.")
+	}
+	if cfgId == "" {
+		if err := api.AddConfigure(appId, *loc.env, *loc.dc, *loc.ns, *loc.key, ct, string(edited), false); err != nil {
+			return err
+		}
+		return c.printer.Message("Configuration created.")
+	}
+	// rejected if changed by others while editing
+	if err := api.UpdateConfigure(cfgId, ct, string(edited), version, false); err != nil {
+		return err
+	}
+	return c.printer.Message("Configuration updated.")
+}
+
+// editContent opens the content in $EDITOR and returns the saved content
+func editContent(name string, content []byte, out, errOut io.Writer) ([]byte, error) {
+	editor := strings.Fields(os.Getenv("EDITOR"))
+	if len(editor) == 0 {
+		if runtime.GOOS == "windows" {
+			editor = []string{"notepad"}
+		} else {
+			editor = []string{"vi"}
+		}
+	}
+	dir, err := os.MkdirTemp("", "ocmd-")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	file := filepath.Join(dir, strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' {
+			return '_'
+		}
+		return r
+	}, name))
+	if err := os.WriteFile(file, content, 0600); err != nil {
+		return nil, err
+	}
+	cmd := exec.Command(editor[0], append(editor[1:], file)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = out
+	cmd.Stderr = errOut
+	if err := cmd.Run(); err != nil {
+		return nil, errors.New("editor failed: " + err.Error())
+	}
+	return os.ReadFile(file)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Credential is the login state saved in the user config file
+type Credential struct {
+	Server   string `json:"server"`
+	Username string `json:"username"`
+	Token    string `json:"token"`
+}
+
+// credentialFile returns the user config file path, can be overridden by OCMD_CONFIG
+func credentialFile() (string, error) {
+	if f := os.Getenv("OCMD_CONFIG"); f != "" {
+		return f, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "onlyconfig", "ocmd.json"), nil
+}
+
+// loadCredential returns empty credential if not logged in
+func loadCredential() (*Credential, error) {
+	f, err := credentialFile()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(f)
+	if errors.Is(err, fs.ErrNotExist) {
+		return new(Credential), nil
+	} else if err != nil {
+		return nil, err
+	}
+	c := new(Credential)
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, errors.New("invalid config file " + f + ": " + err.Error())
+	}
+	return c, nil
+}
+
+func saveCredential(c *Credential) error {
+	f, err := credentialFile()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(f), 0700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(f, data, 0600); err != nil {
+		return err
+	}
+	// the file may exist with wider permission
+	return os.Chmod(f, 0600)
+}
+
+func removeCredential() error {
+	f, err := credentialFile()
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(f); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
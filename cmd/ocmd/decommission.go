@@ -0,0 +1,218 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Decommission struct {
+	Type          string `json:"type"`
+	Name          string `json:"name"`
+	TimeOffline   int64  `json:"time_offline"`
+	TimeDeletable int64  `json:"time_deletable"`
+}
+
+type DecommissionDependent struct {
+	ConfigId        int64  `json:"configure_id"`
+	App             string `json:"app"`
+	Env             string `json:"env"`
+	Dc              string `json:"dc"`
+	Namespace       string `json:"namespace"`
+	Key             string `json:"key"`
+	Version         string `json:"version"`
+	Published       bool   `json:"published"`
+	PublishedStatus int64  `json:"published_status,omitempty"`
+	TimePublished   int64  `json:"time_published,omitempty"`
+	TimeFetched     int64  `json:"time_fetched,omitempty"`
+}
+
+type DecommissionReport struct {
+	Type                string                  `json:"type"`
+	Name                string                  `json:"name"`
+	Offline             bool                    `json:"offline"`
+	TimeOffline         int64                   `json:"time_offline,omitempty"`
+	TimeDeletable       int64                   `json:"time_deletable,omitempty"`
+	Namespaces          []string                `json:"namespaces"`
+	Applications        []string                `json:"applications"`
+	LinkingApplications []string                `json:"linking_applications"`
+	Dependents          []DecommissionDependent `json:"dependents"`
+}
+
+func (a *ApiClient) Decommissions() ([]Decommission, error) {
+	resp := struct {
+		List []Decommission `json:"list"`
+	}{}
+	err := a.do(http.MethodGet, "/configures/decommissions", nil, &resp)
+	return resp.List, err
+}
+
+func (a *ApiClient) DecommissionReport(entityType, name string) (*DecommissionReport, error) {
+	report := new(DecommissionReport)
+	if err := a.do(http.MethodGet, "/configures/decommission"+p(entityType, name), nil, report); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+func (a *ApiClient) OfflineEntity(entityType, name string) error {
+	return a.do(http.MethodPut, "/configures/decommission"+p(entityType, name), nil, nil)
+}
+
+func (a *ApiClient) OnlineEntity(entityType, name string) error {
+	return a.do(http.MethodDelete, "/configures/decommission"+p(entityType, name), nil, nil)
+}
+
+func (a *ApiClient) DeleteEntity(entityType, name string) (*DecommissionReport, error) {
+	report := new(DecommissionReport)
+	if err := a.do(http.MethodPost, "/configures/decommission"+p(entityType, name, "delete"), nil, report); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// entityFlags parses -type and -name of the decommission commands
+func entityFlags(c *cmdContext, args []string) (string, string, error) {
+	fs, format := c.flags()
+	entityType := fs.String("type", "", "entity type: app, env, dc or ns")
+	name := fs.String("name", "", "entity name")
+	if err := c.parse(fs, format, args); err != nil {
+		return "", "", err
+	}
+	switch *entityType {
+	case "app", "env", "dc", "ns":
+	default:
+		return "", "", errors.New("-type should be one of app, env, dc and ns")
+	}
+	if *name == "" {
+		return "", "", errors.New("-name required")
+	}
+	return *entityType, *name, nil
+}
+
+func formatMillis(ms int64) string {
+	if ms == 0 {
+		return "-"
+	}
+	return time.UnixMilli(ms).Format(time.RFC3339)
+}
+
+func decomListCmd(c *cmdContext, args []string) error {
+	fs, format := c.flags()
+	if err := c.parse(fs, format, args); err != nil {
+		return err
+	}
+	api, err := c.api()
+	if err != nil {
+		return err
+	}
+	list, err := api.Decommissions()
+	if err != nil {
+		return err
+	}
+	if list == nil {
+		list = []Decommission{}
+	}
+	var rows [][]string
+	for _, d := range list {
+		rows = append(rows, []string{d.Type, d.Name, formatMillis(d.TimeOffline), formatMillis(d.TimeDeletable)})
+	}
+	return c.printer.Print(list, []string{"TYPE", "NAME", "OFFLINE", "DELETABLE"}, rows)
+}
+
+func decomShowCmd(c *cmdContext, args []string) error {
+	entityType, name, err := entityFlags(c, args)
+	if err != nil {
+		return err
+	}
+	api, err := c.api()
+	if err != nil {
+		return err
+	}
+	report, err := api.DecommissionReport(entityType, name)
+	if err != nil {
+		return err
+	}
+	return printDecommissionReport(c, report)
+}
+
+func decomOfflineCmd(c *cmdContext, args []string) error {
+	entityType, name, err := entityFlags(c, args)
+	if err != nil {
+		return err
+	}
+	api, err := c.api()
+	if err != nil {
+		return err
+	}
+	if err := api.OfflineEntity(entityType, name); err != nil {
+		return err
+	}
+	return c.printer.Message("Marked as offline.")
+}
+
+func decomOnlineCmd(c *cmdContext, args []string) error {
+	entityType, name, err := entityFlags(c, args)
+	if err != nil {
+		return err
+	}
+	api, err := c.api()
+	if err != nil {
+		return err
+	}
+	if err := api.OnlineEntity(entityType, name); err != nil {
+		return err
+	}
+	return c.printer.Message("Back online.")
+}
+
+func decomDeleteCmd(c *cmdContext, args []string) error {
+	entityType, name, err := entityFlags(c, args)
+	if err != nil {
+		return err
+	}
+	api, err := c.api()
+	if err != nil {
+		return err
+	}
+	report, err := api.DeleteEntity(entityType, name)
+	if err != nil {
+		return err
+	}
+	return printDecommissionReport(c, report)
+}
+
+func printDecommissionReport(c *cmdContext, report *DecommissionReport) error {
+	if c.printer.format == outputJson {
+		return c.printer.JSON(report)
+	}
+	status := "online"
+	if report.Offline {
+		status = "offline since " + formatMillis(report.TimeOffline) + ", deletable after " + formatMillis(report.TimeDeletable)
+	}
+	_, _ = fmt.Fprintf(c.out, "%s %s: %s\n", report.Type, report.Name, status)
+	if len(report.Namespaces) > 0 {
+		_, _ = fmt.Fprintf(c.out, "Namespaces: %s\n", strings.Join(report.Namespaces, ", "))
+	}
+	if len(report.Applications) > 0 {
+		_, _ = fmt.Fprintf(c.out, "Linked applications: %s\n", strings.Join(report.Applications, ", "))
+	}
+	if len(report.LinkingApplications) > 0 {
+		_, _ = fmt.Fprintf(c.out, "Namespaces linked by: %s\n", strings.Join(report.LinkingApplications, ", "))
+	}
+	var rows [][]string
+	for _, dep := range report.Dependents {
+		published := "-"
+		if dep.Published {
+			published = formatMillis(dep.TimePublished)
+			if dep.PublishedStatus != 0 {
+				published += "(deleted)"
+			}
+		}
+		rows = append(rows, []string{strconv.FormatInt(dep.ConfigId, 10), dep.App, dep.Env, dep.Dc, dep.Namespace, dep.Key, dep.Version, published, formatMillis(dep.TimeFetched)})
+	}
+	return c.printer.Print(report, []string{"ID", "APP", "ENV", "DC", "NAMESPACE", "KEY", "VERSION", "PUBLISHED", "FETCHED"}, rows)
+}
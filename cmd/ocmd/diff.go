@@ -0,0 +1,183 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+type ConfigureHistory struct {
+	ConfigId    string `json:"cfg_id"`
+	Version     string `json:"cfg_version"`
+	ContentType string `json:"cfg_ct"`
+	TimeCreated int64  `json:"time_created"`
+}
+
+type DiffSide struct {
+	Label       string `json:"label"`
+	Version     string `json:"version"`
+	ContentType string `json:"content_type"`
+}
+
+type StructuralChange struct {
+	Path string `json:"path"`
+	Op   string `json:"op"`
+	From string `json:"from,omitempty"`
+	To   string `json:"to,omitempty"`
+}
+
+type ConfigureDiff struct {
+	Type      string             `json:"type"`
+	From      DiffSide           `json:"from"`
+	To        DiffSide           `json:"to"`
+	Identical bool               `json:"identical"`
+	Unified   string             `json:"unified,omitempty"`
+	Changes   []StructuralChange `json:"changes,omitempty"`
+	Message   string             `json:"message,omitempty"`
+}
+
+func (a *ApiClient) ConfigureHistory(cfgId string) ([]ConfigureHistory, error) {
+	resp := struct {
+		List []ConfigureHistory `json:"list"`
+	}{}
+	err := a.do(http.MethodGet, "/configures/history"+p(cfgId), nil, &resp)
+	return resp.List, err
+}
+
+func (a *ApiClient) DiffVersions(cfgId, from, to, format string) (*ConfigureDiff, error) {
+	query := url.Values{}
+	query.Set("from", from)
+	if to != "" {
+		query.Set("to", to)
+	}
+	if format != "" {
+		query.Set("format", format)
+	}
+	result := new(ConfigureDiff)
+	if err := a.do(http.MethodGet, "/configures/diff"+p(cfgId)+"?"+query.Encode(), nil, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (a *ApiClient) DiffEnvDc(appId int64, ns, key, fromEnv, fromDc, toEnv, toDc, format string) (*ConfigureDiff, error) {
+	query := url.Values{}
+	query.Set("from_env", fromEnv)
+	query.Set("from_dc", fromDc)
+	query.Set("to_env", toEnv)
+	query.Set("to_dc", toDc)
+	if format != "" {
+		query.Set("format", format)
+	}
+	result := new(ConfigureDiff)
+	if err := a.do(http.MethodGet, "/configures/diff_env_dc"+p(strconv.FormatInt(appId, 10), ns, key)+"?"+query.Encode(), nil, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func configHistoryCmd(c *cmdContext, args []string) error {
+	fs, format := c.flags()
+	loc := locationFlags(fs)
+	if err := c.parse(fs, format, args); err != nil {
+		return err
+	}
+	api, err := c.api()
+	if err != nil {
+		return err
+	}
+	_, cfgId, err := loc.resolve(api)
+	if err != nil {
+		return err
+	}
+	if cfgId == "" {
+		return errors.New("configuration not found: " + loc.String())
+	}
+	list, err := api.ConfigureHistory(cfgId)
+	if err != nil {
+		return err
+	}
+	if list == nil {
+		list = []ConfigureHistory{}
+	}
+	var rows [][]string
+	for _, h := range list {
+		rows = append(rows, []string{h.Version, h.ContentType, formatMillis(h.TimeCreated)})
+	}
+	return c.printer.Print(list, []string{"VERSION", "CONTENT TYPE", "CREATED"}, rows)
+}
+
+func configDiffCmd(c *cmdContext, args []string) error {
+	fs, format := c.flags()
+	loc := locationFlags(fs)
+	from := fs.String("from", "", "version to compare from")
+	to := fs.String("to", "", "version to compare to, the current version if empty")
+	toEnv := fs.String("toenv", "", "environment to compare to instead of versions")
+	toDc := fs.String("todc", "", "datacenter to compare to instead of versions")
+	diffFormat := fs.String("format", "", "compare as json, yaml, toml or properties instead of the content type")
+	if err := c.parse(fs, format, args); err != nil {
+		return err
+	}
+	api, err := c.api()
+	if err != nil {
+		return err
+	}
+	var result *ConfigureDiff
+	switch {
+	case *toEnv != "" || *toDc != "":
+		if *toEnv == "" || *toDc == "" {
+			return errors.New("both -toenv and -todc required")
+		}
+		if *loc.app == "" || *loc.env == "" || *loc.dc == "" || *loc.ns == "" || *loc.key == "" {
+			return errors.New("-app, -env, -dc, -ns and -key required")
+		}
+		app, err := api.Application(*loc.app)
+		if err != nil {
+			return err
+		}
+		result, err = api.DiffEnvDc(app.AppId, *loc.ns, *loc.key, *loc.env, *loc.dc, *toEnv, *toDc, *diffFormat)
+		if err != nil {
+			return err
+		}
+	case *from != "":
+		_, cfgId, err := loc.resolve(api)
+		if err != nil {
+			return err
+		}
+		if cfgId == "" {
+			return errors.New("configuration not found: " + loc.String())
+		}
+		result, err = api.DiffVersions(cfgId, *from, *to, *diffFormat)
+		if err != nil {
+			return err
+		}
+	default:
+		return errors.New("-from or -toenv and -todc required")
+	}
+	return printDiff(c, result)
+}
+
+func printDiff(c *cmdContext, result *ConfigureDiff) error {
+	if c.printer.format == outputJson {
+		return c.printer.JSON(result)
+	}
+	if result.Message != "" {
+		_, _ = fmt.Fprintln(c.out, result.Message)
+	}
+	if result.Identical {
+		return c.printer.Message("Identical.")
+	}
+	if result.Type != "structural" {
+		_, err := io.WriteString(c.out, result.Unified)
+		return err
+	}
+	_, _ = fmt.Fprintf(c.out, "--- %s\n+++ %s\n", result.From.Label, result.To.Label)
+	var rows [][]string
+	for _, change := range result.Changes {
+		rows = append(rows, []string{change.Op, change.Path, change.From, change.To})
+	}
+	return c.printer.Print(result, []string{"OP", "PATH", "FROM", "TO"}, rows)
+}
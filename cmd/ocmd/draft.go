@@ -0,0 +1,197 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+type ContentValidation struct {
+	Valid   bool   `json:"valid"`
+	Format  string `json:"format"`
+	Message string `json:"message,omitempty"`
+}
+
+// SaveDraft saves the draft of the configuration, checked against the version if not empty
+func (a *ApiClient) SaveDraft(cfgId, contentType, content, version string) error {
+	return conflictError(a.do(http.MethodPut, "/configures/configure"+p(cfgId, "draft"), map[string]string{
+		"ct":          contentType,
+		"content":     content,
+		"cfg_version": version,
+	}, nil))
+}
+
+func (a *ApiClient) DiscardDraft(cfgId string) error {
+	return a.do(http.MethodDelete, "/configures/configure"+p(cfgId, "draft"), nil, nil)
+}
+
+func (a *ApiClient) DiffDraft(cfgId, format string) (*ConfigureDiff, error) {
+	query := url.Values{}
+	if format != "" {
+		query.Set("format", format)
+	}
+	result := new(ConfigureDiff)
+	if err := a.do(http.MethodGet, "/configures/configure"+p(cfgId, "draft", "diff")+"?"+query.Encode(), nil, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (a *ApiClient) ValidateDraft(cfgId, format string) (*ContentValidation, error) {
+	query := url.Values{}
+	if format != "" {
+		query.Set("format", format)
+	}
+	result := new(ContentValidation)
+	if err := a.do(http.MethodGet, "/configures/configure"+p(cfgId, "draft", "validate")+"?"+query.Encode(), nil, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Publish publishes the drafts of the configurations together as a release, the drafts are validated as format if provided
+func (a *ApiClient) Publish(appId int64, cfgIds []string, format string) (*Release, error) {
+	query := url.Values{}
+	if format != "" {
+		query.Set("format", format)
+	}
+	release := new(Release)
+	if err := a.do(http.MethodPost, "/configures/publish"+p(strconv.FormatInt(appId, 10))+"?"+query.Encode(), map[string]any{
+		"cfg_ids": cfgIds,
+	}, release); err != nil {
+		return nil, err
+	}
+	return release, nil
+}
+
+// requireDraft resolves the configuration which should have a draft
+func requireDraft(api *ApiClient, loc *configLocation) (string, error) {
+	_, cfgId, err := loc.resolve(api)
+	if err != nil {
+		return "", err
+	}
+	if cfgId == "" {
+		return "", errors.New("configuration not found: " + loc.String())
+	}
+	return cfgId, nil
+}
+
+func configDraftCmd(c *cmdContext, args []string) error {
+	fs, format := c.flags()
+	loc := locationFlags(fs)
+	diffFormat := fs.String("format", "", "compare and validate as json, yaml, toml or properties instead of the content type")
+	if err := c.parse(fs, format, args); err != nil {
+		return err
+	}
+	api, err := c.api()
+	if err != nil {
+		return err
+	}
+	cfgId, err := requireDraft(api, loc)
+	if err != nil {
+		return err
+	}
+	validation, err := api.ValidateDraft(cfgId, *diffFormat)
+	if err != nil {
+		return err
+	}
+	result, err := api.DiffDraft(cfgId, *diffFormat)
+	if err != nil {
+		return err
+	}
+	if c.printer.format == outputJson {
+		return c.printer.JSON(map[string]any{
+			"validation": validation,
+			"diff":       result,
+		})
+	}
+	if validation.Valid {
+		_, _ = fmt.Fprintf(c.out, "Valid as %s.\n", validation.Format)
+	} else {
+		_, _ = fmt.Fprintf(c.out, "Invalid as %s: %s\n", validation.Format, validation.Message)
+	}
+	return printDiff(c, result)
+}
+
+func configDiscardCmd(c *cmdContext, args []string) error {
+	fs, format := c.flags()
+	loc := locationFlags(fs)
+	if err := c.parse(fs, format, args); err != nil {
+		return err
+	}
+	api, err := c.api()
+	if err != nil {
+		return err
+	}
+	cfgId, err := requireDraft(api, loc)
+	if err != nil {
+		return err
+	}
+	if err := api.DiscardDraft(cfgId); err != nil {
+		return err
+	}
+	return c.printer.Message("Draft discarded.")
+}
+
+// publishItemFlags parses the flags of the items to publish
+func publishItemFlags(fs *flag.FlagSet) (appName, env, dc *string, items *stringList) {
+	appName = fs.String("app", "", "application name")
+	env = fs.String("env", "", "environment")
+	dc = fs.String("dc", "", "datacenter")
+	items = new(stringList)
+	fs.Var(items, "item", "NAMESPACE/KEY to publish, can be repeated")
+	return
+}
+
+// resolveItems resolves the application and the ids of the NAMESPACE/KEY items
+func resolveItems(api *ApiClient, appName, env, dc string, items []string) (*Application, []string, error) {
+	if appName == "" || env == "" || dc == "" || len(items) == 0 {
+		return nil, nil, errors.New("-app, -env, -dc and -item required")
+	}
+	app, err := api.Application(appName)
+	if err != nil {
+		return nil, nil, err
+	}
+	var cfgIds []string
+	for _, item := range items {
+		ns, key, ok := strings.Cut(item, "/")
+		if !ok || ns == "" || key == "" {
+			return nil, nil, errors.New("-item should be NAMESPACE/KEY: " + item)
+		}
+		cfgId, err := api.FindConfigureId(app.AppId, env, dc, ns, key)
+		if err != nil {
+			return nil, nil, err
+		}
+		if cfgId == "" {
+			return nil, nil, errors.New("configuration not found: " + fmt.Sprint(appName, "/", env, "/", dc, "/", item))
+		}
+		cfgIds = append(cfgIds, cfgId)
+	}
+	return app, cfgIds, nil
+}
+
+func configPublishCmd(c *cmdContext, args []string) error {
+	fs, format := c.flags()
+	appName, env, dc, items := publishItemFlags(fs)
+	validateFormat := fs.String("format", "", "validate the drafts as json, yaml, toml or properties before publishing")
+	if err := c.parse(fs, format, args); err != nil {
+		return err
+	}
+	api, err := c.api()
+	if err != nil {
+		return err
+	}
+	app, cfgIds, err := resolveItems(api, *appName, *env, *dc, *items)
+	if err != nil {
+		return err
+	}
+	release, err := api.Publish(app.AppId, cfgIds, *validateFormat)
+	if err != nil {
+		return err
+	}
+	return printRelease(c, release)
+}
@@ -1,5 +1,218 @@
 package main
 
-func main() {
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+const usage = `ocmd - OnlyConfig command line tool
+
+Usage:
+  ocmd login -server http://127.0.0.1:8880 -username USER [-password PASSWORD]
+  ocmd logout
+  ocmd org list
+  ocmd app list
+  ocmd app create -org ORG -name APP
+  ocmd app link -app APP -env ENV -dc DC
+  ocmd app export -app APP [-envdc ENV:DC ...] [-format json|yaml] [-file FILE]
+  ocmd app import -app APP [-file FILE] [-format json|yaml] [-strategy fail|skip|overwrite] [-dryrun]
+  ocmd app keygen -file PRIVATE_KEY_FILE
+  ocmd app recipient -app APP (-key PUBLIC_KEY | -clear)
+  ocmd env list
+  ocmd env create NAME
+  ocmd dc list
+  ocmd dc create NAME
+  ocmd ns list -app APP
+  ocmd ns create -app APP -name NAMESPACE [-type application|public]
+  ocmd config list -app APP -env ENV -dc DC
+  ocmd config get -app APP -env ENV -dc DC -ns NAMESPACE -key KEY [-reveal]
+  ocmd config set -app APP -env ENV -dc DC -ns NAMESPACE -key KEY [-value VALUE | -file FILE] [-ct general] [-draft] [-version VERSION] [-secret]
+  ocmd config edit -app APP -env ENV -dc DC -ns NAMESPACE -key KEY
+  ocmd config draft|discard -app APP -env ENV -dc DC -ns NAMESPACE -key KEY
+  ocmd config publish -app APP -env ENV -dc DC -item NAMESPACE/KEY [-item NAMESPACE/KEY ...] [-format json|yaml|toml|properties]
+  ocmd config history -app APP -env ENV -dc DC -ns NAMESPACE -key KEY
+  ocmd config diff -app APP -env ENV -dc DC -ns NAMESPACE -key KEY -from VERSION [-to VERSION] [-format json|yaml|toml|properties]
+  ocmd config diff -app APP -env ENV -dc DC -ns NAMESPACE -key KEY -toenv ENV -todc DC [-format json|yaml|toml|properties]
+  ocmd release list -app APP -env ENV -dc DC
+  ocmd release show|rollback -id RELEASE_ID
+  ocmd schedule create -app APP -env ENV -dc DC -item NAMESPACE/KEY [-item NAMESPACE/KEY ...] -at RFC3339_TIME [-revert DURATION] [-format json|yaml|toml|properties]
+  ocmd schedule list -app APP -env ENV -dc DC
+  ocmd schedule show|cancel -id SCHEDULE_ID
+  ocmd webhook list [-org ORG | -app APP]
+  ocmd webhook create (-org ORG | -app APP) -url URL [-secret SECRET] [-desc DESCRIPTION]
+  ocmd webhook delete|deliveries -id WEBHOOK_ID
+  ocmd webhook redeliver -delivery DELIVERY_ID
+  ocmd decom list
+  ocmd decom show|offline|online|delete -type app|env|dc|ns -name NAME
+  ocmd read -server http://srv1 -sel app=x,env=PROD,dc=dc1 [-optsel beta=1] -group GROUP -key KEY [-timeout 30s]
+  ocmd watch -server http://srv1 -sel app=x,env=PROD,dc=dc1 [-optsel beta=1] -group GROUP -key KEY [-count N]
+
+Every command accepts -o table|json to choose the output format, json is for scripting.
+The password of login can also be provided by OCMD_PASSWORD environment variable or stdin.
+The value of config set is read from stdin if neither -value nor -file is provided.
+config set -draft saves a draft, which is published only by config publish together with the other items.
+config set -version rejects the change if the configuration has been changed since the version, so does config edit
+with the version opened in the editor.
+config set -secret encrypts the configuration at rest, config get masks it unless -reveal by an organization owner.
+app keygen writes a new private key for the clients and prints the public key, which app recipient sets to the
+application so that the secret configurations are sealed for the clients holding the private key.
+release rollback publishes the versions before the release, it fails if any item has been changed since.
+schedule create moves the drafts to a schedule, which publishes them at the time and reverts after -revert if provided.
+webhook create prints the secret signing the payloads only once, a random one is generated if not provided.
+decom offline hides the entity and blocks the changes, decom delete removes it after the grace period.
+read and watch fetch from the read server exactly as an application does, no login required.
+Credentials are stored in the user config directory, which can be overridden by OCMD_CONFIG environment variable.
+`
+
+// command is a leaf command or a group of sub commands
+type command struct {
+	run  func(c *cmdContext, args []string) error
+	subs map[string]*command
+}
+
+var commands = map[string]*command{
+	"login":  {run: loginCmd},
+	"logout": {run: logoutCmd},
+	"org": {subs: map[string]*command{
+		"list": {run: orgListCmd},
+	}},
+	"app": {subs: map[string]*command{
+		"list":      {run: appListCmd},
+		"create":    {run: appCreateCmd},
+		"link":      {run: appLinkCmd},
+		"export":    {run: appExportCmd},
+		"import":    {run: appImportCmd},
+		"keygen":    {run: appKeygenCmd},
+		"recipient": {run: appRecipientCmd},
+	}},
+	"env": {subs: map[string]*command{
+		"list":   {run: envDcListCmd("env")},
+		"create": {run: envDcCreateCmd("env")},
+	}},
+	"dc": {subs: map[string]*command{
+		"list":   {run: envDcListCmd("dc")},
+		"create": {run: envDcCreateCmd("dc")},
+	}},
+	"ns": {subs: map[string]*command{
+		"list":   {run: nsListCmd},
+		"create": {run: nsCreateCmd},
+	}},
+	"config": {subs: map[string]*command{
+		"list":    {run: configListCmd},
+		"get":     {run: configGetCmd},
+		"set":     {run: configSetCmd},
+		"edit":    {run: configEditCmd},
+		"draft":   {run: configDraftCmd},
+		"discard": {run: configDiscardCmd},
+		"publish": {run: configPublishCmd},
+		"history": {run: configHistoryCmd},
+		"diff":    {run: configDiffCmd},
+	}},
+	"release": {subs: map[string]*command{
+		"list":     {run: releaseListCmd},
+		"show":     {run: releaseShowCmd},
+		"rollback": {run: releaseRollbackCmd},
+	}},
+	"schedule": {subs: map[string]*command{
+		"create": {run: scheduleCreateCmd},
+		"list":   {run: scheduleListCmd},
+		"show":   {run: scheduleShowCmd},
+		"cancel": {run: scheduleCancelCmd},
+	}},
+	"webhook": {subs: map[string]*command{
+		"list":       {run: webhookListCmd},
+		"create":     {run: webhookCreateCmd},
+		"delete":     {run: webhookDeleteCmd},
+		"deliveries": {run: webhookDeliveriesCmd},
+		"redeliver":  {run: webhookRedeliverCmd},
+	}},
+	"decom": {subs: map[string]*command{
+		"list":    {run: decomListCmd},
+		"show":    {run: decomShowCmd},
+		"offline": {run: decomOfflineCmd},
+		"online":  {run: decomOnlineCmd},
+		"delete":  {run: decomDeleteCmd},
+	}},
+	"read":  {run: readCmd},
+	"watch": {run: watchCmd},
+}
+
+// cmdContext is the environment of the running command
+type cmdContext struct {
+	in  io.Reader
+	out io.Writer
+	err io.Writer
+
+	name    string
+	printer *printer
+}
+
+// flags creates the flag set of the command with the common flags
+func (c *cmdContext) flags() (*flag.FlagSet, *string) {
+	fs := flag.NewFlagSet("ocmd "+c.name, flag.ContinueOnError)
+	fs.SetOutput(c.err)
+	format := fs.String("o", outputTable, "output format: table or json")
+	return fs, format
+}
+
+// parse parses the flags and creates the printer
+func (c *cmdContext) parse(fs *flag.FlagSet, format *string, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	pr, err := newPrinter(c.out, *format)
+	if err != nil {
+		return err
+	}
+	c.printer = pr
+	return nil
+}
 
+// api creates the api client with the saved credential
+func (c *cmdContext) api() (*ApiClient, error) {
+	cred, err := loadCredential()
+	if err != nil {
+		return nil, err
+	}
+	if cred.Server == "" || cred.Token == "" {
+		return nil, ErrUnauthorized
+	}
+	return NewApiClient(cred.Server, cred.Token), nil
+}
+
+func run(args []string, in io.Reader, out, errOut io.Writer) error {
+	cmds := commands
+	name := ""
+	for {
+		if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+			_, _ = fmt.Fprint(errOut, usage)
+			if len(args) == 0 {
+				return errors.New("command required")
+			}
+			return nil
+		}
+		cmd, ok := cmds[args[0]]
+		if !ok {
+			_, _ = fmt.Fprint(errOut, usage)
+			return errors.New("unknown command: " + (name + " " + args[0])[1:])
+		}
+		name = name + " " + args[0]
+		args = args[1:]
+		if cmd.run != nil {
+			return cmd.run(&cmdContext{in: in, out: out, err: errOut, name: name[1:]}, args)
+		}
+		cmds = cmd.subs
+	}
+}
+
+func main() {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr); err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			_, _ = fmt.Fprintln(os.Stderr, "Error:", err)
+		}
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,849 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/goodplayer/onlyconfig/secrets"
+)
+
+// fakeWebmgr serves a minimal subset of the web manager api
+type fakeWebmgr struct {
+	lock      sync.Mutex
+	configs   map[string]*Configure
+	drafts    map[string]string
+	offline   map[string]bool
+	releases  []*Release
+	schedules []*Schedule
+	webhooks  []*Webhook
+	// recipientKey and appUpdated are of the application demo
+	recipientKey string
+	appUpdated   int64
+}
+
+func (f *fakeWebmgr) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if r.URL.Path == "/auth/user/login" {
+		req := map[string]string{}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if req["username"] != "admin" || req["password"] != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{"token": "tk"})
+		return
+	}
+	if r.Header.Get("Authorization") != "Bearer tk" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/configures/applications":
+		_, _ = fmt.Fprintf(w, `{"list":[{"app_id":1,"app_name":"demo","app_owner_org_name":"org","app_recipient_key":%q,"env_and_dc":[{"env":"PROD","dc_list":["dc1"]}],"time_updated":%d}]}`, f.recipientKey, f.appUpdated)
+	case r.Method == http.MethodGet && r.URL.Path == "/user/organizations":
+		_, _ = w.Write([]byte(`{"result":[{"org_id":"o1","org_name":"org"}]}`))
+	case r.Method == http.MethodPut && parts[1] == "recipient_key":
+		req := struct {
+			RecipientKey string `json:"recipient_key"`
+			TimeUpdated  int64  `json:"time_updated"`
+		}{}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if req.TimeUpdated != f.appUpdated {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		f.recipientKey = req.RecipientKey
+		f.appUpdated++
+		_ = json.NewEncoder(w).Encode(Application{AppId: 1, AppName: "demo", RecipientKey: f.recipientKey, TimeUpdated: f.appUpdated})
+	case r.Method == http.MethodGet && parts[1] == "webhooks":
+		var list []*Webhook
+		appId, _ := strconv.ParseInt(r.URL.Query().Get("app_id"), 10, 64)
+		for _, webhook := range f.webhooks {
+			if webhook != nil && webhook.OrgId == r.URL.Query().Get("org_id") && webhook.AppId == appId {
+				list = append(list, webhook)
+			}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"list": list})
+	case r.Method == http.MethodPost && parts[1] == "webhook":
+		webhook := new(Webhook)
+		_ = json.NewDecoder(r.Body).Decode(webhook)
+		if !strings.HasPrefix(webhook.Url, "http") {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte("invalid webhook: url should be http or https"))
+			return
+		}
+		webhook.WebhookId = fmt.Sprint(len(f.webhooks) + 1)
+		if webhook.Secret == "" {
+			webhook.Secret = "generated"
+		}
+		f.webhooks = append(f.webhooks, webhook)
+		_ = json.NewEncoder(w).Encode(webhook)
+	case parts[1] == "webhook":
+		idx, err := strconv.Atoi(parts[2])
+		if err != nil || idx < 1 || idx > len(f.webhooks) || f.webhooks[idx-1] == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Method == http.MethodDelete {
+			f.webhooks[idx-1] = nil
+			return
+		}
+		_, _ = w.Write([]byte(`{"list":[{"delivery_id":"7","webhook_id":"` + parts[2] + `","event":"configure.changed","status":"failed","attempts":8,"response_status":500,"message":"unexpected response status 500"}]}`))
+	case r.Method == http.MethodPost && parts[1] == "webhook_delivery":
+		_, _ = w.Write([]byte(`{"delivery_id":"` + parts[2] + `","status":"pending","attempts":0}`))
+	case r.Method == http.MethodGet && parts[1] == "export":
+		_, _ = w.Write([]byte("format=" + r.URL.Query().Get("format") + " env_dc=" + strings.Join(r.URL.Query()["env_dc"], ",")))
+	case r.Method == http.MethodPost && parts[1] == "import":
+		if r.URL.Query().Get("strategy") == "fail" {
+			w.WriteHeader(http.StatusConflict)
+			_, _ = w.Write([]byte(`{"dry_run":false,"items":[{"namespace":"ns","env":"PROD","dc":"dc1","key":"k","action":"conflict"}]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"dry_run":true,"items":[{"namespace":"ns","env":"PROD","dc":"dc1","key":"k","action":"update"}]}`))
+	case r.Method == http.MethodGet && parts[1] == "decommissions":
+		var list []map[string]any
+		for name := range f.offline {
+			list = append(list, map[string]any{"type": "ns", "name": name, "time_offline": 1, "time_deletable": 2})
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"list": list})
+	case parts[1] == "decommission" && r.Method == http.MethodPut:
+		f.offline[parts[3]] = true
+	case parts[1] == "decommission" && r.Method == http.MethodDelete:
+		if !f.offline[parts[3]] {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		delete(f.offline, parts[3])
+	case parts[1] == "decommission":
+		if r.Method == http.MethodPost && len(parts) > 4 && parts[4] == "delete" {
+			w.WriteHeader(http.StatusConflict)
+			_, _ = w.Write([]byte("decommission grace period not passed"))
+			return
+		}
+		_, _ = w.Write([]byte(`{"type":"ns","name":"` + parts[3] + `","offline":true,"time_offline":1,"time_deletable":2,"dependents":[{"configure_id":1,"app":"demo","env":"PROD","dc":"dc1","namespace":"ns","key":"k","version":"v1","published":true,"time_published":1,"time_fetched":1}]}`))
+	case r.Method == http.MethodGet && parts[1] == "history":
+		_, _ = w.Write([]byte(`{"list":[{"cfg_id":"` + parts[2] + `","cfg_version":"v2","cfg_ct":"general","time_created":2},{"cfg_id":"` + parts[2] + `","cfg_version":"v1","cfg_ct":"general","time_created":1}]}`))
+	case r.Method == http.MethodGet && parts[1] == "diff":
+		if r.URL.Query().Get("from") != "v1" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(`{"type":"unified","from":{"label":"a@v1"},"to":{"label":"a@v2"},"identical":false,"unified":"--- a@v1\n+++ a@v2\n@@ -1 +1 @@\n-v1\n+v2\n"}`))
+	case r.Method == http.MethodGet && parts[1] == "diff_env_dc":
+		q := r.URL.Query()
+		_, _ = w.Write([]byte(`{"type":"structural","from":{"label":"` + q.Get("from_env") + `"},"to":{"label":"` + q.Get("to_env") + `"},"identical":false,"changes":[{"path":"db.host","op":"changed","from":"\"a\"","to":"\"b\""}]}`))
+	case parts[1] == "configure" && len(parts) > 3 && parts[3] == "draft":
+		draft, ok := f.drafts[parts[2]]
+		switch {
+		case r.Method == http.MethodPut:
+			req := map[string]string{}
+			_ = json.NewDecoder(r.Body).Decode(&req)
+			f.drafts[parts[2]] = req["content"]
+		case !ok:
+			w.WriteHeader(http.StatusNotFound)
+		case r.Method == http.MethodDelete:
+			delete(f.drafts, parts[2])
+		case len(parts) > 4 && parts[4] == "validate":
+			_ = json.NewEncoder(w).Encode(map[string]any{"valid": json.Valid([]byte(draft)), "format": r.URL.Query().Get("format"), "message": "invalid"})
+		case len(parts) > 4 && parts[4] == "diff":
+			_ = json.NewEncoder(w).Encode(map[string]any{"type": "unified", "unified": "-" + f.configs[parts[2]].Content + "\n+" + draft + "\n"})
+		}
+	case r.Method == http.MethodPost && parts[1] == "publish" && len(parts) > 3 && parts[3] == "schedule":
+		req := struct {
+			ConfigIds   []string `json:"cfg_ids"`
+			TimePublish int64    `json:"time_publish"`
+			RevertAfter int64    `json:"revert_after"`
+		}{}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		schedule := &Schedule{ScheduleId: fmt.Sprint(len(f.schedules) + 1), AppId: 1, Env: "PROD", Dc: "dc1", Status: "pending", TimePublish: req.TimePublish, RevertAfter: req.RevertAfter}
+		for _, id := range req.ConfigIds {
+			if _, ok := f.drafts[id]; !ok {
+				w.WriteHeader(http.StatusConflict)
+				return
+			}
+		}
+		for _, id := range req.ConfigIds {
+			cfg := f.configs[id]
+			schedule.Items = append(schedule.Items, ScheduleItem{ConfigId: id, ConfigNs: cfg.ConfigNs, ConfigKey: cfg.ConfigKey, FromVersion: cfg.Version, Content: f.drafts[id]})
+			delete(f.drafts, id)
+		}
+		f.schedules = append(f.schedules, schedule)
+		_ = json.NewEncoder(w).Encode(schedule)
+	case r.Method == http.MethodGet && parts[1] == "schedules":
+		_ = json.NewEncoder(w).Encode(map[string]any{"list": f.schedules})
+	case parts[1] == "schedule":
+		idx, err := strconv.Atoi(parts[2])
+		if err != nil || idx < 1 || idx > len(f.schedules) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		schedule := f.schedules[idx-1]
+		if r.Method == http.MethodDelete {
+			if schedule.Status != "pending" {
+				w.WriteHeader(http.StatusConflict)
+				_, _ = w.Write([]byte("schedule already done"))
+				return
+			}
+			schedule.Status = "canceled"
+		}
+		_ = json.NewEncoder(w).Encode(schedule)
+	case r.Method == http.MethodPost && parts[1] == "publish":
+		req := map[string][]string{}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		for _, id := range req["cfg_ids"] {
+			if _, ok := f.drafts[id]; !ok {
+				w.WriteHeader(http.StatusConflict)
+				return
+			}
+		}
+		release := &Release{ReleaseId: fmt.Sprint(len(f.releases) + 1), AppId: 1, Env: "PROD", Dc: "dc1", ReleaseType: "publish", ReleaseVersion: "v" + fmt.Sprint(len(f.releases)+2)}
+		for _, id := range req["cfg_ids"] {
+			cfg := f.configs[id]
+			release.Items = append(release.Items, ReleaseItem{ConfigId: id, ConfigNs: cfg.ConfigNs, ConfigKey: cfg.ConfigKey, FromVersion: cfg.Version, ToVersion: release.ReleaseVersion})
+			cfg.Content = f.drafts[id]
+			cfg.Version = release.ReleaseVersion
+			delete(f.drafts, id)
+		}
+		f.releases = append(f.releases, release)
+		_ = json.NewEncoder(w).Encode(release)
+	case r.Method == http.MethodGet && parts[1] == "releases":
+		_ = json.NewEncoder(w).Encode(map[string]any{"list": f.releases})
+	case parts[1] == "release":
+		idx, err := strconv.Atoi(parts[2])
+		if err != nil || idx < 1 || idx > len(f.releases) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		release := f.releases[idx-1]
+		if r.Method == http.MethodGet {
+			_ = json.NewEncoder(w).Encode(release)
+			return
+		}
+		if release.RolledBackBy != "" {
+			w.WriteHeader(http.StatusConflict)
+			_, _ = w.Write([]byte("configures changed after the release"))
+			return
+		}
+		rollback := &Release{ReleaseId: fmt.Sprint(len(f.releases) + 1), AppId: 1, Env: "PROD", Dc: "dc1", ReleaseType: "rollback", ReleaseVersion: "v" + fmt.Sprint(len(f.releases)+2), RollbackOf: release.ReleaseId}
+		for _, item := range release.Items {
+			rollback.Items = append(rollback.Items, ReleaseItem{ConfigId: item.ConfigId, ConfigNs: item.ConfigNs, ConfigKey: item.ConfigKey, FromVersion: item.ToVersion, ToVersion: rollback.ReleaseVersion})
+		}
+		release.RolledBackBy = rollback.ReleaseId
+		f.releases = append(f.releases, rollback)
+		_ = json.NewEncoder(w).Encode(rollback)
+	case r.Method == http.MethodGet && parts[1] == "configure_list":
+		var list []map[string]string
+		for id, cfg := range f.configs {
+			list = append(list, map[string]string{"key": cfg.ConfigKey, "configure_id": id})
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"result": []any{map[string]any{"namespace": "ns", "configure_list": list}}})
+	case r.Method == http.MethodPost && parts[1] == "configure":
+		req := struct {
+			ContentType string `json:"content_type"`
+			Content     string `json:"content"`
+			Secret      bool   `json:"secret"`
+		}{}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		id := string(rune('1' + len(f.configs)))
+		f.configs[id] = &Configure{ConfigId: id, ConfigKey: parts[6], ConfigNs: parts[5], ContentType: req.ContentType, Content: req.Content, Version: "v1", Secret: req.Secret}
+	case r.Method == http.MethodGet && parts[1] == "configure":
+		cfg := f.configs[parts[2]]
+		if cfg != nil && cfg.Secret && r.URL.Query().Get("reveal") != "true" {
+			masked := *cfg
+			masked.Content = "******"
+			cfg = &masked
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"result": cfg})
+	case r.Method == http.MethodPut && parts[1] == "configure":
+		req := struct {
+			ContentType string `json:"ct"`
+			Content     string `json:"content"`
+			Version     string `json:"cfg_version"`
+			Secret      bool   `json:"secret"`
+		}{}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		cfg := f.configs[parts[2]]
+		if req.Version != cfg.Version {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusConflict)
+			_ = json.NewEncoder(w).Encode(map[string]any{"error": "changed since the version edited", "current": cfg})
+			return
+		}
+		cfg.ContentType = req.ContentType
+		cfg.Content = req.Content
+		cfg.Secret = cfg.Secret || req.Secret
+		cfg.Version += "'"
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func setupFake(t *testing.T) (*fakeWebmgr, string) {
+	f := &fakeWebmgr{configs: map[string]*Configure{}, drafts: map[string]string{}, offline: map[string]bool{}}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	t.Setenv("OCMD_CONFIG", filepath.Join(t.TempDir(), "ocmd.json"))
+	t.Setenv("OCMD_PASSWORD", "")
+	return f, srv.URL
+}
+
+func runCmd(t *testing.T, stdin string, args ...string) (string, error) {
+	out := new(bytes.Buffer)
+	err := run(args, strings.NewReader(stdin), out, new(bytes.Buffer))
+	return out.String(), err
+}
+
+func TestLogin(t *testing.T) {
+	_, server := setupFake(t)
+
+	if _, err := runCmd(t, "", "app", "list"); !errors.Is(err, ErrUnauthorized) {
+		t.Fatal("should require login:", err)
+	}
+	if _, err := runCmd(t, "wrong\n", "login", "-server", server, "-username", "admin"); err == nil {
+		t.Fatal("login with wrong password should fail")
+	}
+	if _, err := runCmd(t, "secret\n", "login", "-server", server, "-username", "admin"); err != nil {
+		t.Fatal(err)
+	}
+	cred, err := loadCredential()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred.Server != server || cred.Username != "admin" || cred.Token != "tk" {
+		t.Fatal("unexpected credential:", cred)
+	}
+	if runtime.GOOS != "windows" {
+		f, _ := credentialFile()
+		if st, err := os.Stat(f); err != nil || st.Mode().Perm() != 0600 {
+			t.Fatal("credential file should be private:", st.Mode(), err)
+		}
+	}
+
+	out, err := runCmd(t, "", "app", "list", "-o", "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var apps []Application
+	if err := json.Unmarshal([]byte(out), &apps); err != nil {
+		t.Fatal(err)
+	}
+	if len(apps) != 1 || apps[0].AppName != "demo" || apps[0].EnvAndDc[0].DcList[0] != "dc1" {
+		t.Fatal("unexpected apps:", out)
+	}
+	out, err = runCmd(t, "", "app", "list")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(out, "ID") || !strings.Contains(out, "PROD:dc1") {
+		t.Fatal("unexpected table:", out)
+	}
+
+	if _, err := runCmd(t, "", "logout"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runCmd(t, "", "app", "list"); !errors.Is(err, ErrUnauthorized) {
+		t.Fatal("should require login after logout:", err)
+	}
+}
+
+func TestConfigSetGetEdit(t *testing.T) {
+	f, server := setupFake(t)
+	if _, err := runCmd(t, "", "login", "-server", server, "-username", "admin", "-password", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	loc := []string{"-app", "demo", "-env", "PROD", "-dc", "dc1", "-ns", "ns", "-key", "k"}
+
+	if _, err := runCmd(t, "", append([]string{"config", "get"}, loc...)...); err == nil {
+		t.Fatal("get missing configuration should fail")
+	}
+	if _, err := runCmd(t, "v1", append([]string{"config", "set"}, loc...)...); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runCmd(t, "", append([]string{"config", "set", "-value", "v2"}, loc...)...); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.configs) != 1 {
+		t.Fatal("set existing configuration should update:", len(f.configs))
+	}
+	// based on the stale version
+	var conflict *ConflictError
+	if _, err := runCmd(t, "", append([]string{"config", "set", "-value", "v3", "-version", "v1"}, loc...)...); !errors.As(err, &conflict) || conflict.Current.Content != "v2" {
+		t.Fatal("conflict expected:", err)
+	}
+	out, err := runCmd(t, "", append([]string{"config", "get"}, loc...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "v2" {
+		t.Fatal("unexpected content:", out)
+	}
+	out, err = runCmd(t, "", append([]string{"config", "get", "-o", "json"}, loc...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := new(Configure)
+	if err := json.Unmarshal([]byte(out), cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.ContentType != "general" || cfg.Content != "v2" {
+		t.Fatal("unexpected configuration:", out)
+	}
+
+	if runtime.GOOS == "windows" {
+		return
+	}
+	editor := filepath.Join(t.TempDir(), "editor.sh")
+	if err := os.WriteFile(editor, []byte("#!/bin/sh\nprintf v3 > \"$1\"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("EDITOR", editor)
+	if out, err = runCmd(t, "", append([]string{"config", "edit"}, loc...)...); err != nil {
+		t.Fatal(err)
+	}
+	if out != "Configuration updated.\n" || f.configs["1"].Content != "v3" {
+		t.Fatal("unexpected edit result:", out, f.configs["1"].Content)
+	}
+	if out, err = runCmd(t, "", append([]string{"config", "edit"}, loc...)...); err != nil {
+		t.Fatal(err)
+	}
+	if out != "No change.\n" {
+		t.Fatal("unchanged content should not be updated:", out)
+	}
+}
+
+func TestConfigSecret(t *testing.T) {
+	f, server := setupFake(t)
+	if _, err := runCmd(t, "", "login", "-server", server, "-username", "admin", "-password", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	loc := []string{"-app", "demo", "-env", "PROD", "-dc", "dc1", "-ns", "ns", "-key", "k"}
+
+	if _, err := runCmd(t, "", append([]string{"config", "set", "-value", "s1", "-secret", "-draft"}, loc...)...); err == nil {
+		t.Fatal("-secret with -draft should fail")
+	}
+	if _, err := runCmd(t, "", append([]string{"config", "set", "-value", "s1", "-secret"}, loc...)...); err != nil {
+		t.Fatal(err)
+	}
+	if !f.configs["1"].Secret {
+		t.Fatal("secret configuration expected")
+	}
+	if out, err := runCmd(t, "", append([]string{"config", "get"}, loc...)...); err != nil || out != "******" {
+		t.Fatal("masked content expected:", out, err)
+	}
+	if out, err := runCmd(t, "", append([]string{"config", "get", "-reveal"}, loc...)...); err != nil || out != "s1" {
+		t.Fatal("revealed content expected:", out, err)
+	}
+
+	if runtime.GOOS == "windows" {
+		return
+	}
+	// edit the revealed content
+	editor := filepath.Join(t.TempDir(), "editor.sh")
+	if err := os.WriteFile(editor, []byte("#!/bin/sh\nprintf s1- >> \"$1\"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("EDITOR", editor)
+	if _, err := runCmd(t, "", append([]string{"config", "edit"}, loc...)...); err != nil {
+		t.Fatal(err)
+	}
+	if f.configs["1"].Content != "s1s1-" || !f.configs["1"].Secret {
+		t.Fatal("unexpected edit result:", f.configs["1"])
+	}
+}
+
+func TestAppRecipient(t *testing.T) {
+	f, server := setupFake(t)
+	f.appUpdated = 100
+	keyFile := filepath.Join(t.TempDir(), "app.key")
+	out, err := runCmd(t, "", "app", "keygen", "-file", keyFile, "-o", "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := map[string]string{}
+	if err := json.Unmarshal([]byte(out), &result); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := secrets.ParseRecipientKey(result["public_key"]); err != nil {
+		t.Fatal("unexpected public key:", out, err)
+	}
+	if _, err := secrets.LoadPrivateKeyFiles(keyFile); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runCmd(t, "", "app", "keygen", "-file", keyFile); err == nil {
+		t.Fatal("existing private key should not be overwritten")
+	}
+
+	if _, err := runCmd(t, "", "login", "-server", server, "-username", "admin", "-password", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runCmd(t, "", "app", "recipient", "-app", "demo", "-key", result["public_key"], "-clear"); err == nil {
+		t.Fatal("-key with -clear should fail")
+	}
+	if _, err := runCmd(t, "", "app", "recipient", "-app", "demo", "-key", result["public_key"]); err != nil {
+		t.Fatal(err)
+	}
+	if f.recipientKey != result["public_key"] {
+		t.Fatal("recipient key should be set:", f.recipientKey)
+	}
+	if _, err := runCmd(t, "", "app", "recipient", "-app", "demo", "-clear"); err != nil {
+		t.Fatal(err)
+	}
+	if f.recipientKey != "" || f.appUpdated != 102 {
+		t.Fatal("recipient key should be cleared:", f.recipientKey, f.appUpdated)
+	}
+}
+
+func TestUnknownCommand(t *testing.T) {
+	if _, err := runCmd(t, "", "app", "remove"); err == nil || err.Error() != "unknown command: app remove" {
+		t.Fatal("unexpected error:", err)
+	}
+	if _, err := runCmd(t, "", "app", "list", "-o", "xml"); err == nil {
+		t.Fatal("unknown output format should fail")
+	}
+}
+
+func TestExportImport(t *testing.T) {
+	_, server := setupFake(t)
+	if _, err := runCmd(t, "", "login", "-server", server, "-username", "admin", "-password", "secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := runCmd(t, "", "app", "export", "-app", "demo", "-format", "yaml", "-envdc", "PROD:dc1", "-envdc", "PROD:dc2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "format=yaml env_dc=PROD:dc1,PROD:dc2" {
+		t.Fatal("unexpected export:", out)
+	}
+
+	out, err = runCmd(t, "{}", "app", "import", "-app", "demo")
+	if err == nil || !strings.Contains(out, "conflict") {
+		t.Fatal("conflicts should be reported:", out, err)
+	}
+	out, err = runCmd(t, "{}", "app", "import", "-app", "demo", "-strategy", "overwrite", "-dryrun", "-o", "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := new(ImportResult)
+	if err := json.Unmarshal([]byte(out), result); err != nil {
+		t.Fatal(err)
+	}
+	if !result.DryRun || len(result.Items) != 1 || result.Items[0].Action != "update" {
+		t.Fatal("unexpected result:", out)
+	}
+}
+
+func TestDecommission(t *testing.T) {
+	f, server := setupFake(t)
+	if _, err := runCmd(t, "", "login", "-server", server, "-username", "admin", "-password", "secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := runCmd(t, "", "decom", "offline", "-type", "org", "-name", "org1"); err == nil {
+		t.Fatal("unknown type should fail")
+	}
+	if _, err := runCmd(t, "", "decom", "offline", "-type", "ns", "-name", "ns"); err != nil || !f.offline["ns"] {
+		t.Fatal("should be offline:", err)
+	}
+	out, err := runCmd(t, "", "decom", "list", "-o", "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var list []Decommission
+	if err := json.Unmarshal([]byte(out), &list); err != nil || len(list) != 1 || list[0].Name != "ns" {
+		t.Fatal("unexpected list:", out, err)
+	}
+	out, err = runCmd(t, "", "decom", "show", "-type", "ns", "-name", "ns")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(out, "ns ns: offline since") || !strings.Contains(out, "PUBLISHED") || !strings.Contains(out, "FETCHED") || !strings.Contains(out, "demo") {
+		t.Fatal("unexpected report:", out)
+	}
+	if _, err := runCmd(t, "", "decom", "delete", "-type", "ns", "-name", "ns"); err == nil || !strings.Contains(err.Error(), "grace period") {
+		t.Fatal("delete before the grace period should fail:", err)
+	}
+	if _, err := runCmd(t, "", "decom", "online", "-type", "ns", "-name", "ns"); err != nil || f.offline["ns"] {
+		t.Fatal("should be online:", err)
+	}
+	if _, err := runCmd(t, "", "decom", "online", "-type", "ns", "-name", "ns"); err == nil {
+		t.Fatal("online entity should fail")
+	}
+}
+
+func TestConfigDiff(t *testing.T) {
+	_, server := setupFake(t)
+	if _, err := runCmd(t, "", "login", "-server", server, "-username", "admin", "-password", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	loc := []string{"-app", "demo", "-env", "PROD", "-dc", "dc1", "-ns", "ns", "-key", "k"}
+	if _, err := runCmd(t, "v1", append([]string{"config", "set"}, loc...)...); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := runCmd(t, "", append([]string{"config", "history"}, loc...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "VERSION") || strings.Index(out, "v2") > strings.Index(out, "v1") {
+		t.Fatal("unexpected history:", out)
+	}
+	out, err = runCmd(t, "", append([]string{"config", "diff", "-from", "v1"}, loc...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "--- a@v1\n+++ a@v2\n@@ -1 +1 @@\n-v1\n+v2\n" {
+		t.Fatal("unexpected diff:", out)
+	}
+	if _, err := runCmd(t, "", append([]string{"config", "diff", "-from", "v0"}, loc...)...); err == nil {
+		t.Fatal("missing version should fail")
+	}
+	out, err = runCmd(t, "", append([]string{"config", "diff", "-toenv", "UAT", "-todc", "dc1", "-format", "json"}, loc...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(out, "--- PROD\n+++ UAT\n") || !strings.Contains(out, "db.host") {
+		t.Fatal("unexpected structural diff:", out)
+	}
+	if _, err := runCmd(t, "", append([]string{"config", "diff"}, loc...)...); err == nil {
+		t.Fatal("-from or -toenv should be required")
+	}
+}
+
+func TestDraftPublish(t *testing.T) {
+	f, server := setupFake(t)
+	if _, err := runCmd(t, "", "login", "-server", server, "-username", "admin", "-password", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	loc := []string{"-app", "demo", "-env", "PROD", "-dc", "dc1", "-ns", "ns", "-key", "k"}
+	if _, err := runCmd(t, "", append([]string{"config", "set", "-draft", "-value", "v0"}, loc...)...); err == nil {
+		t.Fatal("draft of missing configuration should fail")
+	}
+	if _, err := runCmd(t, "", append([]string{"config", "set", "-value", "v1"}, loc...)...); err != nil {
+		t.Fatal(err)
+	}
+	out, err := runCmd(t, "", append([]string{"config", "set", "-draft", "-value", "v2"}, loc...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "Draft saved.\n" || f.configs["1"].Content != "v1" || f.drafts["1"] != "v2" {
+		t.Fatal("draft should not be published:", out, f.configs["1"].Content)
+	}
+	out, err = runCmd(t, "", append([]string{"config", "draft", "-format", "json"}, loc...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "Invalid as json: invalid\n-v1\n+v2\n" {
+		t.Fatal("unexpected draft:", out)
+	}
+
+	if _, err := runCmd(t, "", "config", "publish", "-app", "demo", "-env", "PROD", "-dc", "dc1", "-item", "k"); err == nil {
+		t.Fatal("invalid item should fail")
+	}
+	out, err = runCmd(t, "", "config", "publish", "-app", "demo", "-env", "PROD", "-dc", "dc1", "-item", "ns/k", "-o", "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	release := new(Release)
+	if err := json.Unmarshal([]byte(out), release); err != nil {
+		t.Fatal(err)
+	}
+	if len(release.Items) != 1 || release.Items[0].ConfigKey != "k" || f.configs["1"].Content != "v2" || len(f.drafts) != 0 {
+		t.Fatal("unexpected published:", out)
+	}
+	if _, err := runCmd(t, "", "config", "publish", "-app", "demo", "-env", "PROD", "-dc", "dc1", "-item", "ns/k"); err == nil {
+		t.Fatal("publish without draft should fail")
+	}
+
+	if _, err := runCmd(t, "", append([]string{"config", "set", "-draft", "-value", "v3"}, loc...)...); err != nil {
+		t.Fatal(err)
+	}
+	if out, err = runCmd(t, "", append([]string{"config", "discard"}, loc...)...); err != nil || out != "Draft discarded.\n" || len(f.drafts) != 0 {
+		t.Fatal("draft should be discarded:", out, err)
+	}
+}
+
+func TestRelease(t *testing.T) {
+	_, server := setupFake(t)
+	if _, err := runCmd(t, "", "login", "-server", server, "-username", "admin", "-password", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	loc := []string{"-app", "demo", "-env", "PROD", "-dc", "dc1", "-ns", "ns", "-key", "k"}
+	if _, err := runCmd(t, "", append([]string{"config", "set", "-value", "v1"}, loc...)...); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runCmd(t, "", append([]string{"config", "set", "-draft", "-value", "v2"}, loc...)...); err != nil {
+		t.Fatal(err)
+	}
+	out, err := runCmd(t, "", "config", "publish", "-app", "demo", "-env", "PROD", "-dc", "dc1", "-item", "ns/k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(out, "Release 1 (publish) PROD/dc1 version v2\n") || !strings.Contains(out, "ns         k    ") {
+		t.Fatal("unexpected publish:", out)
+	}
+
+	if _, err := runCmd(t, "", "release", "list", "-app", "demo"); err == nil {
+		t.Fatal("-env and -dc should be required")
+	}
+	if _, err := runCmd(t, "", "release", "show"); err == nil {
+		t.Fatal("-id should be required")
+	}
+	if _, err := runCmd(t, "", "release", "show", "-id", "9"); err == nil {
+		t.Fatal("missing release should fail")
+	}
+	out, err = runCmd(t, "", "release", "rollback", "-id", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(out, "Release 2 (rollback) PROD/dc1 version v3\nRollback of release 1\n") {
+		t.Fatal("unexpected rollback:", out)
+	}
+	if _, err := runCmd(t, "", "release", "rollback", "-id", "1"); err == nil || !strings.Contains(err.Error(), "changed after the release") {
+		t.Fatal("rollback twice should conflict:", err)
+	}
+	out, err = runCmd(t, "", "release", "list", "-app", "demo", "-env", "PROD", "-dc", "dc1", "-o", "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var list []Release
+	if err := json.Unmarshal([]byte(out), &list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].RolledBackBy != "2" || list[1].RollbackOf != "1" || list[1].Items[0].FromVersion != "v2" {
+		t.Fatal("unexpected releases:", out)
+	}
+}
+
+func TestSchedule(t *testing.T) {
+	f, server := setupFake(t)
+	if _, err := runCmd(t, "", "login", "-server", server, "-username", "admin", "-password", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	loc := []string{"-app", "demo", "-env", "PROD", "-dc", "dc1", "-ns", "ns", "-key", "k"}
+	if _, err := runCmd(t, "", append([]string{"config", "set", "-value", "v1"}, loc...)...); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runCmd(t, "", append([]string{"config", "set", "-draft", "-value", "v2"}, loc...)...); err != nil {
+		t.Fatal(err)
+	}
+	item := []string{"-app", "demo", "-env", "PROD", "-dc", "dc1", "-item", "ns/k"}
+	if _, err := runCmd(t, "", append([]string{"schedule", "create"}, item...)...); err == nil {
+		t.Fatal("-at should be required")
+	}
+	if _, err := runCmd(t, "", append([]string{"schedule", "create", "-at", "tomorrow"}, item...)...); err == nil {
+		t.Fatal("invalid -at should fail")
+	}
+	out, err := runCmd(t, "", append([]string{"schedule", "create", "-at", "2030-01-02T03:04:05Z", "-revert", "30m"}, item...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	at, _ := time.Parse(time.RFC3339, "2030-01-02T03:04:05Z")
+	if !strings.Contains(out, "Schedule 1 (pending) PROD/dc1 publish at ") || !strings.Contains(out, "Revert after 30m0s\n") {
+		t.Fatal("unexpected schedule:", out)
+	}
+	if s := f.schedules[0]; s.TimePublish != at.UnixMilli() || s.RevertAfter != (30*time.Minute).Milliseconds() || s.Items[0].Content != "v2" || len(f.drafts) != 0 {
+		t.Fatal("unexpected schedule request:", s)
+	}
+
+	out, err = runCmd(t, "", "schedule", "list", "-app", "demo", "-env", "PROD", "-dc", "dc1", "-o", "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var list []Schedule
+	if err := json.Unmarshal([]byte(out), &list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].Status != "pending" {
+		t.Fatal("unexpected schedules:", out)
+	}
+	if _, err := runCmd(t, "", "schedule", "cancel"); err == nil {
+		t.Fatal("-id should be required")
+	}
+	if out, err = runCmd(t, "", "schedule", "cancel", "-id", "1"); err != nil || !strings.HasPrefix(out, "Schedule 1 (canceled)") {
+		t.Fatal("schedule should be canceled:", out, err)
+	}
+	if _, err := runCmd(t, "", "schedule", "cancel", "-id", "1"); err == nil || !strings.Contains(err.Error(), "already done") {
+		t.Fatal("cancel twice should fail:", err)
+	}
+	if _, err := runCmd(t, "", "schedule", "show", "-id", "2"); err == nil {
+		t.Fatal("missing schedule should fail")
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	f, server := setupFake(t)
+	if _, err := runCmd(t, "", "login", "-server", server, "-username", "admin", "-password", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runCmd(t, "", "webhook", "create", "-url", "http://hook"); err == nil {
+		t.Fatal("-org or -app should be required")
+	}
+	if _, err := runCmd(t, "", "webhook", "create", "-org", "org", "-app", "demo", "-url", "http://hook"); err == nil {
+		t.Fatal("-org and -app should be exclusive")
+	}
+	if _, err := runCmd(t, "", "webhook", "create", "-app", "demo", "-url", "ftp://hook"); err == nil || !strings.Contains(err.Error(), "http or https") {
+		t.Fatal("invalid url should fail:", err)
+	}
+	out, err := runCmd(t, "", "webhook", "create", "-app", "demo", "-url", "http://hook/app", "-desc", "ci")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "Webhook 1 created.\nSecret: generated\n" {
+		t.Fatal("unexpected output:", out)
+	}
+	if _, err := runCmd(t, "", "webhook", "create", "-org", "org", "-url", "http://hook/org", "-secret", "s1"); err != nil {
+		t.Fatal(err)
+	}
+	if w := f.webhooks[1]; w.OrgId != "o1" || w.AppId != 0 || w.Secret != "s1" {
+		t.Fatal("unexpected webhook request:", w)
+	}
+	if _, err := runCmd(t, "", "webhook", "create", "-org", "nope", "-url", "http://hook"); err == nil {
+		t.Fatal("unknown organization should fail")
+	}
+
+	out, err = runCmd(t, "", "webhook", "list", "-app", "demo", "-o", "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var list []Webhook
+	if err := json.Unmarshal([]byte(out), &list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].Url != "http://hook/app" || list[0].Description != "ci" {
+		t.Fatal("unexpected webhooks:", out)
+	}
+	if out, err = runCmd(t, "", "webhook", "list", "-org", "org"); err != nil || !strings.Contains(out, "org:o1") || !strings.Contains(out, "http://hook/org") {
+		t.Fatal("unexpected webhooks:", out, err)
+	}
+
+	if out, err = runCmd(t, "", "webhook", "deliveries", "-id", "1"); err != nil || !strings.Contains(out, "failed") || !strings.Contains(out, "unexpected response status 500") {
+		t.Fatal("unexpected deliveries:", out, err)
+	}
+	if _, err := runCmd(t, "", "webhook", "redeliver"); err == nil {
+		t.Fatal("-delivery should be required")
+	}
+	if out, err = runCmd(t, "", "webhook", "redeliver", "-delivery", "7"); err != nil || out != "Delivery 7 queued.\n" {
+		t.Fatal("unexpected redeliver:", out, err)
+	}
+	if _, err := runCmd(t, "", "webhook", "delete", "-id", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runCmd(t, "", "webhook", "deliveries", "-id", "1"); err == nil {
+		t.Fatal("deleted webhook should not be found")
+	}
+}
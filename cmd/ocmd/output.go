@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+const (
+	outputTable = "table"
+	outputJson  = "json"
+)
+
+// printer prints results as table for reading or as json for scripting
+type printer struct {
+	out    io.Writer
+	format string
+}
+
+func newPrinter(out io.Writer, format string) (*printer, error) {
+	switch format {
+	case outputTable, outputJson:
+		return &printer{out: out, format: format}, nil
+	default:
+		return nil, errors.New("unknown output format: " + format + ", should be table or json")
+	}
+}
+
+// Print prints v in json format, or the rows in table format
+func (pr *printer) Print(v any, header []string, rows [][]string) error {
+	if pr.format == outputJson {
+		return pr.JSON(v)
+	}
+	w := tabwriter.NewWriter(pr.out, 0, 4, 2, ' ', 0)
+	if _, err := fmt.Fprintln(w, strings.Join(header, "\t")); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if _, err := fmt.Fprintln(w, strings.Join(row, "\t")); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
+
+func (pr *printer) JSON(v any) error {
+	e := json.NewEncoder(pr.out)
+	e.SetIndent("", "  ")
+	return e.Encode(v)
+}
+
+// JSONLine prints v as a single line json, used for streaming results
+func (pr *printer) JSONLine(v any) error {
+	return json.NewEncoder(pr.out).Encode(v)
+}
+
+// Message prints the message in table format, or {"result":"ok"} in json format
+func (pr *printer) Message(msg string) error {
+	if pr.format == outputJson {
+		return pr.JSON(map[string]string{"result": "ok"})
+	}
+	_, err := fmt.Fprintln(pr.out, msg)
+	return err
+}
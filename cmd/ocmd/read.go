@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/meidoworks/nekoq-component/configure/configapi"
+
+	"github.com/goodplayer/onlyconfig/client"
+)
+
+// ReadResult is the configuration received from the read server
+type ReadResult struct {
+	Group          string `json:"group"`
+	Key            string `json:"key"`
+	Version        string `json:"version"`
+	Signature      string `json:"signature"`
+	SignatureValid bool   `json:"signature_valid"`
+	Timestamp      int64  `json:"timestamp"`
+	Value          string `json:"value"`
+}
+
+type stringList []string
+
+func (s *stringList) String() string {
+	return fmt.Sprintf("%v", *s)
+}
+
+func (s *stringList) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// readRequest is the flags of read and watch
+type readRequest struct {
+	servers stringList
+	sel     *string
+	optSel  *string
+	group   *string
+	key     *string
+}
+
+func readFlags(fs *flag.FlagSet) *readRequest {
+	r := new(readRequest)
+	fs.Var(&r.servers, "server", "read server list: -server http://srv1 -server http://srv2")
+	r.sel = fs.String("sel", "", "selectors string, e.g. app=app1,env=PROD,dc=dc1")
+	r.optSel = fs.String("optsel", "", "optional selectors string, e.g. beta=1")
+	r.group = fs.String("group", "", "group")
+	r.key = fs.String("key", "", "key")
+	return r
+}
+
+// start starts the client requiring the configuration, every received configuration is sent to the channel.
+// The returned function stops the client.
+func (r *readRequest) start() (func(), <-chan configapi.Configuration, error) {
+	if len(r.servers) == 0 {
+		return nil, nil, errors.New("-server required")
+	}
+	if *r.sel == "" || *r.group == "" || *r.key == "" {
+		return nil, nil, errors.New("-sel, -group and -key required")
+	}
+	var sel = new(configapi.Selectors)
+	var optsel = new(configapi.Selectors)
+	if err := sel.Fill(*r.sel); err != nil {
+		return nil, nil, err
+	}
+	if err := optsel.Fill(*r.optSel); err != nil {
+		return nil, nil, err
+	}
+	// the same as what an application does
+	c := client.NewClient(r.servers, client.ClientOptions{
+		OverrideSelectors:         sel,
+		OverrideOptionalSelectors: optsel,
+	})
+	ch := make(chan configapi.Configuration, 16)
+	done := make(chan struct{})
+	c.AddConfigurationRequirement(client.RequiredConfig{
+		Required: configapi.RequestedConfigurationKey{
+			Group: *r.group,
+			Key:   *r.key,
+		},
+		Callback: func(cfg configapi.Configuration) {
+			select {
+			case ch <- cfg:
+			case <-done:
+			}
+		},
+	})
+	if err := c.StartClient(); err != nil {
+		return nil, nil, err
+	}
+	return func() {
+		close(done)
+		_ = c.StopClient()
+	}, ch, nil
+}
+
+func toReadResult(cfg configapi.Configuration) *ReadResult {
+	return &ReadResult{
+		Group:          cfg.Group,
+		Key:            cfg.Key,
+		Version:        cfg.Version,
+		Signature:      cfg.Signature,
+		SignatureValid: cfg.ValidateSignature(),
+		Timestamp:      cfg.Timestamp,
+		Value:          string(cfg.Value),
+	}
+}
+
+// printReadResult prints the fields and the value in table format, or a single line json in json format
+func printReadResult(pr *printer, r *ReadResult) error {
+	if pr.format == outputJson {
+		return pr.JSONLine(r)
+	}
+	ts := time.Unix(r.Timestamp, 0).Format(time.RFC3339)
+	if err := pr.Print(r, []string{"FIELD", "VALUE"}, [][]string{
+		{"group", r.Group},
+		{"key", r.Key},
+		{"version", r.Version},
+		{"signature", fmt.Sprint(r.Signature, " (valid: ", r.SignatureValid, ")")},
+		{"timestamp", fmt.Sprint(r.Timestamp, " (", ts, ")")},
+	}); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(pr.out, "value:\n%s\n", r.Value)
+	return err
+}
+
+func readCmd(c *cmdContext, args []string) error {
+	fs, format := c.flags()
+	req := readFlags(fs)
+	timeout := fs.Duration("timeout", 30*time.Second, "time limit of fetching configuration")
+	if err := c.parse(fs, format, args); err != nil {
+		return err
+	}
+	stopClient, ch, err := req.start()
+	if err != nil {
+		return err
+	}
+	defer stopClient()
+	select {
+	case cfg := <-ch:
+		return printReadResult(c.printer, toReadResult(cfg))
+	case <-time.After(*timeout):
+		return fmt.Errorf("configuration not fetched within %s", *timeout)
+	}
+}
+
+func watchCmd(c *cmdContext, args []string) error {
+	fs, format := c.flags()
+	req := readFlags(fs)
+	count := fs.Int("count", 0, "exit after receiving the number of changes, 0 means watching until interrupted")
+	if err := c.parse(fs, format, args); err != nil {
+		return err
+	}
+	stopClient, ch, err := req.start()
+	if err != nil {
+		return err
+	}
+	defer stopClient()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	for received := 0; *count <= 0 || received < *count; received++ {
+		select {
+		case cfg := <-ch:
+			if c.printer.format == outputTable && received > 0 {
+				if _, err := fmt.Fprintln(c.out, "---"); err != nil {
+					return err
+				}
+			}
+			if err := printReadResult(c.printer, toReadResult(cfg)); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/goodplayer/onlyconfig/onlyconfigtest"
+)
+
+func TestRead(t *testing.T) {
+	srv := onlyconfigtest.NewServer(t)
+	if err := srv.Set("app=app1,dc=dc1,env=DEV", "group1", "key1", []byte("value1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.SetWithOptionalSelectors("app=app1,dc=dc1,env=DEV", "beta=1", "group1", "key1", []byte("beta value")); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := runCmd(t, "", "read", "-server", srv.URL(), "-sel", "app=app1,dc=dc1,env=DEV", "-group", "group1", "-key", "key1", "-o", "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := new(ReadResult)
+	if err := json.Unmarshal([]byte(out), r); err != nil {
+		t.Fatal(err)
+	}
+	if r.Value != "value1" || r.Version == "" || !r.SignatureValid || r.Timestamp == 0 {
+		t.Fatal("unexpected result:", out)
+	}
+
+	out, err = runCmd(t, "", "read", "-server", srv.URL(), "-sel", "app=app1,dc=dc1,env=DEV", "-optsel", "beta=1", "-group", "group1", "-key", "key1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "version") || !strings.HasSuffix(out, "value:\nbeta value\n") {
+		t.Fatal("unexpected output:", out)
+	}
+
+	if _, err := runCmd(t, "", "read", "-server", srv.URL(), "-sel", "app=app1,dc=dc1,env=DEV", "-group", "group1", "-key", "missing", "-timeout", "1s"); err == nil {
+		t.Fatal("missing configuration should fail")
+	}
+}
+
+func TestWatch(t *testing.T) {
+	srv := onlyconfigtest.NewServer(t)
+	if err := srv.Set("app=app1,dc=dc1,env=DEV", "group1", "key1", []byte("value1")); err != nil {
+		t.Fatal(err)
+	}
+
+	pr, pw := io.Pipe()
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- run([]string{"watch", "-server", srv.URL(), "-sel", "app=app1,dc=dc1,env=DEV", "-group", "group1", "-key", "key1", "-count", "2", "-o", "json"},
+			strings.NewReader(""), pw, io.Discard)
+		_ = pw.Close()
+	}()
+	lines := bufio.NewScanner(pr)
+	var values []string
+	for lines.Scan() {
+		r := new(ReadResult)
+		if err := json.Unmarshal(lines.Bytes(), r); err != nil {
+			t.Fatal(err)
+		}
+		values = append(values, r.Value)
+		if len(values) == 1 {
+			if err := srv.Set("app=app1,dc=dc1,env=DEV", "group1", "key1", []byte("value2")); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("watch not finished")
+	}
+	if len(values) != 2 || values[0] != "value1" || values[1] != "value2" {
+		t.Fatal("unexpected values:", values)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/goodplayer/onlyconfig/secrets"
+)
+
+// UpdateRecipientKey sets the key the secret configurations of the application are sealed for, empty to stop sealing
+func (a *ApiClient) UpdateRecipientKey(appId int64, recipientKey string, timeUpdated int64) (*Application, error) {
+	app := new(Application)
+	if err := a.do(http.MethodPut, "/configures/recipient_key"+p(strconv.FormatInt(appId, 10)), map[string]any{
+		"recipient_key": recipientKey,
+		"time_updated":  timeUpdated,
+	}, app); err != nil {
+		return nil, conflictError(err)
+	}
+	return app, nil
+}
+
+// appKeygenCmd writes a new private key to the file and prints the public key, no login required
+func appKeygenCmd(c *cmdContext, args []string) error {
+	fs, format := c.flags()
+	file := fs.String("file", "", "file to write the private key")
+	if err := c.parse(fs, format, args); err != nil {
+		return err
+	}
+	if *file == "" {
+		return errors.New("-file required")
+	}
+	privateKey, publicKey, err := secrets.GenerateRecipientKey()
+	if err != nil {
+		return err
+	}
+	// never overwrite an existing private key, the configurations sealed for it would be lost
+	f, err := os.OpenFile(*file, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(privateKey + "\n"); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return c.printer.Print(map[string]string{"public_key": publicKey}, []string{"PUBLIC KEY"}, [][]string{{publicKey}})
+}
+
+func appRecipientCmd(c *cmdContext, args []string) error {
+	fs, format := c.flags()
+	appName := fs.String("app", "", "application name")
+	publicKey := fs.String("key", "", "public key printed by app keygen")
+	clearKey := fs.Bool("clear", false, "stop sealing the secret configurations")
+	if err := c.parse(fs, format, args); err != nil {
+		return err
+	}
+	if *appName == "" || (*publicKey == "") == !*clearKey {
+		return errors.New("-app and either -key or -clear required")
+	}
+	api, err := c.api()
+	if err != nil {
+		return err
+	}
+	app, err := api.Application(*appName)
+	if err != nil {
+		return err
+	}
+	if _, err := api.UpdateRecipientKey(app.AppId, *publicKey, app.TimeUpdated); err != nil {
+		return err
+	}
+	if *clearKey {
+		return c.printer.Message(fmt.Sprintf("Recipient key of %s cleared.", app.AppName))
+	}
+	return c.printer.Message(fmt.Sprintf("Recipient key of %s updated.", app.AppName))
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+type ReleaseItem struct {
+	ConfigId    string `json:"cfg_id"`
+	ConfigNs    string `json:"cfg_ns"`
+	ConfigKey   string `json:"cfg_key"`
+	FromVersion string `json:"from_version"`
+	ToVersion   string `json:"to_version"`
+}
+
+type Release struct {
+	ReleaseId      string        `json:"release_id"`
+	AppId          int64         `json:"app_id"`
+	Env            string        `json:"env"`
+	Dc             string        `json:"dc"`
+	ReleaseType    string        `json:"release_type"`
+	ReleaseVersion string        `json:"release_version"`
+	RollbackOf     string        `json:"rollback_of"`
+	RolledBackBy   string        `json:"rolled_back_by"`
+	TimeCreated    int64         `json:"time_created"`
+	Items          []ReleaseItem `json:"items"`
+}
+
+func (a *ApiClient) Releases(appId int64, env, dc string) ([]Release, error) {
+	resp := struct {
+		List []Release `json:"list"`
+	}{}
+	err := a.do(http.MethodGet, "/configures/releases"+p(strconv.FormatInt(appId, 10), env, dc), nil, &resp)
+	return resp.List, err
+}
+
+func (a *ApiClient) Release(releaseId string) (*Release, error) {
+	release := new(Release)
+	if err := a.do(http.MethodGet, "/configures/release"+p(releaseId), nil, release); err != nil {
+		return nil, err
+	}
+	return release, nil
+}
+
+// Rollback publishes the versions before the release, it fails if the configurations have been changed since
+func (a *ApiClient) Rollback(releaseId string) (*Release, error) {
+	release := new(Release)
+	if err := a.do(http.MethodPost, "/configures/release"+p(releaseId, "rollback"), nil, release); err != nil {
+		return nil, err
+	}
+	return release, nil
+}
+
+// releaseIdFlag parses -id of the release commands
+func releaseIdFlag(c *cmdContext, args []string) (string, error) {
+	fs, format := c.flags()
+	releaseId := fs.String("id", "", "release id")
+	if err := c.parse(fs, format, args); err != nil {
+		return "", err
+	}
+	if *releaseId == "" {
+		return "", errors.New("-id required")
+	}
+	return *releaseId, nil
+}
+
+func releaseListCmd(c *cmdContext, args []string) error {
+	fs, format := c.flags()
+	appName := fs.String("app", "", "application name")
+	env := fs.String("env", "", "environment")
+	dc := fs.String("dc", "", "datacenter")
+	if err := c.parse(fs, format, args); err != nil {
+		return err
+	}
+	if *appName == "" || *env == "" || *dc == "" {
+		return errors.New("-app, -env and -dc required")
+	}
+	api, err := c.api()
+	if err != nil {
+		return err
+	}
+	app, err := api.Application(*appName)
+	if err != nil {
+		return err
+	}
+	list, err := api.Releases(app.AppId, *env, *dc)
+	if err != nil {
+		return err
+	}
+	if list == nil {
+		list = []Release{}
+	}
+	var rows [][]string
+	for _, r := range list {
+		rows = append(rows, []string{r.ReleaseId, r.ReleaseType, r.ReleaseVersion, strconv.Itoa(len(r.Items)), releaseRef(r.RollbackOf), releaseRef(r.RolledBackBy), formatMillis(r.TimeCreated)})
+	}
+	return c.printer.Print(list, []string{"ID", "TYPE", "VERSION", "ITEMS", "ROLLBACK OF", "ROLLED BACK BY", "CREATED"}, rows)
+}
+
+func releaseShowCmd(c *cmdContext, args []string) error {
+	releaseId, err := releaseIdFlag(c, args)
+	if err != nil {
+		return err
+	}
+	api, err := c.api()
+	if err != nil {
+		return err
+	}
+	release, err := api.Release(releaseId)
+	if err != nil {
+		return err
+	}
+	return printRelease(c, release)
+}
+
+func releaseRollbackCmd(c *cmdContext, args []string) error {
+	releaseId, err := releaseIdFlag(c, args)
+	if err != nil {
+		return err
+	}
+	api, err := c.api()
+	if err != nil {
+		return err
+	}
+	release, err := api.Rollback(releaseId)
+	if err != nil {
+		return err
+	}
+	return printRelease(c, release)
+}
+
+// releaseRef shows the referenced release id, 0 means none
+func releaseRef(id string) string {
+	if id == "" || id == "0" {
+		return "-"
+	}
+	return id
+}
+
+func printRelease(c *cmdContext, release *Release) error {
+	if c.printer.format == outputJson {
+		return c.printer.JSON(release)
+	}
+	_, _ = fmt.Fprintf(c.out, "Release %s (%s) %s/%s version %s\n", release.ReleaseId, release.ReleaseType, release.Env, release.Dc, release.ReleaseVersion)
+	if ref := releaseRef(release.RollbackOf); ref != "-" {
+		_, _ = fmt.Fprintf(c.out, "Rollback of release %s\n", ref)
+	}
+	if ref := releaseRef(release.RolledBackBy); ref != "-" {
+		_, _ = fmt.Fprintf(c.out, "Rolled back by release %s\n", ref)
+	}
+	var rows [][]string
+	for _, item := range release.Items {
+		rows = append(rows, []string{item.ConfigId, item.ConfigNs, item.ConfigKey, item.FromVersion, item.ToVersion})
+	}
+	return c.printer.Print(release, []string{"ID", "NAMESPACE", "KEY", "FROM", "TO"}, rows)
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+type ScheduleItem struct {
+	ConfigId    string `json:"cfg_id"`
+	ConfigNs    string `json:"cfg_ns"`
+	ConfigKey   string `json:"cfg_key"`
+	FromVersion string `json:"from_version"`
+	ContentType string `json:"cfg_ct"`
+	Content     string `json:"cfg_content"`
+}
+
+type Schedule struct {
+	ScheduleId      string         `json:"schedule_id"`
+	AppId           int64          `json:"app_id"`
+	Env             string         `json:"env"`
+	Dc              string         `json:"dc"`
+	Status          string         `json:"status"`
+	TimePublish     int64          `json:"time_publish"`
+	RevertAfter     int64          `json:"revert_after"`
+	TimeRevert      int64          `json:"time_revert"`
+	ReleaseId       string         `json:"release_id"`
+	RevertReleaseId string         `json:"revert_release_id"`
+	Message         string         `json:"message,omitempty"`
+	TimeCreated     int64          `json:"time_created"`
+	Items           []ScheduleItem `json:"items"`
+}
+
+// SchedulePublish schedules publishing the drafts of the configurations together at the time,
+// and reverting them after revertAfter if positive
+func (a *ApiClient) SchedulePublish(appId int64, cfgIds []string, at time.Time, revertAfter time.Duration, format string) (*Schedule, error) {
+	query := url.Values{}
+	if format != "" {
+		query.Set("format", format)
+	}
+	schedule := new(Schedule)
+	if err := a.do(http.MethodPost, "/configures/publish"+p(strconv.FormatInt(appId, 10), "schedule")+"?"+query.Encode(), map[string]any{
+		"cfg_ids":      cfgIds,
+		"time_publish": at.UnixMilli(),
+		"revert_after": revertAfter.Milliseconds(),
+	}, schedule); err != nil {
+		return nil, err
+	}
+	return schedule, nil
+}
+
+func (a *ApiClient) Schedules(appId int64, env, dc string) ([]Schedule, error) {
+	resp := struct {
+		List []Schedule `json:"list"`
+	}{}
+	err := a.do(http.MethodGet, "/configures/schedules"+p(strconv.FormatInt(appId, 10), env, dc), nil, &resp)
+	return resp.List, err
+}
+
+func (a *ApiClient) Schedule(scheduleId string) (*Schedule, error) {
+	schedule := new(Schedule)
+	if err := a.do(http.MethodGet, "/configures/schedule"+p(scheduleId), nil, schedule); err != nil {
+		return nil, err
+	}
+	return schedule, nil
+}
+
+// CancelSchedule cancels publishing of a pending schedule, or the revert of a published one
+func (a *ApiClient) CancelSchedule(scheduleId string) (*Schedule, error) {
+	schedule := new(Schedule)
+	if err := a.do(http.MethodDelete, "/configures/schedule"+p(scheduleId), nil, schedule); err != nil {
+		return nil, err
+	}
+	return schedule, nil
+}
+
+// scheduleIdFlag parses -id of the schedule commands
+func scheduleIdFlag(c *cmdContext, args []string) (string, error) {
+	fs, format := c.flags()
+	scheduleId := fs.String("id", "", "schedule id")
+	if err := c.parse(fs, format, args); err != nil {
+		return "", err
+	}
+	if *scheduleId == "" {
+		return "", errors.New("-id required")
+	}
+	return *scheduleId, nil
+}
+
+func scheduleCreateCmd(c *cmdContext, args []string) error {
+	fs, format := c.flags()
+	appName, env, dc, items := publishItemFlags(fs)
+	at := fs.String("at", "", "time to publish in RFC3339, e.g. 2024-01-02T03:04:05+08:00")
+	revert := fs.Duration("revert", 0, "revert the release after the duration, e.g. 30m")
+	validateFormat := fs.String("format", "", "validate the drafts as json, yaml, toml or properties before scheduling")
+	if err := c.parse(fs, format, args); err != nil {
+		return err
+	}
+	if *at == "" {
+		return errors.New("-at required")
+	}
+	publishTime, err := time.Parse(time.RFC3339, *at)
+	if err != nil {
+		return errors.New("-at should be in RFC3339: " + err.Error())
+	}
+	if *revert < 0 {
+		return errors.New("-revert should not be negative")
+	}
+	api, err := c.api()
+	if err != nil {
+		return err
+	}
+	app, cfgIds, err := resolveItems(api, *appName, *env, *dc, *items)
+	if err != nil {
+		return err
+	}
+	schedule, err := api.SchedulePublish(app.AppId, cfgIds, publishTime, *revert, *validateFormat)
+	if err != nil {
+		return err
+	}
+	return printSchedule(c, schedule)
+}
+
+func scheduleListCmd(c *cmdContext, args []string) error {
+	fs, format := c.flags()
+	appName := fs.String("app", "", "application name")
+	env := fs.String("env", "", "environment")
+	dc := fs.String("dc", "", "datacenter")
+	if err := c.parse(fs, format, args); err != nil {
+		return err
+	}
+	if *appName == "" || *env == "" || *dc == "" {
+		return errors.New("-app, -env and -dc required")
+	}
+	api, err := c.api()
+	if err != nil {
+		return err
+	}
+	app, err := api.Application(*appName)
+	if err != nil {
+		return err
+	}
+	list, err := api.Schedules(app.AppId, *env, *dc)
+	if err != nil {
+		return err
+	}
+	if list == nil {
+		list = []Schedule{}
+	}
+	var rows [][]string
+	for _, s := range list {
+		rows = append(rows, []string{s.ScheduleId, s.Status, formatMillis(s.TimePublish), formatMillis(s.TimeRevert), strconv.Itoa(len(s.Items)), releaseRef(s.ReleaseId), s.Message})
+	}
+	return c.printer.Print(list, []string{"ID", "STATUS", "PUBLISH", "REVERT", "ITEMS", "RELEASE", "MESSAGE"}, rows)
+}
+
+func scheduleShowCmd(c *cmdContext, args []string) error {
+	scheduleId, err := scheduleIdFlag(c, args)
+	if err != nil {
+		return err
+	}
+	api, err := c.api()
+	if err != nil {
+		return err
+	}
+	schedule, err := api.Schedule(scheduleId)
+	if err != nil {
+		return err
+	}
+	return printSchedule(c, schedule)
+}
+
+func scheduleCancelCmd(c *cmdContext, args []string) error {
+	scheduleId, err := scheduleIdFlag(c, args)
+	if err != nil {
+		return err
+	}
+	api, err := c.api()
+	if err != nil {
+		return err
+	}
+	schedule, err := api.CancelSchedule(scheduleId)
+	if err != nil {
+		return err
+	}
+	return printSchedule(c, schedule)
+}
+
+func printSchedule(c *cmdContext, schedule *Schedule) error {
+	if c.printer.format == outputJson {
+		return c.printer.JSON(schedule)
+	}
+	_, _ = fmt.Fprintf(c.out, "Schedule %s (%s) %s/%s publish at %s\n", schedule.ScheduleId, schedule.Status, schedule.Env, schedule.Dc, formatMillis(schedule.TimePublish))
+	if schedule.TimeRevert > 0 {
+		_, _ = fmt.Fprintf(c.out, "Revert at %s\n", formatMillis(schedule.TimeRevert))
+	} else if schedule.RevertAfter > 0 && schedule.Status == "pending" {
+		_, _ = fmt.Fprintf(c.out, "Revert after %s\n", time.Duration(schedule.RevertAfter)*time.Millisecond)
+	}
+	if ref := releaseRef(schedule.ReleaseId); ref != "-" {
+		_, _ = fmt.Fprintf(c.out, "Release %s\n", ref)
+	}
+	if ref := releaseRef(schedule.RevertReleaseId); ref != "-" {
+		_, _ = fmt.Fprintf(c.out, "Reverted by release %s\n", ref)
+	}
+	if schedule.Message != "" {
+		_, _ = fmt.Fprintf(c.out, "Failed: %s\n", schedule.Message)
+	}
+	var rows [][]string
+	for _, item := range schedule.Items {
+		rows = append(rows, []string{item.ConfigId, item.ConfigNs, item.ConfigKey, item.FromVersion})
+	}
+	return c.printer.Print(schedule, []string{"ID", "NAMESPACE", "KEY", "FROM"}, rows)
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+type Webhook struct {
+	WebhookId   string `json:"webhook_id"`
+	OrgId       string `json:"org_id"`
+	AppId       int64  `json:"app_id"`
+	Url         string `json:"url"`
+	Description string `json:"description"`
+	Secret      string `json:"secret,omitempty"`
+	TimeCreated int64  `json:"time_created"`
+}
+
+type WebhookDelivery struct {
+	DeliveryId      string `json:"delivery_id"`
+	WebhookId       string `json:"webhook_id"`
+	Event           string `json:"event"`
+	Payload         string `json:"payload"`
+	Status          string `json:"status"`
+	Attempts        int    `json:"attempts"`
+	TimeNextAttempt int64  `json:"time_next_attempt"`
+	ResponseStatus  int    `json:"response_status"`
+	Message         string `json:"message"`
+	TimeCreated     int64  `json:"time_created"`
+	TimeUpdated     int64  `json:"time_updated"`
+}
+
+// Webhooks lists the webhooks of the organization if orgId is not empty and of the application if appId is positive
+func (a *ApiClient) Webhooks(orgId string, appId int64) ([]Webhook, error) {
+	query := url.Values{}
+	if orgId != "" {
+		query.Set("org_id", orgId)
+	}
+	if appId > 0 {
+		query.Set("app_id", strconv.FormatInt(appId, 10))
+	}
+	resp := struct {
+		List []Webhook `json:"list"`
+	}{}
+	err := a.do(http.MethodGet, "/configures/webhooks?"+query.Encode(), nil, &resp)
+	return resp.List, err
+}
+
+// AddWebhook creates a webhook of either the organization or the application, the secret is generated if empty
+func (a *ApiClient) AddWebhook(orgId string, appId int64, webhookUrl, secret, description string) (*Webhook, error) {
+	webhook := new(Webhook)
+	if err := a.do(http.MethodPost, "/configures/webhook", map[string]any{
+		"org_id":      orgId,
+		"app_id":      appId,
+		"url":         webhookUrl,
+		"secret":      secret,
+		"description": description,
+	}, webhook); err != nil {
+		return nil, err
+	}
+	return webhook, nil
+}
+
+func (a *ApiClient) DeleteWebhook(webhookId string) error {
+	return a.do(http.MethodDelete, "/configures/webhook"+p(webhookId), nil, nil)
+}
+
+func (a *ApiClient) WebhookDeliveries(webhookId string) ([]WebhookDelivery, error) {
+	resp := struct {
+		List []WebhookDelivery `json:"list"`
+	}{}
+	err := a.do(http.MethodGet, "/configures/webhook"+p(webhookId, "deliveries"), nil, &resp)
+	return resp.List, err
+}
+
+// Redeliver queues the delivery to be delivered again with the same payload
+func (a *ApiClient) Redeliver(deliveryId string) (*WebhookDelivery, error) {
+	delivery := new(WebhookDelivery)
+	if err := a.do(http.MethodPost, "/configures/webhook_delivery"+p(deliveryId, "redeliver"), nil, delivery); err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+// webhookOwner resolves the organization name or the application name to the owner of webhooks
+func webhookOwner(api *ApiClient, orgName, appName string) (string, int64, error) {
+	if orgName != "" {
+		orgs, err := api.Organizations()
+		if err != nil {
+			return "", 0, err
+		}
+		for _, org := range orgs {
+			if org.OrgName == orgName {
+				return org.OrgId, 0, nil
+			}
+		}
+		return "", 0, errors.New("organization not found: " + orgName)
+	}
+	if appName != "" {
+		app, err := api.Application(appName)
+		if err != nil {
+			return "", 0, err
+		}
+		return "", app.AppId, nil
+	}
+	return "", 0, nil
+}
+
+// webhookIdFlag parses -id of the webhook commands
+func webhookIdFlag(c *cmdContext, args []string) (string, error) {
+	fs, format := c.flags()
+	webhookId := fs.String("id", "", "webhook id")
+	if err := c.parse(fs, format, args); err != nil {
+		return "", err
+	}
+	if *webhookId == "" {
+		return "", errors.New("-id required")
+	}
+	return *webhookId, nil
+}
+
+func webhookListCmd(c *cmdContext, args []string) error {
+	fs, format := c.flags()
+	orgName := fs.String("org", "", "organization name")
+	appName := fs.String("app", "", "application name")
+	if err := c.parse(fs, format, args); err != nil {
+		return err
+	}
+	api, err := c.api()
+	if err != nil {
+		return err
+	}
+	orgId, appId, err := webhookOwner(api, *orgName, *appName)
+	if err != nil {
+		return err
+	}
+	list, err := api.Webhooks(orgId, appId)
+	if err != nil {
+		return err
+	}
+	if list == nil {
+		list = []Webhook{}
+	}
+	var rows [][]string
+	for _, w := range list {
+		owner := "org:" + w.OrgId
+		if w.AppId > 0 {
+			owner = "app:" + strconv.FormatInt(w.AppId, 10)
+		}
+		rows = append(rows, []string{w.WebhookId, owner, w.Url, w.Description, formatMillis(w.TimeCreated)})
+	}
+	return c.printer.Print(list, []string{"ID", "OWNER", "URL", "DESCRIPTION", "CREATED"}, rows)
+}
+
+func webhookCreateCmd(c *cmdContext, args []string) error {
+	fs, format := c.flags()
+	orgName := fs.String("org", "", "organization name, notified of the changes of all its applications")
+	appName := fs.String("app", "", "application name")
+	webhookUrl := fs.String("url", "", "http or https url receiving the notifications")
+	secret := fs.String("secret", "", "secret signing the payloads, generated if not provided")
+	desc := fs.String("desc", "", "description")
+	if err := c.parse(fs, format, args); err != nil {
+		return err
+	}
+	if (*orgName == "") == (*appName == "") {
+		return errors.New("either -org or -app required")
+	}
+	if *webhookUrl == "" {
+		return errors.New("-url required")
+	}
+	api, err := c.api()
+	if err != nil {
+		return err
+	}
+	orgId, appId, err := webhookOwner(api, *orgName, *appName)
+	if err != nil {
+		return err
+	}
+	webhook, err := api.AddWebhook(orgId, appId, *webhookUrl, *secret, *desc)
+	if err != nil {
+		return err
+	}
+	if c.printer.format == outputJson {
+		return c.printer.JSON(webhook)
+	}
+	_, _ = fmt.Fprintf(c.out, "Webhook %s created.\n", webhook.WebhookId)
+	_, _ = fmt.Fprintf(c.out, "Secret: %s\n", webhook.Secret)
+	return nil
+}
+
+func webhookDeleteCmd(c *cmdContext, args []string) error {
+	webhookId, err := webhookIdFlag(c, args)
+	if err != nil {
+		return err
+	}
+	api, err := c.api()
+	if err != nil {
+		return err
+	}
+	if err := api.DeleteWebhook(webhookId); err != nil {
+		return err
+	}
+	return c.printer.Message("Webhook deleted.")
+}
+
+func webhookDeliveriesCmd(c *cmdContext, args []string) error {
+	webhookId, err := webhookIdFlag(c, args)
+	if err != nil {
+		return err
+	}
+	api, err := c.api()
+	if err != nil {
+		return err
+	}
+	list, err := api.WebhookDeliveries(webhookId)
+	if err != nil {
+		return err
+	}
+	if list == nil {
+		list = []WebhookDelivery{}
+	}
+	var rows [][]string
+	for _, d := range list {
+		next := "-"
+		if d.Status == "pending" {
+			next = formatMillis(d.TimeNextAttempt)
+		}
+		rows = append(rows, []string{d.DeliveryId, d.Event, d.Status, strconv.Itoa(d.Attempts), strconv.Itoa(d.ResponseStatus), next, d.Message})
+	}
+	return c.printer.Print(list, []string{"ID", "EVENT", "STATUS", "ATTEMPTS", "RESPONSE", "NEXT", "MESSAGE"}, rows)
+}
+
+func webhookRedeliverCmd(c *cmdContext, args []string) error {
+	fs, format := c.flags()
+	deliveryId := fs.String("delivery", "", "delivery id")
+	if err := c.parse(fs, format, args); err != nil {
+		return err
+	}
+	if *deliveryId == "" {
+		return errors.New("-delivery required")
+	}
+	api, err := c.api()
+	if err != nil {
+		return err
+	}
+	delivery, err := api.Redeliver(*deliveryId)
+	if err != nil {
+		return err
+	}
+	if c.printer.format == outputJson {
+		return c.printer.JSON(delivery)
+	}
+	return c.printer.Message("Delivery " + delivery.DeliveryId + " queued.")
+}
@@ -0,0 +1,377 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+
+	"github.com/meidoworks/nekoq-component/configure/configapi"
+
+	"github.com/goodplayer/onlyconfig/client"
+)
+
+// agent runs the entries of config_list and applies new config on reload.
+// Entries are grouped by selectors, each group is served by one client.
+// Since requirements cannot be removed from a running client, a group with any changed entry is served by a new client
+// while unchanged groups keep running.
+type agent struct {
+	// sink receives the outputs instead of writing them if provided
+	sink  func(e *entry, val []byte, version string)
+	store *stateStore
+
+	lock   sync.Mutex
+	groups map[string]*entryGroup
+}
+
+// entryGroup is the entries with the same selectors and optional selectors
+type entryGroup struct {
+	entries []*entry
+	client  *client.Client
+}
+
+// entry is a running item of config_list
+type entry struct {
+	id       string
+	item     *ConfigItem
+	renderer *templateRenderer
+	hooks    []*hookRunner
+	writer   *outputWriter
+	stopped  atomic.Bool
+
+	lock    sync.Mutex
+	written bool
+	lastVal []byte
+}
+
+// newAgent creates the agent writing the outputs. The states of the entries are persisted if store is provided.
+func newAgent(store *stateStore) *agent {
+	return &agent{
+		store:  store,
+		groups: map[string]*entryGroup{},
+	}
+}
+
+// newCollectAgent creates the agent sending the outputs to sink without writing
+func newCollectAgent(sink func(e *entry, val []byte, version string)) *agent {
+	return &agent{
+		sink:   sink,
+		groups: map[string]*entryGroup{},
+	}
+}
+
+func (a *agent) newEntry(id string, item *ConfigItem) (*entry, error) {
+	e, err := newEntry(id, item)
+	if err != nil {
+		return nil, err
+	}
+	if a.sink == nil {
+		e.writer = newOutputWriter(e, a.store)
+	}
+	return e, nil
+}
+
+func newEntry(id string, item *ConfigItem) (*entry, error) {
+	e := &entry{
+		id:   id,
+		item: item,
+	}
+	if item.Template != "" {
+		renderer, err := newTemplateRenderer(item)
+		if err != nil {
+			return nil, err
+		}
+		e.renderer = renderer
+	}
+	actions, err := hookActionsOf(item)
+	if err != nil {
+		return nil, err
+	}
+	for _, action := range actions {
+		h, err := newHookRunner(item, action)
+		if err != nil {
+			e.stop()
+			return nil, err
+		}
+		e.hooks = append(e.hooks, h)
+	}
+	return e, nil
+}
+
+func (e *entry) stop() {
+	e.stopped.Store(true)
+	if e.writer != nil {
+		e.writer.Stop()
+	}
+	for _, h := range e.hooks {
+		h.Stop()
+	}
+}
+
+// update sends the output to the writer or sink unless it is the same as the previous one
+func (e *entry) update(val []byte, version string, sink func(e *entry, val []byte, version string)) {
+	if e.stopped.Load() {
+		return
+	}
+	e.lock.Lock()
+	if e.written && bytes.Equal(e.lastVal, val) {
+		e.lock.Unlock()
+		return
+	}
+	e.written = true
+	e.lastVal = val
+	e.lock.Unlock()
+	if e.writer != nil {
+		e.writer.Submit(val, version)
+	} else {
+		sink(e, val, version)
+	}
+}
+
+// requirements returns the configurations required by the entry
+func (e *entry) requirements(sink func(e *entry, val []byte, version string)) map[configapi.RequestedConfigurationKey]func(cfg configapi.Configuration) {
+	item := e.item
+	if e.renderer == nil {
+		return map[configapi.RequestedConfigurationKey]func(cfg configapi.Configuration){
+			{Group: item.Group, Key: item.Key}: func(cfg configapi.Configuration) {
+				e.update(cfg.Value, cfg.Version, sink)
+			},
+		}
+	}
+	// the same configuration may be referenced by several names, which are updated together
+	names := map[configapi.RequestedConfigurationKey][]string{}
+	for _, data := range item.TemplateData {
+		required := configapi.RequestedConfigurationKey{Group: data.Group, Key: data.Key}
+		names[required] = append(names[required], data.Name)
+	}
+	m := map[configapi.RequestedConfigurationKey]func(cfg configapi.Configuration){}
+	for required, dataNames := range names {
+		m[required] = func(cfg configapi.Configuration) {
+			if val, ready, err := e.renderer.Update(dataNames, cfg.Value); err != nil {
+				log.Println("Render template failed! template:", item.Template, "error:", err)
+			} else if ready {
+				e.update(val, cfg.Version, sink)
+			}
+		}
+	}
+	return m
+}
+
+// entryIds returns the identities of the entries grouped by selectors and the items of the identities.
+// An entry with any changed item is treated as a new one.
+func entryIds(cfg *Config) (map[string][]string, map[string]*ConfigItem, error) {
+	groups := map[string][]string{}
+	items := map[string]*ConfigItem{}
+	seen := map[string]int{}
+	for i := range cfg.ConfigList {
+		item := &cfg.ConfigList[i]
+		data, err := json.Marshal(item)
+		if err != nil {
+			return nil, nil, err
+		}
+		// the same items are distinguished by occurrence
+		seen[string(data)]++
+		id := fmt.Sprint(string(data), "#", seen[string(data)])
+		selKey := fmt.Sprint(item.SelectorsString, "::", item.OptionalSelectorsString)
+		groups[selKey] = append(groups[selKey], id)
+		items[id] = item
+	}
+	return groups, items, nil
+}
+
+// apply diffs the config against the running one and starts the new set.
+// The running set is untouched if any error occurs.
+func (a *agent) apply(cfg *Config) error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	ids, items, err := entryIds(cfg)
+	if err != nil {
+		return err
+	}
+
+	running := map[string]*entry{}
+	for _, g := range a.groups {
+		for _, e := range g.entries {
+			running[e.id] = e
+		}
+	}
+
+	newGroups := map[string]*entryGroup{}
+	var createdEntries []*entry
+	var createdClients []*client.Client
+	rollback := func() {
+		for _, c := range createdClients {
+			_ = c.StopClient()
+		}
+		for _, e := range createdEntries {
+			e.stop()
+		}
+	}
+	for selKey, groupIds := range ids {
+		if g, ok := a.groups[selKey]; ok && sameEntries(g, groupIds) {
+			newGroups[selKey] = g
+			continue
+		}
+		g := new(entryGroup)
+		for _, id := range groupIds {
+			e, ok := running[id]
+			if !ok {
+				e, err = a.newEntry(id, items[id])
+				if err != nil {
+					rollback()
+					return err
+				}
+				createdEntries = append(createdEntries, e)
+			}
+			g.entries = append(g.entries, e)
+		}
+		c, err := a.newGroupClient(g.entries)
+		if err != nil {
+			rollback()
+			return err
+		}
+		g.client = c
+		createdClients = append(createdClients, c)
+		newGroups[selKey] = g
+	}
+	for _, c := range createdClients {
+		if err := c.StartClient(); err != nil {
+			rollback()
+			return err
+		}
+	}
+
+	// stop the replaced clients and the removed entries
+	kept := map[*entry]struct{}{}
+	for _, g := range newGroups {
+		for _, e := range g.entries {
+			kept[e] = struct{}{}
+		}
+	}
+	var errs []error
+	for selKey, g := range a.groups {
+		if newGroups[selKey] == g {
+			continue
+		}
+		if err := g.client.StopClient(); err != nil {
+			errs = append(errs, err)
+		}
+		for _, e := range g.entries {
+			if _, ok := kept[e]; !ok {
+				log.Println("entry removed, output:", e.item.Output)
+				e.stop()
+			}
+		}
+	}
+	a.groups = newGroups
+	if a.store != nil {
+		keptIds := map[string]struct{}{}
+		for e := range kept {
+			keptIds[e.id] = struct{}{}
+		}
+		a.store.prune(keptIds)
+	}
+	if len(createdClients) > 0 || len(errs) > 0 {
+		log.Println("config applied, restarted groups:", len(createdClients), "new entries:", len(createdEntries))
+	}
+	if len(errs) > 0 {
+		log.Println("stop replaced clients failed:", errors.Join(errs...))
+	}
+	return nil
+}
+
+func sameEntries(g *entryGroup, ids []string) bool {
+	if len(g.entries) != len(ids) {
+		return false
+	}
+	for i, e := range g.entries {
+		if e.id != ids[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (a *agent) newGroupClient(entries []*entry) (*client.Client, error) {
+	sample := entries[0].item
+	var sel = new(configapi.Selectors)
+	var optsel = new(configapi.Selectors)
+	if err := sel.Fill(sample.SelectorsString); err != nil {
+		return nil, err
+	}
+	if err := optsel.Fill(sample.OptionalSelectorsString); err != nil {
+		return nil, err
+	}
+	// the same configuration may be used by several entries, so listeners are grouped by configuration
+	listeners := map[configapi.RequestedConfigurationKey][]func(cfg configapi.Configuration){}
+	for _, e := range entries {
+		for required, fn := range e.requirements(a.sink) {
+			listeners[required] = append(listeners[required], fn)
+		}
+	}
+	c := client.NewClient(srvList, client.ClientOptions{
+		OverrideSelectors:         sel,
+		OverrideOptionalSelectors: optsel,
+	})
+	for required, fns := range listeners {
+		c.AddConfigurationRequirement(client.RequiredConfig{
+			Required: required,
+			// the sealed configurations are opened once for all the entries
+			Callback: client.Decrypt(privateKeys, func(cfg configapi.Configuration) {
+				for _, fn := range fns {
+					fn(cfg)
+				}
+			}),
+		})
+	}
+	return c, nil
+}
+
+// waitLoaded waits for all the clients loading configurations for the first time
+func (a *agent) waitLoaded(ctx context.Context) error {
+	a.lock.Lock()
+	var cs []*client.Client
+	for _, g := range a.groups {
+		cs = append(cs, g.client)
+	}
+	a.lock.Unlock()
+	var errs []error
+	for _, c := range cs {
+		if err := c.WaitStartupConfigureLoaded(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// entries returns all the running entries
+func (a *agent) entries() []*entry {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	var l []*entry
+	for _, g := range a.groups {
+		l = append(l, g.entries...)
+	}
+	return l
+}
+
+func (a *agent) stop() error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	var errs []error
+	for _, g := range a.groups {
+		if err := g.client.StopClient(); err != nil {
+			errs = append(errs, err)
+		}
+		for _, e := range g.entries {
+			e.stop()
+		}
+	}
+	a.groups = map[string]*entryGroup{}
+	return errors.Join(errs...)
+}
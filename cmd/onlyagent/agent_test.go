@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/goodplayer/onlyconfig/onlyconfigtest"
+)
+
+func TestAgentApply(t *testing.T) {
+	srv := onlyconfigtest.NewServer(t)
+	for _, v := range [][3]string{
+		{"app=app1,dc=dc1,env=DEV", "group1", "key1"},
+		{"app=app1,dc=dc2,env=DEV", "group2", "key2"},
+		{"app=app1,dc=dc2,env=DEV", "group2", "key3"},
+	} {
+		if err := srv.Set(v[0], v[1], v[2], []byte(v[2])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	srvList = stringList{srv.URL()}
+	defer func() {
+		srvList = nil
+	}()
+
+	updates := make(chan string, 10)
+	a := newCollectAgent(func(e *entry, val []byte, version string) {
+		updates <- e.item.Output + ":" + string(val)
+	})
+	defer func() {
+		_ = a.stop()
+	}()
+	expectUpdate := func(expected string) {
+		t.Helper()
+		select {
+		case u := <-updates:
+			if u != expected {
+				t.Fatal("unexpected update:", u, "expected:", expected)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("update not received:", expected)
+		}
+	}
+	groupOf := func(selKey string) *entryGroup {
+		a.lock.Lock()
+		defer a.lock.Unlock()
+		return a.groups[selKey]
+	}
+
+	dir := t.TempDir()
+	cfg := &Config{ConfigList: []ConfigItem{
+		{SelectorsString: "app=app1,dc=dc1,env=DEV", Group: "group1", Key: "key1", Output: filepath.Join(dir, "o1")},
+		{SelectorsString: "app=app1,dc=dc2,env=DEV", Group: "group2", Key: "key2", Output: filepath.Join(dir, "o2")},
+	}}
+	if err := a.apply(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.waitLoaded(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	received := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		select {
+		case u := <-updates:
+			received[u] = true
+		case <-time.After(5 * time.Second):
+			t.Fatal("initial updates not received")
+		}
+	}
+	if !received[filepath.Join(dir, "o1")+":key1"] || !received[filepath.Join(dir, "o2")+":key2"] {
+		t.Fatal("unexpected initial updates:", received)
+	}
+	g1 := groupOf("app=app1,dc=dc1,env=DEV::")
+	g2 := groupOf("app=app1,dc=dc2,env=DEV::")
+
+	// change the entry of the second group only
+	cfg = &Config{ConfigList: []ConfigItem{
+		{SelectorsString: "app=app1,dc=dc1,env=DEV", Group: "group1", Key: "key1", Output: filepath.Join(dir, "o1")},
+		{SelectorsString: "app=app1,dc=dc2,env=DEV", Group: "group2", Key: "key3", Output: filepath.Join(dir, "o2")},
+	}}
+	if err := a.apply(cfg); err != nil {
+		t.Fatal(err)
+	}
+	expectUpdate(filepath.Join(dir, "o2") + ":key3")
+	if groupOf("app=app1,dc=dc1,env=DEV::") != g1 {
+		t.Fatal("unchanged group should keep running")
+	}
+	newG2 := groupOf("app=app1,dc=dc2,env=DEV::")
+	if newG2 == g2 || newG2.client == g2.client {
+		t.Fatal("changed group should be served by a new client")
+	}
+	if !g2.entries[0].stopped.Load() {
+		t.Fatal("removed entry should be stopped")
+	}
+
+	// invalid config is rejected without touching the running set
+	cfg = &Config{ConfigList: []ConfigItem{
+		{SelectorsString: "app=app1,dc=dc3,env=DEV", Template: filepath.Join(dir, "missing.tmpl"), Output: filepath.Join(dir, "o3"),
+			TemplateData: []TemplateData{{Name: "a", Group: "group1", Key: "key1"}}},
+	}}
+	if err := a.apply(cfg); err == nil {
+		t.Fatal("invalid config should be rejected")
+	}
+	if groupOf("app=app1,dc=dc1,env=DEV::") != g1 || groupOf("app=app1,dc=dc2,env=DEV::") != newG2 {
+		t.Fatal("running set should be untouched")
+	}
+
+	// updates still delivered
+	if err := srv.Set("app=app1,dc=dc1,env=DEV", "group1", "key1", []byte("key1-new")); err != nil {
+		t.Fatal(err)
+	}
+	expectUpdate(filepath.Join(dir, "o1") + ":key1-new")
+}
+
+func TestAgentTemplateSameConfiguration(t *testing.T) {
+	srv := onlyconfigtest.NewServer(t)
+	if err := srv.Set("app=app1,dc=dc1,env=DEV", "group1", "key1", []byte("value1")); err != nil {
+		t.Fatal(err)
+	}
+	srvList = stringList{srv.URL()}
+	defer func() {
+		srvList = nil
+	}()
+
+	updates := make(chan string, 10)
+	a := newCollectAgent(func(e *entry, val []byte, version string) {
+		updates <- string(val)
+	})
+	defer func() {
+		_ = a.stop()
+	}()
+
+	dir := t.TempDir()
+	tmplFile := filepath.Join(dir, "a.tmpl")
+	if err := os.WriteFile(tmplFile, []byte("{{ .a }},{{ .b }}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// both names reference the same configuration
+	cfg := &Config{ConfigList: []ConfigItem{
+		{SelectorsString: "app=app1,dc=dc1,env=DEV", Template: tmplFile, Output: filepath.Join(dir, "o1"),
+			TemplateData: []TemplateData{{Name: "a", Group: "group1", Key: "key1"}, {Name: "b", Group: "group1", Key: "key1"}}},
+	}}
+	if err := a.apply(cfg); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case u := <-updates:
+		if u != "value1,value1" {
+			t.Fatal("unexpected update:", u)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("template not rendered")
+	}
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+
+	"github.com/meidoworks/nekoq-component/configure/configapi"
+
+	"github.com/goodplayer/onlyconfig/webmgr/tools"
+)
+
+type Config struct {
+	ConfigList []ConfigItem `toml:"config_list"`
+}
+
+type ConfigItem struct {
+	SelectorsString         string `toml:"selectors"`
+	OptionalSelectorsString string `toml:"optional_selectors"`
+	Group                   string `toml:"group"`
+	Key                     string `toml:"key"`
+	Hook                    string `toml:"hook"`
+	Output                  string `toml:"output"`
+
+	// Template is the path of the text/template file rendering the configurations in TemplateData into the output.
+	// Group and Key are not used when Template is provided.
+	Template     string         `toml:"template"`
+	TemplateData []TemplateData `toml:"template_data"`
+
+	// Format is the output format. "raw" or empty writes the configuration as is.
+	// "env" flattens the configuration into KEY=value lines for .env or systemd EnvironmentFile.
+	// "dir" writes one file per top-level key into the output directory.
+	Format string `toml:"format"`
+	// ContentType is the type of the configuration for env and dir format: json, yaml, toml or properties
+	ContentType string `toml:"content_type"`
+	// EnvPrefix is prepended to the keys in env format
+	EnvPrefix string `toml:"env_prefix"`
+
+	// Mode is the permission of the output file in octal, e.g. "0640". Keep the permission of the existing file or 0644 if empty.
+	Mode string `toml:"mode"`
+	// Owner is the user name or uid of the output file. Keep unchanged if empty.
+	Owner string `toml:"owner"`
+	// OwnerGroup is the group name or gid of the output file. Keep unchanged if empty.
+	OwnerGroup string `toml:"owner_group"`
+	// Backups is the number of previous versions of the output file to keep as <output>.1 to <output>.N
+	Backups int `toml:"backups"`
+
+	// HookArgs are the arguments passed to the hook
+	HookArgs []string `toml:"hook_args"`
+	// HookTimeout is the time limit of a single hook run, e.g. "30s". 60s if empty.
+	HookTimeout string `toml:"hook_timeout"`
+	// HookDir is the working directory of the hook. Use the working directory of onlyagent if empty.
+	HookDir string `toml:"hook_dir"`
+	// HookEnv is the extra environment variables of the hook
+	HookEnv map[string]string `toml:"hook_env"`
+	// HookDebounce merges the updates within the period into one hook run, e.g. "500ms". 1s if empty, "0s" to disable.
+	HookDebounce string `toml:"hook_debounce"`
+	// HookMaxRetry is the max retry count of a failed hook. 3 if 0, negative to disable retry.
+	HookMaxRetry int `toml:"hook_max_retry"`
+
+	// ReloadSignal is the signal sent to the process of ReloadPidFile or ReloadProcess, e.g. "SIGHUP". SIGHUP if empty.
+	ReloadSignal string `toml:"reload_signal"`
+	// ReloadPidFile is the pidfile of the process to reload
+	ReloadPidFile string `toml:"reload_pidfile"`
+	// ReloadProcess is the command name of the processes to reload. Linux only.
+	ReloadProcess string `toml:"reload_process"`
+	// ReloadUrl is the endpoint requested to reload after update
+	ReloadUrl string `toml:"reload_url"`
+	// ReloadMethod is the http method of ReloadUrl. POST if empty.
+	ReloadMethod string `toml:"reload_method"`
+}
+
+func (c *ConfigItem) FileMode() (fs.FileMode, bool, error) {
+	if c.Mode == "" {
+		return 0, false, nil
+	}
+	m, err := strconv.ParseUint(c.Mode, 8, 32)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid mode %q: %w", c.Mode, err)
+	}
+	if m > 0777 {
+		return 0, false, errors.New("invalid mode " + c.Mode + ": only permission bits are allowed")
+	}
+	return fs.FileMode(m), true, nil
+}
+
+// Validate checks all the entries and reports all the errors found
+func (c *Config) Validate() error {
+	if len(c.ConfigList) == 0 {
+		return errors.New("config_list is empty")
+	}
+	var errs []error
+	outputs := map[string]int{}
+	for i := range c.ConfigList {
+		item := &c.ConfigList[i]
+		for _, err := range item.validate() {
+			errs = append(errs, fmt.Errorf("config_list[%d] output=%q: %w", i, item.Output, err))
+		}
+		if item.Output == "" {
+			continue
+		}
+		output, err := filepath.Abs(item.Output)
+		if err != nil {
+			output = filepath.Clean(item.Output)
+		}
+		if prev, ok := outputs[output]; ok {
+			errs = append(errs, fmt.Errorf("config_list[%d] output=%q: duplicated output with config_list[%d]", i, item.Output, prev))
+			continue
+		}
+		outputs[output] = i
+	}
+	return errors.Join(errs...)
+}
+
+func (c *ConfigItem) validate() []error {
+	var errs []error
+	if c.Output == "" {
+		errs = append(errs, errors.New("output is required"))
+	} else if fi, err := os.Stat(c.Output); c.Format == formatDir && err == nil {
+		if !fi.IsDir() {
+			errs = append(errs, errors.New("output is not a directory"))
+		} else if err := checkWritableDir(c.Output); err != nil {
+			errs = append(errs, fmt.Errorf("output directory is not writable: %w", err))
+		}
+	} else if c.Format != formatDir && err == nil && fi.IsDir() {
+		errs = append(errs, errors.New("output is a directory"))
+	} else if err := checkWritableDir(filepath.Dir(c.Output)); err != nil {
+		errs = append(errs, fmt.Errorf("output directory is not writable: %w", err))
+	}
+
+	switch c.Format {
+	case "", formatRaw:
+		if c.ContentType != "" || c.EnvPrefix != "" {
+			errs = append(errs, errors.New("content_type and env_prefix require env or dir format"))
+		}
+	case formatEnv, formatDir:
+		if !slices.Contains([]string{"json", "yaml", "toml", "properties"}, c.ContentType) {
+			errs = append(errs, fmt.Errorf("invalid content_type %q for %s format, should be json, yaml, toml or properties", c.ContentType, c.Format))
+		}
+		if c.Template != "" {
+			errs = append(errs, errors.New(c.Format+" format cannot be used with template"))
+		}
+		if c.Format == formatDir && c.Backups > 0 {
+			errs = append(errs, errors.New("backups cannot be used with dir format"))
+		}
+		if c.Format == formatDir && c.EnvPrefix != "" {
+			errs = append(errs, errors.New("env_prefix requires env format"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("unknown format %q, should be raw, env or dir", c.Format))
+	}
+
+	if c.SelectorsString == "" {
+		errs = append(errs, errors.New("selectors is required"))
+	}
+	errs = append(errs, validateSelectors("selectors", c.SelectorsString)...)
+	errs = append(errs, validateSelectors("optional_selectors", c.OptionalSelectorsString)...)
+
+	if c.Template == "" {
+		errs = append(errs, validateGroupKey("", c.Group, c.Key)...)
+		if len(c.TemplateData) > 0 {
+			errs = append(errs, errors.New("template_data requires template"))
+		}
+	} else {
+		for _, data := range c.TemplateData {
+			errs = append(errs, validateGroupKey("template_data "+data.Name+" ", data.Group, data.Key)...)
+		}
+		if _, err := newTemplateRenderer(c); err != nil {
+			errs = append(errs, fmt.Errorf("invalid template: %w", err))
+		}
+	}
+
+	if _, _, err := c.FileMode(); err != nil {
+		errs = append(errs, err)
+	}
+	if _, _, err := lookupOwner(c.Owner, c.OwnerGroup); err != nil {
+		errs = append(errs, fmt.Errorf("invalid owner: %w", err))
+	}
+	if c.Backups < 0 {
+		errs = append(errs, errors.New("backups should not be negative"))
+	}
+
+	if c.Hook != "" {
+		if _, err := exec.LookPath(c.Hook); err != nil {
+			errs = append(errs, fmt.Errorf("hook is not executable: %w", err))
+		}
+	} else if len(c.HookArgs) > 0 || c.HookDir != "" || len(c.HookEnv) > 0 {
+		errs = append(errs, errors.New("hook_args, hook_dir and hook_env require hook"))
+	}
+	if c.HookDir != "" {
+		if fi, err := os.Stat(c.HookDir); err != nil {
+			errs = append(errs, fmt.Errorf("invalid hook_dir: %w", err))
+		} else if !fi.IsDir() {
+			errs = append(errs, errors.New("hook_dir is not a directory"))
+		}
+	}
+	if _, err := parseDurationOrDefault(c.HookTimeout, defaultHookTimeout); err != nil {
+		errs = append(errs, fmt.Errorf("invalid hook_timeout: %w", err))
+	}
+	if _, err := parseDurationOrDefault(c.HookDebounce, defaultHookDebounce); err != nil {
+		errs = append(errs, fmt.Errorf("invalid hook_debounce: %w", err))
+	}
+	if _, err := hookActionsOf(c); err != nil {
+		errs = append(errs, err)
+	}
+	if c.ReloadUrl != "" {
+		if u, err := url.Parse(c.ReloadUrl); err != nil {
+			errs = append(errs, fmt.Errorf("invalid reload_url: %w", err))
+		} else if u.Scheme != "http" && u.Scheme != "https" {
+			errs = append(errs, errors.New("reload_url should be http or https"))
+		}
+	}
+	return errs
+}
+
+func validateSelectors(name, str string) []error {
+	sel := new(configapi.Selectors)
+	if err := sel.Fill(str); err != nil {
+		return []error{fmt.Errorf("invalid %s %q: %w", name, str, err)}
+	}
+	var errs []error
+	for _, k := range slices.Sorted(maps.Keys(sel.Data)) {
+		v := sel.Data[k]
+		if !tools.ValidateName(k) || !tools.ValidateName(v) {
+			errs = append(errs, fmt.Errorf("invalid %s %q: illegal name %s=%s", name, str, k, v))
+		}
+	}
+	return errs
+}
+
+func validateGroupKey(prefix, group, key string) []error {
+	var errs []error
+	if !tools.ValidateName(group) {
+		errs = append(errs, fmt.Errorf("%sinvalid group %q", prefix, group))
+	}
+	if !tools.ValidateName(key) {
+		errs = append(errs, fmt.Errorf("%sinvalid key %q", prefix, key))
+	}
+	return errs
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConfigValidate(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &Config{ConfigList: []ConfigItem{
+		{SelectorsString: "app=app1,dc=dc1,env=DEV", Group: "group1", Key: "key1", Output: filepath.Join(dir, "o1")},
+		{SelectorsString: "app=app1,dc=dc1,env=DEV", OptionalSelectorsString: "beta=1", Group: "group1", Key: "key2", Output: filepath.Join(dir, "o2"), Mode: "0640"},
+	}}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := filepath.Join(dir, "a.tmpl")
+	if err := os.WriteFile(tmpl, []byte("{{ .a }}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg = &Config{ConfigList: []ConfigItem{
+		// missing selectors and key, invalid optional selectors
+		{Group: "group1", OptionalSelectorsString: "beta", Output: filepath.Join(dir, "o1")},
+		// duplicated output, missing hook, invalid timeout
+		{SelectorsString: "dc=dc1", Group: "group1", Key: "key1", Output: filepath.Join(dir, "o1"), Hook: filepath.Join(dir, "missing.sh"), HookTimeout: "1x"},
+		// missing output directory
+		{SelectorsString: "dc=dc1", Group: "group1", Key: "key1", Output: filepath.Join(dir, "missing", "o3")},
+		// template with invalid template_data
+		{SelectorsString: "dc=dc1", Template: tmpl, Output: filepath.Join(dir, "o4"), TemplateData: []TemplateData{{Name: "a", Group: "group1"}}},
+		// missing output
+		{SelectorsString: "dc=dc1", Group: "group1", Key: "key1"},
+	}}
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("invalid config should be rejected")
+	}
+	for _, expected := range []string{
+		"config_list[0] output=" + `"` + filepath.Join(dir, "o1") + `"` + ": selectors is required",
+		"config_list[0] output=" + `"` + filepath.Join(dir, "o1") + `"` + ": invalid optional_selectors",
+		"config_list[0] output=" + `"` + filepath.Join(dir, "o1") + `"` + `: invalid key ""`,
+		"config_list[1] output=" + `"` + filepath.Join(dir, "o1") + `"` + ": duplicated output with config_list[0]",
+		"config_list[1] output=" + `"` + filepath.Join(dir, "o1") + `"` + ": hook is not executable",
+		"config_list[1] output=" + `"` + filepath.Join(dir, "o1") + `"` + ": invalid hook_timeout",
+		"config_list[2] output=" + `"` + filepath.Join(dir, "missing", "o3") + `"` + ": output directory is not writable",
+		"config_list[3] output=" + `"` + filepath.Join(dir, "o4") + `"` + `: template_data a invalid key ""`,
+		`config_list[4] output="": output is required`,
+	} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Error("expected error not reported:", expected)
+		}
+	}
+	if t.Failed() {
+		t.Log(err)
+	}
+
+	if err := (&Config{}).Validate(); err == nil {
+		t.Fatal("empty config_list should be rejected")
+	}
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/magiconair/properties"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	formatRaw = "raw"
+	formatEnv = "env"
+	formatDir = "dir"
+)
+
+// dirManifest records the files written in directory format in order to remove the files of deleted keys
+const dirManifest = ".onlyagent-keys"
+
+// writeOutput writes the configuration in the format of the entry
+func writeOutput(item *ConfigItem, data []byte) error {
+	switch item.Format {
+	case "", formatRaw:
+		return writeFileAtomic(item, data)
+	case formatEnv:
+		tree, err := parseStructured(item.ContentType, data)
+		if err != nil {
+			return err
+		}
+		return writeFileAtomic(item, renderEnvFile(item.EnvPrefix, tree))
+	case formatDir:
+		tree, err := parseStructured(item.ContentType, data)
+		if err != nil {
+			return err
+		}
+		files, err := dirFiles(item.ContentType, tree)
+		if err != nil {
+			return err
+		}
+		return writeDir(item, files)
+	default:
+		return errors.New("unknown format: " + item.Format)
+	}
+}
+
+// parseStructured parses the configuration into maps, slices and values
+func parseStructured(contentType string, data []byte) (map[string]any, error) {
+	tree := map[string]any{}
+	switch contentType {
+	case "json":
+		d := json.NewDecoder(bytes.NewReader(data))
+		d.UseNumber()
+		if err := d.Decode(&tree); err != nil {
+			return nil, err
+		}
+	case "yaml":
+		if err := yaml.Unmarshal(data, &tree); err != nil {
+			return nil, err
+		}
+	case "toml":
+		var m map[string]any
+		if err := toml.Unmarshal(data, &m); err != nil {
+			return nil, err
+		}
+		// normalize tables and arrays of tables
+		j, err := json.Marshal(m)
+		if err != nil {
+			return nil, err
+		}
+		d := json.NewDecoder(bytes.NewReader(j))
+		d.UseNumber()
+		if err := d.Decode(&tree); err != nil {
+			return nil, err
+		}
+	case "properties":
+		p, err := properties.Load(data, properties.UTF8)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range p.Map() {
+			tree[k] = v
+		}
+	default:
+		return nil, errors.New("unsupported content_type: " + contentType)
+	}
+	return tree, nil
+}
+
+// renderEnvFile flattens the configuration into KEY=value lines, e.g. {"db":{"hosts":["a"]}} to DB_HOSTS_0=a
+func renderEnvFile(prefix string, tree map[string]any) []byte {
+	env := map[string]string{}
+	for k, v := range tree {
+		flattenEnv(prefix+envName(k), v, env)
+	}
+	buf := new(bytes.Buffer)
+	for _, k := range slices.Sorted(maps.Keys(env)) {
+		buf.WriteString(k)
+		buf.WriteByte('=')
+		buf.WriteString(quoteEnvValue(env[k]))
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+func flattenEnv(name string, v any, env map[string]string) {
+	switch val := v.(type) {
+	case map[string]any:
+		for k, sub := range val {
+			flattenEnv(name+"_"+envName(k), sub, env)
+		}
+	case []any:
+		for i, sub := range val {
+			flattenEnv(name+"_"+strconv.Itoa(i), sub, env)
+		}
+	default:
+		env[name] = scalarString(v)
+	}
+}
+
+// envName converts the key to upper case and replaces the characters other than letters, digits and underscore
+func envName(key string) string {
+	b := []byte(strings.ToUpper(key))
+	for i, c := range b {
+		if !(c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_') {
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
+
+// quoteEnvValue quotes the value if it contains characters other than the safe ones
+// The quoted value is supported by both systemd EnvironmentFile and .env files.
+func quoteEnvValue(s string) string {
+	safe := true
+	for _, c := range s {
+		if !(c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || strings.ContainsRune("_-./:@+,", c)) {
+			safe = false
+			break
+		}
+	}
+	if safe {
+		return s
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "$", `\$`, "`", "\\`")
+	return `"` + r.Replace(s) + `"`
+}
+
+func scalarString(v any) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	default:
+		return fmt.Sprint(val)
+	}
+}
+
+// dirFiles returns the content of the file for each top-level key.
+// Nested values are encoded in yaml for yaml content type, otherwise in json.
+func dirFiles(contentType string, tree map[string]any) (map[string][]byte, error) {
+	files := map[string][]byte{}
+	for k, v := range tree {
+		if k == "" || k == "." || k == ".." || strings.HasPrefix(k, ".") || strings.ContainsAny(k, `/\`) {
+			return nil, errors.New("key cannot be used as file name: " + k)
+		}
+		switch v.(type) {
+		case map[string]any, []any:
+			var data []byte
+			var err error
+			if contentType == "yaml" {
+				data, err = yaml.Marshal(v)
+			} else {
+				data, err = json.Marshal(v)
+			}
+			if err != nil {
+				return nil, err
+			}
+			files[k] = data
+		default:
+			files[k] = []byte(scalarString(v))
+		}
+	}
+	return files, nil
+}
+
+// writeDir writes the files into the output directory and removes the files of deleted keys
+func writeDir(item *ConfigItem, files map[string][]byte) error {
+	dir := item.Output
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	var previous []string
+	if data, err := os.ReadFile(filepath.Join(dir, dirManifest)); err == nil {
+		previous = strings.Fields(string(data))
+	}
+	names := slices.Sorted(maps.Keys(files))
+	for _, name := range names {
+		fileItem := *item
+		fileItem.Output = filepath.Join(dir, name)
+		fileItem.Backups = 0
+		if err := writeFileAtomic(&fileItem, files[name]); err != nil {
+			return err
+		}
+	}
+	manifest := *item
+	manifest.Output = filepath.Join(dir, dirManifest)
+	manifest.Backups = 0
+	if err := writeFileAtomic(&manifest, []byte(strings.Join(names, "\n"))); err != nil {
+		return err
+	}
+	for _, name := range previous {
+		if _, ok := files[name]; ok || strings.ContainsAny(name, `/\`) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, name)); err != nil && !os.IsNotExist(err) {
+			log.Println("remove file of deleted key failed:", name, "error:", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRenderEnvFile(t *testing.T) {
+	for _, c := range []struct {
+		contentType string
+		data        string
+	}{
+		{"json", `{"db":{"host":"10.0.0.1","port":5432,"hosts":["a","b"]},"app-name":"demo app","debug":true,"ratio":0.5}`},
+		{"yaml", "db:\n  host: 10.0.0.1\n  port: 5432\n  hosts: [a, b]\napp-name: demo app\ndebug: true\nratio: 0.5\n"},
+		{"toml", "app-name = \"demo app\"\ndebug = true\nratio = 0.5\n[db]\nhost = \"10.0.0.1\"\nport = 5432\nhosts = [\"a\", \"b\"]\n"},
+		{"properties", "db.host=10.0.0.1\ndb.port=5432\ndb.hosts.0=a\ndb.hosts.1=b\napp-name=demo app\ndebug=true\nratio=0.5\n"},
+	} {
+		tree, err := parseStructured(c.contentType, []byte(c.data))
+		if err != nil {
+			t.Fatal(c.contentType, err)
+		}
+		expected := "APP_APP_NAME=\"demo app\"\nAPP_DB_HOST=10.0.0.1\nAPP_DB_HOSTS_0=a\nAPP_DB_HOSTS_1=b\nAPP_DB_PORT=5432\nAPP_DEBUG=true\nAPP_RATIO=0.5\n"
+		if out := string(renderEnvFile("APP_", tree)); out != expected {
+			t.Fatal(c.contentType, "unexpected output:", out)
+		}
+	}
+}
+
+func TestQuoteEnvValue(t *testing.T) {
+	for in, out := range map[string]string{
+		"abc":      "abc",
+		"":         "",
+		"a b":      `"a b"`,
+		`a"b`:      `"a\"b"`,
+		"a\nb":     `"a\nb"`,
+		"$HOME":    `"\$HOME"`,
+		`C:\path`:  `"C:\\path"`,
+		"x=1,y=2":  `"x=1,y=2"`,
+		"1.2.3-rc": "1.2.3-rc",
+	} {
+		if v := quoteEnvValue(in); v != out {
+			t.Error("unexpected quoted value:", in, v)
+		}
+	}
+}
+
+func TestWriteDirOutput(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "conf")
+	item := &ConfigItem{Output: dir, Format: formatDir, ContentType: "yaml"}
+	if err := writeOutput(item, []byte("host: 10.0.0.1\nports: [80, 443]\nremoved: x\n")); err != nil {
+		t.Fatal(err)
+	}
+	expect := func(name, content string) {
+		t.Helper()
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != content {
+			t.Fatal("unexpected content of", name, string(data))
+		}
+	}
+	expect("host", "10.0.0.1")
+	expect("ports", "- 80\n- 443\n")
+	expect("removed", "x")
+
+	if err := writeOutput(item, []byte("host: 10.0.0.2\nports: [80]\n")); err != nil {
+		t.Fatal(err)
+	}
+	expect("host", "10.0.0.2")
+	expect("ports", "- 80\n")
+	if _, err := os.Stat(filepath.Join(dir, "removed")); !os.IsNotExist(err) {
+		t.Fatal("file of deleted key should be removed")
+	}
+
+	if err := writeOutput(item, []byte("../escape: x\n")); err == nil {
+		t.Fatal("key of invalid file name should be rejected")
+	}
+}
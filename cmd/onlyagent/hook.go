@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultHookTimeout  = 60 * time.Second
+	defaultHookDebounce = 1 * time.Second
+	defaultHookMaxRetry = 3
+
+	hookRetryBackoffBase = 1 * time.Second
+	hookRetryBackoffMax  = 1 * time.Minute
+
+	maxHookOutputLog = 4096
+)
+
+// hookAction is the action invoked after the output of an entry is updated
+type hookAction interface {
+	// Name is used for logging
+	Name() string
+	Run(ctx context.Context, version string) error
+}
+
+// execHookAction runs the hook executable of the entry
+type execHookAction struct {
+	item *ConfigItem
+}
+
+func (e *execHookAction) Name() string {
+	return e.item.Hook
+}
+
+func (e *execHookAction) Run(ctx context.Context, version string) error {
+	item := e.item
+	c := exec.CommandContext(ctx, item.Hook, item.HookArgs...)
+	c.Dir = item.HookDir
+	c.Env = append(os.Environ(),
+		fmt.Sprint("ONLYAGENT_GROUP=", item.Group),
+		fmt.Sprint("ONLYAGENT_KEY=", item.Key),
+		fmt.Sprint("ONLYAGENT_SEL=", item.SelectorsString),
+		fmt.Sprint("ONLYAGENT_OPTSEL=", item.OptionalSelectorsString),
+		fmt.Sprint("ONLYAGENT_OUTPUT=", item.Output),
+		fmt.Sprint("ONLYAGENT_VERSION=", version),
+	)
+	envKeys := make([]string, 0, len(item.HookEnv))
+	for k := range item.HookEnv {
+		envKeys = append(envKeys, k)
+	}
+	slices.Sort(envKeys)
+	for _, k := range envKeys {
+		c.Env = append(c.Env, fmt.Sprint(k, "=", item.HookEnv[k]))
+	}
+	stdout := new(bytes.Buffer)
+	stderr := new(bytes.Buffer)
+	c.Stdout = stdout
+	c.Stderr = stderr
+	// make sure Wait returns when the hook is killed while its children still hold the output
+	c.WaitDelay = 5 * time.Second
+
+	err := c.Run()
+	exitCode := -1
+	if c.ProcessState != nil {
+		exitCode = c.ProcessState.ExitCode()
+	}
+	log.Println("hook finished:", item.Hook, "output:", item.Output, "exit code:", exitCode,
+		"stdout:", truncateHookOutput(stdout.String()), "stderr:", truncateHookOutput(stderr.String()))
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("hook timeout: %w", ctx.Err())
+	}
+	return err
+}
+
+func truncateHookOutput(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) > maxHookOutputLog {
+		return s[:maxHookOutputLog] + "...(truncated)"
+	}
+	return s
+}
+
+// hookRunner runs the hook action of one entry.
+// Triggers within the debounce period are merged and the hook runs serially with the latest version.
+// Failed hook will be retried with exponential backoff unless a newer trigger arrives.
+type hookRunner struct {
+	action   hookAction
+	output   string
+	timeout  time.Duration
+	debounce time.Duration
+	maxRetry int
+
+	lock    sync.Mutex
+	version string
+	dirty   bool
+
+	notify  chan struct{}
+	closeCh chan struct{}
+	doneCh  chan struct{}
+}
+
+func newHookRunner(item *ConfigItem, action hookAction) (*hookRunner, error) {
+	timeout, err := parseDurationOrDefault(item.HookTimeout, defaultHookTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("invalid hook_timeout: %w", err)
+	}
+	debounce, err := parseDurationOrDefault(item.HookDebounce, defaultHookDebounce)
+	if err != nil {
+		return nil, fmt.Errorf("invalid hook_debounce: %w", err)
+	}
+	maxRetry := item.HookMaxRetry
+	if maxRetry == 0 {
+		maxRetry = defaultHookMaxRetry
+	} else if maxRetry < 0 {
+		maxRetry = 0
+	}
+	h := &hookRunner{
+		action:   action,
+		output:   item.Output,
+		timeout:  timeout,
+		debounce: debounce,
+		maxRetry: maxRetry,
+		notify:   make(chan struct{}, 1),
+		closeCh:  make(chan struct{}),
+		doneCh:   make(chan struct{}),
+	}
+	go h.loop()
+	return h, nil
+}
+
+func parseDurationOrDefault(s string, def time.Duration) (time.Duration, error) {
+	if s == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if d < 0 {
+		return 0, errors.New("negative duration: " + s)
+	}
+	return d, nil
+}
+
+// Trigger schedules the hook with the version of the updated output
+func (h *hookRunner) Trigger(version string) {
+	h.lock.Lock()
+	h.version = version
+	h.dirty = true
+	h.lock.Unlock()
+	select {
+	case h.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Stop stops the runner and waits for the running hook
+func (h *hookRunner) Stop() {
+	close(h.closeCh)
+	<-h.doneCh
+}
+
+func (h *hookRunner) loop() {
+	defer close(h.doneCh)
+	for {
+		select {
+		case <-h.closeCh:
+			return
+		case <-h.notify:
+		}
+		if !h.waitDebounce() {
+			return
+		}
+		h.lock.Lock()
+		version := h.version
+		h.dirty = false
+		h.lock.Unlock()
+		_ = h.runWithRetry(version)
+	}
+}
+
+// waitDebounce waits until no trigger arrives within the debounce period
+func (h *hookRunner) waitDebounce() bool {
+	if h.debounce <= 0 {
+		return true
+	}
+	timer := time.NewTimer(h.debounce)
+	defer timer.Stop()
+	for {
+		select {
+		case <-h.closeCh:
+			return false
+		case <-h.notify:
+			timer.Reset(h.debounce)
+		case <-timer.C:
+			return true
+		}
+	}
+}
+
+func (h *hookRunner) runWithRetry(version string) error {
+	backoff := hookRetryBackoffBase
+	for i := 0; ; i++ {
+		log.Println("trigger hook:", h.action.Name(), "output:", h.output, "version:", version)
+		err := h.runOnce(version)
+		if err == nil {
+			log.Println("Invoke hook success:", h.action.Name(), "output:", h.output, "version:", version)
+			return nil
+		}
+		log.Println("Invoke hook error:", h.action.Name(), "output:", h.output, "version:", version, "attempt:", i+1, "error:", err)
+		if i >= h.maxRetry {
+			log.Println("Invoke hook failed after retries:", h.action.Name(), "output:", h.output, "version:", version)
+			return err
+		}
+		select {
+		case <-h.closeCh:
+			return err
+		case <-time.After(backoff):
+		}
+		if h.isDirty() {
+			// newer version will be handled in the next round
+			return err
+		}
+		backoff = min(backoff*2, hookRetryBackoffMax)
+	}
+}
+
+func (h *hookRunner) runOnce(version string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), h.timeout)
+	defer cancel()
+	return h.action.Run(ctx, version)
+}
+
+func (h *hookRunner) isDirty() bool {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return h.dirty
+}
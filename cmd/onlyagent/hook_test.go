@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordHookAction struct {
+	lock     sync.Mutex
+	versions []string
+	running  int
+	overlap  bool
+	failures int
+	done     chan string
+}
+
+func (r *recordHookAction) Name() string {
+	return "record"
+}
+
+func (r *recordHookAction) Run(ctx context.Context, version string) error {
+	r.lock.Lock()
+	r.running++
+	if r.running > 1 {
+		r.overlap = true
+	}
+	r.versions = append(r.versions, version)
+	fail := r.failures > 0
+	if fail {
+		r.failures--
+	}
+	r.lock.Unlock()
+
+	time.Sleep(50 * time.Millisecond)
+
+	r.lock.Lock()
+	r.running--
+	r.lock.Unlock()
+	if fail {
+		return errors.New("failed")
+	}
+	r.done <- version
+	return nil
+}
+
+func TestHookRunnerDebounce(t *testing.T) {
+	action := &recordHookAction{done: make(chan string, 10)}
+	h, err := newHookRunner(&ConfigItem{HookDebounce: "200ms"}, action)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Stop()
+
+	for _, v := range []string{"v1", "v2", "v3"} {
+		h.Trigger(v)
+		time.Sleep(20 * time.Millisecond)
+	}
+	select {
+	case v := <-action.done:
+		if v != "v3" {
+			t.Fatal("hook should run with the latest version:", v)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("hook not invoked")
+	}
+	time.Sleep(300 * time.Millisecond)
+	action.lock.Lock()
+	defer action.lock.Unlock()
+	if len(action.versions) != 1 {
+		t.Fatal("updates within debounce period should be merged:", action.versions)
+	}
+}
+
+func TestHookRunnerSerialAndRetry(t *testing.T) {
+	action := &recordHookAction{done: make(chan string, 10), failures: 1}
+	h, err := newHookRunner(&ConfigItem{HookDebounce: "0s"}, action)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Stop()
+
+	h.Trigger("v1")
+	select {
+	case v := <-action.done:
+		if v != "v1" {
+			t.Fatal("unexpected version:", v)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("failed hook should be retried")
+	}
+	for _, v := range []string{"v2", "v3", "v4"} {
+		h.Trigger(v)
+		time.Sleep(10 * time.Millisecond)
+	}
+	deadline := time.After(5 * time.Second)
+	for {
+		select {
+		case v := <-action.done:
+			if v != "v4" {
+				continue
+			}
+		case <-deadline:
+			t.Fatal("hook not invoked with the latest version")
+		}
+		break
+	}
+	action.lock.Lock()
+	defer action.lock.Unlock()
+	if action.overlap {
+		t.Fatal("hooks of the same entry should not overlap")
+	}
+	if action.versions[0] != "v1" || action.versions[1] != "v1" {
+		t.Fatal("failed hook should be retried with the same version:", action.versions)
+	}
+}
+
+func TestExecHookAction(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell script hook")
+	}
+	dir := t.TempDir()
+	hook := filepath.Join(dir, "hook.sh")
+	script := "#!/bin/sh\necho \"$1 $ONLYAGENT_OUTPUT $ONLYAGENT_VERSION $EXTRA $(pwd)\" > result\n"
+	if err := os.WriteFile(hook, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	action := &execHookAction{item: &ConfigItem{
+		Hook:     hook,
+		HookArgs: []string{"arg1"},
+		HookDir:  dir,
+		HookEnv:  map[string]string{"EXTRA": "extra"},
+		Output:   "app.yaml",
+	}}
+	if err := action.Run(context.Background(), "v1"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "result"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(string(data)) != "arg1 app.yaml v1 extra "+dir {
+		t.Fatal("unexpected hook result:", string(data))
+	}
+
+	if err := os.WriteFile(hook, []byte("#!/bin/sh\nexit 3\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := action.Run(context.Background(), "v2"); err == nil {
+		t.Fatal("non-zero exit code should fail")
+	}
+
+	if err := os.WriteFile(hook, []byte("#!/bin/sh\nexec sleep 10\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := action.Run(ctx, "v3"); err == nil {
+		t.Fatal("hook should be killed after timeout")
+	}
+	if time.Since(start) > 8*time.Second {
+		t.Fatal("hook timeout not applied")
+	}
+}
+
+func TestNewHookRunnerInvalidDuration(t *testing.T) {
+	if _, err := newHookRunner(&ConfigItem{HookTimeout: "abc"}, &recordHookAction{}); err == nil {
+		t.Fatal("invalid hook_timeout should be rejected")
+	}
+}
@@ -2,19 +2,15 @@ package main
 
 import (
 	"context"
-	"errors"
 	"flag"
 	"fmt"
-	"io"
 	"log"
 	"os"
-	"os/exec"
 	"os/signal"
 	"syscall"
 	"time"
 
 	"github.com/BurntSushi/toml"
-	"github.com/meidoworks/nekoq-component/configure/configapi"
 
 	"github.com/goodplayer/onlyconfig/client"
 )
@@ -25,21 +21,40 @@ var group string
 var key string
 var outputFile string
 var hookFile string
+var fileMode string
+var fileOwner string
+var fileOwnerGroup string
+var backups int
+var hookArgs stringList
+var hookTimeout string
+var reloadSignal string
+var reloadPidFile string
+var reloadUrl string
+var outputFormat string
+var contentType string
 
 var configFile string
+var once bool
+var checkOnly bool
+var stateDir string
+var onceTimeout time.Duration
 
-type serverList []string
+type stringList []string
 
-func (s *serverList) String() string {
+func (s *stringList) String() string {
 	return fmt.Sprintf("%v", *s)
 }
 
-func (s *serverList) Set(value string) error {
+func (s *stringList) Set(value string) error {
 	*s = append(*s, value)
 	return nil
 }
 
-var srvList serverList
+var srvList stringList
+var privateKeyFiles stringList
+
+// privateKeys opens the configurations sealed for the recipient key of the application, nil if not provided
+var privateKeys *client.PrivateKeys
 
 func init() {
 	flag.StringVar(&sel, "sel", "", "selectors string, single configuration")
@@ -48,267 +63,175 @@ func init() {
 	flag.StringVar(&key, "key", "", "key, single configuration")
 	flag.StringVar(&outputFile, "output", "", "output file path, single configuration")
 	flag.StringVar(&hookFile, "hook", "", "hook executable file path, onlyagent will invoke the hook after any update if it is provided, single configuration")
+	flag.StringVar(&outputFormat, "format", "", "output format: raw(default), env or dir, single configuration")
+	flag.StringVar(&contentType, "contenttype", "", "content type of configuration for env and dir format: json, yaml, toml or properties, single configuration")
+	flag.StringVar(&fileMode, "mode", "", "output file permission in octal, e.g. 0640, single configuration")
+	flag.StringVar(&fileOwner, "owner", "", "output file owner user name or uid, single configuration")
+	flag.StringVar(&fileOwnerGroup, "ownergroup", "", "output file owner group name or gid, single configuration")
+	flag.IntVar(&backups, "backups", 0, "number of previous versions of output file to keep, single configuration")
+	flag.Var(&hookArgs, "hookarg", "hook argument, can be repeated: -hookarg arg1 -hookarg arg2, single configuration")
+	flag.StringVar(&hookTimeout, "hooktimeout", "", "time limit of hook, e.g. 30s, default 60s, single configuration")
+	flag.StringVar(&reloadSignal, "reloadsignal", "", "signal sent to the process of reloadpidfile after any update, default SIGHUP, single configuration")
+	flag.StringVar(&reloadPidFile, "reloadpidfile", "", "pidfile of the process to reload after any update, single configuration")
+	flag.StringVar(&reloadUrl, "reloadurl", "", "url to POST after any update, single configuration")
+	flag.StringVar(&stateDir, "statedir", "", "directory to persist the states of the outputs, in order to skip the applied outputs and to write the outputs not yet applied again after restart")
+	flag.BoolVar(&checkOnly, "check", false, "validate configurations and exit")
+	flag.BoolVar(&once, "once", false, "fetch and write all configurations, run hooks and exit. Exit with non-zero status if any configuration is not applied.")
+	flag.DurationVar(&onceTimeout, "timeout", 30*time.Second, "time limit of fetching configurations in -once mode")
 	flag.StringVar(&configFile, "config", "", "Config file path. This will override other flags for single configuration.")
 	flag.Var(&srvList, "server", "server list: -server http://srv1 -server http://srv2")
-	flag.Parse()
-}
-
-type Config struct {
-	ConfigList []struct {
-		SelectorsString         string `toml:"selectors"`
-		OptionalSelectorsString string `toml:"optional_selectors"`
-		Group                   string `toml:"group"`
-		Key                     string `toml:"key"`
-		Hook                    string `toml:"hook"`
-		Output                  string `toml:"output"`
-	} `toml:"config_list"`
-}
-
-func (c *Config) Validate() error {
-	//FIXME add validation
-	return nil
+	flag.Var(&privateKeyFiles, "privatekey", "private key file opening the sealed secret configurations, can be repeated during key rotation: -privatekey new.key -privatekey old.key")
 }
 
 func main() {
-	var config = new(Config)
+	flag.Parse()
+
+	var config *Config
 	if configFile != "" {
-		f := func() []byte {
-			file, err := os.Open(configFile)
-			if err != nil {
-				log.Fatal(err)
-			}
-			defer func(file *os.File) {
-				_ = file.Close()
-			}(file)
-			data, err := io.ReadAll(file)
-			if err != nil {
-				log.Fatal(err)
-			}
-			return data
-		}
-		content := f()
-		if err := toml.Unmarshal(content, config); err != nil {
+		cfg, err := loadConfig(configFile)
+		if err != nil {
 			log.Fatal(err)
 		}
+		config = cfg
 	} else {
-		config.ConfigList = append(config.ConfigList, struct {
-			SelectorsString         string `toml:"selectors"`
-			OptionalSelectorsString string `toml:"optional_selectors"`
-			Group                   string `toml:"group"`
-			Key                     string `toml:"key"`
-			Hook                    string `toml:"hook"`
-			Output                  string `toml:"output"`
-		}{SelectorsString: sel, OptionalSelectorsString: optSel, Group: group, Key: key, Output: outputFile, Hook: hookFile})
+		config = new(Config)
+		config.ConfigList = append(config.ConfigList, ConfigItem{
+			SelectorsString:         sel,
+			OptionalSelectorsString: optSel,
+			Group:                   group,
+			Key:                     key,
+			Output:                  outputFile,
+			Hook:                    hookFile,
+			Format:                  outputFormat,
+			ContentType:             contentType,
+			Mode:                    fileMode,
+			Owner:                   fileOwner,
+			OwnerGroup:              fileOwnerGroup,
+			Backups:                 backups,
+			HookArgs:                hookArgs,
+			HookTimeout:             hookTimeout,
+			ReloadSignal:            reloadSignal,
+			ReloadPidFile:           reloadPidFile,
+			ReloadUrl:               reloadUrl,
+		})
 	}
 
 	if err := config.Validate(); err != nil {
-		log.Fatal(err)
+		log.Fatal("invalid config:\n", err)
+	}
+	if len(privateKeyFiles) > 0 {
+		keys, err := client.LoadPrivateKeys(privateKeyFiles...)
+		if err != nil {
+			log.Fatal("load private keys failed: ", err)
+		}
+		privateKeys = keys
+	}
+	if checkOnly {
+		log.Println("config is valid")
+		return
+	}
+
+	if once {
+		log.Println("applying configurations once...")
+		if err := runOnce(config, onceTimeout); err != nil {
+			log.Fatal(err)
+		}
+		log.Println("all configurations applied!")
+		return
 	}
 
 	log.Println("starting agent...")
-	start(config)
+	a := start(config)
 	log.Println("initial configurations applied! update listening...")
 
 	s := make(chan os.Signal, 1)
-	signal.Notify(s, os.Interrupt, syscall.SIGTERM)
-	<-s
-	stop()
+	signal.Notify(s, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	if configFile != "" {
+		go watchConfig(configFile, s)
+	}
+	for sig := range s {
+		if sig != syscall.SIGHUP {
+			break
+		}
+		if configFile == "" {
+			log.Println("no config file to reload")
+			continue
+		}
+		reload(a, configFile)
+	}
+	if err := a.stop(); err != nil {
+		log.Fatal(err)
+	}
 	log.Println("Shutting down...")
 }
 
-var clients []*client.Client
-
-func start(cfg *Config) {
-	// group by selectors in order for client creation
-	m := map[string][]struct {
-		SelectorsString         string
-		OptionalSelectorsString string
-		Group                   string
-		Key                     string
-		Output                  string
-		Hook                    string
-	}{}
-	for _, v := range cfg.ConfigList {
-		key := fmt.Sprint(v.SelectorsString, "::", v.OptionalSelectorsString)
-		m[key] = append(m[key], struct {
-			SelectorsString         string
-			OptionalSelectorsString string
-			Group                   string
-			Key                     string
-			Output                  string
-			Hook                    string
-		}{
-			SelectorsString:         v.SelectorsString,
-			OptionalSelectorsString: v.OptionalSelectorsString,
-			Group:                   v.Group,
-			Key:                     v.Key,
-			Output:                  v.Output,
-			Hook:                    v.Hook,
-		})
+func loadConfig(file string) (*Config, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
 	}
+	config := new(Config)
+	if err := toml.Unmarshal(data, config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
 
-	taskQueue := make(chan struct {
-		Output string
-		Val    []byte
-		Hook   string
-
-		SelectorsString         string
-		OptionalSelectorsString string
-		Group                   string
-		Key                     string
-	}, 1024) // use 1024 to retain enough pending writing items even when filesystem is failed to write for short period
-	// create clients and add listeners
-	for _, val := range m {
-		sample := val[0]
-		var sel = new(configapi.Selectors)
-		var optsel = new(configapi.Selectors)
-		if err := sel.Fill(sample.SelectorsString); err != nil {
-			log.Fatal(err)
-		}
-		if err := optsel.Fill(sample.OptionalSelectorsString); err != nil {
-			log.Fatal(err)
-		}
-		c := client.NewClient(srvList, client.ClientOptions{
-			OverrideSelectors:         sel,
-			OverrideOptionalSelectors: optsel,
-		})
-		for _, item := range val {
-			output := item.Output
-			hook := item.Hook
-
-			sel := item.SelectorsString
-			optsel := item.OptionalSelectorsString
-			group := item.Group
-			key := item.Key
-			c.AddConfigurationRequirement(client.RequiredConfig{
-				Required: configapi.RequestedConfigurationKey{
-					Group: item.Group,
-					Key:   item.Key,
-				},
-				Callback: func(cfg configapi.Configuration) {
-					select {
-					case taskQueue <- struct {
-						Output string
-						Val    []byte
-						Hook   string
+// reload applies the new config file. Invalid config is rejected and the running entries are untouched.
+func reload(a *agent, file string) {
+	log.Println("reloading config:", file)
+	cfg, err := loadConfig(file)
+	if err == nil {
+		err = cfg.Validate()
+	}
+	if err == nil {
+		err = a.apply(cfg)
+	}
+	if err != nil {
+		log.Println("Reload config failed, keep running config. error:", err)
+		return
+	}
+	log.Println("config reloaded:", file)
+}
 
-						SelectorsString         string
-						OptionalSelectorsString string
-						Group                   string
-						Key                     string
-					}{
-						Output: output,
-						Val:    cfg.Value,
-						Hook:   hook,
+const configWatchInterval = 2 * time.Second
 
-						SelectorsString:         sel,
-						OptionalSelectorsString: optsel,
-						Group:                   group,
-						Key:                     key,
-					}:
-					default:
-						log.Panicln(errors.New("task queue full and there should be errors processing configuration update"))
-					}
-				},
-			})
+// watchConfig polls the config file and sends SIGHUP to reload when the file is modified
+func watchConfig(file string, ch chan<- os.Signal) {
+	stat := func() (time.Time, int64) {
+		fi, err := os.Stat(file)
+		if err != nil {
+			return time.Time{}, -1
 		}
-		clients = append(clients, c)
+		return fi.ModTime(), fi.Size()
 	}
-	// start clients
-	for _, c := range clients {
-		if err := c.StartClient(); err != nil {
-			log.Fatal(err)
-		}
-		if err := c.WaitStartupConfigureLoaded(context.Background()); err != nil {
-			log.Fatal(err)
+	lastMod, lastSize := stat()
+	for range time.Tick(configWatchInterval) {
+		mod, size := stat()
+		if size < 0 || (mod.Equal(lastMod) && size == lastSize) {
+			continue
 		}
+		lastMod, lastSize = mod, size
+		log.Println("config file modified:", file)
+		ch <- syscall.SIGHUP
 	}
-	// start file writer
-	go func() {
-		for {
-			item := <-taskQueue
-			const maxRetry = 10
-			for i := 0; i < maxRetry; i++ {
-				f := func() error {
-					fi, err := os.OpenFile(item.Output, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
-					if err != nil {
-						return err
-					}
-					defer func(fi *os.File) {
-						_ = fi.Close()
-					}(fi)
-					if _, err := fi.Write(item.Val); err != nil {
-						return err
-					}
-					return nil
-				}
-				if err := f(); err != nil {
-					log.Println("Update failed! file:", item.Output, "error:", err)
-					time.Sleep(1 * time.Second)
-					continue
-				} else {
-					log.Println("Update success! file:", item.Output)
-					// trigger hook
-					if item.Hook != "" {
-						log.Println("trigger hook:", item.Hook)
-						if err := ExecCmd(item.Hook, item.Group, item.Key, item.SelectorsString, item.OptionalSelectorsString); err != nil {
-							log.Println("Invoke hook error:", err)
-							continue
-						}
-					}
-					break
-				}
-			}
-		}
-	}()
 }
 
-func stop() {
-	var errs []error
-	for _, c := range clients {
-		if err := c.StopClient(); err != nil {
-			errs = append(errs, err)
+func start(cfg *Config) *agent {
+	var store *stateStore
+	if stateDir != "" {
+		s, err := newStateStore(stateDir)
+		if err != nil {
+			log.Fatal(err)
 		}
+		store = s
 	}
-	if len(errs) > 0 {
-		log.Fatal(errors.Join(errs...))
+	a := newAgent(store)
+	if err := a.apply(cfg); err != nil {
+		log.Fatal(err)
 	}
-}
-
-func ExecCmd(cmd, group, key, sel, optsel string) error {
-	c := exec.Command(cmd)
-	c.Env = append(os.Environ(),
-		fmt.Sprint("ONLYAGENT_GROUP=", group),
-		fmt.Sprint("ONLYAGENT_KEY=", key),
-		fmt.Sprint("ONLYAGENT_SEL=", sel),
-		fmt.Sprint("ONLYAGENT_OPTSEL=", optsel),
-	)
-	if err := c.Start(); err != nil {
-		return err
+	if err := a.waitLoaded(context.Background()); err != nil {
+		log.Fatal(err)
 	}
-
-	// async waiting task with deadline
-	closeCh := make(chan struct{})
-	go func() {
-		// deadline task - 60s timeout
-		select {
-		case <-closeCh:
-		case <-time.After(60 * time.Second):
-			err := c.Process.Kill()
-			if err != nil {
-				log.Println("kill execCmd process error:", err)
-			} else {
-				log.Println("process killed")
-			}
-		}
-	}()
-	go func() {
-		if err := c.Wait(); err != nil {
-			log.Println("waiting result of execCmd error:", err)
-		} else {
-			log.Println("waiting result of execCmd success")
-		}
-		close(closeCh)
-	}()
-	return nil
+	return a
 }
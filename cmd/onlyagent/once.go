@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// runOnce fetches all the entries within the timeout, writes the outputs and runs the hooks.
+// All entries that are not fetched, written or whose hook failed are reported in the returned error.
+func runOnce(cfg *Config, timeout time.Duration) error {
+	lock := new(sync.Mutex)
+	type fetched struct {
+		Entry   *entry
+		Val     []byte
+		Version string
+	}
+	latest := map[*ConfigItem]fetched{}
+	a := newCollectAgent(func(e *entry, val []byte, version string) {
+		lock.Lock()
+		defer lock.Unlock()
+		latest[e.item] = fetched{
+			Entry:   e,
+			Val:     val,
+			Version: version,
+		}
+	})
+	if err := a.apply(cfg); err != nil {
+		return err
+	}
+	defer func() {
+		if err := a.stop(); err != nil {
+			log.Println("stop agent failed:", err)
+		}
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	// entries not loaded are reported below
+	_ = a.waitLoaded(ctx)
+
+	lock.Lock()
+	defer lock.Unlock()
+	var errs []error
+	for i := range cfg.ConfigList {
+		item := &cfg.ConfigList[i]
+		task, ok := latest[item]
+		if !ok {
+			errs = append(errs, fmt.Errorf("configuration not fetched within %s, output: %s", timeout, item.Output))
+			continue
+		}
+		if err := writeOutput(item, task.Val); err != nil {
+			errs = append(errs, fmt.Errorf("write output %s failed: %w", item.Output, err))
+			continue
+		}
+		log.Println("Update success! file:", item.Output, "version:", task.Version)
+		for _, h := range task.Entry.hooks {
+			if err := h.runWithRetry(task.Version); err != nil {
+				errs = append(errs, fmt.Errorf("hook %s of output %s failed: %w", h.action.Name(), item.Output, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/goodplayer/onlyconfig/client"
+	"github.com/goodplayer/onlyconfig/onlyconfigtest"
+	"github.com/goodplayer/onlyconfig/secrets"
+)
+
+func TestRunOnce(t *testing.T) {
+	srv := onlyconfigtest.NewServer(t)
+	if err := srv.Set("app=app1,dc=dc1,env=DEV", "group1", "key1", []byte("value1")); err != nil {
+		t.Fatal(err)
+	}
+	srvList = stringList{srv.URL()}
+	defer func() {
+		srvList = nil
+	}()
+
+	dir := t.TempDir()
+	output1 := filepath.Join(dir, "output1")
+	cfg := &Config{ConfigList: []ConfigItem{
+		{SelectorsString: "app=app1,dc=dc1,env=DEV", Group: "group1", Key: "key1", Output: output1},
+	}}
+	if err := runOnce(cfg, 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(output1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "value1" {
+		t.Fatal("unexpected output:", string(data))
+	}
+
+	// missing configuration, the server rejects the whole request of the same selectors so use different selectors here
+	output2 := filepath.Join(dir, "output2")
+	cfg = &Config{ConfigList: []ConfigItem{
+		{SelectorsString: "app=app1,dc=dc1,env=DEV", Group: "group1", Key: "key1", Output: output1},
+		{SelectorsString: "app=app1,dc=dc2,env=DEV", Group: "group1", Key: "missing", Output: output2},
+	}}
+	err = runOnce(cfg, 2*time.Second)
+	if err == nil {
+		t.Fatal("missing configuration should fail")
+	}
+	if !strings.Contains(err.Error(), output2) || strings.Contains(err.Error(), output1) {
+		t.Fatal("unexpected error:", err)
+	}
+	if _, err := os.Stat(output2); !os.IsNotExist(err) {
+		t.Fatal("output of missing configuration should not be written")
+	}
+}
+
+func TestRunOnceSealed(t *testing.T) {
+	privateKey, publicKey, err := secrets.GenerateRecipientKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := secrets.SealFor(publicKey, []byte("s3cret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := onlyconfigtest.NewServer(t)
+	if err := srv.Set("app=app1,dc=dc1,env=DEV", "group1", "password", []byte(sealed)); err != nil {
+		t.Fatal(err)
+	}
+	srvList = stringList{srv.URL()}
+	defer func() {
+		srvList = nil
+		privateKeys = nil
+	}()
+
+	dir := t.TempDir()
+	output := filepath.Join(dir, "password")
+	cfg := &Config{ConfigList: []ConfigItem{
+		{SelectorsString: "app=app1,dc=dc1,env=DEV", Group: "group1", Key: "password", Output: output},
+	}}
+	// not applied without the private key
+	if err := runOnce(cfg, 2*time.Second); err == nil {
+		t.Fatal("sealed configuration should not be applied without the private key")
+	}
+	if _, err := os.Stat(output); !os.IsNotExist(err) {
+		t.Fatal("sealed configuration should not be written")
+	}
+
+	keyFile := filepath.Join(dir, "app.key")
+	if err := os.WriteFile(keyFile, []byte(privateKey), 0600); err != nil {
+		t.Fatal(err)
+	}
+	keys, err := client.LoadPrivateKeys(keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privateKeys = keys
+	if err := runOnce(cfg, 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(output); err != nil || string(data) != "s3cret" {
+		t.Fatal("unexpected output:", string(data), err)
+	}
+}
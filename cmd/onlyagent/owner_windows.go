@@ -0,0 +1,31 @@
+//go:build windows
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+func lookupOwner(owner, group string) (int, int, error) {
+	if owner != "" || group != "" {
+		return 0, 0, errors.New("owner and owner_group are not supported on windows")
+	}
+	return -1, -1, nil
+}
+
+func syncDir(dir string) error {
+	return nil
+}
+
+// checkWritableDir only checks the existence of the directory since permission is not available from file mode on windows
+func checkWritableDir(dir string) error {
+	fi, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		return errors.New("not a directory: " + dir)
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// hookActionsOf returns the hook executable and the built-in reload actions of the entry
+func hookActionsOf(item *ConfigItem) ([]hookAction, error) {
+	var actions []hookAction
+	if item.Hook != "" {
+		actions = append(actions, &execHookAction{item: item})
+	}
+	if item.ReloadPidFile != "" || item.ReloadProcess != "" {
+		if item.ReloadPidFile != "" && item.ReloadProcess != "" {
+			return nil, errors.New("reload_pidfile and reload_process cannot be used together for output: " + item.Output)
+		}
+		name := item.ReloadSignal
+		if name == "" {
+			name = "SIGHUP"
+		}
+		sig, err := parseSignal(name)
+		if err != nil {
+			return nil, err
+		}
+		actions = append(actions, &signalReloadAction{
+			signal:     sig,
+			signalName: name,
+			pidFile:    item.ReloadPidFile,
+			process:    item.ReloadProcess,
+		})
+	} else if item.ReloadSignal != "" {
+		return nil, errors.New("reload_signal requires reload_pidfile or reload_process for output: " + item.Output)
+	}
+	if item.ReloadUrl != "" {
+		method := item.ReloadMethod
+		if method == "" {
+			method = http.MethodPost
+		}
+		actions = append(actions, &httpReloadAction{
+			method: strings.ToUpper(method),
+			url:    item.ReloadUrl,
+		})
+	}
+	return actions, nil
+}
+
+// signalReloadAction sends a signal to the process from pidfile or matched by name
+type signalReloadAction struct {
+	signal     os.Signal
+	signalName string
+	pidFile    string
+	process    string
+}
+
+func (s *signalReloadAction) Name() string {
+	if s.pidFile != "" {
+		return fmt.Sprint("signal ", s.signalName, " to pidfile ", s.pidFile)
+	}
+	return fmt.Sprint("signal ", s.signalName, " to process ", s.process)
+}
+
+func (s *signalReloadAction) Run(ctx context.Context, version string) error {
+	var pids []int
+	if s.pidFile != "" {
+		data, err := os.ReadFile(s.pidFile)
+		if err != nil {
+			return err
+		}
+		pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+		if err != nil {
+			return fmt.Errorf("invalid pidfile %s: %w", s.pidFile, err)
+		}
+		pids = append(pids, pid)
+	} else {
+		found, err := findProcessByName(s.process)
+		if err != nil {
+			return err
+		}
+		if len(found) == 0 {
+			return errors.New("no process found: " + s.process)
+		}
+		pids = found
+	}
+	for _, pid := range pids {
+		p, err := os.FindProcess(pid)
+		if err != nil {
+			return err
+		}
+		if err := p.Signal(s.signal); err != nil {
+			return fmt.Errorf("send signal to pid %d failed: %w", pid, err)
+		}
+		log.Println("signal sent:", s.signalName, "pid:", pid)
+	}
+	return nil
+}
+
+// httpReloadAction requests the reload endpoint. Any status other than 2xx is treated as failure.
+type httpReloadAction struct {
+	method string
+	url    string
+}
+
+func (h *httpReloadAction) Name() string {
+	return fmt.Sprint(h.method, " ", h.url)
+}
+
+func (h *httpReloadAction) Run(ctx context.Context, version string) error {
+	req, err := http.NewRequestWithContext(ctx, h.method, h.url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-OnlyAgent-Version", version)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func(body io.ReadCloser) {
+		_ = body.Close()
+	}(resp.Body)
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxHookOutputLog))
+	log.Println("reload request finished:", h.Name(), "status:", resp.Status, "response:", strings.TrimSpace(string(body)))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.New("unexpected reload response status: " + resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package main
+
+import "errors"
+
+func findProcessByName(name string) ([]int, error) {
+	return nil, errors.New("reload_process is only supported on linux, use reload_pidfile instead")
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHttpReloadAction(t *testing.T) {
+	var method, version string
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method = r.Method
+		version = r.Header.Get("X-OnlyAgent-Version")
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	actions, err := hookActionsOf(&ConfigItem{ReloadUrl: srv.URL + "/-/reload"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(actions) != 1 {
+		t.Fatal("expect one reload action")
+	}
+	if err := actions[0].Run(context.Background(), "v1"); err != nil {
+		t.Fatal(err)
+	}
+	if method != http.MethodPost || version != "v1" {
+		t.Fatal("unexpected request:", method, version)
+	}
+
+	status = http.StatusInternalServerError
+	if err := actions[0].Run(context.Background(), "v2"); err == nil {
+		t.Fatal("non-2xx status should fail")
+	}
+}
+
+func TestHookActionsOf(t *testing.T) {
+	actions, err := hookActionsOf(&ConfigItem{Hook: "pwd", ReloadPidFile: "app.pid", ReloadUrl: "http://127.0.0.1/reload"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(actions) != 3 {
+		t.Fatal("expect hook, signal and http actions:", len(actions))
+	}
+	if _, err := hookActionsOf(&ConfigItem{ReloadPidFile: "app.pid", ReloadProcess: "app"}); err == nil {
+		t.Fatal("pidfile and process should not be used together")
+	}
+	if _, err := hookActionsOf(&ConfigItem{ReloadSignal: "SIGHUP"}); err == nil {
+		t.Fatal("signal without target should be rejected")
+	}
+	if _, err := hookActionsOf(&ConfigItem{ReloadPidFile: "app.pid", ReloadSignal: "SIGNOPE"}); err == nil {
+		t.Fatal("unknown signal should be rejected")
+	}
+}
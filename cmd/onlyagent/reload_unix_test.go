@@ -0,0 +1,59 @@
+//go:build !windows
+
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestSignalReloadActionPidFile(t *testing.T) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGUSR1)
+	defer signal.Stop(ch)
+
+	pidFile := filepath.Join(t.TempDir(), "app.pid")
+	if err := os.WriteFile(pidFile, []byte(strconv.Itoa(os.Getpid())+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	actions, err := hookActionsOf(&ConfigItem{ReloadPidFile: pidFile, ReloadSignal: "USR1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := actions[0].Run(context.Background(), "v1"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case sig := <-ch:
+		if sig != syscall.SIGUSR1 {
+			t.Fatal("unexpected signal:", sig)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("signal not received")
+	}
+
+	if err := os.WriteFile(pidFile, []byte("abc"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := actions[0].Run(context.Background(), "v2"); err == nil {
+		t.Fatal("invalid pidfile should fail")
+	}
+}
+
+func TestParseSignal(t *testing.T) {
+	for _, name := range []string{"SIGHUP", "HUP", "hup", "1"} {
+		sig, err := parseSignal(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sig != syscall.SIGHUP {
+			t.Fatal("unexpected signal:", name, sig)
+		}
+	}
+}
@@ -0,0 +1,17 @@
+//go:build windows
+
+package main
+
+import (
+	"errors"
+	"os"
+	"strings"
+)
+
+// parseSignal only supports KILL on windows
+func parseSignal(name string) (os.Signal, error) {
+	if strings.TrimPrefix(strings.ToUpper(name), "SIG") == "KILL" {
+		return os.Kill, nil
+	}
+	return nil, errors.New("unsupported signal on windows: " + name)
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	writeRetryBackoffBase = 1 * time.Second
+	writeRetryBackoffMax  = 1 * time.Minute
+)
+
+// entryState is the version and hash of the desired output of an entry and whether it has been applied.
+// The output itself is never persisted since it may contain the opened secret configurations. After restart, the output
+// is rebuilt from the configurations once loaded, which is skipped if the same as the applied one, otherwise written
+// with the hooks run again.
+type entryState struct {
+	Output         string `json:"output"`
+	DesiredVersion string `json:"desired_version"`
+	DesiredHash    string `json:"desired_hash"`
+	Applied        bool   `json:"applied"`
+}
+
+// outputHash is the hash of the output in the state
+func outputHash(val []byte) string {
+	h := sha256.Sum256(val)
+	return hex.EncodeToString(h[:])
+}
+
+// outputApplied reports whether the state is applied with the same output, which is still in the file
+func outputApplied(st *entryState, hash string) bool {
+	if !st.Applied || st.DesiredHash != hash {
+		return false
+	}
+	data, err := os.ReadFile(st.Output)
+	return err == nil && outputHash(data) == hash
+}
+
+// stateStore saves the state of each entry as a file in the directory
+type stateStore struct {
+	dir string
+}
+
+func newStateStore(dir string) (*stateStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &stateStore{dir: dir}, nil
+}
+
+func (s *stateStore) file(id string) string {
+	h := sha256.Sum256([]byte(id))
+	return filepath.Join(s.dir, hex.EncodeToString(h[:16])+".json")
+}
+
+func (s *stateStore) load(id string) (*entryState, error) {
+	data, err := os.ReadFile(s.file(id))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	st := new(entryState)
+	if err := json.Unmarshal(data, st); err != nil {
+		return nil, err
+	}
+	return st, nil
+}
+
+func (s *stateStore) save(id string, st *entryState) error {
+	data, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(&ConfigItem{Output: s.file(id), Mode: "0600"}, data)
+}
+
+// prune removes the states of the entries not in use
+func (s *stateStore) prune(ids map[string]struct{}) {
+	keep := map[string]struct{}{}
+	for id := range ids {
+		keep[filepath.Base(s.file(id))] = struct{}{}
+	}
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		log.Println("list state directory failed:", err)
+		return
+	}
+	for _, e := range entries {
+		if _, ok := keep[e.Name()]; ok || e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		if err := os.Remove(filepath.Join(s.dir, e.Name())); err != nil {
+			log.Println("remove unused state failed:", e.Name(), "error:", err)
+		}
+	}
+}
+
+// outputWriter writes the latest output of an entry.
+// Updates are coalesced and only the newest value is written. A failed write is retried with exponential backoff until
+// succeeded or superseded by a newer value.
+type outputWriter struct {
+	e     *entry
+	store *stateStore
+
+	lock    sync.Mutex
+	state   entryState
+	desired []byte
+	pending bool
+	// restored is the state before restart, which decides whether the first submitted output is written
+	restored *entryState
+
+	notify  chan struct{}
+	closeCh chan struct{}
+	doneCh  chan struct{}
+}
+
+func newOutputWriter(e *entry, store *stateStore) *outputWriter {
+	w := &outputWriter{
+		e:       e,
+		store:   store,
+		notify:  make(chan struct{}, 1),
+		closeCh: make(chan struct{}),
+		doneCh:  make(chan struct{}),
+	}
+	w.state.Output = e.item.Output
+	if store != nil {
+		if st, err := store.load(e.id); err != nil {
+			log.Println("load state failed, output:", e.item.Output, "error:", err)
+		} else if st != nil && st.Output == e.item.Output {
+			if !st.Applied {
+				log.Println("pending output found, waiting for the configurations to rebuild, output:", e.item.Output, "version:", st.DesiredVersion)
+			}
+			w.state = *st
+			w.restored = st
+		}
+	}
+	go w.loop()
+	return w
+}
+
+// Submit sets the desired output
+func (w *outputWriter) Submit(val []byte, version string) {
+	w.lock.Lock()
+	hash := outputHash(val)
+	if st := w.restored; st != nil {
+		w.restored = nil
+		if outputApplied(st, hash) {
+			// neither written again nor the hooks run again after restart
+			w.lock.Unlock()
+			log.Println("Output unchanged since applied, skip. file:", w.e.item.Output, "version:", version)
+			return
+		}
+		if !st.Applied {
+			log.Println("pending output rebuilt, output:", w.state.Output, "pending version:", st.DesiredVersion, "version:", version)
+		}
+	}
+	w.desired = val
+	w.state.DesiredVersion = version
+	w.state.DesiredHash = hash
+	w.state.Applied = false
+	w.pending = true
+	w.saveState()
+	w.lock.Unlock()
+	select {
+	case w.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Stop stops the writer and waits for the running write
+func (w *outputWriter) Stop() {
+	close(w.closeCh)
+	<-w.doneCh
+}
+
+// saveState requires lock
+func (w *outputWriter) saveState() {
+	if w.store == nil {
+		return
+	}
+	if err := w.store.save(w.e.id, &w.state); err != nil {
+		log.Println("save state failed, output:", w.state.Output, "error:", err)
+	}
+}
+
+func (w *outputWriter) loop() {
+	defer close(w.doneCh)
+	for {
+		select {
+		case <-w.closeCh:
+			return
+		case <-w.notify:
+		}
+		backoff := writeRetryBackoffBase
+		for {
+			w.lock.Lock()
+			if !w.pending {
+				w.lock.Unlock()
+				break
+			}
+			val, version := w.desired, w.state.DesiredVersion
+			w.pending = false
+			w.lock.Unlock()
+
+			if err := writeOutput(w.e.item, val); err != nil {
+				log.Println("Update failed! file:", w.e.item.Output, "version:", version, "retry after:", backoff, "error:", err)
+				w.lock.Lock()
+				// retry unless a newer value is submitted
+				if !w.pending {
+					w.pending = true
+				}
+				w.lock.Unlock()
+				select {
+				case <-w.closeCh:
+					return
+				case <-w.notify:
+					// newer value submitted
+					backoff = writeRetryBackoffBase
+				case <-time.After(backoff):
+					backoff = min(backoff*2, writeRetryBackoffMax)
+				}
+				continue
+			}
+
+			log.Println("Update success! file:", w.e.item.Output, "version:", version)
+			w.lock.Lock()
+			if !w.pending {
+				w.state.Applied = true
+				w.saveState()
+			}
+			w.lock.Unlock()
+			// trigger hook, hooks run in background serially per entry
+			for _, h := range w.e.hooks {
+				h.Trigger(version)
+			}
+		}
+	}
+}
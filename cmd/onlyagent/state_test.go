@@ -0,0 +1,209 @@
+package main
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func waitFileContent(t *testing.T, file, content string) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		if data, err := os.ReadFile(file); err == nil && string(data) == content {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatal("file content not expected:", file, content)
+}
+
+func TestOutputWriterRetry(t *testing.T) {
+	dir := t.TempDir()
+	output := filepath.Join(dir, "sub", "output")
+	store, err := newStateStore(filepath.Join(dir, "state"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := &entry{id: "entry1", item: &ConfigItem{Output: output}}
+	w := newOutputWriter(e, store)
+	defer w.Stop()
+
+	// the directory is missing so writing fails until it is created
+	w.Submit([]byte("v1"), "v1")
+	w.Submit([]byte("v2"), "v2")
+	time.Sleep(200 * time.Millisecond)
+	st, err := store.load("entry1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st == nil || st.Applied || st.DesiredVersion != "v2" || st.DesiredHash != outputHash([]byte("v2")) {
+		t.Fatal("desired state should be persisted before applied:", st)
+	}
+	if err := os.MkdirAll(filepath.Dir(output), 0755); err != nil {
+		t.Fatal(err)
+	}
+	waitFileContent(t, output, "v2")
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		st, err = store.load("entry1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if st.Applied {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("applied state should be persisted")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestOutputWriterRestart(t *testing.T) {
+	dir := t.TempDir()
+	output := filepath.Join(dir, "output")
+	store, err := newStateStore(filepath.Join(dir, "state"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// written but not marked applied before restart
+	if err := os.WriteFile(output, []byte("pending"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.save("entry1", &entryState{Output: output, DesiredVersion: "v1", DesiredHash: outputHash([]byte("pending"))}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.save("removed", &entryState{Output: output, DesiredVersion: "v1", DesiredHash: outputHash([]byte("removed"))}); err != nil {
+		t.Fatal(err)
+	}
+
+	// pending output is rebuilt from the configurations after restart, then written with the hooks run again
+	action := &recordHookAction{done: make(chan string, 10)}
+	h, err := newHookRunner(&ConfigItem{Output: output, HookDebounce: "0s"}, action)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Stop()
+	e := &entry{id: "entry1", item: &ConfigItem{Output: output}, hooks: []*hookRunner{h}}
+	w := newOutputWriter(e, store)
+	defer w.Stop()
+	w.Submit([]byte("pending"), "v1")
+	select {
+	case v := <-action.done:
+		if v != "v1" {
+			t.Fatal("unexpected hook version:", v)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("hook of the output not applied should run again")
+	}
+	waitFileContent(t, output, "pending")
+	if st, err := store.load("entry1"); err != nil || st == nil || !st.Applied {
+		t.Fatal("rebuilt output should be applied:", st, err)
+	}
+
+	store.prune(map[string]struct{}{"entry1": {}})
+	if st, err := store.load("removed"); err != nil || st != nil {
+		t.Fatal("unused state should be pruned:", st, err)
+	}
+	if st, err := store.load("entry1"); err != nil || st == nil {
+		t.Fatal("used state should be kept:", st, err)
+	}
+}
+
+func TestOutputWriterRestartApplied(t *testing.T) {
+	dir := t.TempDir()
+	output := filepath.Join(dir, "output")
+	store, err := newStateStore(filepath.Join(dir, "state"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(output, []byte("applied"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.save("entry1", &entryState{Output: output, DesiredVersion: "v1", DesiredHash: outputHash([]byte("applied")), Applied: true}); err != nil {
+		t.Fatal(err)
+	}
+	past := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(output, past, past); err != nil {
+		t.Fatal(err)
+	}
+
+	action := &recordHookAction{done: make(chan string, 10)}
+	h, err := newHookRunner(&ConfigItem{Output: output, HookDebounce: "0s"}, action)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Stop()
+	e := &entry{id: "entry1", item: &ConfigItem{Output: output}, hooks: []*hookRunner{h}}
+	w := newOutputWriter(e, store)
+	defer w.Stop()
+
+	// the same output as applied is neither written nor the hooks run
+	w.Submit([]byte("applied"), "v1")
+	time.Sleep(300 * time.Millisecond)
+	if fi, err := os.Stat(output); err != nil || !fi.ModTime().Equal(past) {
+		t.Fatal("applied output should not be written again:", err)
+	}
+	select {
+	case v := <-action.done:
+		t.Fatal("hook should not run for the applied output:", v)
+	default:
+	}
+
+	// the later changes are written as usual
+	w.Submit([]byte("changed"), "v2")
+	waitFileContent(t, output, "changed")
+	select {
+	case v := <-action.done:
+		if v != "v2" {
+			t.Fatal("unexpected hook version:", v)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("hook not invoked")
+	}
+}
+
+func TestOutputWriterRestartModified(t *testing.T) {
+	dir := t.TempDir()
+	output := filepath.Join(dir, "output")
+	store, err := newStateStore(filepath.Join(dir, "state"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// applied but modified by others meanwhile
+	if err := os.WriteFile(output, []byte("modified"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.save("entry1", &entryState{Output: output, DesiredVersion: "v1", DesiredHash: outputHash([]byte("applied")), Applied: true}); err != nil {
+		t.Fatal(err)
+	}
+	e := &entry{id: "entry1", item: &ConfigItem{Output: output}}
+	w := newOutputWriter(e, store)
+	defer w.Stop()
+	w.Submit([]byte("applied"), "v1")
+	waitFileContent(t, output, "applied")
+}
+
+func TestOutputWriterStateWithoutOutput(t *testing.T) {
+	dir := t.TempDir()
+	store, err := newStateStore(filepath.Join(dir, "state"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := &entry{id: "entry1", item: &ConfigItem{Output: filepath.Join(dir, "missing", "output")}}
+	w := newOutputWriter(e, store)
+	defer w.Stop()
+
+	w.Submit([]byte("password=hunter2"), "v1")
+	data, err := os.ReadFile(store.file("entry1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "hunter2") || strings.Contains(string(data), base64.StdEncoding.EncodeToString([]byte("password=hunter2"))) {
+		t.Fatal("output should not be persisted in the state:", string(data))
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+// TemplateData declares a configuration referenced by the template.
+// The value is available in the template as {{ .<name> }} in raw string.
+type TemplateData struct {
+	Name  string `toml:"name"`
+	Group string `toml:"group"`
+	Key   string `toml:"key"`
+}
+
+var templateFuncs = template.FuncMap{
+	// json parses json string into maps, slices and values, e.g. {{ (json .upstreams).servers }}
+	"json": func(s string) (any, error) {
+		var v any
+		if err := json.Unmarshal([]byte(s), &v); err != nil {
+			return nil, err
+		}
+		return v, nil
+	},
+	// yaml parses yaml string into maps, slices and values, e.g. {{ (yaml .upstreams).servers }}
+	"yaml": func(s string) (any, error) {
+		var v any
+		if err := yaml.Unmarshal([]byte(s), &v); err != nil {
+			return nil, err
+		}
+		return v, nil
+	},
+}
+
+// templateRenderer renders the output of a template entry once all referenced configurations are loaded
+type templateRenderer struct {
+	tmpl *template.Template
+	size int
+
+	lock   sync.Mutex
+	values map[string]string
+}
+
+func newTemplateRenderer(item *ConfigItem) (*templateRenderer, error) {
+	if len(item.TemplateData) == 0 {
+		return nil, errors.New("template_data is required for template: " + item.Template)
+	}
+	names := map[string]struct{}{}
+	for _, v := range item.TemplateData {
+		if v.Name == "" {
+			return nil, errors.New("empty template_data name in template: " + item.Template)
+		}
+		if _, ok := names[v.Name]; ok {
+			return nil, errors.New("duplicated template_data name " + v.Name + " in template: " + item.Template)
+		}
+		names[v.Name] = struct{}{}
+	}
+	data, err := os.ReadFile(item.Template)
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := template.New(filepath.Base(item.Template)).Funcs(templateFuncs).Option("missingkey=error").Parse(string(data))
+	if err != nil {
+		return nil, err
+	}
+	return &templateRenderer{
+		tmpl:   tmpl,
+		size:   len(item.TemplateData),
+		values: map[string]string{},
+	}, nil
+}
+
+// Update saves the latest value of the configuration under all the names referencing it and renders the template.
+// False is returned if some of the referenced configurations are not loaded yet.
+func (t *templateRenderer) Update(names []string, value []byte) ([]byte, bool, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	for _, name := range names {
+		t.values[name] = string(value)
+	}
+	if len(t.values) < t.size {
+		return nil, false, nil
+	}
+	buf := new(bytes.Buffer)
+	if err := t.tmpl.Execute(buf, t.values); err != nil {
+		return nil, false, err
+	}
+	return buf.Bytes(), true, nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTemplateRenderer(t *testing.T) {
+	dir := t.TempDir()
+	tmplFile := filepath.Join(dir, "nginx.conf.tmpl")
+	tmpl := `upstream backend {
+{{- range (json .upstreams).servers }}
+    server {{ . }};
+{{- end }}
+}
+listen {{ (yaml .listen).port }};
+`
+	if err := os.WriteFile(tmplFile, []byte(tmpl), 0644); err != nil {
+		t.Fatal(err)
+	}
+	r, err := newTemplateRenderer(&ConfigItem{
+		Template: tmplFile,
+		TemplateData: []TemplateData{
+			{Name: "upstreams", Group: "group1", Key: "upstreams"},
+			{Name: "listen", Group: "group2", Key: "listen"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ready, err := r.Update([]string{"upstreams"}, []byte(`{"servers":["10.0.0.1:80","10.0.0.2:80"]}`)); err != nil {
+		t.Fatal(err)
+	} else if ready {
+		t.Fatal("should not render before all configurations are loaded")
+	}
+	out, ready, err := r.Update([]string{"listen"}, []byte("port: 8080\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ready {
+		t.Fatal("should render after all configurations are loaded")
+	}
+	expected := `upstream backend {
+    server 10.0.0.1:80;
+    server 10.0.0.2:80;
+}
+listen 8080;
+`
+	if string(out) != expected {
+		t.Fatal("unexpected output:", string(out))
+	}
+
+	// re-render on any change
+	out, ready, err = r.Update([]string{"upstreams"}, []byte(`{"servers":["10.0.0.3:80"]}`))
+	if err != nil || !ready {
+		t.Fatal("re-render failed:", err)
+	}
+	if string(out) != "upstream backend {\n    server 10.0.0.3:80;\n}\nlisten 8080;\n" {
+		t.Fatal("unexpected output:", string(out))
+	}
+
+	if _, _, err := r.Update([]string{"upstreams"}, []byte(`{"servers":`)); err == nil {
+		t.Fatal("invalid json should fail rendering")
+	}
+}
+
+func TestTemplateRendererInvalidData(t *testing.T) {
+	tmplFile := filepath.Join(t.TempDir(), "a.tmpl")
+	if err := os.WriteFile(tmplFile, []byte("{{ .a }}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newTemplateRenderer(&ConfigItem{Template: tmplFile}); err == nil {
+		t.Fatal("template_data should be required")
+	}
+	if _, err := newTemplateRenderer(&ConfigItem{Template: tmplFile, TemplateData: []TemplateData{
+		{Name: "a", Group: "g", Key: "k1"},
+		{Name: "a", Group: "g", Key: "k2"},
+	}}); err == nil {
+		t.Fatal("duplicated name should be rejected")
+	}
+}
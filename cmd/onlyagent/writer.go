@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// writeFileAtomic writes data to a temporary file in the same directory of the output, then renames it to the output.
+// Readers will observe either the previous or the new content, and a failed write leaves the previous content untouched.
+func writeFileAtomic(item *ConfigItem, data []byte) (rerr error) {
+	output := item.Output
+	mode, hasMode, err := item.FileMode()
+	if err != nil {
+		return err
+	}
+	if !hasMode {
+		mode = 0644
+		if fi, err := os.Stat(output); err == nil {
+			mode = fi.Mode().Perm()
+		}
+	}
+	uid, gid, err := lookupOwner(item.Owner, item.OwnerGroup)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(output)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(output)+".tmp*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	defer func() {
+		if rerr != nil {
+			_ = tmp.Close()
+			_ = os.Remove(tmpName)
+		}
+	}()
+
+	if _, err := tmp.Write(data); err != nil {
+		return err
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		return err
+	}
+	if uid != -1 || gid != -1 {
+		if err := tmp.Chown(uid, gid); err != nil {
+			return err
+		}
+	}
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if item.Backups > 0 {
+		if err := backupFile(output, item.Backups); err != nil {
+			return fmt.Errorf("backup previous output failed: %w", err)
+		}
+	}
+
+	if err := os.Rename(tmpName, output); err != nil {
+		return err
+	}
+	return syncDir(dir)
+}
+
+// backupFile keeps previous versions of the file as <file>.1 (the latest) to <file>.<count>
+func backupFile(file string, count int) error {
+	data, err := os.ReadFile(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	for i := count - 1; i >= 1; i-- {
+		from := fmt.Sprint(file, ".", i)
+		if err := os.Rename(from, fmt.Sprint(file, ".", i+1)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	mode := fs.FileMode(0644)
+	if fi, err := os.Stat(file); err == nil {
+		mode = fi.Mode().Perm()
+	}
+	return os.WriteFile(fmt.Sprint(file, ".1"), data, mode)
+}
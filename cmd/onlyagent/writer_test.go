@@ -0,0 +1,86 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "application.yaml")
+	item := &ConfigItem{
+		Output:  output,
+		Backups: 2,
+	}
+	for _, v := range []string{"v1", "v2", "v3", "v4"} {
+		if err := writeFileAtomic(item, []byte(v)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	assertFileContent(t, output, "v4")
+	assertFileContent(t, output+".1", "v3")
+	assertFileContent(t, output+".2", "v2")
+	if _, err := os.Stat(output + ".3"); !os.IsNotExist(err) {
+		t.Fatal("only 2 backups expected")
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(output))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Fatal("temporary files should be removed, entries:", len(entries))
+	}
+}
+
+func TestWriteFileAtomicMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permission bits are not supported on windows")
+	}
+	output := filepath.Join(t.TempDir(), "application.yaml")
+	if err := writeFileAtomic(&ConfigItem{Output: output, Mode: "0600"}, []byte("v1")); err != nil {
+		t.Fatal(err)
+	}
+	assertFileMode(t, output, 0600)
+	// keep permission of existing file
+	if err := writeFileAtomic(&ConfigItem{Output: output}, []byte("v2")); err != nil {
+		t.Fatal(err)
+	}
+	assertFileMode(t, output, 0600)
+
+	if err := writeFileAtomic(&ConfigItem{Output: output, Mode: "0999"}, []byte("v3")); err == nil {
+		t.Fatal("invalid mode should be rejected")
+	}
+	assertFileContent(t, output, "v2")
+}
+
+func TestWriteFileAtomicMissingDir(t *testing.T) {
+	dir := t.TempDir()
+	output := filepath.Join(dir, "missing", "application.yaml")
+	if err := writeFileAtomic(&ConfigItem{Output: output}, []byte("v1")); err == nil {
+		t.Fatal("write into missing directory should fail")
+	}
+}
+
+func assertFileContent(t *testing.T, file, expected string) {
+	t.Helper()
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != expected {
+		t.Fatal("unexpected content of", file, ":", string(data))
+	}
+}
+
+func assertFileMode(t *testing.T, file string, expected os.FileMode) {
+	t.Helper()
+	fi, err := os.Stat(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != expected {
+		t.Fatal("unexpected mode of", file, ":", fi.Mode().Perm())
+	}
+}
@@ -0,0 +1,175 @@
+package datapump
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/meidoworks/nekoq-component/configure/configapi"
+)
+
+// FetchKey identifies a configuration requested by the clients, the same as the unique key of the configuration table
+type FetchKey struct {
+	Selectors         string
+	OptionalSelectors string
+	Group             string
+	Key               string
+}
+
+// FetchStore saves the last fetch times of the configurations in unix milliseconds
+type FetchStore interface {
+	SaveFetches(fetches map[FetchKey]int64) error
+}
+
+// FetchRecorder records the last time the configurations are requested by the clients through the read server, and
+// saves them to the store periodically, so that the web manager is able to tell whether a configuration is still in use.
+type FetchRecorder struct {
+	store FetchStore
+
+	lock    sync.Mutex
+	pending map[FetchKey]int64
+
+	closeCh chan struct{}
+	doneCh  chan struct{}
+}
+
+// NewFetchRecorder starts a recorder saving the fetches every interval
+func NewFetchRecorder(store FetchStore, interval time.Duration) *FetchRecorder {
+	r := &FetchRecorder{
+		store:   store,
+		pending: map[FetchKey]int64{},
+		closeCh: make(chan struct{}),
+		doneCh:  make(chan struct{}),
+	}
+	go r.loop(interval)
+	return r
+}
+
+func (r *FetchRecorder) loop(interval time.Duration) {
+	defer close(r.doneCh)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.closeCh:
+			return
+		case <-ticker.C:
+		}
+		if err := r.Flush(); err != nil {
+			log.Println("[ERROR] save fetches failed:", err)
+		}
+	}
+}
+
+// Record records the fetch of the configuration at the time
+func (r *FetchRecorder) Record(key FetchKey, t time.Time) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.pending[key] = max(r.pending[key], t.UnixMilli())
+}
+
+// Flush saves the pending fetches, which are kept for the next flush if failed
+func (r *FetchRecorder) Flush() error {
+	r.lock.Lock()
+	fetches := r.pending
+	r.pending = map[FetchKey]int64{}
+	r.lock.Unlock()
+	if len(fetches) == 0 {
+		return nil
+	}
+	if err := r.store.SaveFetches(fetches); err != nil {
+		r.lock.Lock()
+		for k, v := range fetches {
+			r.pending[k] = max(r.pending[k], v)
+		}
+		r.lock.Unlock()
+		return err
+	}
+	return nil
+}
+
+// Stop stops the recorder and saves the pending fetches
+func (r *FetchRecorder) Stop() error {
+	close(r.closeCh)
+	<-r.doneCh
+	return r.Flush()
+}
+
+// Handler records the configurations served successfully by the read api of next
+func (r *FetchRecorder) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		keys := r.requestedKeys(req)
+		if len(keys) == 0 {
+			next.ServeHTTP(w, req)
+			return
+		}
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, req)
+		// not modified means the client is up-to-date with the configurations
+		if sw.status != http.StatusOK && sw.status != http.StatusNotModified {
+			return
+		}
+		now := time.Now()
+		for _, key := range keys {
+			r.Record(key, now)
+		}
+	})
+}
+
+// requestedKeys returns the configurations requested by the read api, the same as the routes of the configure server
+func (r *FetchRecorder) requestedKeys(req *http.Request) []FetchKey {
+	switch {
+	case req.Method == http.MethodPost && req.URL.Path == "/retrieving":
+		data, err := io.ReadAll(req.Body)
+		_ = req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(data))
+		if err != nil {
+			return nil
+		}
+		acquire := new(configapi.AcquireConfigurationReq)
+		if err := cbor.Unmarshal(data, acquire); err != nil {
+			return nil
+		}
+		configapi.SelectorsHelperCache(&acquire.Selectors)
+		configapi.SelectorsHelperCache(&acquire.OptionalSelectors)
+		selectors := configapi.SelectorsHelperCacheValue(&acquire.Selectors)
+		optSelectors := configapi.SelectorsHelperCacheValue(&acquire.OptionalSelectors)
+		var keys []FetchKey
+		for _, v := range acquire.Requested {
+			keys = append(keys, FetchKey{Selectors: selectors, OptionalSelectors: optSelectors, Group: v.Group, Key: v.Key})
+		}
+		return keys
+	case req.Method == http.MethodGet && strings.HasPrefix(req.URL.Path, "/configure/"):
+		parts := strings.Split(strings.TrimPrefix(req.URL.Path, "/configure/"), "/")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil
+		}
+		return []FetchKey{{
+			Selectors:         strings.TrimSpace(req.Header.Get("X-Configuration-Sel")),
+			OptionalSelectors: strings.TrimSpace(req.Header.Get("X-Configuration-Opt-Sel")),
+			Group:             parts[0],
+			Key:               parts[1],
+		}}
+	default:
+		return nil
+	}
+}
+
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusWriter) WriteHeader(statusCode int) {
+	s.status = statusCode
+	s.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (s *statusWriter) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
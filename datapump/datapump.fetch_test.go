@@ -0,0 +1,107 @@
+package datapump
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/meidoworks/nekoq-component/configure/configapi"
+)
+
+// testFetchStore keeps the saved fetches, fails the saving if err is set
+type testFetchStore struct {
+	saved map[FetchKey]int64
+	err   error
+}
+
+func (s *testFetchStore) SaveFetches(fetches map[FetchKey]int64) error {
+	if s.err != nil {
+		return s.err
+	}
+	for k, v := range fetches {
+		s.saved[k] = v
+	}
+	return nil
+}
+
+func TestFetchRecorder(t *testing.T) {
+	store := &testFetchStore{saved: map[FetchKey]int64{}}
+	recorder := NewFetchRecorder(store, time.Hour)
+	// the configure server responds 404 for the unknown configurations and consumes the body
+	srv := httptest.NewServer(recorder.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		req := new(configapi.AcquireConfigurationReq)
+		if r.URL.Path == "/retrieving" {
+			if err := cbor.Unmarshal(data, req); err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+		}
+		if len(req.Requested) > 0 && req.Requested[0].Group == "unknown" || r.URL.Path == "/configure/unknown/k1" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Header.Get("X-Test-Not-Modified") != "" {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	})))
+	defer srv.Close()
+
+	retrieve := func(group string, notModified bool) {
+		data, _ := cbor.Marshal(&configapi.AcquireConfigurationReq{
+			Requested: []configapi.RequestedConfigurationKey{{Group: group, Key: "k1"}, {Group: group, Key: "k2"}},
+			Selectors: configapi.Selectors{Data: map[string]string{"env": "PROD", "app": "app1"}},
+		})
+		req, _ := http.NewRequest(http.MethodPost, srv.URL+"/retrieving", bytes.NewReader(data))
+		if notModified {
+			req.Header.Set("X-Test-Not-Modified", "true")
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+	}
+	retrieve("db", true)
+	retrieve("unknown", false)
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/configure/web/k1", nil)
+	req.Header.Set("X-Configuration-Sel", "app=app1,env=PROD")
+	if resp, err := http.DefaultClient.Do(req); err != nil {
+		t.Fatal(err)
+	} else {
+		_ = resp.Body.Close()
+	}
+	if resp, err := http.Get(srv.URL + "/configure/unknown/k1"); err != nil {
+		t.Fatal(err)
+	} else {
+		_ = resp.Body.Close()
+	}
+
+	store.err = errors.New("failed")
+	if err := recorder.Flush(); err == nil {
+		t.Fatal("flush should fail")
+	}
+	store.err = nil
+	if err := recorder.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.saved) != 3 {
+		t.Fatal("only the served configurations should be recorded:", store.saved)
+	}
+	for _, key := range []FetchKey{
+		{Selectors: "app=app1,env=PROD", Group: "db", Key: "k1"},
+		{Selectors: "app=app1,env=PROD", Group: "db", Key: "k2"},
+		{Selectors: "app=app1,env=PROD", Group: "web", Key: "k1"},
+	} {
+		if store.saved[key] == 0 {
+			t.Fatal("fetch not recorded:", key)
+		}
+	}
+}
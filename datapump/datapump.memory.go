@@ -0,0 +1,158 @@
+package datapump
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/meidoworks/nekoq-component/configure/configapi"
+)
+
+// MemoryDataPump keeps configurations in memory and pushes every change to the server immediately.
+// It is used for testing and local development without database.
+type MemoryDataPump struct {
+	lock     sync.Mutex
+	seq      int64
+	data     map[string]*configapi.Configuration
+	eventSeq int64
+
+	// the events are sent in the order of eventSeq assigned under lock, while lock is not held when sending
+	sendLock sync.Mutex
+	sendCond *sync.Cond
+	sentSeq  int64
+	eventCh  chan configapi.Event
+}
+
+func NewMemoryDataPump() *MemoryDataPump {
+	m := &MemoryDataPump{
+		data:    map[string]*configapi.Configuration{},
+		eventCh: make(chan configapi.Event, 1024),
+	}
+	m.sendCond = sync.NewCond(&m.sendLock)
+	return m
+}
+
+func (m *MemoryDataPump) Startup() error {
+	return nil
+}
+
+func (m *MemoryDataPump) Stop() error {
+	return nil
+}
+
+func (m *MemoryDataPump) EventChannel() <-chan configapi.Event {
+	return m.eventCh
+}
+
+func (m *MemoryDataPump) TriggerDumpToChannel() <-chan configapi.Event {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	ch := make(chan configapi.Event, len(m.data))
+	for _, cfg := range m.data {
+		ch <- configapi.Event{
+			Configuration: cfg,
+			Created:       true,
+		}
+	}
+	close(ch)
+	return ch
+}
+
+// Save creates or updates the configuration with a new version and returns the saved configuration
+func (m *MemoryDataPump) Save(selectors, optionalSelectors, group, key string, value []byte) (*configapi.Configuration, error) {
+	sel, optSel, err := fillSelectors(selectors, optionalSelectors)
+	if err != nil {
+		return nil, err
+	}
+
+	m.lock.Lock()
+	m.seq++
+	cfg := &configapi.Configuration{
+		Group:             group,
+		Key:               key,
+		Version:           fmt.Sprintf("v%016x", m.seq),
+		Value:             append([]byte(nil), value...),
+		Selectors:         sel,
+		OptionalSelectors: optSel,
+		Timestamp:         time.Now().Unix(),
+	}
+	cfg.Signature = cfg.GenerateSignature()
+	k := m.dataKey(cfg)
+	_, exists := m.data[k]
+	m.data[k] = cfg
+	m.eventSeq++
+	seq := m.eventSeq
+	m.lock.Unlock()
+	m.sendEvent(seq, configapi.Event{
+		Configuration: cfg,
+		Created:       !exists,
+		Modified:      exists,
+	})
+	return cfg, nil
+}
+
+// Delete removes the configuration and returns whether the configuration exists
+func (m *MemoryDataPump) Delete(selectors, optionalSelectors, group, key string) (bool, error) {
+	sel, optSel, err := fillSelectors(selectors, optionalSelectors)
+	if err != nil {
+		return false, err
+	}
+
+	m.lock.Lock()
+	k := m.dataKey(&configapi.Configuration{
+		Group:             group,
+		Key:               key,
+		Selectors:         sel,
+		OptionalSelectors: optSel,
+	})
+	cfg, ok := m.data[k]
+	if !ok {
+		m.lock.Unlock()
+		return false, nil
+	}
+	delete(m.data, k)
+	m.eventSeq++
+	seq := m.eventSeq
+	m.lock.Unlock()
+	m.sendEvent(seq, configapi.Event{
+		Configuration: cfg,
+		Deleted:       true,
+	})
+	return true, nil
+}
+
+// sendEvent sends the event after the events of the previous sequences, lock should not be held.
+// A full event channel blocks the changes waiting to send only, while the data is still able to change and dump.
+func (m *MemoryDataPump) sendEvent(seq int64, ev configapi.Event) {
+	m.sendLock.Lock()
+	for m.sentSeq != seq-1 {
+		m.sendCond.Wait()
+	}
+	m.sendLock.Unlock()
+
+	m.eventCh <- ev
+
+	m.sendLock.Lock()
+	m.sentSeq = seq
+	m.sendCond.Broadcast()
+	m.sendLock.Unlock()
+}
+
+func (m *MemoryDataPump) dataKey(cfg *configapi.Configuration) string {
+	return fmt.Sprint(configapi.SelectorsHelperCacheValue(&cfg.Selectors), "||", configapi.SelectorsHelperCacheValue(&cfg.OptionalSelectors), "||", cfg.Group, "||", cfg.Key)
+}
+
+func fillSelectors(selectors, optionalSelectors string) (configapi.Selectors, configapi.Selectors, error) {
+	var sel, optSel configapi.Selectors
+	if err := sel.Fill(selectors); err != nil {
+		return sel, optSel, err
+	}
+	if len(sel.Data) == 0 {
+		return sel, optSel, errors.New("selectors should not be empty")
+	}
+	if err := optSel.Fill(optionalSelectors); err != nil {
+		return sel, optSel, err
+	}
+	return sel, optSel, nil
+}
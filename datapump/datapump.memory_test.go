@@ -0,0 +1,80 @@
+package datapump
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestMemoryDataPumpFullEventChannel(t *testing.T) {
+	m := NewMemoryDataPump()
+	for i := 0; i < cap(m.eventCh); i++ {
+		if _, err := m.Save("dc=dc1", "", "group1", fmt.Sprint("key", i), []byte("value")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the event channel is full, both saves are blocked until the events are consumed
+	saved := make(chan struct{}, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			if _, err := m.Save("dc=dc1", "", "group1", "key_blocked", []byte("value")); err != nil {
+				t.Error(err)
+			}
+			saved <- struct{}{}
+		}()
+	}
+	// wait for both saves changing the data
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		m.lock.Lock()
+		eventSeq := m.eventSeq
+		m.lock.Unlock()
+		if eventSeq == int64(cap(m.eventCh))+2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("saves should not be blocked before sending")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	dumped := make(chan int)
+	go func() {
+		cnt := 0
+		for range m.TriggerDumpToChannel() {
+			cnt++
+		}
+		dumped <- cnt
+	}()
+	select {
+	case cnt := <-dumped:
+		if cnt != cap(m.eventCh)+1 {
+			t.Fatal("unexpected dumped configurations:", cnt)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("dumping should not be blocked by the full event channel")
+	}
+
+	// the events are delivered in the order of the changes
+	var versions []string
+	for i := 0; i < cap(m.eventCh)+2; i++ {
+		select {
+		case ev := <-m.EventChannel():
+			if ev.Configuration.Key == "key_blocked" {
+				versions = append(versions, ev.Configuration.Version)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("events should be delivered once consumed")
+		}
+	}
+	if len(versions) != 2 || versions[0] >= versions[1] {
+		t.Fatal("unexpected order of the events:", versions)
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case <-saved:
+		case <-time.After(2 * time.Second):
+			t.Fatal("save should be unblocked once the events are consumed")
+		}
+	}
+}
@@ -1,7 +1,14 @@
 package datapump
 
 import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/meidoworks/nekoq-component/configure/cfgimpl"
+	"github.com/meidoworks/nekoq-component/configure/configapi"
 )
 
 type PostgresDataPump = cfgimpl.DatabaseDataPump
@@ -9,3 +16,67 @@ type PostgresDataPump = cfgimpl.DatabaseDataPump
 func NewPostgresDataPump(connStr string) *PostgresDataPump {
 	return cfgimpl.NewDatabaseDataPump(connStr)
 }
+
+// PostgresSecretIndex looks up the cfg_secret flag of the configuration table written by the web manager
+type PostgresSecretIndex struct {
+	p *pgxpool.Pool
+}
+
+func NewPostgresSecretIndex(connStr string) (*PostgresSecretIndex, error) {
+	p, err := pgxpool.New(context.Background(), connStr)
+	if err != nil {
+		return nil, err
+	}
+	return &PostgresSecretIndex{p: p}, nil
+}
+
+func (i *PostgresSecretIndex) IsSecret(cfg *configapi.Configuration) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var secret bool
+	var version string
+	if err := i.p.QueryRow(ctx, "select cfg_secret, cfg_version from configuration where selectors = $1 and optional_selectors = $2 and cfg_group = $3 and cfg_key = $4",
+		configapi.SelectorsHelperCacheValue(&cfg.Selectors), configapi.SelectorsHelperCacheValue(&cfg.OptionalSelectors), cfg.Group, cfg.Key).Scan(&secret, &version); errors.Is(err, pgx.ErrNoRows) {
+		return false, ErrStaleConfiguration
+	} else if err != nil {
+		return false, err
+	}
+	if version != cfg.Version {
+		return false, ErrStaleConfiguration
+	}
+	return secret, nil
+}
+
+func (i *PostgresSecretIndex) Close() {
+	i.p.Close()
+}
+
+// PostgresFetchStore saves the last fetch times to the configuration_fetch table read by the web manager
+type PostgresFetchStore struct {
+	p *pgxpool.Pool
+}
+
+func NewPostgresFetchStore(connStr string) (*PostgresFetchStore, error) {
+	p, err := pgxpool.New(context.Background(), connStr)
+	if err != nil {
+		return nil, err
+	}
+	return &PostgresFetchStore{p: p}, nil
+}
+
+func (s *PostgresFetchStore) SaveFetches(fetches map[FetchKey]int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	batch := new(pgx.Batch)
+	for k, t := range fetches {
+		// several read servers may save the same configuration
+		batch.Queue("insert into configuration_fetch (selectors, optional_selectors, cfg_group, cfg_key, time_fetched) values ($1, $2, $3, $4, $5) "+
+			"on conflict (selectors, optional_selectors, cfg_group, cfg_key) do update set time_fetched = greatest(configuration_fetch.time_fetched, excluded.time_fetched)",
+			k.Selectors, k.OptionalSelectors, k.Group, k.Key, t)
+	}
+	return s.p.SendBatch(ctx, batch).Close()
+}
+
+func (s *PostgresFetchStore) Close() {
+	s.p.Close()
+}
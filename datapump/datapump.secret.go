@@ -0,0 +1,136 @@
+package datapump
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/meidoworks/nekoq-component/configure/configapi"
+
+	"github.com/goodplayer/onlyconfig/secrets"
+)
+
+// ErrStaleConfiguration is returned by SecretIndex if the configuration has been changed to another version
+var ErrStaleConfiguration = errors.New("configuration changed")
+
+// SecretIndex looks up whether the configuration is flagged secret
+type SecretIndex interface {
+	// IsSecret returns ErrStaleConfiguration if the version of the configuration is no longer the current one
+	IsSecret(cfg *configapi.Configuration) (bool, error)
+}
+
+// SecretDataPump decrypts the values of secret configurations pumped by the underlying data pump,
+// so that the clients receive the plaintext while only the envelopes are stored in the database.
+// Only the configurations flagged secret by the index are decrypted, a plain value looking like an envelope is served
+// as is. A secret configuration failing to decrypt, e.g. without the master key, is skipped instead of serving the
+// envelope. The values sealed for the recipient key of the application are served as is, which only the clients are
+// able to open.
+type SecretDataPump struct {
+	pump    configapi.DataPump
+	keys    secrets.KeyProvider
+	index   SecretIndex
+	eventCh chan configapi.Event
+	closeCh chan struct{}
+}
+
+// NewSecretDataPump wraps the data pump. keys and index can be nil if there is no secret configuration.
+func NewSecretDataPump(pump configapi.DataPump, keys secrets.KeyProvider, index SecretIndex) *SecretDataPump {
+	return &SecretDataPump{
+		pump:    pump,
+		keys:    keys,
+		index:   index,
+		eventCh: make(chan configapi.Event, 1024),
+		closeCh: make(chan struct{}),
+	}
+}
+
+func (s *SecretDataPump) Startup() error {
+	if err := s.pump.Startup(); err != nil {
+		return err
+	}
+	go s.forward()
+	return nil
+}
+
+func (s *SecretDataPump) Stop() error {
+	close(s.closeCh)
+	return s.pump.Stop()
+}
+
+func (s *SecretDataPump) EventChannel() <-chan configapi.Event {
+	return s.eventCh
+}
+
+func (s *SecretDataPump) TriggerDumpToChannel() <-chan configapi.Event {
+	in := s.pump.TriggerDumpToChannel()
+	out := make(chan configapi.Event, cap(in))
+	go func() {
+		defer close(out)
+		for ev := range in {
+			if ev, ok := s.decrypt(ev); ok {
+				out <- ev
+			}
+		}
+	}()
+	return out
+}
+
+func (s *SecretDataPump) forward() {
+	in := s.pump.EventChannel()
+	for {
+		select {
+		case <-s.closeCh:
+			return
+		case ev, ok := <-in:
+			if !ok {
+				return
+			}
+			if ev, ok := s.decrypt(ev); ok {
+				s.eventCh <- ev
+			}
+		}
+	}
+}
+
+// decrypt replaces the envelope with the plaintext and its signature, returns false if failed
+func (s *SecretDataPump) decrypt(ev configapi.Event) (configapi.Event, bool) {
+	// the values of the secret configurations are always envelopes or sealed, so the others are never looked up
+	if ev.Deleted || ev.Configuration == nil || s.index == nil || !secrets.IsEncrypted(string(ev.Configuration.Value)) {
+		return ev, true
+	}
+	if secret, ok := s.isSecret(ev.Configuration); !ok {
+		return ev, false
+	} else if !secret {
+		return ev, true
+	}
+	plaintext, err := secrets.Decrypt(s.keys, string(ev.Configuration.Value))
+	if err != nil {
+		log.Println("[ERROR] decrypt secret configuration failed:", ev.Configuration.Group, ev.Configuration.Key, ev.Configuration.Version, err)
+		return ev, false
+	}
+	cfg := *ev.Configuration
+	cfg.Value = plaintext
+	cfg.Signature = cfg.GenerateSignature()
+	ev.Configuration = &cfg
+	return ev, true
+}
+
+// isSecret looks up the index until succeeded, returns false if the configuration is stale or the pump is stopped
+func (s *SecretDataPump) isSecret(cfg *configapi.Configuration) (secret bool, ok bool) {
+	for {
+		secret, err := s.index.IsSecret(cfg)
+		if err == nil {
+			return secret, true
+		}
+		if errors.Is(err, ErrStaleConfiguration) {
+			// the event of the newer version follows
+			return false, false
+		}
+		log.Println("[ERROR] look up secret configuration failed, retry later:", cfg.Group, cfg.Key, cfg.Version, err)
+		select {
+		case <-s.closeCh:
+			return false, false
+		case <-time.After(time.Second):
+		}
+	}
+}
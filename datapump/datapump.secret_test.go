@@ -0,0 +1,135 @@
+package datapump
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/meidoworks/nekoq-component/configure/configapi"
+
+	"github.com/goodplayer/onlyconfig/secrets"
+)
+
+// testSecretIndex flags the configurations by key
+type testSecretIndex map[string]bool
+
+func (i testSecretIndex) IsSecret(cfg *configapi.Configuration) (bool, error) {
+	return i[cfg.Key], nil
+}
+
+func TestSecretDataPump(t *testing.T) {
+	keys, err := secrets.NewLocalKeyProvider(bytes.Repeat([]byte{1}, secrets.MasterKeySize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _ := secrets.NewLocalKeyProvider(bytes.Repeat([]byte{2}, secrets.MasterKeySize))
+	envelope, err := secrets.Encrypt(keys, []byte("hunter2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	unknown, _ := secrets.Encrypt(other, []byte("x"))
+	_, publicKey, _ := secrets.GenerateRecipientKey()
+	sealed, err := secrets.SealFor(publicKey, []byte("s3cret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mem := NewMemoryDataPump()
+	pump := NewSecretDataPump(mem, keys, testSecretIndex{"password": true, "unknown": true, "token": true})
+	if _, err := mem.Save("app=app1", "", "db", "host", []byte("127.0.0.1")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mem.Save("app=app1", "", "db", "password", []byte(envelope)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mem.Save("app=app1", "", "db", "unknown", []byte(unknown)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mem.Save("app=app1", "", "db", "token", []byte(sealed)); err != nil {
+		t.Fatal(err)
+	}
+	// not flagged secret, served as is even if looks like an envelope
+	plain := secrets.EnvelopePrefix + "plain"
+	if _, err := mem.Save("app=app1", "", "db", "plain", []byte(plain)); err != nil {
+		t.Fatal(err)
+	}
+	values := map[string]string{}
+	for ev := range pump.TriggerDumpToChannel() {
+		if !ev.Configuration.ValidateSignature() {
+			t.Fatal("signature should match the plaintext:", ev.Configuration.Key)
+		}
+		values[ev.Configuration.Key] = string(ev.Configuration.Value)
+	}
+	if len(values) != 4 || values["host"] != "127.0.0.1" || values["password"] != "hunter2" || values["token"] != sealed || values["plain"] != plain {
+		t.Fatal("unexpected dump:", values)
+	}
+
+	// drain the events of the saves above
+	for len(mem.EventChannel()) > 0 {
+		<-mem.EventChannel()
+	}
+	if err := pump.Startup(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = pump.Stop()
+	}()
+	if _, err := mem.Save("app=app1", "", "db", "unknown", []byte(unknown)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mem.Save("app=app1", "", "db", "password", []byte(envelope)); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case ev := <-pump.EventChannel():
+		if ev.Configuration.Key != "password" || string(ev.Configuration.Value) != "hunter2" || !ev.Configuration.ValidateSignature() {
+			t.Fatal("unexpected event:", ev.Configuration.Key, string(ev.Configuration.Value))
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for the event")
+	}
+	if _, err := mem.Delete("app=app1", "", "db", "password"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case ev := <-pump.EventChannel():
+		if !ev.Deleted {
+			t.Fatal("unexpected event:", ev)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for the event")
+	}
+}
+
+// staleSecretIndex reports every configuration changed meanwhile
+type staleSecretIndex struct{}
+
+func (staleSecretIndex) IsSecret(cfg *configapi.Configuration) (bool, error) {
+	return false, ErrStaleConfiguration
+}
+
+func TestSecretDataPumpStale(t *testing.T) {
+	keys, err := secrets.NewLocalKeyProvider(bytes.Repeat([]byte{1}, secrets.MasterKeySize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	envelope, err := secrets.Encrypt(keys, []byte("hunter2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mem := NewMemoryDataPump()
+	if _, err := mem.Save("app=app1", "", "db", "password", []byte(envelope)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mem.Save("app=app1", "", "db", "host", []byte("127.0.0.1")); err != nil {
+		t.Fatal(err)
+	}
+	// the stale envelope is skipped, the newer version follows
+	var dumped []string
+	for ev := range NewSecretDataPump(mem, keys, staleSecretIndex{}).TriggerDumpToChannel() {
+		dumped = append(dumped, ev.Configuration.Key)
+	}
+	if len(dumped) != 1 || dumped[0] != "host" {
+		t.Fatal("unexpected dump:", dumped)
+	}
+}
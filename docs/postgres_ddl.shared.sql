@@ -12,6 +12,7 @@ create table configuration
     time_created       bigint        not null,
     time_updated       bigint        not null,
     sequence           bigint        not null,
+    cfg_secret         boolean       not null default false,
     primary key (cfg_id)
 );
 
@@ -23,6 +24,20 @@ create index on configuration (cfg_group);
 
 comment on column configuration.cfg_status is '0-valid, 1-deleted';
 
+comment on column configuration.cfg_secret is 'the value is the envelope decrypted by the read server, or sealed for the recipient key of the application';
+
 comment on column configuration.sequence is 'values generated by cfg_seq on every data change to support incremental queries';
 
 create sequence cfg_seq increment by 16 minvalue 1 maxvalue 9223372036854775807 start 1 cache 1 no cycle;
+
+create table configuration_fetch
+(
+    selectors          varchar(1000) not null,
+    optional_selectors varchar(1000) not null,
+    cfg_group          varchar(200)  not null,
+    cfg_key            varchar(200)  not null,
+    time_fetched       bigint        not null,
+    primary key (selectors, optional_selectors, cfg_group, cfg_key)
+);
+
+comment on table configuration_fetch is 'the last time the configurations are requested by the clients, saved by the read servers';
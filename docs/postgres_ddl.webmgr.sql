@@ -47,17 +47,20 @@ VALUES ('DEV', 'production environment', EXTRACT(EPOCH FROM CURRENT_TIMESTAMP) *
 
 create table onlyconfig_application
 (
-    application_id          bigserial not null,
-    application_name        varchar   not null,
-    application_description varchar   not null,
-    application_owner_org   varchar   not null,
-    time_created            bigint    not null,
-    time_updated            bigint    not null,
+    application_id            bigserial not null,
+    application_name          varchar   not null,
+    application_description   varchar   not null,
+    application_owner_org     varchar   not null,
+    application_recipient_key varchar   not null default '',
+    time_created              bigint    not null,
+    time_updated              bigint    not null,
     primary key (application_id)
 );
 
 create unique index on onlyconfig_application (application_name);
 
+comment on column onlyconfig_application.application_recipient_key is 'X25519 public key in hex the secret configs are sealed for before pushed to the clients, empty if not sealed';
+
 create table onlyconfig_application_detail
 (
     application_detail_id bigserial not null,
@@ -121,6 +124,10 @@ create table onlyconfig_config
     config_status       bigint    not null,
     time_created        bigint    not null,
     time_updated        bigint    not null,
+    draft_content_type  varchar   not null default '',
+    draft_content       varchar   not null default '',
+    time_drafted        bigint    not null default 0,
+    config_secret       boolean   not null default false,
     primary key (config_id)
 );
 
@@ -132,6 +139,185 @@ comment on column onlyconfig_config.config_content_type is 'general:"no specific
 
 comment on column onlyconfig_config.config_status is '0-normal, 1-deleted';
 
+comment on column onlyconfig_config.config_secret is 'contents of the config, its draft, history and schedule items are encrypted by the master key';
+
+comment on column onlyconfig_config.time_drafted is '0-no draft, otherwise the time the draft saved';
+
+-- Versions of the configurations, one row per published version
+create table onlyconfig_config_history
+(
+    history_id          bigserial not null,
+    config_id           bigint    not null,
+    config_version      varchar   not null,
+    config_content_type varchar   not null,
+    config_content      varchar   not null,
+    release_id          bigint    not null default 0,
+    time_created        bigint    not null,
+    config_secret       boolean   not null default false,
+    primary key (history_id)
+);
+
+create unique index on onlyconfig_config_history (config_id, config_version);
+
+comment on column onlyconfig_config_history.release_id is '0-published alone, otherwise the release publishing the version';
+
+comment on column onlyconfig_config_history.config_secret is 'config_content is encrypted by the master key';
+
+-- Configurations of an env and dc of the application published together, sharing the same version
+create table onlyconfig_release
+(
+    release_id      bigserial not null,
+    application_id  bigint    not null,
+    env_name        varchar   not null,
+    datacenter_name varchar   not null,
+    release_type    varchar   not null,
+    release_version varchar   not null,
+    rollback_of     bigint    not null default 0,
+    rolled_back_by  bigint    not null default 0,
+    time_created    bigint    not null,
+    time_updated    bigint    not null,
+    primary key (release_id)
+);
+
+create index on onlyconfig_release (application_id, env_name, datacenter_name);
+
+comment on column onlyconfig_release.release_type is 'publish:"publish the drafts", rollback:"restore the versions before the release rollback_of", scheduled:"publish by a schedule"';
+
+create table onlyconfig_release_item
+(
+    release_item_id  bigserial not null,
+    release_id       bigint    not null,
+    config_id        bigint    not null,
+    config_namespace varchar   not null,
+    config_key       varchar   not null,
+    from_version     varchar   not null,
+    to_version       varchar   not null,
+    primary key (release_item_id)
+);
+
+create index on onlyconfig_release_item (release_id);
+
+-- Releases scheduled to publish at a time, optionally reverted after a duration
+-- The contents are taken from the drafts when scheduled
+create table onlyconfig_schedule
+(
+    schedule_id       bigserial not null,
+    application_id    bigint    not null,
+    env_name          varchar   not null,
+    datacenter_name   varchar   not null,
+    status            varchar   not null,
+    time_publish      bigint    not null,
+    revert_after      bigint    not null default 0,
+    time_revert       bigint    not null default 0,
+    release_id        bigint    not null default 0,
+    revert_release_id bigint    not null default 0,
+    message           varchar   not null default '',
+    time_created      bigint    not null,
+    time_updated      bigint    not null,
+    primary key (schedule_id)
+);
+
+create index on onlyconfig_schedule (application_id, env_name, datacenter_name);
+create index on onlyconfig_schedule (status);
+
+comment on column onlyconfig_schedule.status is 'pending, published, reverted, failed or canceled';
+comment on column onlyconfig_schedule.revert_after is 'milliseconds to revert after published, 0-no revert';
+comment on column onlyconfig_schedule.time_revert is 'time to revert, 0-no revert pending';
+comment on column onlyconfig_schedule.message is 'reason of the failure';
+
+create table onlyconfig_schedule_item
+(
+    schedule_item_id    bigserial not null,
+    schedule_id         bigint    not null,
+    config_id           bigint    not null,
+    config_namespace    varchar   not null,
+    config_key          varchar   not null,
+    from_version        varchar   not null,
+    config_content_type varchar   not null,
+    config_content      varchar   not null,
+    config_secret       boolean   not null default false,
+    primary key (schedule_item_id)
+);
+
+create index on onlyconfig_schedule_item (schedule_id);
+
+comment on column onlyconfig_schedule_item.config_secret is 'config_content is encrypted by the master key';
+
+-- Webhooks notified of the configuration changes
+create table onlyconfig_webhook
+(
+    webhook_id          bigserial not null,
+    org_id              varchar   not null default '',
+    application_id      bigint    not null default 0,
+    webhook_url         varchar   not null,
+    webhook_secret      varchar   not null,
+    webhook_description varchar   not null default '',
+    time_created        bigint    not null,
+    time_updated        bigint    not null,
+    primary key (webhook_id)
+);
+
+create index on onlyconfig_webhook (application_id);
+create index on onlyconfig_webhook (org_id);
+
+comment on column onlyconfig_webhook.org_id is 'subscribe all the applications of the organization if application_id is 0';
+comment on column onlyconfig_webhook.webhook_secret is 'key of the HMAC-SHA256 signature of the payload';
+
+-- Queue of the webhook payloads and the log of delivering them
+create table onlyconfig_webhook_delivery
+(
+    delivery_id       bigserial not null,
+    webhook_id        bigint    not null,
+    event             varchar   not null,
+    payload           varchar   not null,
+    status            varchar   not null,
+    attempts          int       not null default 0,
+    time_next_attempt bigint    not null,
+    response_status   int       not null default 0,
+    message           varchar   not null default '',
+    time_created      bigint    not null,
+    time_updated      bigint    not null,
+    primary key (delivery_id)
+);
+
+create index on onlyconfig_webhook_delivery (webhook_id);
+create index on onlyconfig_webhook_delivery (status, time_next_attempt);
+
+comment on column onlyconfig_webhook_delivery.status is 'pending, succeeded or failed';
+comment on column onlyconfig_webhook_delivery.response_status is 'http status of the last attempt, 0-no response';
+comment on column onlyconfig_webhook_delivery.message is 'error of the last failed attempt';
+
+-- Audit log of revealing the contents of the secret configs
+create table onlyconfig_secret_reveal
+(
+    audit_id       bigserial not null,
+    config_id      bigint    not null,
+    application_id bigint    not null,
+    username       varchar   not null,
+    reveal_action  varchar   not null,
+    time_created   bigint    not null,
+    primary key (audit_id)
+);
+
+create index on onlyconfig_secret_reveal (config_id);
+
+comment on column onlyconfig_secret_reveal.reveal_action is 'view, draft, history, diff or export';
+
+-- Offline applications, datacenters and namespaces waiting for deletion
+-- Offline entities are hidden and the changes on them are rejected
+create table onlyconfig_decommission
+(
+    entity_type  varchar not null,
+    entity_name  varchar not null,
+    time_created bigint  not null,
+    time_updated bigint  not null,
+    primary key (entity_type, entity_name)
+);
+
+comment on column onlyconfig_decommission.entity_type is 'app:"application name", dc:"datacenter name", ns:"namespace name"';
+
+comment on column onlyconfig_decommission.time_created is 'time of marking as offline, the grace period of deletion starts from it';
+
 -- ---------------------------------------------------------------------------------------
 -- user related metadata
 -- ---------------------------------------------------------------------------------------
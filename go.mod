@@ -12,8 +12,10 @@ require (
 	github.com/golang-jwt/jwt/v5 v5.2.1
 	github.com/google/uuid v1.6.0
 	github.com/jackc/pgx/v5 v5.7.1
+	github.com/magiconair/properties v1.8.10
 	github.com/meidoworks/nekoq-component v0.10.14
 	golang.org/x/crypto v0.29.0
+	gopkg.in/yaml.v3 v3.0.1
 	xorm.io/xorm v1.3.9
 )
 
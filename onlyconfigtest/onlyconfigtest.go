@@ -0,0 +1,104 @@
+// Package onlyconfigtest provides an in-process OnlyConfig server backed by memory for testing clients and agents
+// without database.
+package onlyconfigtest
+
+import (
+	"net"
+
+	"github.com/meidoworks/nekoq-component/configure/configserver"
+
+	"github.com/goodplayer/onlyconfig/datapump"
+)
+
+// Server is an OnlyConfig read server listening on a random local port
+type Server struct {
+	addr   string
+	pump   *datapump.MemoryDataPump
+	server *configserver.ConfigureServer
+}
+
+// TB is the subset of testing.TB used by the server
+type TB interface {
+	Helper()
+	Fatal(args ...any)
+	Cleanup(func())
+}
+
+// NewServer starts a server and stops it when the test finishes
+func NewServer(t TB) *Server {
+	t.Helper()
+	s, err := StartServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = s.Close()
+	})
+	return s
+}
+
+// StartServer starts a server. The server should be stopped by Close.
+func StartServer() (*Server, error) {
+	addr, err := freeLocalAddr()
+	if err != nil {
+		return nil, err
+	}
+	pump := datapump.NewMemoryDataPump()
+	server := configserver.NewConfigureServer(configserver.ConfigureOptions{
+		Addr:                 addr,
+		MaxWaitTimeForUpdate: 60,
+		DataPump:             pump,
+	})
+	if err := server.Startup(); err != nil {
+		return nil, err
+	}
+	return &Server{
+		addr:   addr,
+		pump:   pump,
+		server: server,
+	}, nil
+}
+
+// URL returns the server address to be used in the server list of clients
+func (s *Server) URL() string {
+	return "http://" + s.addr
+}
+
+// Set creates or updates the configuration matched by the selectors string, e.g. "app=app1,dc=dc1,env=DEV".
+// The waiting clients are notified immediately.
+func (s *Server) Set(selectors, group, key string, value []byte) error {
+	return s.SetWithOptionalSelectors(selectors, "", group, key, value)
+}
+
+// SetWithOptionalSelectors is the same as Set with optional selectors string provided
+func (s *Server) SetWithOptionalSelectors(selectors, optionalSelectors, group, key string, value []byte) error {
+	_, err := s.pump.Save(selectors, optionalSelectors, group, key, value)
+	return err
+}
+
+// Delete removes the configuration matched by the selectors string and returns whether the configuration exists
+func (s *Server) Delete(selectors, group, key string) (bool, error) {
+	return s.DeleteWithOptionalSelectors(selectors, "", group, key)
+}
+
+// DeleteWithOptionalSelectors is the same as Delete with optional selectors string provided
+func (s *Server) DeleteWithOptionalSelectors(selectors, optionalSelectors, group, key string) (bool, error) {
+	return s.pump.Delete(selectors, optionalSelectors, group, key)
+}
+
+// Close stops the server
+func (s *Server) Close() error {
+	return s.server.Shutdown()
+}
+
+func freeLocalAddr() (string, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	addr := l.Addr().String()
+	if err := l.Close(); err != nil {
+		return "", err
+	}
+	return addr, nil
+}
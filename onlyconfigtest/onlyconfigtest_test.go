@@ -0,0 +1,74 @@
+package onlyconfigtest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/goodplayer/onlyconfig/client"
+)
+
+func TestServerSetAndNotify(t *testing.T) {
+	srv := NewServer(t)
+	if err := srv.Set("app=app1,dc=dc1,env=DEV", "group1", "key1", []byte("value1")); err != nil {
+		t.Fatal(err)
+	}
+
+	c := client.NewClient([]string{srv.URL()}, client.ClientOptions{
+		SelectorApp:         "app1",
+		SelectorEnvironment: "DEV",
+		SelectorDatacenter:  "dc1",
+	})
+	value, err := client.NewValue[string](c, "group1", "key1", client.ContentTypeGeneral)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	changes := value.Changes(ctx)
+
+	if err := c.StartClient(); err != nil {
+		t.Fatal(err)
+	}
+	defer func(c *client.Client) {
+		_ = c.StopClient()
+	}(c)
+	if err := c.WaitStartupConfigureLoaded(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if v := <-changes; v != "value1" {
+		t.Fatal("unexpected value:", v)
+	}
+
+	if err := srv.Set("app=app1,dc=dc1,env=DEV", "group1", "key1", []byte("value2")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case v := <-changes:
+		if v != "value2" {
+			t.Fatal("unexpected value:", v)
+		}
+	case <-ctx.Done():
+		t.Fatal("timeout waiting for update")
+	}
+}
+
+func TestServerDelete(t *testing.T) {
+	srv := NewServer(t)
+	if err := srv.SetWithOptionalSelectors("dc=dc1", "beta=1", "group1", "key1", []byte("value1")); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := srv.Delete("dc=dc1", "group1", "key1"); err != nil {
+		t.Fatal(err)
+	} else if ok {
+		t.Fatal("configuration without optional selectors should not exist")
+	}
+	if ok, err := srv.DeleteWithOptionalSelectors("dc=dc1", "beta=1", "group1", "key1"); err != nil {
+		t.Fatal(err)
+	} else if !ok {
+		t.Fatal("configuration should exist")
+	}
+	if err := srv.Set("", "group1", "key1", []byte("value1")); err == nil {
+		t.Fatal("empty selectors should be rejected")
+	}
+}
@@ -0,0 +1,78 @@
+package secrets
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// MasterKeySize is the size of the master keys in bytes, for AES-256
+const MasterKeySize = 32
+
+// LocalKeyProvider wraps the data keys with the master keys in memory, usually loaded from local key files
+type LocalKeyProvider struct {
+	currentId string
+	keys      map[string][]byte
+}
+
+// NewLocalKeyProvider creates the provider wrapping with the first key, all the keys are able to unwrap
+func NewLocalKeyProvider(keys ...[]byte) (*LocalKeyProvider, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("no master key")
+	}
+	p := &LocalKeyProvider{keys: map[string][]byte{}}
+	for i, key := range keys {
+		if len(key) != MasterKeySize {
+			return nil, fmt.Errorf("master key should be %d bytes", MasterKeySize)
+		}
+		id := KeyId(key)
+		if i == 0 {
+			p.currentId = id
+		}
+		p.keys[id] = key
+	}
+	return p, nil
+}
+
+// LoadKeyFiles loads the master keys from the files containing the keys in hex, the first one is the current key.
+// The old keys are kept after the current one during rotation, to decrypt the contents not yet saved again.
+func LoadKeyFiles(paths ...string) (*LocalKeyProvider, error) {
+	var keys [][]byte
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil {
+			return nil, fmt.Errorf("invalid master key file %s: %w", path, err)
+		}
+		keys = append(keys, key)
+	}
+	return NewLocalKeyProvider(keys...)
+}
+
+// KeyId identifies the master key without exposing it
+func KeyId(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:8])
+}
+
+func (p *LocalKeyProvider) WrapKey(dataKey []byte) (string, []byte, error) {
+	wrapped, err := seal(p.keys[p.currentId], dataKey, []byte(p.currentId))
+	if err != nil {
+		return "", nil, err
+	}
+	return p.currentId, wrapped, nil
+}
+
+func (p *LocalKeyProvider) UnwrapKey(keyId string, wrapped []byte) ([]byte, error) {
+	key, ok := p.keys[keyId]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, keyId)
+	}
+	return open(key, wrapped, []byte(keyId))
+}
@@ -0,0 +1,156 @@
+package secrets
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// SealedPrefix marks the contents sealed for a recipient, which are decrypted only by the clients
+const SealedPrefix = "ocsealed:v1:"
+
+var (
+	ErrInvalidRecipientKey = errors.New("invalid recipient key")
+	ErrNoPrivateKey        = errors.New("private key required for sealed configurations")
+)
+
+// GenerateRecipientKey generates an X25519 key pair in hex.
+// The private key is provisioned to the clients, while the public key is set to the application in the web manager.
+func GenerateRecipientKey() (privateKey, publicKey string, err error) {
+	key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+	return hex.EncodeToString(key.Bytes()), hex.EncodeToString(key.PublicKey().Bytes()), nil
+}
+
+// ParseRecipientKey parses the public key in hex generated by GenerateRecipientKey
+func ParseRecipientKey(publicKey string) (*ecdh.PublicKey, error) {
+	data, err := hex.DecodeString(strings.TrimSpace(publicKey))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidRecipientKey, err)
+	}
+	key, err := ecdh.X25519().NewPublicKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidRecipientKey, err)
+	}
+	return key, nil
+}
+
+// IsSealed reports whether the value is produced by SealFor
+func IsSealed(value string) bool {
+	return strings.HasPrefix(value, SealedPrefix)
+}
+
+// SealFor encrypts the plaintext for the recipient public key in hex, so that only the holder of the private key is
+// able to decrypt. The data key is derived from an ephemeral X25519 key agreement and used with AES-256-GCM.
+// The result is in the form of ocsealed:v1:{key id}:{ephemeral public key}:{nonce and ciphertext} in base64.
+func SealFor(publicKey string, plaintext []byte) (string, error) {
+	recipient, err := ParseRecipientKey(publicKey)
+	if err != nil {
+		return "", err
+	}
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", err
+	}
+	shared, err := ephemeral.ECDH(recipient)
+	if err != nil {
+		return "", err
+	}
+	keyId := KeyId(recipient.Bytes())
+	sealed, err := seal(sealingKey(shared, ephemeral.PublicKey().Bytes(), recipient.Bytes()), plaintext, []byte(keyId))
+	if err != nil {
+		return "", err
+	}
+	return SealedPrefix + keyId + ":" + base64.RawStdEncoding.EncodeToString(ephemeral.PublicKey().Bytes()) + ":" + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// sealingKey derives the AES-256 key from the shared secret bound to both public keys
+func sealingKey(shared, ephemeral, recipient []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte(SealedPrefix))
+	h.Write(shared)
+	h.Write(ephemeral)
+	h.Write(recipient)
+	return h.Sum(nil)
+}
+
+// PrivateKeys opens the values sealed for any of the keys, the old keys are kept during rotation
+type PrivateKeys struct {
+	keys map[string]*ecdh.PrivateKey
+}
+
+// NewPrivateKeys creates the private keys from the raw X25519 keys
+func NewPrivateKeys(keys ...[]byte) (*PrivateKeys, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("no private key")
+	}
+	p := &PrivateKeys{keys: map[string]*ecdh.PrivateKey{}}
+	for _, data := range keys {
+		key, err := ecdh.X25519().NewPrivateKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid private key: %w", err)
+		}
+		p.keys[KeyId(key.PublicKey().Bytes())] = key
+	}
+	return p, nil
+}
+
+// LoadPrivateKeyFiles loads the private keys from the files containing the keys in hex
+func LoadPrivateKeyFiles(paths ...string) (*PrivateKeys, error) {
+	var keys [][]byte
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil {
+			return nil, fmt.Errorf("invalid private key file %s: %w", path, err)
+		}
+		keys = append(keys, key)
+	}
+	return NewPrivateKeys(keys...)
+}
+
+// Open decrypts the value produced by SealFor
+func (p *PrivateKeys) Open(value string) ([]byte, error) {
+	if p == nil {
+		return nil, ErrNoPrivateKey
+	}
+	if !IsSealed(value) {
+		return nil, ErrInvalidEnvelope
+	}
+	parts := strings.Split(strings.TrimPrefix(value, SealedPrefix), ":")
+	if len(parts) != 3 {
+		return nil, ErrInvalidEnvelope
+	}
+	key, ok := p.keys[parts[0]]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, parts[0])
+	}
+	ephemeralData, err := base64.RawStdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrInvalidEnvelope
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidEnvelope
+	}
+	ephemeral, err := ecdh.X25519().NewPublicKey(ephemeralData)
+	if err != nil {
+		return nil, ErrInvalidEnvelope
+	}
+	shared, err := key.ECDH(ephemeral)
+	if err != nil {
+		return nil, ErrDecryptionFailed
+	}
+	return open(sealingKey(shared, ephemeralData, key.PublicKey().Bytes()), sealed, []byte(parts[0]))
+}
@@ -0,0 +1,129 @@
+// Package secrets encrypts the contents of secret configurations at rest with envelope encryption.
+//
+// Each content is encrypted by a random data key with AES-256-GCM, and the data key is wrapped by the master key of
+// a KeyProvider. The envelope records the id of the master key, so that the master key can be rotated while the old
+// ones are still able to decrypt.
+//
+// The values pushed to the clients can also be sealed for the recipient public key of the application by SealFor,
+// which only the clients holding the private key are able to open.
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// EnvelopePrefix marks the encrypted contents
+const EnvelopePrefix = "ocsecret:v1:"
+
+const dataKeySize = 32
+
+var (
+	ErrNoKeyProvider    = errors.New("master key required for secret configurations")
+	ErrUnknownKey       = errors.New("unknown master key")
+	ErrInvalidEnvelope  = errors.New("invalid secret envelope")
+	ErrDecryptionFailed = errors.New("secret decryption failed")
+)
+
+// KeyProvider wraps the data keys with the master key, e.g. by a local key file or a remote KMS
+type KeyProvider interface {
+	// WrapKey encrypts the data key with the current master key, returns the id of the master key
+	WrapKey(dataKey []byte) (keyId string, wrapped []byte, err error)
+	// UnwrapKey decrypts the data key with the master key of the id
+	UnwrapKey(keyId string, wrapped []byte) ([]byte, error)
+}
+
+// IsEncrypted reports whether the value is an envelope produced by Encrypt
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, EnvelopePrefix)
+}
+
+// Encrypt encrypts the plaintext with a new data key wrapped by the provider.
+// The result is in the form of ocsecret:v1:{key id}:{wrapped data key}:{nonce and ciphertext} in base64.
+func Encrypt(keys KeyProvider, plaintext []byte) (string, error) {
+	if keys == nil {
+		return "", ErrNoKeyProvider
+	}
+	dataKey := make([]byte, dataKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", err
+	}
+	keyId, wrapped, err := keys.WrapKey(dataKey)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := seal(dataKey, plaintext, []byte(keyId))
+	if err != nil {
+		return "", err
+	}
+	return EnvelopePrefix + keyId + ":" + base64.RawStdEncoding.EncodeToString(wrapped) + ":" + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt decrypts the envelope produced by Encrypt
+func Decrypt(keys KeyProvider, envelope string) ([]byte, error) {
+	if keys == nil {
+		return nil, ErrNoKeyProvider
+	}
+	if !IsEncrypted(envelope) {
+		return nil, ErrInvalidEnvelope
+	}
+	parts := strings.Split(strings.TrimPrefix(envelope, EnvelopePrefix), ":")
+	if len(parts) != 3 {
+		return nil, ErrInvalidEnvelope
+	}
+	wrapped, err := base64.RawStdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrInvalidEnvelope
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidEnvelope
+	}
+	dataKey, err := keys.UnwrapKey(parts[0], wrapped)
+	if err != nil {
+		return nil, err
+	}
+	return open(dataKey, sealed, []byte(parts[0]))
+}
+
+// seal encrypts with AES-GCM and prepends the random nonce
+func seal(key, plaintext, additionalData []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// open decrypts the result of seal
+func open(key, sealed, additionalData []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, ErrInvalidEnvelope
+	}
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], additionalData)
+	if err != nil {
+		return nil, ErrDecryptionFailed
+	}
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
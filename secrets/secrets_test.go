@@ -0,0 +1,156 @@
+package secrets
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEncrypt(t *testing.T) {
+	oldKey := bytes.Repeat([]byte{1}, MasterKeySize)
+	newKey := bytes.Repeat([]byte{2}, MasterKeySize)
+	old, err := NewLocalKeyProvider(oldKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	envelope, err := Encrypt(old, []byte("password=hunter2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsEncrypted(envelope) || strings.Contains(envelope, "hunter2") || !strings.HasPrefix(envelope, EnvelopePrefix+KeyId(oldKey)+":") {
+		t.Fatal("unexpected envelope:", envelope)
+	}
+	if again, _ := Encrypt(old, []byte("password=hunter2")); again == envelope {
+		t.Fatal("data key and nonce should be random")
+	}
+	if plaintext, err := Decrypt(old, envelope); err != nil || string(plaintext) != "password=hunter2" {
+		t.Fatal("unexpected plaintext:", string(plaintext), err)
+	}
+
+	// rotated: new contents use the new key, the old ones are still readable
+	rotated, err := NewLocalKeyProvider(newKey, oldKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plaintext, err := Decrypt(rotated, envelope); err != nil || string(plaintext) != "password=hunter2" {
+		t.Fatal("unexpected plaintext:", string(plaintext), err)
+	}
+	rotatedEnvelope, err := Encrypt(rotated, []byte("x"))
+	if err != nil || !strings.HasPrefix(rotatedEnvelope, EnvelopePrefix+KeyId(newKey)+":") {
+		t.Fatal("should wrap with the current key:", rotatedEnvelope, err)
+	}
+	if _, err := Decrypt(old, rotatedEnvelope); !errors.Is(err, ErrUnknownKey) {
+		t.Fatal("unknown key should fail:", err)
+	}
+
+	tampered := envelope[:len(envelope)-2] + "AA"
+	if _, err := Decrypt(old, tampered); !errors.Is(err, ErrDecryptionFailed) {
+		t.Fatal("tampered envelope should fail:", err)
+	}
+	if _, err := Decrypt(old, "plain"); !errors.Is(err, ErrInvalidEnvelope) {
+		t.Fatal("plain value should fail:", err)
+	}
+	if _, err := Encrypt(nil, []byte("x")); !errors.Is(err, ErrNoKeyProvider) {
+		t.Fatal("nil provider should fail:", err)
+	}
+	if _, err := NewLocalKeyProvider([]byte("short")); err == nil {
+		t.Fatal("short key should fail")
+	}
+}
+
+func TestLoadKeyFiles(t *testing.T) {
+	key := bytes.Repeat([]byte{3}, MasterKeySize)
+	path := filepath.Join(t.TempDir(), "master.key")
+	if err := os.WriteFile(path, []byte(hex.EncodeToString(key)+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	keys, err := LoadKeyFiles(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	envelope, err := Encrypt(keys, []byte("v"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	direct, _ := NewLocalKeyProvider(key)
+	if plaintext, err := Decrypt(direct, envelope); err != nil || string(plaintext) != "v" {
+		t.Fatal("unexpected plaintext:", string(plaintext), err)
+	}
+	if err := os.WriteFile(path, []byte("not hex"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadKeyFiles(path); err == nil {
+		t.Fatal("invalid key file should fail")
+	}
+}
+
+func TestSealFor(t *testing.T) {
+	oldPrivate, oldPublic, err := GenerateRecipientKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	newPrivate, newPublic, err := GenerateRecipientKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := SealFor(oldPublic, []byte("password=hunter2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsSealed(sealed) || IsEncrypted(sealed) || strings.Contains(sealed, "hunter2") {
+		t.Fatal("unexpected sealed value:", sealed)
+	}
+	if again, _ := SealFor(oldPublic, []byte("password=hunter2")); again == sealed {
+		t.Fatal("ephemeral key should be random")
+	}
+
+	dir := t.TempDir()
+	oldPath, newPath := filepath.Join(dir, "old.key"), filepath.Join(dir, "new.key")
+	if err := os.WriteFile(oldPath, []byte(oldPrivate+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(newPath, []byte(newPrivate), 0600); err != nil {
+		t.Fatal(err)
+	}
+	// rotating: both keys are provisioned till the application is switched to the new key
+	keys, err := LoadPrivateKeyFiles(newPath, oldPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plaintext, err := keys.Open(sealed); err != nil || string(plaintext) != "password=hunter2" {
+		t.Fatal("unexpected plaintext:", string(plaintext), err)
+	}
+	rotated, err := SealFor(newPublic, []byte("x"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plaintext, err := keys.Open(rotated); err != nil || string(plaintext) != "x" {
+		t.Fatal("unexpected plaintext:", string(plaintext), err)
+	}
+	oldKeys, err := LoadPrivateKeyFiles(oldPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := oldKeys.Open(rotated); !errors.Is(err, ErrUnknownKey) {
+		t.Fatal("unknown key should fail:", err)
+	}
+
+	tampered := sealed[:len(sealed)-2] + "AA"
+	if _, err := keys.Open(tampered); !errors.Is(err, ErrDecryptionFailed) {
+		t.Fatal("tampered value should fail:", err)
+	}
+	if _, err := keys.Open("plain"); !errors.Is(err, ErrInvalidEnvelope) {
+		t.Fatal("plain value should fail:", err)
+	}
+	var none *PrivateKeys
+	if _, err := none.Open(sealed); !errors.Is(err, ErrNoPrivateKey) {
+		t.Fatal("nil keys should fail:", err)
+	}
+	if _, err := SealFor("not hex", []byte("x")); !errors.Is(err, ErrInvalidRecipientKey) {
+		t.Fatal("invalid public key should fail:", err)
+	}
+}
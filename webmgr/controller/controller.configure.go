@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"slices"
@@ -25,8 +26,10 @@ func (cc *ControllerContainer) addConfigureControllers(r *chi.Mux, engine *xorm.
 			Engine: engine,
 		},
 		ConfigureHandler: &domains.ConfigureHandler{
-			ConfigureRepository:  &postgres.ConfigureStoreImpl{},
-			PushChangeRepository: &postgres.PushChangeRepositoryImpl{},
+			ConfigureRepository:  &postgres.ConfigureStoreImpl{Keys: cc.Keys},
+			PushChangeRepository: &postgres.PushChangeRepositoryImpl{Keys: cc.Keys},
+			WebhookRepository:    &postgres.WebhookStoreImpl{},
+			SecretRepository:     &postgres.SecretStoreImpl{},
 		},
 		UserHandler: cc.UserHandler,
 	}
@@ -45,6 +48,39 @@ func (cc *ControllerContainer) addConfigureControllers(r *chi.Mux, engine *xorm.
 		r.Post("/configure/{app_id}/{env}/{dc}/{namespace}/{key}", ccl.AddConfiguration)
 		r.Get("/configure/{cfg_id}", ccl.QueryConfigById)
 		r.Put("/configure/{cfg_id}", ccl.UpdateConfigById)
+		r.Put("/application/{app_id}", ccl.UpdateApplication)
+		r.Get("/namespace/{ns_name}", ccl.QueryNamespace)
+		r.Put("/namespace/{ns_name}", ccl.UpdateNamespace)
+		r.Get("/export/{app_id}", ccl.ExportApplication)
+		r.Post("/import/{app_id}", ccl.ImportApplication)
+		r.Get("/decommissions", ccl.Decommissions)
+		r.Get("/decommission/{type}/{name}", ccl.QueryDecommission)
+		r.Put("/decommission/{type}/{name}", ccl.OfflineEntity)
+		r.Delete("/decommission/{type}/{name}", ccl.OnlineEntity)
+		r.Post("/decommission/{type}/{name}/delete", ccl.DeleteEntity)
+		r.Get("/history/{cfg_id}", ccl.QueryConfigHistory)
+		r.Get("/configure/{cfg_id}/reveals", ccl.QueryRevealAudits)
+		r.Put("/recipient_key/{app_id}", ccl.UpdateRecipientKey)
+		r.Get("/diff/{cfg_id}", ccl.DiffConfigVersions)
+		r.Get("/diff_env_dc/{app_id}/{namespace}/{key}", ccl.DiffConfigEnvDc)
+		r.Get("/configure/{cfg_id}/draft", ccl.QueryConfigDraft)
+		r.Put("/configure/{cfg_id}/draft", ccl.SaveConfigDraft)
+		r.Delete("/configure/{cfg_id}/draft", ccl.DiscardConfigDraft)
+		r.Get("/configure/{cfg_id}/draft/diff", ccl.DiffConfigDraft)
+		r.Get("/configure/{cfg_id}/draft/validate", ccl.ValidateConfigDraft)
+		r.Post("/publish/{app_id}", ccl.PublishConfigs)
+		r.Get("/releases/{app_id}/{env}/{dc}", ccl.QueryReleases)
+		r.Get("/release/{release_id}", ccl.QueryRelease)
+		r.Post("/release/{release_id}/rollback", ccl.RollbackRelease)
+		r.Post("/publish/{app_id}/schedule", ccl.SchedulePublish)
+		r.Get("/schedules/{app_id}/{env}/{dc}", ccl.QuerySchedules)
+		r.Get("/schedule/{schedule_id}", ccl.QuerySchedule)
+		r.Delete("/schedule/{schedule_id}", ccl.CancelSchedule)
+		r.Get("/webhooks", ccl.QueryWebhooks)
+		r.Post("/webhook", ccl.AddWebhook)
+		r.Delete("/webhook/{webhook_id}", ccl.DeleteWebhook)
+		r.Get("/webhook/{webhook_id}/deliveries", ccl.QueryWebhookDeliveries)
+		r.Post("/webhook_delivery/{delivery_id}/redeliver", ccl.RedeliverWebhook)
 	})
 }
 
@@ -165,7 +201,9 @@ func (c *ConfigureController) Applications(w http.ResponseWriter, r *http.Reques
 				"app_desc":           application.ApplicationDescription,
 				"app_owner_org_id":   application.ApplicationOwnerOrganization.OrgId,
 				"app_owner_org_name": application.ApplicationOwnerOrganization.OrgName,
+				"app_recipient_key":  application.RecipientKey,
 				"env_and_dc":         item,
+				"time_updated":       application.TimeUpdated,
 			})
 		}
 		return func(writer http.ResponseWriter, request *http.Request) {
@@ -232,7 +270,12 @@ func (c *ConfigureController) AddAppEnvAndDc(w http.ResponseWriter, r *http.Requ
 				writer.WriteHeader(http.StatusBadRequest)
 			}, TxnStatusRollback
 		}
-		if err := c.ConfigureHandler.LinkEnvAndDcToApp(ctx, env, dc, appId); err != nil {
+		if err := c.ConfigureHandler.LinkEnvAndDcToApp(ctx, env, dc, appId); errors.Is(err, domains.ErrEntityOffline) {
+			log.Println("link env and dc to app failed:", err)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusLocked)
+			}, TxnStatusRollback
+		} else if err != nil {
 			log.Println("link env and dc to app failed:", err)
 			return func(writer http.ResponseWriter, request *http.Request) {
 				writer.WriteHeader(http.StatusInternalServerError)
@@ -278,7 +321,12 @@ func (c *ConfigureController) AddAppNamespace(w http.ResponseWriter, r *http.Req
 			}, TxnStatusRollback
 		}
 
-		if err := c.ConfigureHandler.AddApplicationNamespace(ctx, appId, nsName, nsType); err != nil {
+		if err := c.ConfigureHandler.AddApplicationNamespace(ctx, appId, nsName, nsType); errors.Is(err, domains.ErrEntityOffline) {
+			log.Println("add namespace failed:", err)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusLocked)
+			}, TxnStatusRollback
+		} else if err != nil {
 			log.Println("add namespace failed:", err)
 			return func(writer http.ResponseWriter, request *http.Request) {
 				writer.WriteHeader(http.StatusInternalServerError)
@@ -361,7 +409,15 @@ func (c *ConfigureController) AddConfiguration(w http.ResponseWriter, r *http.Re
 			}, TxnStatusRollback
 		}
 
-		if err := c.ConfigureHandler.AddConfiguration(ctx, req); err != nil {
+		if err := c.ConfigureHandler.AddConfiguration(ctx, req); errors.Is(err, domains.ErrEntityOffline) {
+			log.Println("add configuration failed:", err)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusLocked)
+			}, TxnStatusRollback
+		} else if fn, ok := secretErrorRender(err); ok {
+			log.Println("add configuration failed:", err)
+			return fn, TxnStatusRollback
+		} else if err != nil {
 			log.Println("add configuration failed:", err)
 			return func(writer http.ResponseWriter, request *http.Request) {
 				writer.WriteHeader(http.StatusInternalServerError)
@@ -459,7 +515,10 @@ func (c *ConfigureController) QueryConfigById(w http.ResponseWriter, r *http.Req
 		}
 
 		cfg, err := c.ConfigureHandler.QueryConfigureById(ctx, cfgId)
-		if err != nil {
+		if fn, ok := secretErrorRender(err); ok {
+			log.Println("query configuration failed:", err)
+			return fn, TxnStatusRollback
+		} else if err != nil {
 			log.Println("query configuration failed:", err)
 			return func(writer http.ResponseWriter, request *http.Request) {
 				writer.WriteHeader(http.StatusInternalServerError)
@@ -467,16 +526,7 @@ func (c *ConfigureController) QueryConfigById(w http.ResponseWriter, r *http.Req
 		}
 		return func(writer http.ResponseWriter, request *http.Request) {
 			render.JSON(writer, request, map[string]any{
-				"result": map[string]any{
-					"cfg_id":      fmt.Sprint(cfg.ConfigId),
-					"cfg_key":     cfg.ConfigKey,
-					"cfg_ns":      cfg.ConfigNamespace,
-					"cfg_env":     cfg.ConfigEnv,
-					"cfg_dc":      cfg.ConfigDc,
-					"cfg_status":  cfg.ConfigStatus,
-					"cfg_ct":      cfg.ContentType,
-					"cfg_content": cfg.Content,
-				},
+				"result": configureResult(cfg),
 			})
 		}, TxnStatusCommit
 	})
@@ -502,7 +552,24 @@ func (c *ConfigureController) UpdateConfigById(w http.ResponseWriter, r *http.Re
 				writer.WriteHeader(http.StatusBadRequest)
 			}, TxnStatusRollback
 		}
-		if err := c.ConfigureHandler.UpdateConfigurationById(ctx, req); err != nil {
+		if err := c.ConfigureHandler.UpdateConfigurationById(ctx, req); errors.Is(err, domains.ErrEntityOffline) {
+			log.Println("update configuration failed:", err)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusLocked)
+			}, TxnStatusRollback
+		} else if errors.Is(err, domains.ErrVersionRequired) {
+			log.Println("update configuration failed:", err)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusPreconditionRequired)
+				_, _ = writer.Write([]byte(err.Error()))
+			}, TxnStatusRollback
+		} else if errors.Is(err, domains.ErrConflict) {
+			log.Println("update configuration failed:", err)
+			return conflictRender(err), TxnStatusRollback
+		} else if fn, ok := secretErrorRender(err); ok {
+			log.Println("update configuration failed:", err)
+			return fn, TxnStatusRollback
+		} else if err != nil {
 			log.Println("update configuration failed:", err)
 			return func(writer http.ResponseWriter, request *http.Request) {
 				writer.WriteHeader(http.StatusInternalServerError)
@@ -513,3 +580,160 @@ func (c *ConfigureController) UpdateConfigById(w http.ResponseWriter, r *http.Re
 		}, TxnStatusCommit
 	})
 }
+
+func (c *ConfigureController) ExportApplication(w http.ResponseWriter, r *http.Request) {
+	c.RunInTxn(w, r, func(ctx context.Context) (RenderFn, TxnStatus) {
+		appIdStr := strings.TrimSpace(chi.URLParam(r, "app_id"))
+		appId, err := strconv.ParseInt(appIdStr, 10, 64)
+		if err != nil {
+			log.Println("invalid format of appId:", appIdStr)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusBadRequest)
+			}, TxnStatusRollback
+		}
+		format := archiveFormat(r)
+		if format == "" {
+			log.Println("unknown archive format:", r.URL.Query().Get("format"))
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusBadRequest)
+			}, TxnStatusRollback
+		}
+		// env_dc=ENV:DC, can be repeated
+		var envDcList []domains.ArchiveEnvDc
+		for _, item := range r.URL.Query()["env_dc"] {
+			env, dc, ok := strings.Cut(item, ":")
+			if !ok || env == "" || dc == "" {
+				log.Println("invalid format of env_dc:", item)
+				return func(writer http.ResponseWriter, request *http.Request) {
+					writer.WriteHeader(http.StatusBadRequest)
+				}, TxnStatusRollback
+			}
+			envDcList = append(envDcList, domains.ArchiveEnvDc{Env: env, Dc: dc})
+		}
+
+		archive, err := c.ConfigureHandler.ExportApplication(ctx, appId, envDcList)
+		if fn, ok := secretErrorRender(err); ok {
+			log.Println("export application failed:", err)
+			return fn, TxnStatusRollback
+		} else if err != nil {
+			log.Println("export application failed:", err)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusInternalServerError)
+			}, TxnStatusRollback
+		}
+		data, err := archive.Encode(format)
+		if err != nil {
+			log.Println("encode archive failed:", err)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusInternalServerError)
+			}, TxnStatusRollback
+		}
+		return func(writer http.ResponseWriter, request *http.Request) {
+			if format == domains.ArchiveFormatYaml {
+				writer.Header().Set("Content-Type", "application/yaml")
+			} else {
+				writer.Header().Set("Content-Type", "application/json")
+			}
+			writer.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, archive.Application, format))
+			writer.WriteHeader(http.StatusOK)
+			_, _ = writer.Write(data)
+		}, TxnStatusCommit
+	})
+}
+
+func (c *ConfigureController) ImportApplication(w http.ResponseWriter, r *http.Request) {
+	c.RunInTxn(w, r, func(ctx context.Context) (RenderFn, TxnStatus) {
+		appIdStr := strings.TrimSpace(chi.URLParam(r, "app_id"))
+		appId, err := strconv.ParseInt(appIdStr, 10, 64)
+		if err != nil {
+			log.Println("invalid format of appId:", appIdStr)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusBadRequest)
+			}, TxnStatusRollback
+		}
+		format := archiveFormat(r)
+		if format == "" {
+			log.Println("unknown archive format:", r.URL.Query().Get("format"))
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusBadRequest)
+			}, TxnStatusRollback
+		}
+		strategy := domains.ImportStrategy(r.URL.Query().Get("strategy"))
+		switch strategy {
+		case "":
+			strategy = domains.ImportStrategyFail
+		case domains.ImportStrategySkip:
+		case domains.ImportStrategyOverwrite:
+		case domains.ImportStrategyFail:
+		default:
+			log.Println("invalid import strategy:", strategy)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusBadRequest)
+			}, TxnStatusRollback
+		}
+		dryRun := r.URL.Query().Get("dry_run") == "true"
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			log.Println("read body failed:", err)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusBadRequest)
+			}, TxnStatusRollback
+		}
+		archive, err := domains.DecodeConfigureArchive(data, format)
+		if err != nil {
+			log.Println("decode archive failed:", err)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusBadRequest)
+			}, TxnStatusRollback
+		}
+
+		result, err := c.ConfigureHandler.ImportApplication(ctx, &domains.ImportRequest{
+			AppId:    appId,
+			Archive:  archive,
+			Strategy: strategy,
+			DryRun:   dryRun,
+		})
+		if errors.Is(err, domains.ErrImportConflict) {
+			log.Println("import application conflicts:", err)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				render.Status(request, http.StatusConflict)
+				render.JSON(writer, request, result)
+			}, TxnStatusRollback
+		} else if errors.Is(err, domains.ErrEntityOffline) {
+			log.Println("import application failed:", err)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusLocked)
+			}, TxnStatusRollback
+		} else if errors.Is(err, domains.ErrInvalidArchive) {
+			log.Println("invalid archive:", err)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusBadRequest)
+				_, _ = writer.Write([]byte(err.Error()))
+			}, TxnStatusRollback
+		} else if err != nil {
+			log.Println("import application failed:", err)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusInternalServerError)
+			}, TxnStatusRollback
+		}
+		status := TxnStatusCommit
+		if dryRun {
+			status = TxnStatusRollback
+		}
+		return func(writer http.ResponseWriter, request *http.Request) {
+			render.JSON(writer, request, result)
+		}, status
+	})
+}
+
+// archiveFormat returns the archive format from the format query parameter, empty if unknown
+func archiveFormat(r *http.Request) string {
+	switch format := r.URL.Query().Get("format"); format {
+	case "", domains.ArchiveFormatJson:
+		return domains.ArchiveFormatJson
+	case domains.ArchiveFormatYaml, "yml":
+		return domains.ArchiveFormatYaml
+	default:
+		return ""
+	}
+}
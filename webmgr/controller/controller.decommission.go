@@ -0,0 +1,189 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+
+	"github.com/goodplayer/onlyconfig/webmgr/domains"
+)
+
+func (c *ConfigureController) Decommissions(w http.ResponseWriter, r *http.Request) {
+	c.RunInTxn(w, r, func(ctx context.Context) (RenderFn, TxnStatus) {
+		list, gracePeriod, err := c.ConfigureHandler.QueryDecommissions(ctx)
+		if err != nil {
+			log.Println("query decommissions failed:", err)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusInternalServerError)
+			}, TxnStatusRollback
+		}
+		result := []map[string]any{}
+		for _, d := range list {
+			result = append(result, map[string]any{
+				"type":           d.EntityType,
+				"name":           d.EntityName,
+				"time_offline":   d.TimeOffline,
+				"time_deletable": d.TimeOffline + gracePeriod.Milliseconds(),
+			})
+		}
+		return func(writer http.ResponseWriter, request *http.Request) {
+			render.JSON(writer, request, map[string]any{
+				"list": result,
+			})
+		}, TxnStatusCommit
+	})
+}
+
+func (c *ConfigureController) QueryDecommission(w http.ResponseWriter, r *http.Request) {
+	c.RunInTxn(w, r, func(ctx context.Context) (RenderFn, TxnStatus) {
+		entityType, entityName, ok := decommissionEntity(r)
+		if !ok {
+			log.Println("invalid decommission entity:", entityType, entityName)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusBadRequest)
+			}, TxnStatusRollback
+		}
+		report, err := c.ConfigureHandler.QueryDecommissionDependents(ctx, entityType, entityName)
+		if err != nil {
+			log.Println("query decommission dependents failed:", err)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusInternalServerError)
+			}, TxnStatusRollback
+		}
+		return func(writer http.ResponseWriter, request *http.Request) {
+			render.JSON(writer, request, decommissionReport(report))
+		}, TxnStatusCommit
+	})
+}
+
+func (c *ConfigureController) OfflineEntity(w http.ResponseWriter, r *http.Request) {
+	c.RunInTxn(w, r, func(ctx context.Context) (RenderFn, TxnStatus) {
+		entityType, entityName, ok := decommissionEntity(r)
+		if !ok {
+			log.Println("invalid decommission entity:", entityType, entityName)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusBadRequest)
+			}, TxnStatusRollback
+		}
+		if err := c.ConfigureHandler.OfflineEntity(ctx, entityType, entityName, time.Now()); err != nil {
+			log.Println("offline entity failed:", err)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusInternalServerError)
+			}, TxnStatusRollback
+		}
+		return func(writer http.ResponseWriter, request *http.Request) {
+			writer.WriteHeader(http.StatusOK)
+		}, TxnStatusCommit
+	})
+}
+
+func (c *ConfigureController) OnlineEntity(w http.ResponseWriter, r *http.Request) {
+	c.RunInTxn(w, r, func(ctx context.Context) (RenderFn, TxnStatus) {
+		entityType, entityName, ok := decommissionEntity(r)
+		if !ok {
+			log.Println("invalid decommission entity:", entityType, entityName)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusBadRequest)
+			}, TxnStatusRollback
+		}
+		if err := c.ConfigureHandler.OnlineEntity(ctx, entityType, entityName); errors.Is(err, domains.ErrEntityNotOffline) {
+			log.Println("online entity failed:", err)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusConflict)
+			}, TxnStatusRollback
+		} else if err != nil {
+			log.Println("online entity failed:", err)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusInternalServerError)
+			}, TxnStatusRollback
+		}
+		return func(writer http.ResponseWriter, request *http.Request) {
+			writer.WriteHeader(http.StatusOK)
+		}, TxnStatusCommit
+	})
+}
+
+func (c *ConfigureController) DeleteEntity(w http.ResponseWriter, r *http.Request) {
+	c.RunInTxn(w, r, func(ctx context.Context) (RenderFn, TxnStatus) {
+		entityType, entityName, ok := decommissionEntity(r)
+		if !ok {
+			log.Println("invalid decommission entity:", entityType, entityName)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusBadRequest)
+			}, TxnStatusRollback
+		}
+		report, err := c.ConfigureHandler.DeleteEntity(ctx, entityType, entityName, time.Now())
+		if errors.Is(err, domains.ErrEntityNotOffline) || errors.Is(err, domains.ErrGracePeriodNotPassed) || errors.Is(err, domains.ErrNamespaceLinked) {
+			log.Println("delete entity failed:", err)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusConflict)
+				_, _ = writer.Write([]byte(err.Error()))
+			}, TxnStatusRollback
+		} else if err != nil {
+			log.Println("delete entity failed:", err)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusInternalServerError)
+			}, TxnStatusRollback
+		}
+		return func(writer http.ResponseWriter, request *http.Request) {
+			render.JSON(writer, request, decommissionReport(report))
+		}, TxnStatusCommit
+	})
+}
+
+// decommissionEntity returns the entity type and name from the url
+func decommissionEntity(r *http.Request) (string, string, bool) {
+	entityType := strings.TrimSpace(chi.URLParam(r, "type"))
+	entityName := strings.TrimSpace(chi.URLParam(r, "name"))
+	switch entityType {
+	case domains.DecommissionTypeApplication:
+	case domains.DecommissionTypeEnvironment:
+	case domains.DecommissionTypeDatacenter:
+	case domains.DecommissionTypeNamespace:
+	default:
+		return entityType, entityName, false
+	}
+	return entityType, entityName, entityName != ""
+}
+
+func decommissionReport(report *domains.DecommissionReport) map[string]any {
+	dependents := []map[string]any{}
+	for _, dep := range report.Dependents {
+		item := map[string]any{
+			"configure_id": dep.Configure.ConfigId,
+			"app":          dep.Application.ApplicationName,
+			"env":          dep.Configure.ConfigEnv,
+			"dc":           dep.Configure.ConfigDc,
+			"namespace":    dep.Configure.ConfigNamespace,
+			"key":          dep.Configure.ConfigKey,
+			"version":      dep.Configure.ConfigVersion,
+			"published":    dep.Published != nil,
+		}
+		if dep.Published != nil {
+			item["published_status"] = dep.Published.Status
+			item["time_published"] = dep.Published.TimeUpdated
+			item["time_fetched"] = dep.TimeFetched
+		}
+		dependents = append(dependents, item)
+	}
+	result := map[string]any{
+		"type":                 report.EntityType,
+		"name":                 report.EntityName,
+		"offline":              report.Decommission != nil,
+		"namespaces":           report.Namespaces,
+		"applications":         report.Applications,
+		"linking_applications": report.LinkingApplications,
+		"dependents":           dependents,
+	}
+	if report.Decommission != nil {
+		result["time_offline"] = report.Decommission.TimeOffline
+		result["time_deletable"] = report.TimeDeletable
+	}
+	return result
+}
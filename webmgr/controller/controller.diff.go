@@ -0,0 +1,150 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+
+	"github.com/goodplayer/onlyconfig/webmgr/domains"
+)
+
+func (c *ConfigureController) QueryConfigHistory(w http.ResponseWriter, r *http.Request) {
+	c.RunInTxn(w, r, func(ctx context.Context) (RenderFn, TxnStatus) {
+		cfgIdStr := strings.TrimSpace(chi.URLParam(r, "cfg_id"))
+		cfgId, err := strconv.ParseInt(cfgIdStr, 10, 64)
+		if err != nil {
+			log.Println("invalid format of cfgId:", cfgIdStr)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusBadRequest)
+			}, TxnStatusRollback
+		}
+		withContent := r.URL.Query().Get("content") == "true"
+
+		list, err := c.ConfigureHandler.QueryConfigureHistory(ctx, cfgId)
+		if fn, ok := secretErrorRender(err); ok {
+			log.Println("query configuration history failed:", err)
+			return fn, TxnStatusRollback
+		} else if err != nil {
+			log.Println("query configuration history failed:", err)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusInternalServerError)
+			}, TxnStatusRollback
+		}
+		result := []map[string]any{}
+		for _, h := range list {
+			item := map[string]any{
+				"cfg_id":       fmt.Sprint(h.ConfigId),
+				"cfg_version":  h.ConfigVersion,
+				"cfg_ct":       h.ContentType,
+				"time_created": h.TimeCreated,
+			}
+			if withContent {
+				item["cfg_content"] = h.Content
+			}
+			result = append(result, item)
+		}
+		return func(writer http.ResponseWriter, request *http.Request) {
+			render.JSON(writer, request, map[string]any{
+				"list": result,
+			})
+		}, TxnStatusCommit
+	})
+}
+
+func (c *ConfigureController) DiffConfigVersions(w http.ResponseWriter, r *http.Request) {
+	c.RunInTxn(w, r, func(ctx context.Context) (RenderFn, TxnStatus) {
+		cfgIdStr := strings.TrimSpace(chi.URLParam(r, "cfg_id"))
+		cfgId, err := strconv.ParseInt(cfgIdStr, 10, 64)
+		if err != nil {
+			log.Println("invalid format of cfgId:", cfgIdStr)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusBadRequest)
+			}, TxnStatusRollback
+		}
+		query := r.URL.Query()
+		from := strings.TrimSpace(query.Get("from"))
+		to := strings.TrimSpace(query.Get("to"))
+		format := strings.TrimSpace(query.Get("format"))
+		if from == "" {
+			log.Println("from version required")
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusBadRequest)
+			}, TxnStatusRollback
+		}
+
+		result, err := c.ConfigureHandler.DiffConfigureVersions(ctx, cfgId, from, to, format)
+		if errors.Is(err, domains.ErrVersionNotFound) {
+			log.Println("diff configuration failed:", err)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusNotFound)
+			}, TxnStatusRollback
+		} else if fn, ok := secretErrorRender(err); ok {
+			log.Println("diff configuration failed:", err)
+			return fn, TxnStatusRollback
+		} else if err != nil {
+			log.Println("diff configuration failed:", err)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusInternalServerError)
+			}, TxnStatusRollback
+		}
+		return func(writer http.ResponseWriter, request *http.Request) {
+			render.JSON(writer, request, result)
+		}, TxnStatusCommit
+	})
+}
+
+func (c *ConfigureController) DiffConfigEnvDc(w http.ResponseWriter, r *http.Request) {
+	c.RunInTxn(w, r, func(ctx context.Context) (RenderFn, TxnStatus) {
+		appIdStr := strings.TrimSpace(chi.URLParam(r, "app_id"))
+		appId, err := strconv.ParseInt(appIdStr, 10, 64)
+		if err != nil {
+			log.Println("invalid format of appId:", appIdStr)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusBadRequest)
+			}, TxnStatusRollback
+		}
+		query := r.URL.Query()
+		req := &domains.EnvDcDiffRequest{
+			AppId:     appId,
+			Namespace: strings.TrimSpace(chi.URLParam(r, "namespace")),
+			Key:       strings.TrimSpace(chi.URLParam(r, "key")),
+			FromEnv:   strings.TrimSpace(query.Get("from_env")),
+			FromDc:    strings.TrimSpace(query.Get("from_dc")),
+			ToEnv:     strings.TrimSpace(query.Get("to_env")),
+			ToDc:      strings.TrimSpace(query.Get("to_dc")),
+			Format:    strings.TrimSpace(query.Get("format")),
+		}
+		if req.Namespace == "" || req.Key == "" || req.FromEnv == "" || req.FromDc == "" || req.ToEnv == "" || req.ToDc == "" {
+			log.Println("invalid diff request:", req)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusBadRequest)
+			}, TxnStatusRollback
+		}
+
+		result, err := c.ConfigureHandler.DiffConfigureEnvDc(ctx, req)
+		if errors.Is(err, domains.ErrVersionNotFound) {
+			log.Println("diff configuration failed:", err)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusNotFound)
+			}, TxnStatusRollback
+		} else if fn, ok := secretErrorRender(err); ok {
+			log.Println("diff configuration failed:", err)
+			return fn, TxnStatusRollback
+		} else if err != nil {
+			log.Println("diff configuration failed:", err)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusInternalServerError)
+			}, TxnStatusRollback
+		}
+		return func(writer http.ResponseWriter, request *http.Request) {
+			render.JSON(writer, request, result)
+		}, TxnStatusCommit
+	})
+}
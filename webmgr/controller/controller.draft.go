@@ -0,0 +1,259 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+
+	"github.com/goodplayer/onlyconfig/webmgr/domains"
+)
+
+func (c *ConfigureController) SaveConfigDraft(w http.ResponseWriter, r *http.Request) {
+	c.RunInTxn(w, r, func(ctx context.Context) (RenderFn, TxnStatus) {
+		cfgIdStr := strings.TrimSpace(chi.URLParam(r, "cfg_id"))
+		cfgId, err := strconv.ParseInt(cfgIdStr, 10, 64)
+		if err != nil {
+			log.Println("invalid format of cfgId:", cfgIdStr)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusBadRequest)
+			}, TxnStatusRollback
+		}
+
+		req := &domains.UpdateConfigurationRequest{
+			ConfigId: cfgId,
+		}
+		if err := render.DefaultDecoder(r, req); err != nil {
+			log.Println("bind failed:", err)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusBadRequest)
+			}, TxnStatusRollback
+		}
+		if err := c.ConfigureHandler.SaveConfigureDraft(ctx, req); errors.Is(err, domains.ErrEntityOffline) {
+			log.Println("save configuration draft failed:", err)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusLocked)
+			}, TxnStatusRollback
+		} else if errors.Is(err, domains.ErrConflict) {
+			log.Println("save configuration draft failed:", err)
+			return conflictRender(err), TxnStatusRollback
+		} else if fn, ok := secretErrorRender(err); ok {
+			log.Println("save configuration draft failed:", err)
+			return fn, TxnStatusRollback
+		} else if err != nil {
+			log.Println("save configuration draft failed:", err)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusInternalServerError)
+			}, TxnStatusRollback
+		}
+		return func(writer http.ResponseWriter, request *http.Request) {
+			writer.WriteHeader(http.StatusOK)
+		}, TxnStatusCommit
+	})
+}
+
+func (c *ConfigureController) QueryConfigDraft(w http.ResponseWriter, r *http.Request) {
+	c.RunInTxn(w, r, func(ctx context.Context) (RenderFn, TxnStatus) {
+		cfgIdStr := strings.TrimSpace(chi.URLParam(r, "cfg_id"))
+		cfgId, err := strconv.ParseInt(cfgIdStr, 10, 64)
+		if err != nil {
+			log.Println("invalid format of cfgId:", cfgIdStr)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusBadRequest)
+			}, TxnStatusRollback
+		}
+
+		cfg, err := c.ConfigureHandler.QueryConfigureDraft(ctx, cfgId)
+		if errors.Is(err, domains.ErrNoDraft) {
+			log.Println("query configuration draft failed:", err)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusNotFound)
+			}, TxnStatusRollback
+		} else if fn, ok := secretErrorRender(err); ok {
+			log.Println("query configuration draft failed:", err)
+			return fn, TxnStatusRollback
+		} else if err != nil {
+			log.Println("query configuration draft failed:", err)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusInternalServerError)
+			}, TxnStatusRollback
+		}
+		return func(writer http.ResponseWriter, request *http.Request) {
+			render.JSON(writer, request, map[string]any{
+				"result": map[string]any{
+					"cfg_id":       fmt.Sprint(cfg.ConfigId),
+					"cfg_key":      cfg.ConfigKey,
+					"cfg_ns":       cfg.ConfigNamespace,
+					"cfg_env":      cfg.ConfigEnv,
+					"cfg_dc":       cfg.ConfigDc,
+					"cfg_version":  cfg.ConfigVersion,
+					"cfg_secret":   cfg.Secret,
+					"draft_ct":     cfg.DraftContentType,
+					"draft":        cfg.DraftContent,
+					"time_drafted": cfg.TimeDrafted,
+				},
+			})
+		}, TxnStatusCommit
+	})
+}
+
+func (c *ConfigureController) DiscardConfigDraft(w http.ResponseWriter, r *http.Request) {
+	c.RunInTxn(w, r, func(ctx context.Context) (RenderFn, TxnStatus) {
+		cfgIdStr := strings.TrimSpace(chi.URLParam(r, "cfg_id"))
+		cfgId, err := strconv.ParseInt(cfgIdStr, 10, 64)
+		if err != nil {
+			log.Println("invalid format of cfgId:", cfgIdStr)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusBadRequest)
+			}, TxnStatusRollback
+		}
+
+		if err := c.ConfigureHandler.DiscardConfigureDraft(ctx, cfgId); errors.Is(err, domains.ErrNoDraft) {
+			log.Println("discard configuration draft failed:", err)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusNotFound)
+			}, TxnStatusRollback
+		} else if err != nil {
+			log.Println("discard configuration draft failed:", err)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusInternalServerError)
+			}, TxnStatusRollback
+		}
+		return func(writer http.ResponseWriter, request *http.Request) {
+			writer.WriteHeader(http.StatusOK)
+		}, TxnStatusCommit
+	})
+}
+
+func (c *ConfigureController) DiffConfigDraft(w http.ResponseWriter, r *http.Request) {
+	c.RunInTxn(w, r, func(ctx context.Context) (RenderFn, TxnStatus) {
+		cfgIdStr := strings.TrimSpace(chi.URLParam(r, "cfg_id"))
+		cfgId, err := strconv.ParseInt(cfgIdStr, 10, 64)
+		if err != nil {
+			log.Println("invalid format of cfgId:", cfgIdStr)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusBadRequest)
+			}, TxnStatusRollback
+		}
+		format := strings.TrimSpace(r.URL.Query().Get("format"))
+
+		result, err := c.ConfigureHandler.DiffConfigureDraft(ctx, cfgId, format)
+		if errors.Is(err, domains.ErrNoDraft) {
+			log.Println("diff configuration draft failed:", err)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusNotFound)
+			}, TxnStatusRollback
+		} else if fn, ok := secretErrorRender(err); ok {
+			log.Println("diff configuration draft failed:", err)
+			return fn, TxnStatusRollback
+		} else if err != nil {
+			log.Println("diff configuration draft failed:", err)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusInternalServerError)
+			}, TxnStatusRollback
+		}
+		return func(writer http.ResponseWriter, request *http.Request) {
+			render.JSON(writer, request, result)
+		}, TxnStatusCommit
+	})
+}
+
+func (c *ConfigureController) ValidateConfigDraft(w http.ResponseWriter, r *http.Request) {
+	c.RunInTxn(w, r, func(ctx context.Context) (RenderFn, TxnStatus) {
+		cfgIdStr := strings.TrimSpace(chi.URLParam(r, "cfg_id"))
+		cfgId, err := strconv.ParseInt(cfgIdStr, 10, 64)
+		if err != nil {
+			log.Println("invalid format of cfgId:", cfgIdStr)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusBadRequest)
+			}, TxnStatusRollback
+		}
+		format := strings.TrimSpace(r.URL.Query().Get("format"))
+
+		result, err := c.ConfigureHandler.ValidateConfigureDraft(ctx, cfgId, format)
+		if errors.Is(err, domains.ErrNoDraft) {
+			log.Println("validate configuration draft failed:", err)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusNotFound)
+			}, TxnStatusRollback
+		} else if err != nil {
+			log.Println("validate configuration draft failed:", err)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusInternalServerError)
+			}, TxnStatusRollback
+		}
+		return func(writer http.ResponseWriter, request *http.Request) {
+			render.JSON(writer, request, result)
+		}, TxnStatusCommit
+	})
+}
+
+func (c *ConfigureController) PublishConfigs(w http.ResponseWriter, r *http.Request) {
+	c.RunInTxn(w, r, func(ctx context.Context) (RenderFn, TxnStatus) {
+		appIdStr := strings.TrimSpace(chi.URLParam(r, "app_id"))
+		appId, err := strconv.ParseInt(appIdStr, 10, 64)
+		if err != nil {
+			log.Println("invalid format of appId:", appIdStr)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusBadRequest)
+			}, TxnStatusRollback
+		}
+		body := struct {
+			ConfigIds []string `json:"cfg_ids"`
+		}{}
+		if err := render.DefaultDecoder(r, &body); err != nil {
+			log.Println("bind failed:", err)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusBadRequest)
+			}, TxnStatusRollback
+		}
+		req := &domains.PublishRequest{
+			AppId:  appId,
+			Format: strings.TrimSpace(r.URL.Query().Get("format")),
+		}
+		for _, idStr := range body.ConfigIds {
+			id, err := strconv.ParseInt(strings.TrimSpace(idStr), 10, 64)
+			if err != nil {
+				log.Println("invalid format of cfgId:", idStr)
+				return func(writer http.ResponseWriter, request *http.Request) {
+					writer.WriteHeader(http.StatusBadRequest)
+				}, TxnStatusRollback
+			}
+			req.ConfigIds = append(req.ConfigIds, id)
+		}
+
+		release, err := c.ConfigureHandler.PublishConfigures(ctx, req)
+		if errors.Is(err, domains.ErrEntityOffline) {
+			log.Println("publish configurations failed:", err)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusLocked)
+			}, TxnStatusRollback
+		} else if errors.Is(err, domains.ErrNoDraft) || errors.Is(err, domains.ErrReleaseEnvDc) {
+			log.Println("publish configurations failed:", err)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusConflict)
+				_, _ = writer.Write([]byte(err.Error()))
+			}, TxnStatusRollback
+		} else if errors.Is(err, domains.ErrInvalidDraft) {
+			log.Println("publish configurations failed:", err)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusUnprocessableEntity)
+				_, _ = writer.Write([]byte(err.Error()))
+			}, TxnStatusRollback
+		} else if err != nil {
+			log.Println("publish configurations failed:", err)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusInternalServerError)
+			}, TxnStatusRollback
+		}
+		return func(writer http.ResponseWriter, request *http.Request) {
+			render.JSON(writer, request, releaseResult(release))
+		}, TxnStatusCommit
+	})
+}
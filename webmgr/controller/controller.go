@@ -6,6 +6,7 @@ import (
 	"github.com/go-chi/chi/v5"
 	"xorm.io/xorm"
 
+	"github.com/goodplayer/onlyconfig/secrets"
 	"github.com/goodplayer/onlyconfig/webmgr/config"
 	"github.com/goodplayer/onlyconfig/webmgr/domains"
 )
@@ -14,9 +15,11 @@ type ControllerContainer struct {
 	CfgVal *atomic.Value
 
 	UserHandler *domains.UserHandler
+	// Keys encrypts the secret configures, nil if no master key
+	Keys secrets.KeyProvider
 }
 
-func AddControllers(r *chi.Mux, engine *xorm.Engine) {
+func AddControllers(r *chi.Mux, engine *xorm.Engine, keys secrets.KeyProvider) {
 	//FIXME Jwt secret store preparation
 	//FIXME Initializing from OnlyConfig server
 	cfg := &config.WebManagerConfig{
@@ -27,6 +30,7 @@ func AddControllers(r *chi.Mux, engine *xorm.Engine) {
 
 	cc := &ControllerContainer{
 		CfgVal: cfgVal,
+		Keys:   keys,
 	}
 
 	cc.addIndex(r)
@@ -0,0 +1,142 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+
+	"github.com/goodplayer/onlyconfig/webmgr/domains"
+)
+
+func (c *ConfigureController) QueryReleases(w http.ResponseWriter, r *http.Request) {
+	c.RunInTxn(w, r, func(ctx context.Context) (RenderFn, TxnStatus) {
+		appIdStr := strings.TrimSpace(chi.URLParam(r, "app_id"))
+		env := strings.TrimSpace(chi.URLParam(r, "env"))
+		dc := strings.TrimSpace(chi.URLParam(r, "dc"))
+		appId, err := strconv.ParseInt(appIdStr, 10, 64)
+		if err != nil {
+			log.Println("invalid format of appId:", appIdStr)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusBadRequest)
+			}, TxnStatusRollback
+		}
+
+		list, err := c.ConfigureHandler.QueryReleases(ctx, appId, env, dc)
+		if err != nil {
+			log.Println("query releases failed:", err)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusInternalServerError)
+			}, TxnStatusRollback
+		}
+		result := []map[string]any{}
+		for _, release := range list {
+			result = append(result, releaseResult(release))
+		}
+		return func(writer http.ResponseWriter, request *http.Request) {
+			render.JSON(writer, request, map[string]any{
+				"list": result,
+			})
+		}, TxnStatusCommit
+	})
+}
+
+func (c *ConfigureController) QueryRelease(w http.ResponseWriter, r *http.Request) {
+	c.RunInTxn(w, r, func(ctx context.Context) (RenderFn, TxnStatus) {
+		releaseIdStr := strings.TrimSpace(chi.URLParam(r, "release_id"))
+		releaseId, err := strconv.ParseInt(releaseIdStr, 10, 64)
+		if err != nil {
+			log.Println("invalid format of releaseId:", releaseIdStr)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusBadRequest)
+			}, TxnStatusRollback
+		}
+
+		release, err := c.ConfigureHandler.QueryRelease(ctx, releaseId)
+		if errors.Is(err, domains.ErrReleaseNotFound) {
+			log.Println("query release failed:", err)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusNotFound)
+			}, TxnStatusRollback
+		} else if err != nil {
+			log.Println("query release failed:", err)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusInternalServerError)
+			}, TxnStatusRollback
+		}
+		return func(writer http.ResponseWriter, request *http.Request) {
+			render.JSON(writer, request, releaseResult(release))
+		}, TxnStatusCommit
+	})
+}
+
+func (c *ConfigureController) RollbackRelease(w http.ResponseWriter, r *http.Request) {
+	c.RunInTxn(w, r, func(ctx context.Context) (RenderFn, TxnStatus) {
+		releaseIdStr := strings.TrimSpace(chi.URLParam(r, "release_id"))
+		releaseId, err := strconv.ParseInt(releaseIdStr, 10, 64)
+		if err != nil {
+			log.Println("invalid format of releaseId:", releaseIdStr)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusBadRequest)
+			}, TxnStatusRollback
+		}
+
+		rollback, err := c.ConfigureHandler.RollbackRelease(ctx, releaseId)
+		if errors.Is(err, domains.ErrReleaseNotFound) {
+			log.Println("rollback release failed:", err)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusNotFound)
+			}, TxnStatusRollback
+		} else if errors.Is(err, domains.ErrEntityOffline) {
+			log.Println("rollback release failed:", err)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusLocked)
+			}, TxnStatusRollback
+		} else if errors.Is(err, domains.ErrReleaseConflict) {
+			log.Println("rollback release failed:", err)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusConflict)
+				_, _ = writer.Write([]byte(err.Error()))
+			}, TxnStatusRollback
+		} else if err != nil {
+			log.Println("rollback release failed:", err)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusInternalServerError)
+			}, TxnStatusRollback
+		}
+		return func(writer http.ResponseWriter, request *http.Request) {
+			render.JSON(writer, request, releaseResult(rollback))
+		}, TxnStatusCommit
+	})
+}
+
+func releaseResult(release *domains.Release) map[string]any {
+	items := []map[string]any{}
+	for _, item := range release.Items {
+		items = append(items, map[string]any{
+			"cfg_id":       fmt.Sprint(item.ConfigId),
+			"cfg_ns":       item.ConfigNamespace,
+			"cfg_key":      item.ConfigKey,
+			"from_version": item.FromVersion,
+			"to_version":   item.ToVersion,
+		})
+	}
+	return map[string]any{
+		"release_id":      fmt.Sprint(release.ReleaseId),
+		"app_id":          release.AppId,
+		"env":             release.Env,
+		"dc":              release.Dc,
+		"release_type":    release.ReleaseType,
+		"release_version": release.ReleaseVersion,
+		"rollback_of":     fmt.Sprint(release.RollbackOf),
+		"rolled_back_by":  fmt.Sprint(release.RolledBackBy),
+		"time_created":    release.TimeCreated,
+		"items":           items,
+	}
+}
@@ -0,0 +1,217 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+
+	"github.com/goodplayer/onlyconfig/webmgr/domains"
+)
+
+func (c *ConfigureController) SchedulePublish(w http.ResponseWriter, r *http.Request) {
+	c.RunInTxn(w, r, func(ctx context.Context) (RenderFn, TxnStatus) {
+		appIdStr := strings.TrimSpace(chi.URLParam(r, "app_id"))
+		appId, err := strconv.ParseInt(appIdStr, 10, 64)
+		if err != nil {
+			log.Println("invalid format of appId:", appIdStr)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusBadRequest)
+			}, TxnStatusRollback
+		}
+		body := struct {
+			ConfigIds   []string `json:"cfg_ids"`
+			TimePublish int64    `json:"time_publish"`
+			RevertAfter int64    `json:"revert_after"`
+		}{}
+		if err := render.DefaultDecoder(r, &body); err != nil {
+			log.Println("bind failed:", err)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusBadRequest)
+			}, TxnStatusRollback
+		}
+		if body.TimePublish <= 0 || body.RevertAfter < 0 {
+			log.Println("invalid schedule time:", body.TimePublish, body.RevertAfter)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusBadRequest)
+			}, TxnStatusRollback
+		}
+		req := &domains.ScheduleRequest{
+			PublishRequest: domains.PublishRequest{
+				AppId:  appId,
+				Format: strings.TrimSpace(r.URL.Query().Get("format")),
+			},
+			TimePublish: body.TimePublish,
+			RevertAfter: body.RevertAfter,
+		}
+		for _, idStr := range body.ConfigIds {
+			id, err := strconv.ParseInt(strings.TrimSpace(idStr), 10, 64)
+			if err != nil {
+				log.Println("invalid format of cfgId:", idStr)
+				return func(writer http.ResponseWriter, request *http.Request) {
+					writer.WriteHeader(http.StatusBadRequest)
+				}, TxnStatusRollback
+			}
+			req.ConfigIds = append(req.ConfigIds, id)
+		}
+
+		schedule, err := c.ConfigureHandler.SchedulePublish(ctx, req)
+		if errors.Is(err, domains.ErrEntityOffline) {
+			log.Println("schedule publishing failed:", err)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusLocked)
+			}, TxnStatusRollback
+		} else if errors.Is(err, domains.ErrNoDraft) || errors.Is(err, domains.ErrReleaseEnvDc) {
+			log.Println("schedule publishing failed:", err)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusConflict)
+				_, _ = writer.Write([]byte(err.Error()))
+			}, TxnStatusRollback
+		} else if errors.Is(err, domains.ErrInvalidDraft) {
+			log.Println("schedule publishing failed:", err)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusUnprocessableEntity)
+				_, _ = writer.Write([]byte(err.Error()))
+			}, TxnStatusRollback
+		} else if err != nil {
+			log.Println("schedule publishing failed:", err)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusInternalServerError)
+			}, TxnStatusRollback
+		}
+		return func(writer http.ResponseWriter, request *http.Request) {
+			render.JSON(writer, request, scheduleResult(schedule))
+		}, TxnStatusCommit
+	})
+}
+
+func (c *ConfigureController) QuerySchedules(w http.ResponseWriter, r *http.Request) {
+	c.RunInTxn(w, r, func(ctx context.Context) (RenderFn, TxnStatus) {
+		appIdStr := strings.TrimSpace(chi.URLParam(r, "app_id"))
+		env := strings.TrimSpace(chi.URLParam(r, "env"))
+		dc := strings.TrimSpace(chi.URLParam(r, "dc"))
+		appId, err := strconv.ParseInt(appIdStr, 10, 64)
+		if err != nil {
+			log.Println("invalid format of appId:", appIdStr)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusBadRequest)
+			}, TxnStatusRollback
+		}
+
+		list, err := c.ConfigureHandler.QuerySchedules(ctx, appId, env, dc)
+		if err != nil {
+			log.Println("query schedules failed:", err)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusInternalServerError)
+			}, TxnStatusRollback
+		}
+		result := []map[string]any{}
+		for _, schedule := range list {
+			result = append(result, scheduleResult(schedule))
+		}
+		return func(writer http.ResponseWriter, request *http.Request) {
+			render.JSON(writer, request, map[string]any{
+				"list": result,
+			})
+		}, TxnStatusCommit
+	})
+}
+
+func (c *ConfigureController) QuerySchedule(w http.ResponseWriter, r *http.Request) {
+	c.RunInTxn(w, r, func(ctx context.Context) (RenderFn, TxnStatus) {
+		scheduleIdStr := strings.TrimSpace(chi.URLParam(r, "schedule_id"))
+		scheduleId, err := strconv.ParseInt(scheduleIdStr, 10, 64)
+		if err != nil {
+			log.Println("invalid format of scheduleId:", scheduleIdStr)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusBadRequest)
+			}, TxnStatusRollback
+		}
+
+		schedule, err := c.ConfigureHandler.QuerySchedule(ctx, scheduleId)
+		if errors.Is(err, domains.ErrScheduleNotFound) {
+			log.Println("query schedule failed:", err)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusNotFound)
+			}, TxnStatusRollback
+		} else if err != nil {
+			log.Println("query schedule failed:", err)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusInternalServerError)
+			}, TxnStatusRollback
+		}
+		return func(writer http.ResponseWriter, request *http.Request) {
+			render.JSON(writer, request, scheduleResult(schedule))
+		}, TxnStatusCommit
+	})
+}
+
+func (c *ConfigureController) CancelSchedule(w http.ResponseWriter, r *http.Request) {
+	c.RunInTxn(w, r, func(ctx context.Context) (RenderFn, TxnStatus) {
+		scheduleIdStr := strings.TrimSpace(chi.URLParam(r, "schedule_id"))
+		scheduleId, err := strconv.ParseInt(scheduleIdStr, 10, 64)
+		if err != nil {
+			log.Println("invalid format of scheduleId:", scheduleIdStr)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusBadRequest)
+			}, TxnStatusRollback
+		}
+
+		schedule, err := c.ConfigureHandler.CancelSchedule(ctx, scheduleId)
+		if errors.Is(err, domains.ErrScheduleNotFound) {
+			log.Println("cancel schedule failed:", err)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusNotFound)
+			}, TxnStatusRollback
+		} else if errors.Is(err, domains.ErrScheduleDone) {
+			log.Println("cancel schedule failed:", err)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusConflict)
+				_, _ = writer.Write([]byte(err.Error()))
+			}, TxnStatusRollback
+		} else if err != nil {
+			log.Println("cancel schedule failed:", err)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusInternalServerError)
+			}, TxnStatusRollback
+		}
+		return func(writer http.ResponseWriter, request *http.Request) {
+			render.JSON(writer, request, scheduleResult(schedule))
+		}, TxnStatusCommit
+	})
+}
+
+func scheduleResult(schedule *domains.Schedule) map[string]any {
+	items := []map[string]any{}
+	for _, item := range schedule.Items {
+		items = append(items, map[string]any{
+			"cfg_id":       fmt.Sprint(item.ConfigId),
+			"cfg_ns":       item.ConfigNamespace,
+			"cfg_key":      item.ConfigKey,
+			"from_version": item.FromVersion,
+			"cfg_ct":       item.ContentType,
+			"cfg_content":  item.Content,
+		})
+	}
+	return map[string]any{
+		"schedule_id":       fmt.Sprint(schedule.ScheduleId),
+		"app_id":            schedule.AppId,
+		"env":               schedule.Env,
+		"dc":                schedule.Dc,
+		"status":            schedule.Status,
+		"time_publish":      schedule.TimePublish,
+		"revert_after":      schedule.RevertAfter,
+		"time_revert":       schedule.TimeRevert,
+		"release_id":        fmt.Sprint(schedule.ReleaseId),
+		"revert_release_id": fmt.Sprint(schedule.RevertReleaseId),
+		"message":           schedule.Message,
+		"time_created":      schedule.TimeCreated,
+		"items":             items,
+	}
+}
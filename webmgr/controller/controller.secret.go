@@ -0,0 +1,106 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+
+	"github.com/goodplayer/onlyconfig/secrets"
+	"github.com/goodplayer/onlyconfig/webmgr/domains"
+)
+
+// revealAuditLimit is the number of the latest reveals responded
+const revealAuditLimit = 100
+
+// secretErrorRender maps the errors of the secret configures, returns false if not one of them
+func secretErrorRender(err error) (RenderFn, bool) {
+	if errors.Is(err, domains.ErrRevealForbidden) || errors.Is(err, domains.ErrRecipientKeyForbidden) {
+		return func(writer http.ResponseWriter, request *http.Request) {
+			writer.WriteHeader(http.StatusForbidden)
+			_, _ = writer.Write([]byte(err.Error()))
+		}, true
+	} else if errors.Is(err, domains.ErrMaskedContent) || errors.Is(err, secrets.ErrNoKeyProvider) || errors.Is(err, secrets.ErrInvalidRecipientKey) {
+		return func(writer http.ResponseWriter, request *http.Request) {
+			writer.WriteHeader(http.StatusBadRequest)
+			_, _ = writer.Write([]byte(err.Error()))
+		}, true
+	}
+	return nil, false
+}
+
+func (c *ConfigureController) QueryRevealAudits(w http.ResponseWriter, r *http.Request) {
+	c.RunInTxn(w, r, func(ctx context.Context) (RenderFn, TxnStatus) {
+		cfgIdStr := strings.TrimSpace(chi.URLParam(r, "cfg_id"))
+		cfgId, err := strconv.ParseInt(cfgIdStr, 10, 64)
+		if err != nil {
+			log.Println("invalid format of cfgId:", cfgIdStr)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusBadRequest)
+			}, TxnStatusRollback
+		}
+
+		list, err := c.ConfigureHandler.QueryRevealAudits(ctx, cfgId, revealAuditLimit)
+		if err != nil {
+			log.Println("query reveal audits failed:", err)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusInternalServerError)
+			}, TxnStatusRollback
+		}
+		result := []map[string]any{}
+		for _, a := range list {
+			result = append(result, map[string]any{
+				"audit_id":     fmt.Sprint(a.AuditId),
+				"cfg_id":       fmt.Sprint(a.ConfigId),
+				"username":     a.Username,
+				"action":       a.Action,
+				"time_created": a.TimeCreated,
+			})
+		}
+		return func(writer http.ResponseWriter, request *http.Request) {
+			render.JSON(writer, request, map[string]any{
+				"list": result,
+			})
+		}, TxnStatusCommit
+	})
+}
+
+func (c *ConfigureController) UpdateRecipientKey(w http.ResponseWriter, r *http.Request) {
+	c.RunInTxn(w, r, func(ctx context.Context) (RenderFn, TxnStatus) {
+		appIdStr := strings.TrimSpace(chi.URLParam(r, "app_id"))
+		appId, err := strconv.ParseInt(appIdStr, 10, 64)
+		if err != nil {
+			log.Println("invalid format of appId:", appIdStr)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusBadRequest)
+			}, TxnStatusRollback
+		}
+		req := &domains.UpdateRecipientKeyRequest{
+			AppId: appId,
+		}
+		if err := render.DefaultDecoder(r, req); err != nil {
+			log.Println("bind failed:", err)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusBadRequest)
+			}, TxnStatusRollback
+		}
+
+		app, err := c.ConfigureHandler.UpdateRecipientKey(ctx, req)
+		if fn, ok := secretErrorRender(err); ok {
+			log.Println("update recipient key failed:", err)
+			return fn, TxnStatusRollback
+		}
+		if fn, ok := updateErrorRender("update recipient key failed:", err); ok {
+			return fn, TxnStatusRollback
+		}
+		return func(writer http.ResponseWriter, request *http.Request) {
+			render.JSON(writer, request, applicationResult(app))
+		}, TxnStatusCommit
+	})
+}
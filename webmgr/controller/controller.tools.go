@@ -7,6 +7,7 @@ import (
 
 	"xorm.io/xorm"
 
+	"github.com/goodplayer/onlyconfig/webmgr/domains"
 	"github.com/goodplayer/onlyconfig/webmgr/storage/dbtxn"
 )
 
@@ -28,7 +29,12 @@ func (tc *TxnController) RunInTxn(w http.ResponseWriter, r *http.Request, fn fun
 		txnMgr := &dbtxn.TxnMgr{
 			Engine: tc.Engine,
 		}
-		ctx, err := txnMgr.StartTxn(context.Background())
+		txnCtx := domains.WithAuthor(context.Background(), domains.AuthorFrom(r.Context()))
+		// reveal=true requests the contents of the secret configures, which are checked and audited by the domain
+		if r.URL.Query().Get("reveal") == "true" {
+			txnCtx = domains.WithReveal(txnCtx)
+		}
+		ctx, err := txnMgr.StartTxn(txnCtx)
 		if err != nil {
 			log.Println("error:", err)
 			w.WriteHeader(http.StatusInternalServerError)
@@ -0,0 +1,179 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+
+	"github.com/goodplayer/onlyconfig/webmgr/domains"
+)
+
+func (c *ConfigureController) UpdateApplication(w http.ResponseWriter, r *http.Request) {
+	c.RunInTxn(w, r, func(ctx context.Context) (RenderFn, TxnStatus) {
+		appIdStr := strings.TrimSpace(chi.URLParam(r, "app_id"))
+		appId, err := strconv.ParseInt(appIdStr, 10, 64)
+		if err != nil {
+			log.Println("invalid format of appId:", appIdStr)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusBadRequest)
+			}, TxnStatusRollback
+		}
+		req := &domains.UpdateApplicationRequest{
+			AppId: appId,
+		}
+		if err := render.DefaultDecoder(r, req); err != nil {
+			log.Println("bind failed:", err)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusBadRequest)
+			}, TxnStatusRollback
+		}
+
+		app, err := c.ConfigureHandler.UpdateApplication(ctx, req)
+		if fn, ok := updateErrorRender("update application failed:", err); ok {
+			return fn, TxnStatusRollback
+		}
+		return func(writer http.ResponseWriter, request *http.Request) {
+			render.JSON(writer, request, applicationResult(app))
+		}, TxnStatusCommit
+	})
+}
+
+func (c *ConfigureController) QueryNamespace(w http.ResponseWriter, r *http.Request) {
+	c.RunInTxn(w, r, func(ctx context.Context) (RenderFn, TxnStatus) {
+		nsName := strings.TrimSpace(chi.URLParam(r, "ns_name"))
+		if nsName == "" {
+			log.Println("empty namespace")
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusBadRequest)
+			}, TxnStatusRollback
+		}
+
+		ns, err := c.ConfigureHandler.QueryNamespace(ctx, nsName)
+		if err != nil {
+			log.Println("query namespace failed:", err)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusInternalServerError)
+			}, TxnStatusRollback
+		}
+		return func(writer http.ResponseWriter, request *http.Request) {
+			render.JSON(writer, request, namespaceResult(ns))
+		}, TxnStatusCommit
+	})
+}
+
+func (c *ConfigureController) UpdateNamespace(w http.ResponseWriter, r *http.Request) {
+	c.RunInTxn(w, r, func(ctx context.Context) (RenderFn, TxnStatus) {
+		nsName := strings.TrimSpace(chi.URLParam(r, "ns_name"))
+		if nsName == "" {
+			log.Println("empty namespace")
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusBadRequest)
+			}, TxnStatusRollback
+		}
+		req := &domains.UpdateNamespaceRequest{
+			Name: nsName,
+		}
+		if err := render.DefaultDecoder(r, req); err != nil {
+			log.Println("bind failed:", err)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusBadRequest)
+			}, TxnStatusRollback
+		}
+
+		ns, err := c.ConfigureHandler.UpdateNamespace(ctx, req)
+		if fn, ok := updateErrorRender("update namespace failed:", err); ok {
+			return fn, TxnStatusRollback
+		}
+		return func(writer http.ResponseWriter, request *http.Request) {
+			render.JSON(writer, request, namespaceResult(ns))
+		}, TxnStatusCommit
+	})
+}
+
+// updateErrorRender maps the error of the versioned updates, returns false if no error
+func updateErrorRender(msg string, err error) (RenderFn, bool) {
+	if err == nil {
+		return nil, false
+	}
+	log.Println(msg, err)
+	if errors.Is(err, domains.ErrEntityOffline) {
+		return func(writer http.ResponseWriter, request *http.Request) {
+			writer.WriteHeader(http.StatusLocked)
+		}, true
+	} else if errors.Is(err, domains.ErrVersionRequired) {
+		return func(writer http.ResponseWriter, request *http.Request) {
+			writer.WriteHeader(http.StatusPreconditionRequired)
+			_, _ = writer.Write([]byte(err.Error()))
+		}, true
+	} else if errors.Is(err, domains.ErrConflict) {
+		return conflictRender(err), true
+	}
+	return func(writer http.ResponseWriter, request *http.Request) {
+		writer.WriteHeader(http.StatusInternalServerError)
+	}, true
+}
+
+// conflictRender responds 409 with the error and the current entity, so that the client is able to merge and retry
+func conflictRender(err error) RenderFn {
+	result := map[string]any{
+		"error": err.Error(),
+	}
+	var conflict *domains.ConflictError
+	if errors.As(err, &conflict) {
+		switch current := conflict.Current.(type) {
+		case *domains.Configure:
+			result["current"] = configureResult(current)
+		case *domains.Application:
+			result["current"] = applicationResult(current)
+		case *domains.Namespace:
+			result["current"] = namespaceResult(current)
+		}
+	}
+	return func(writer http.ResponseWriter, request *http.Request) {
+		render.Status(request, http.StatusConflict)
+		render.JSON(writer, request, result)
+	}
+}
+
+func configureResult(cfg *domains.Configure) map[string]any {
+	return map[string]any{
+		"cfg_id":        fmt.Sprint(cfg.ConfigId),
+		"cfg_key":       cfg.ConfigKey,
+		"cfg_ns":        cfg.ConfigNamespace,
+		"cfg_env":       cfg.ConfigEnv,
+		"cfg_dc":        cfg.ConfigDc,
+		"cfg_status":    cfg.ConfigStatus,
+		"cfg_ct":        cfg.ContentType,
+		"cfg_content":   cfg.Content,
+		"cfg_version":   cfg.ConfigVersion,
+		"cfg_has_draft": cfg.HasDraft(),
+		"cfg_secret":    cfg.Secret,
+	}
+}
+
+func applicationResult(app *domains.Application) map[string]any {
+	return map[string]any{
+		"app_id":            app.ApplicationId,
+		"app_name":          app.ApplicationName,
+		"app_desc":          app.ApplicationDescription,
+		"app_recipient_key": app.RecipientKey,
+		"time_updated":      app.TimeUpdated,
+	}
+}
+
+func namespaceResult(ns *domains.Namespace) map[string]any {
+	return map[string]any{
+		"ns_name":      ns.Name,
+		"ns_desc":      ns.Description,
+		"ns_type":      ns.Type,
+		"ns_app_id":    ns.OwnerAppId,
+		"time_updated": ns.TimeUpdated,
+	}
+}
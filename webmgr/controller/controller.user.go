@@ -68,6 +68,10 @@ func (cc *ControllerContainer) userJwtTokenMiddleware() func(handler http.Handle
 			}
 
 			ctx := context.WithValue(r.Context(), JwtTokenContextKey, bearer)
+			// the author of the changes notified to the webhooks
+			if claims, err := cc.UserHandler.GetClaimsFromJwtToken(bearer); err == nil {
+				ctx = domains.WithAuthor(ctx, claims.Username)
+			}
 			handler.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
@@ -0,0 +1,206 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+
+	"github.com/goodplayer/onlyconfig/webmgr/domains"
+)
+
+// webhookDeliveryLimit is the number of the latest deliveries in the delivery log
+const webhookDeliveryLimit = 100
+
+func (c *ConfigureController) QueryWebhooks(w http.ResponseWriter, r *http.Request) {
+	c.RunInTxn(w, r, func(ctx context.Context) (RenderFn, TxnStatus) {
+		orgId := strings.TrimSpace(r.URL.Query().Get("org_id"))
+		var appId int64
+		if appIdStr := strings.TrimSpace(r.URL.Query().Get("app_id")); appIdStr != "" {
+			id, err := strconv.ParseInt(appIdStr, 10, 64)
+			if err != nil {
+				log.Println("invalid format of appId:", appIdStr)
+				return func(writer http.ResponseWriter, request *http.Request) {
+					writer.WriteHeader(http.StatusBadRequest)
+				}, TxnStatusRollback
+			}
+			appId = id
+		}
+
+		list, err := c.ConfigureHandler.QueryWebhooks(ctx, orgId, appId)
+		if err != nil {
+			log.Println("query webhooks failed:", err)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusInternalServerError)
+			}, TxnStatusRollback
+		}
+		result := []map[string]any{}
+		for _, webhook := range list {
+			result = append(result, webhookResult(webhook, false))
+		}
+		return func(writer http.ResponseWriter, request *http.Request) {
+			render.JSON(writer, request, map[string]any{
+				"list": result,
+			})
+		}, TxnStatusCommit
+	})
+}
+
+func (c *ConfigureController) AddWebhook(w http.ResponseWriter, r *http.Request) {
+	c.RunInTxn(w, r, func(ctx context.Context) (RenderFn, TxnStatus) {
+		req := new(domains.AddWebhookRequest)
+		if err := render.DefaultDecoder(r, req); err != nil {
+			log.Println("bind failed:", err)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusBadRequest)
+			}, TxnStatusRollback
+		}
+
+		webhook, err := c.ConfigureHandler.AddWebhook(ctx, req)
+		if errors.Is(err, domains.ErrInvalidWebhook) {
+			log.Println("add webhook failed:", err)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusBadRequest)
+				_, _ = writer.Write([]byte(err.Error()))
+			}, TxnStatusRollback
+		} else if err != nil {
+			log.Println("add webhook failed:", err)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusInternalServerError)
+			}, TxnStatusRollback
+		}
+		// the secret is responded only once on creation
+		return func(writer http.ResponseWriter, request *http.Request) {
+			render.JSON(writer, request, webhookResult(webhook, true))
+		}, TxnStatusCommit
+	})
+}
+
+func (c *ConfigureController) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	c.RunInTxn(w, r, func(ctx context.Context) (RenderFn, TxnStatus) {
+		webhookIdStr := strings.TrimSpace(chi.URLParam(r, "webhook_id"))
+		webhookId, err := strconv.ParseInt(webhookIdStr, 10, 64)
+		if err != nil {
+			log.Println("invalid format of webhookId:", webhookIdStr)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusBadRequest)
+			}, TxnStatusRollback
+		}
+
+		if err := c.ConfigureHandler.DeleteWebhook(ctx, webhookId); errors.Is(err, domains.ErrWebhookNotFound) {
+			log.Println("delete webhook failed:", err)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusNotFound)
+			}, TxnStatusRollback
+		} else if err != nil {
+			log.Println("delete webhook failed:", err)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusInternalServerError)
+			}, TxnStatusRollback
+		}
+		return func(writer http.ResponseWriter, request *http.Request) {
+			writer.WriteHeader(http.StatusOK)
+		}, TxnStatusCommit
+	})
+}
+
+func (c *ConfigureController) QueryWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	c.RunInTxn(w, r, func(ctx context.Context) (RenderFn, TxnStatus) {
+		webhookIdStr := strings.TrimSpace(chi.URLParam(r, "webhook_id"))
+		webhookId, err := strconv.ParseInt(webhookIdStr, 10, 64)
+		if err != nil {
+			log.Println("invalid format of webhookId:", webhookIdStr)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusBadRequest)
+			}, TxnStatusRollback
+		}
+
+		list, err := c.ConfigureHandler.QueryWebhookDeliveries(ctx, webhookId, webhookDeliveryLimit)
+		if errors.Is(err, domains.ErrWebhookNotFound) {
+			log.Println("query webhook deliveries failed:", err)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusNotFound)
+			}, TxnStatusRollback
+		} else if err != nil {
+			log.Println("query webhook deliveries failed:", err)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusInternalServerError)
+			}, TxnStatusRollback
+		}
+		result := []map[string]any{}
+		for _, delivery := range list {
+			result = append(result, webhookDeliveryResult(delivery))
+		}
+		return func(writer http.ResponseWriter, request *http.Request) {
+			render.JSON(writer, request, map[string]any{
+				"list": result,
+			})
+		}, TxnStatusCommit
+	})
+}
+
+func (c *ConfigureController) RedeliverWebhook(w http.ResponseWriter, r *http.Request) {
+	c.RunInTxn(w, r, func(ctx context.Context) (RenderFn, TxnStatus) {
+		deliveryIdStr := strings.TrimSpace(chi.URLParam(r, "delivery_id"))
+		deliveryId, err := strconv.ParseInt(deliveryIdStr, 10, 64)
+		if err != nil {
+			log.Println("invalid format of deliveryId:", deliveryIdStr)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusBadRequest)
+			}, TxnStatusRollback
+		}
+
+		delivery, err := c.ConfigureHandler.RedeliverWebhook(ctx, deliveryId)
+		if errors.Is(err, domains.ErrDeliveryNotFound) {
+			log.Println("redeliver webhook failed:", err)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusNotFound)
+			}, TxnStatusRollback
+		} else if err != nil {
+			log.Println("redeliver webhook failed:", err)
+			return func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusInternalServerError)
+			}, TxnStatusRollback
+		}
+		return func(writer http.ResponseWriter, request *http.Request) {
+			render.JSON(writer, request, webhookDeliveryResult(delivery))
+		}, TxnStatusCommit
+	})
+}
+
+func webhookResult(webhook *domains.Webhook, withSecret bool) map[string]any {
+	result := map[string]any{
+		"webhook_id":   fmt.Sprint(webhook.WebhookId),
+		"org_id":       webhook.OrgId,
+		"app_id":       webhook.AppId,
+		"url":          webhook.Url,
+		"description":  webhook.Description,
+		"time_created": webhook.TimeCreated,
+	}
+	if withSecret {
+		result["secret"] = webhook.Secret
+	}
+	return result
+}
+
+func webhookDeliveryResult(delivery *domains.WebhookDelivery) map[string]any {
+	return map[string]any{
+		"delivery_id":       fmt.Sprint(delivery.DeliveryId),
+		"webhook_id":        fmt.Sprint(delivery.WebhookId),
+		"event":             delivery.Event,
+		"payload":           delivery.Payload,
+		"status":            delivery.Status,
+		"attempts":          delivery.Attempts,
+		"time_next_attempt": delivery.TimeNextAttempt,
+		"response_status":   delivery.ResponseStatus,
+		"message":           delivery.Message,
+		"time_created":      delivery.TimeCreated,
+		"time_updated":      delivery.TimeUpdated,
+	}
+}
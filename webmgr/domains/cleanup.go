@@ -0,0 +1,210 @@
+package domains
+
+import (
+	"context"
+	"slices"
+	"time"
+)
+
+const (
+	PublishedStatusValid   = 0
+	PublishedStatusDeleted = 1
+)
+
+// PublishedConfiguration is a configuration served by the read servers
+type PublishedConfiguration struct {
+	ConfigId          int64
+	Selectors         string
+	OptionalSelectors string
+	Group             string
+	Key               string
+	Version           string
+	Status            int64
+	TimeUpdated       int64
+}
+
+func (p *PublishedConfiguration) identity() string {
+	return p.Selectors + "\x00" + p.OptionalSelectors + "\x00" + p.Group + "\x00" + p.Key
+}
+
+type CleanupReport struct {
+	// Orphans are the valid published configurations without any live configure in the web manager
+	Orphans []*PublishedConfiguration
+	// Purgeable are the deleted published configurations older than the retention
+	Purgeable []*PublishedConfiguration
+
+	UnusedApplications []string
+	UnusedEnvironments []string
+	UnusedDatacenters  []string
+	UnusedNamespaces   []string
+}
+
+// CleanupHandler finds and removes the published configurations not managed by the web manager any more.
+// Orphans are marked as deleted rather than removed, so the read servers are able to drop them by the incremental
+// changes. The deleted ones are purged after the retention.
+type CleanupHandler struct {
+	ConfigureRepository ConfigureRepository
+	CleanupRepository   CleanupRepository
+}
+
+// liveConfigurations returns the identities of the published configurations expected by the live configures
+func (c *CleanupHandler) liveConfigurations(ctx context.Context) (map[string]struct{}, []*Configure, map[string]*Namespace, map[int64]*Application, error) {
+	apps, err := c.CleanupRepository.LoadAllApplications(ctx)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	appMap := map[int64]*Application{}
+	for _, app := range apps {
+		appMap[app.ApplicationId] = app
+	}
+	namespaces, err := c.CleanupRepository.LoadAllNamespaces(ctx)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	nsMap := map[string]*Namespace{}
+	for _, ns := range namespaces {
+		nsMap[ns.Name] = ns
+	}
+	configures, err := c.CleanupRepository.LoadAllConfigures(ctx)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	live := map[string]struct{}{}
+	var liveConfigures []*Configure
+	for _, cfg := range configures {
+		if cfg.ConfigStatus != 0 {
+			continue
+		}
+		ns, ok := nsMap[cfg.ConfigNamespace]
+		if !ok {
+			continue
+		}
+		app, ok := appMap[ns.OwnerAppId]
+		if !ok {
+			continue
+		}
+		// the same as what is pushed by ApplyConfigureChange
+		p := &PublishedConfiguration{
+			Selectors: cfg.GenerateSelectorsString(app),
+			Group:     cfg.ConfigNamespace,
+			Key:       cfg.ConfigKey,
+		}
+		live[p.identity()] = struct{}{}
+		liveConfigures = append(liveConfigures, cfg)
+	}
+	return live, liveConfigures, nsMap, appMap, nil
+}
+
+// Inspect reports the orphans, the purgeable configurations and the entities without usage
+func (c *CleanupHandler) Inspect(ctx context.Context, retention time.Duration, now time.Time) (*CleanupReport, error) {
+	live, liveConfigures, nsMap, appMap, err := c.liveConfigurations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	published, err := c.CleanupRepository.LoadPublishedConfigurations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	report := new(CleanupReport)
+	deletedBefore := now.Add(-retention).UnixMilli()
+	for _, p := range published {
+		switch p.Status {
+		case PublishedStatusValid:
+			if _, ok := live[p.identity()]; !ok {
+				report.Orphans = append(report.Orphans, p)
+			}
+		case PublishedStatusDeleted:
+			if p.TimeUpdated < deletedBefore {
+				report.Purgeable = append(report.Purgeable, p)
+			}
+		}
+	}
+
+	// usages
+	usedNs := map[string]struct{}{}
+	usedApp := map[int64]struct{}{}
+	usedEnv := map[string]struct{}{}
+	usedDc := map[string]struct{}{}
+	for _, cfg := range liveConfigures {
+		usedNs[cfg.ConfigNamespace] = struct{}{}
+		usedApp[nsMap[cfg.ConfigNamespace].OwnerAppId] = struct{}{}
+		usedEnv[cfg.ConfigEnv] = struct{}{}
+		usedDc[cfg.ConfigDc] = struct{}{}
+	}
+	links, err := c.CleanupRepository.LoadAllAppEnvAndDc(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, link := range links {
+		usedEnv[link.EnvName] = struct{}{}
+		usedDc[link.DcName] = struct{}{}
+	}
+	for _, ns := range nsMap {
+		if _, ok := usedNs[ns.Name]; !ok {
+			report.UnusedNamespaces = append(report.UnusedNamespaces, ns.Name)
+		}
+	}
+	for _, app := range appMap {
+		if _, ok := usedApp[app.ApplicationId]; !ok {
+			report.UnusedApplications = append(report.UnusedApplications, app.ApplicationName)
+		}
+	}
+	envs, err := c.ConfigureRepository.LoadEnvList(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, env := range envs {
+		if _, ok := usedEnv[env.EnvName]; !ok {
+			report.UnusedEnvironments = append(report.UnusedEnvironments, env.EnvName)
+		}
+	}
+	dcs, err := c.ConfigureRepository.LoadDcList(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, dc := range dcs {
+		if _, ok := usedDc[dc.DatacenterName]; !ok {
+			report.UnusedDatacenters = append(report.UnusedDatacenters, dc.DatacenterName)
+		}
+	}
+	slices.Sort(report.UnusedNamespaces)
+	slices.Sort(report.UnusedApplications)
+	slices.Sort(report.UnusedEnvironments)
+	slices.Sort(report.UnusedDatacenters)
+	return report, nil
+}
+
+// MarkOrphansDeleted marks the orphans as deleted and returns the number of the marked ones.
+// The orphans are checked again in case of the configures are added after inspection.
+func (c *CleanupHandler) MarkOrphansDeleted(ctx context.Context, orphans []*PublishedConfiguration) (int, error) {
+	live, _, _, _, err := c.liveConfigurations(ctx)
+	if err != nil {
+		return 0, err
+	}
+	cnt := 0
+	for _, p := range orphans {
+		if _, ok := live[p.identity()]; ok {
+			continue
+		}
+		if marked, err := c.CleanupRepository.MarkConfigurationDeleted(ctx, p.ConfigId); err != nil {
+			return 0, err
+		} else if marked {
+			cnt++
+		}
+	}
+	return cnt, nil
+}
+
+// Purge removes the deleted configurations older than the retention and returns the number of the removed ones
+func (c *CleanupHandler) Purge(ctx context.Context, purgeable []*PublishedConfiguration, retention time.Duration, now time.Time) (int, error) {
+	deletedBefore := now.Add(-retention).UnixMilli()
+	cnt := 0
+	for _, p := range purgeable {
+		if purged, err := c.CleanupRepository.PurgeConfiguration(ctx, p.ConfigId, deletedBefore); err != nil {
+			return 0, err
+		} else if purged {
+			cnt++
+		}
+	}
+	return cnt, nil
+}
@@ -0,0 +1,18 @@
+package domains
+
+import "context"
+
+type CleanupRepository interface {
+	LoadPublishedConfigurations(ctx context.Context) ([]*PublishedConfiguration, error)
+	LoadAllConfigures(ctx context.Context) ([]*Configure, error)
+	LoadAllNamespaces(ctx context.Context) ([]*Namespace, error)
+	LoadAllApplications(ctx context.Context) ([]*Application, error)
+	LoadAllAppEnvAndDc(ctx context.Context) ([]struct {
+		AppId   int64
+		EnvName string
+		DcName  string
+	}, error)
+
+	MarkConfigurationDeleted(ctx context.Context, cfgId int64) (bool, error)
+	PurgeConfiguration(ctx context.Context, cfgId int64, deletedBefore int64) (bool, error)
+}
@@ -0,0 +1,135 @@
+package domains
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+// memCleanupRepository is an in-memory CleanupRepository and the env/dc part of ConfigureRepository
+type memCleanupRepository struct {
+	ConfigureRepository
+
+	published  []*PublishedConfiguration
+	configures []*Configure
+	namespaces []*Namespace
+	apps       []*Application
+	links      []struct {
+		AppId   int64
+		EnvName string
+		DcName  string
+	}
+}
+
+func (m *memCleanupRepository) LoadEnvList(ctx context.Context) ([]*Environment, error) {
+	return []*Environment{{EnvName: "DEV"}, {EnvName: "PROD"}, {EnvName: "UAT"}}, nil
+}
+
+func (m *memCleanupRepository) LoadDcList(ctx context.Context) ([]*Datacenter, error) {
+	return []*Datacenter{{DatacenterName: "dc1"}, {DatacenterName: "dc2"}}, nil
+}
+
+func (m *memCleanupRepository) LoadPublishedConfigurations(ctx context.Context) ([]*PublishedConfiguration, error) {
+	return m.published, nil
+}
+
+func (m *memCleanupRepository) LoadAllConfigures(ctx context.Context) ([]*Configure, error) {
+	return m.configures, nil
+}
+
+func (m *memCleanupRepository) LoadAllNamespaces(ctx context.Context) ([]*Namespace, error) {
+	return m.namespaces, nil
+}
+
+func (m *memCleanupRepository) LoadAllApplications(ctx context.Context) ([]*Application, error) {
+	return m.apps, nil
+}
+
+func (m *memCleanupRepository) LoadAllAppEnvAndDc(ctx context.Context) ([]struct {
+	AppId   int64
+	EnvName string
+	DcName  string
+}, error) {
+	return m.links, nil
+}
+
+func (m *memCleanupRepository) MarkConfigurationDeleted(ctx context.Context, cfgId int64) (bool, error) {
+	for _, p := range m.published {
+		if p.ConfigId == cfgId && p.Status == PublishedStatusValid {
+			p.Status = PublishedStatusDeleted
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *memCleanupRepository) PurgeConfiguration(ctx context.Context, cfgId int64, deletedBefore int64) (bool, error) {
+	for i, p := range m.published {
+		if p.ConfigId == cfgId && p.Status == PublishedStatusDeleted && p.TimeUpdated < deletedBefore {
+			m.published = slices.Delete(m.published, i, i+1)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func TestCleanup(t *testing.T) {
+	now := time.Now()
+	app1 := &Application{ApplicationId: 1, ApplicationName: "app1"}
+	live := &Configure{ConfigKey: "k1", ConfigNamespace: "ns1", ConfigEnv: "PROD", ConfigDc: "dc1"}
+	repo := &memCleanupRepository{
+		apps:       []*Application{app1, {ApplicationId: 2, ApplicationName: "app2"}},
+		namespaces: []*Namespace{{Name: "ns1", OwnerAppId: 1}, {Name: "ns2", OwnerAppId: 1}},
+		configures: []*Configure{
+			live,
+			// deleted configure
+			{ConfigKey: "k2", ConfigNamespace: "ns1", ConfigEnv: "PROD", ConfigDc: "dc1", ConfigStatus: 1},
+		},
+		links: []struct {
+			AppId   int64
+			EnvName string
+			DcName  string
+		}{{AppId: 1, EnvName: "DEV", DcName: "dc1"}},
+		published: []*PublishedConfiguration{
+			{ConfigId: 1, Selectors: live.GenerateSelectorsString(app1), Group: "ns1", Key: "k1"},
+			{ConfigId: 2, Selectors: live.GenerateSelectorsString(app1), Group: "ns1", Key: "k2"},
+			{ConfigId: 3, Selectors: "app=gone,dc=dc1,env=PROD", Group: "ns9", Key: "k1"},
+			{ConfigId: 4, Selectors: "app=gone,dc=dc1,env=PROD", Group: "ns9", Key: "k2", Status: PublishedStatusDeleted, TimeUpdated: now.Add(-48 * time.Hour).UnixMilli()},
+			{ConfigId: 5, Selectors: "app=gone,dc=dc1,env=PROD", Group: "ns9", Key: "k3", Status: PublishedStatusDeleted, TimeUpdated: now.Add(-time.Hour).UnixMilli()},
+		},
+	}
+	h := &CleanupHandler{ConfigureRepository: repo, CleanupRepository: repo}
+	ctx := context.Background()
+
+	report, err := h.Inspect(ctx, 24*time.Hour, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := func(list []*PublishedConfiguration) (r []int64) {
+		for _, p := range list {
+			r = append(r, p.ConfigId)
+		}
+		return
+	}
+	if !slices.Equal(ids(report.Orphans), []int64{2, 3}) || !slices.Equal(ids(report.Purgeable), []int64{4}) {
+		t.Fatal("unexpected report:", ids(report.Orphans), ids(report.Purgeable))
+	}
+	if !slices.Equal(report.UnusedApplications, []string{"app2"}) || !slices.Equal(report.UnusedNamespaces, []string{"ns2"}) ||
+		!slices.Equal(report.UnusedEnvironments, []string{"UAT"}) || !slices.Equal(report.UnusedDatacenters, []string{"dc2"}) {
+		t.Fatal("unexpected unused entities:", report)
+	}
+
+	// k2 is added again after inspection
+	repo.configures[1].ConfigStatus = 0
+	if cnt, err := h.MarkOrphansDeleted(ctx, report.Orphans); err != nil {
+		t.Fatal(err)
+	} else if cnt != 1 || repo.published[1].Status != PublishedStatusValid || repo.published[2].Status != PublishedStatusDeleted {
+		t.Fatal("only the orphans should be marked as deleted:", cnt)
+	}
+	if cnt, err := h.Purge(ctx, report.Purgeable, 24*time.Hour, now); err != nil {
+		t.Fatal(err)
+	} else if cnt != 1 || len(repo.published) != 4 {
+		t.Fatal("unexpected purge:", cnt, len(repo.published))
+	}
+}
@@ -0,0 +1,407 @@
+package domains
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/goodplayer/onlyconfig/webmgr/tools"
+)
+
+const ConfigureArchiveVersion = 1
+
+const (
+	ArchiveFormatJson = "json"
+	ArchiveFormatYaml = "yaml"
+)
+
+var ErrInvalidArchive = errors.New("invalid configure archive")
+var ErrImportConflict = errors.New("configure conflicts with existing one")
+
+// ConfigureArchive is the manifest and contents of the configurations of an application
+type ConfigureArchive struct {
+	ArchiveVersion int                `json:"archive_version" yaml:"archive_version"`
+	Application    string             `json:"application" yaml:"application"`
+	TimeExported   int64              `json:"time_exported" yaml:"time_exported"`
+	EnvAndDcList   []ArchiveEnvDc     `json:"env_and_dc_list" yaml:"env_and_dc_list"`
+	Namespaces     []ArchiveNamespace `json:"namespaces" yaml:"namespaces"`
+	Configures     []ArchiveConfigure `json:"configures" yaml:"configures"`
+}
+
+type ArchiveEnvDc struct {
+	Env string `json:"env" yaml:"env"`
+	Dc  string `json:"dc" yaml:"dc"`
+}
+
+type ArchiveNamespace struct {
+	Name string `json:"name" yaml:"name"`
+	Type string `json:"type" yaml:"type"`
+}
+
+type ArchiveConfigure struct {
+	Namespace   string `json:"namespace" yaml:"namespace"`
+	Env         string `json:"env" yaml:"env"`
+	Dc          string `json:"dc" yaml:"dc"`
+	Key         string `json:"key" yaml:"key"`
+	ContentType string `json:"content_type" yaml:"content_type"`
+	Content     string `json:"content" yaml:"content"`
+	// Version is informational and ignored on import
+	Version string `json:"version,omitempty" yaml:"version,omitempty"`
+	// Secret content is masked unless revealed on export, and the masked one keeps the existing content on import
+	Secret bool `json:"secret,omitempty" yaml:"secret,omitempty"`
+}
+
+func (a *ConfigureArchive) Encode(format string) ([]byte, error) {
+	switch format {
+	case ArchiveFormatJson:
+		return json.MarshalIndent(a, "", "  ")
+	case ArchiveFormatYaml:
+		return yaml.Marshal(a)
+	default:
+		return nil, errors.New("unknown archive format:" + format)
+	}
+}
+
+func DecodeConfigureArchive(data []byte, format string) (*ConfigureArchive, error) {
+	a := new(ConfigureArchive)
+	switch format {
+	case ArchiveFormatJson:
+		if err := json.Unmarshal(data, a); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidArchive, err)
+		}
+	case ArchiveFormatYaml:
+		if err := yaml.Unmarshal(data, a); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidArchive, err)
+		}
+	default:
+		return nil, errors.New("unknown archive format:" + format)
+	}
+	return a, nil
+}
+
+// Validate checks the archive itself regardless of the target application
+func (a *ConfigureArchive) Validate() error {
+	invalid := func(msg string, args ...any) error {
+		return fmt.Errorf("%w: %s", ErrInvalidArchive, fmt.Sprint(append([]any{msg}, args...)...))
+	}
+	if a.ArchiveVersion != ConfigureArchiveVersion {
+		return invalid("unsupported archive_version:", a.ArchiveVersion)
+	}
+	namespaces := map[string]struct{}{}
+	for _, ns := range a.Namespaces {
+		if !tools.ValidateName(ns.Name) {
+			return invalid("invalid format of namespace:", ns.Name)
+		}
+		switch ns.Type {
+		case "public":
+		case "application":
+		default:
+			return invalid("invalid format of namespace type:", ns.Type)
+		}
+		if _, ok := namespaces[ns.Name]; ok {
+			return invalid("duplicated namespace:", ns.Name)
+		}
+		namespaces[ns.Name] = struct{}{}
+	}
+	configures := map[ArchiveConfigure]struct{}{}
+	for _, cfg := range a.Configures {
+		if cfg.Namespace == "" || cfg.Env == "" || cfg.Dc == "" {
+			return invalid("empty namespace or env or dc of key:", cfg.Key)
+		}
+		if !tools.ValidateName(cfg.Key) {
+			return invalid("invalid format of key:", cfg.Key)
+		}
+		switch cfg.ContentType {
+		case "general":
+		default:
+			return invalid("invalid format of content type:", cfg.ContentType)
+		}
+		id := ArchiveConfigure{Namespace: cfg.Namespace, Env: cfg.Env, Dc: cfg.Dc, Key: cfg.Key}
+		if _, ok := configures[id]; ok {
+			return invalid("duplicated configure:", cfg.Env, "/", cfg.Dc, "/", cfg.Namespace, "/", cfg.Key)
+		}
+		configures[id] = struct{}{}
+	}
+	return nil
+}
+
+// ExportApplication exports the namespaces and the configurations of the application in the env and dc list.
+// All the linked env and dc are exported if envDcList is empty. The secret contents are masked unless revealed.
+func (c *ConfigureHandler) ExportApplication(ctx context.Context, appId int64, envDcList []ArchiveEnvDc) (*ConfigureArchive, error) {
+	app, err := c.ConfigureRepository.LoadApplicationById(ctx, appId)
+	if err != nil {
+		return nil, err
+	}
+	if len(envDcList) == 0 {
+		linked, err := c.ConfigureRepository.LoadEnvAndDcListByAppId(ctx, appId)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range linked {
+			envDcList = append(envDcList, ArchiveEnvDc{Env: item.EnvName, Dc: item.DcName})
+		}
+	}
+	archive := &ConfigureArchive{
+		ArchiveVersion: ConfigureArchiveVersion,
+		Application:    app.ApplicationName,
+		TimeExported:   time.Now().UnixMilli(),
+		EnvAndDcList:   []ArchiveEnvDc{},
+		Namespaces:     []ArchiveNamespace{},
+		Configures:     []ArchiveConfigure{},
+	}
+	nsList, err := c.ConfigureRepository.LoadAppNamespaces(ctx, app)
+	if err != nil {
+		return nil, err
+	}
+	for _, ns := range nsList {
+		archive.Namespaces = append(archive.Namespaces, ArchiveNamespace{Name: ns.Name, Type: ns.Type})
+	}
+	for _, item := range envDcList {
+		env, dc, err := c.loadAppEnvDc(ctx, app, item)
+		if err != nil {
+			return nil, err
+		}
+		archive.EnvAndDcList = append(archive.EnvAndDcList, item)
+		list, err := c.ConfigureRepository.LoadAppConfigList(ctx, app, env, dc)
+		if err != nil {
+			return nil, err
+		}
+		for _, cfg := range list {
+			cfg, err := c.maskedConfigure(ctx, cfg, RevealActionExport)
+			if err != nil {
+				return nil, err
+			}
+			archive.Configures = append(archive.Configures, ArchiveConfigure{
+				Namespace:   cfg.ConfigNamespace,
+				Env:         cfg.ConfigEnv,
+				Dc:          cfg.ConfigDc,
+				Key:         cfg.ConfigKey,
+				ContentType: cfg.ContentType,
+				Content:     cfg.Content,
+				Version:     cfg.ConfigVersion,
+				Secret:      cfg.Secret,
+			})
+		}
+	}
+	slices.SortFunc(archive.Namespaces, func(a, b ArchiveNamespace) int {
+		return cmp.Compare(a.Name, b.Name)
+	})
+	slices.SortFunc(archive.Configures, func(a, b ArchiveConfigure) int {
+		return cmp.Or(cmp.Compare(a.Env, b.Env), cmp.Compare(a.Dc, b.Dc), cmp.Compare(a.Namespace, b.Namespace), cmp.Compare(a.Key, b.Key))
+	})
+	return archive, nil
+}
+
+// loadAppEnvDc loads env and dc and checks they are linked to the application
+func (c *ConfigureHandler) loadAppEnvDc(ctx context.Context, app *Application, item ArchiveEnvDc) (*Environment, *Datacenter, error) {
+	env, err := c.ConfigureRepository.LoadEnvironment(ctx, item.Env)
+	if err != nil {
+		return nil, nil, err
+	}
+	dc, err := c.ConfigureRepository.LoadDatacenter(ctx, item.Dc)
+	if err != nil {
+		return nil, nil, err
+	}
+	if exists, err := c.ConfigureRepository.ExistsAppEnvDcMapping(ctx, env, dc, app); err != nil {
+		return nil, nil, err
+	} else if !exists {
+		return nil, nil, errors.New("app-env-dc mapping not exists: " + item.Env + "/" + item.Dc)
+	}
+	return env, dc, nil
+}
+
+type ImportStrategy string
+
+const (
+	// ImportStrategySkip keeps the existing configurations
+	ImportStrategySkip ImportStrategy = "skip"
+	// ImportStrategyOverwrite replaces the existing configurations
+	ImportStrategyOverwrite ImportStrategy = "overwrite"
+	// ImportStrategyFail rejects the whole import if any existing configuration is different
+	ImportStrategyFail ImportStrategy = "fail"
+)
+
+const (
+	ImportActionCreate    = "create"
+	ImportActionUpdate    = "update"
+	ImportActionSkip      = "skip"
+	ImportActionUnchanged = "unchanged"
+	ImportActionConflict  = "conflict"
+)
+
+type ImportRequest struct {
+	AppId    int64
+	Archive  *ConfigureArchive
+	Strategy ImportStrategy
+	DryRun   bool
+}
+
+type ImportItem struct {
+	Namespace string `json:"namespace"`
+	Env       string `json:"env,omitempty"`
+	Dc        string `json:"dc,omitempty"`
+	// Key is empty for namespace item
+	Key    string `json:"key,omitempty"`
+	Action string `json:"action"`
+}
+
+type ImportResult struct {
+	DryRun bool          `json:"dry_run"`
+	Items  []*ImportItem `json:"items"`
+}
+
+type importPlan struct {
+	item     *ImportItem
+	ns       *ArchiveNamespace
+	cfg      *ArchiveConfigure
+	existing *Configure
+}
+
+// ImportApplication applies the archive to the application, missing namespaces are created.
+// The whole archive is validated and planned before any change. For ImportStrategyFail, ErrImportConflict is returned
+// with the result containing conflicts and nothing is applied. Nothing is applied in dry run either.
+func (c *ConfigureHandler) ImportApplication(ctx context.Context, req *ImportRequest) (*ImportResult, error) {
+	switch req.Strategy {
+	case ImportStrategySkip:
+	case ImportStrategyOverwrite:
+	case ImportStrategyFail:
+	default:
+		return nil, errors.New("unknown import strategy:" + string(req.Strategy))
+	}
+	archive := req.Archive
+	if err := archive.Validate(); err != nil {
+		return nil, err
+	}
+	app, err := c.ConfigureRepository.LoadApplicationById(ctx, req.AppId)
+	if err != nil {
+		return nil, err
+	}
+
+	var plans []*importPlan
+	// namespaces
+	owned := map[string]struct{}{}
+	nsList, err := c.ConfigureRepository.LoadAppNamespaces(ctx, app)
+	if err != nil {
+		return nil, err
+	}
+	for _, ns := range nsList {
+		owned[ns.Name] = struct{}{}
+	}
+	for i := range archive.Namespaces {
+		ns := &archive.Namespaces[i]
+		if _, ok := owned[ns.Name]; ok {
+			continue
+		}
+		if exists, err := c.ConfigureRepository.ExistsNamespace(ctx, ns.Name); err != nil {
+			return nil, err
+		} else if exists {
+			return nil, fmt.Errorf("%w: namespace owned by other application: %s", ErrInvalidArchive, ns.Name)
+		}
+		owned[ns.Name] = struct{}{}
+		plans = append(plans, &importPlan{
+			item: &ImportItem{Namespace: ns.Name, Action: ImportActionCreate},
+			ns:   ns,
+		})
+	}
+
+	// configurations
+	existing := map[ArchiveEnvDc]map[string]*Configure{}
+	conflict := false
+	for i := range archive.Configures {
+		cfg := &archive.Configures[i]
+		if _, ok := owned[cfg.Namespace]; !ok {
+			return nil, fmt.Errorf("%w: namespace not found: %s", ErrInvalidArchive, cfg.Namespace)
+		}
+		envDc := ArchiveEnvDc{Env: cfg.Env, Dc: cfg.Dc}
+		cfgMap, ok := existing[envDc]
+		if !ok {
+			env, dc, err := c.loadAppEnvDc(ctx, app, envDc)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %w", ErrInvalidArchive, err)
+			}
+			list, err := c.ConfigureRepository.LoadAppConfigList(ctx, app, env, dc)
+			if err != nil {
+				return nil, err
+			}
+			cfgMap = map[string]*Configure{}
+			for _, v := range list {
+				cfgMap[v.ConfigNamespace+"/"+v.ConfigKey] = v
+			}
+			existing[envDc] = cfgMap
+		}
+		plan := &importPlan{
+			item: &ImportItem{Namespace: cfg.Namespace, Env: cfg.Env, Dc: cfg.Dc, Key: cfg.Key},
+			cfg:  cfg,
+		}
+		current, ok := cfgMap[cfg.Namespace+"/"+cfg.Key]
+		masked := cfg.Secret && cfg.Content == MaskedContent
+		switch {
+		case masked && !ok:
+			return nil, fmt.Errorf("%w: masked content of new secret configure: %s/%s/%s/%s", ErrInvalidArchive, cfg.Env, cfg.Dc, cfg.Namespace, cfg.Key)
+		case masked:
+			plan.item.Action = ImportActionUnchanged
+		case !ok:
+			plan.item.Action = ImportActionCreate
+		case current.ContentType == cfg.ContentType && current.Content == cfg.Content:
+			plan.item.Action = ImportActionUnchanged
+		case req.Strategy == ImportStrategySkip:
+			plan.item.Action = ImportActionSkip
+		case req.Strategy == ImportStrategyOverwrite:
+			plan.item.Action = ImportActionUpdate
+			plan.existing = current
+		default:
+			plan.item.Action = ImportActionConflict
+			conflict = true
+		}
+		plans = append(plans, plan)
+	}
+
+	result := &ImportResult{DryRun: req.DryRun, Items: []*ImportItem{}}
+	for _, plan := range plans {
+		result.Items = append(result.Items, plan.item)
+	}
+	if conflict {
+		return result, ErrImportConflict
+	}
+	if req.DryRun {
+		return result, nil
+	}
+	for _, plan := range plans {
+		switch {
+		case plan.ns != nil:
+			if err := c.AddApplicationNamespace(ctx, app.ApplicationId, plan.ns.Name, plan.ns.Type); err != nil {
+				return nil, err
+			}
+		case plan.item.Action == ImportActionCreate:
+			if err := c.AddConfiguration(ctx, &AddConfigurationRequest{
+				AppId:       app.ApplicationId,
+				Env:         plan.cfg.Env,
+				Dc:          plan.cfg.Dc,
+				Namespace:   plan.cfg.Namespace,
+				Key:         plan.cfg.Key,
+				ContentType: plan.cfg.ContentType,
+				Content:     plan.cfg.Content,
+				Secret:      plan.cfg.Secret,
+			}); err != nil {
+				return nil, err
+			}
+		case plan.item.Action == ImportActionUpdate:
+			if err := c.UpdateConfigurationById(ctx, &UpdateConfigurationRequest{
+				ConfigId:      plan.existing.ConfigId,
+				ContentType:   plan.cfg.ContentType,
+				Content:       plan.cfg.Content,
+				ConfigVersion: plan.existing.ConfigVersion,
+				Secret:        plan.cfg.Secret,
+			}); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,331 @@
+package domains
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// memConfigureRepository is an in-memory ConfigureRepository with one application linked to PROD/dc1 and UAT/dc1
+type memConfigureRepository struct {
+	ConfigureRepository
+
+	app           *Application
+	namespaces    map[string]*Namespace
+	configures    []*Configure
+	seq           int64
+	decommissions []*Decommission
+	histories     []*ConfigureHistory
+	releases      []*Release
+	schedules     []*Schedule
+	// lockedByOthers makes LockSchedules fail without waiting
+	lockedByOthers bool
+	// linkingApps are the other applications linking the namespaces
+	linkingApps map[string][]*Application
+}
+
+func newMemConfigureRepository() *memConfigureRepository {
+	return &memConfigureRepository{
+		app:        &Application{ApplicationId: 1, ApplicationName: "app1"},
+		namespaces: map[string]*Namespace{},
+	}
+}
+
+func (m *memConfigureRepository) LoadApplicationById(ctx context.Context, applicationId int64) (*Application, error) {
+	for _, apps := range m.linkingApps {
+		for _, app := range apps {
+			if app.ApplicationId == applicationId {
+				return app, nil
+			}
+		}
+	}
+	if applicationId != m.app.ApplicationId {
+		return nil, errors.New("application not found")
+	}
+	return m.app, nil
+}
+
+func (m *memConfigureRepository) LoadEnvAndDcListByAppId(ctx context.Context, appId int64) ([]struct {
+	EnvName string
+	DcName  string
+}, error) {
+	return []struct {
+		EnvName string
+		DcName  string
+	}{{EnvName: "PROD", DcName: "dc1"}}, nil
+}
+
+func (m *memConfigureRepository) LoadEnvironment(ctx context.Context, env string) (*Environment, error) {
+	return &Environment{EnvName: env}, nil
+}
+
+func (m *memConfigureRepository) LoadDatacenter(ctx context.Context, dc string) (*Datacenter, error) {
+	return &Datacenter{DatacenterName: dc}, nil
+}
+
+func (m *memConfigureRepository) ExistsAppEnvDcMapping(ctx context.Context, env *Environment, dc *Datacenter, app *Application) (bool, error) {
+	return (env.EnvName == "PROD" || env.EnvName == "UAT") && dc.DatacenterName == "dc1", nil
+}
+
+func (m *memConfigureRepository) LoadAppNamespaces(ctx context.Context, app *Application) (result []*Namespace, rerr error) {
+	for _, ns := range m.namespaces {
+		if ns.IsOwnerApp(app) {
+			result = append(result, ns)
+		}
+	}
+	return
+}
+
+func (m *memConfigureRepository) ExistsNamespace(ctx context.Context, nsName string) (bool, error) {
+	_, ok := m.namespaces[nsName]
+	return ok, nil
+}
+
+func (m *memConfigureRepository) ExistsApplicationNamespace(ctx context.Context, app *Application, nsName string) (bool, error) {
+	ns, ok := m.namespaces[nsName]
+	return ok && ns.IsOwnerApp(app), nil
+}
+
+func (m *memConfigureRepository) AddApplicationNamespace(ctx context.Context, app *Application, ns *Namespace) error {
+	m.namespaces[ns.Name] = ns
+	return nil
+}
+
+func (m *memConfigureRepository) LoadNamespace(ctx context.Context, nsName string) (*Namespace, error) {
+	ns, ok := m.namespaces[nsName]
+	if !ok {
+		return nil, errors.New("namespace not found")
+	}
+	return ns, nil
+}
+
+func (m *memConfigureRepository) LoadAppConfigList(ctx context.Context, app *Application, env *Environment, dc *Datacenter) (result []*Configure, rerr error) {
+	for _, cfg := range m.configures {
+		if cfg.ConfigEnv == env.EnvName && cfg.ConfigDc == dc.DatacenterName && m.namespaces[cfg.ConfigNamespace].IsOwnerApp(app) {
+			result = append(result, cfg)
+		}
+	}
+	return
+}
+
+func (m *memConfigureRepository) ExistsConfigure(ctx context.Context, app *Application, env *Environment, dc *Datacenter, ns *Namespace, key string) (bool, error) {
+	for _, cfg := range m.configures {
+		if cfg.ConfigEnv == env.EnvName && cfg.ConfigDc == dc.DatacenterName && cfg.ConfigNamespace == ns.Name && cfg.ConfigKey == key {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *memConfigureRepository) NextConfigVersionSeq(ctx context.Context) (int64, error) {
+	m.seq++
+	return m.seq, nil
+}
+
+func (m *memConfigureRepository) AddConfiguration(ctx context.Context, cfg *Configure) error {
+	cfg.ConfigId = int64(len(m.configures) + 1)
+	m.configures = append(m.configures, cfg)
+	return nil
+}
+
+func (m *memConfigureRepository) LoadConfigureById(ctx context.Context, cfgId int64) (*Configure, error) {
+	for _, cfg := range m.configures {
+		if cfg.ConfigId == cfgId {
+			v := *cfg
+			return &v, nil
+		}
+	}
+	return nil, errors.New("config not found")
+}
+
+func (m *memConfigureRepository) UpdateConfiguration(ctx context.Context, cfg *Configure) error {
+	for i, v := range m.configures {
+		if v.ConfigId == cfg.ConfigId {
+			m.configures[i] = cfg
+			return nil
+		}
+	}
+	return errors.New("config not found")
+}
+
+// memPushChangeRepository records the pushed configurations
+type memPushChangeRepository struct {
+	pushed    []string
+	published []*PublishedConfiguration
+	// fetched is the last fetch time by the id of the published configuration
+	fetched map[int64]int64
+}
+
+func (m *memPushChangeRepository) ExistsConfiguration(ctx context.Context, cfg *Configure, app *Application) (bool, error) {
+	return true, nil
+}
+
+func (m *memPushChangeRepository) InsertNewConfigure(ctx context.Context, cfg *Configure, app *Application) (int64, error) {
+	return 0, nil
+}
+
+func (m *memPushChangeRepository) UpdateConfigurationSequence(ctx context.Context, cfg *Configure, app *Application, configId int64) (bool, error) {
+	return true, nil
+}
+
+func (m *memPushChangeRepository) UpdateConfigure(ctx context.Context, cfg *Configure, app *Application) (bool, error) {
+	m.pushed = append(m.pushed, cfg.ConfigKey+"="+cfg.Content)
+	if p, _ := m.LoadPublishedConfiguration(ctx, cfg, app); p != nil {
+		p.Version = cfg.ConfigVersion
+		p.Status = PublishedStatusValid
+		return true, nil
+	}
+	m.published = append(m.published, &PublishedConfiguration{
+		ConfigId:  int64(len(m.published) + 1),
+		Selectors: cfg.GenerateSelectorsString(app),
+		Group:     cfg.ConfigNamespace,
+		Key:       cfg.ConfigKey,
+		Version:   cfg.ConfigVersion,
+	})
+	return true, nil
+}
+
+func (m *memPushChangeRepository) LoadFetchTime(ctx context.Context, p *PublishedConfiguration) (int64, error) {
+	return m.fetched[p.ConfigId], nil
+}
+
+func (m *memPushChangeRepository) LoadPublishedConfiguration(ctx context.Context, cfg *Configure, app *Application) (*PublishedConfiguration, error) {
+	for _, p := range m.published {
+		if p.Selectors == cfg.GenerateSelectorsString(app) && p.Group == cfg.ConfigNamespace && p.Key == cfg.ConfigKey {
+			return p, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *memPushChangeRepository) MarkConfigurationDeleted(ctx context.Context, configId int64) (bool, error) {
+	for _, p := range m.published {
+		if p.ConfigId == configId && p.Status == PublishedStatusValid {
+			p.Status = PublishedStatusDeleted
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func newArchive(content string) *ConfigureArchive {
+	return &ConfigureArchive{
+		ArchiveVersion: ConfigureArchiveVersion,
+		Namespaces:     []ArchiveNamespace{{Name: "ns1", Type: "application"}},
+		Configures: []ArchiveConfigure{
+			{Namespace: "ns1", Env: "PROD", Dc: "dc1", Key: "k1", ContentType: "general", Content: content},
+			{Namespace: "ns1", Env: "PROD", Dc: "dc1", Key: "k2", ContentType: "general", Content: "v2"},
+		},
+	}
+}
+
+func actions(result *ImportResult) (r []string) {
+	for _, item := range result.Items {
+		r = append(r, item.Key+":"+item.Action)
+	}
+	return
+}
+
+func TestImportApplication(t *testing.T) {
+	repo := newMemConfigureRepository()
+	push := new(memPushChangeRepository)
+	h := &ConfigureHandler{ConfigureRepository: repo, PushChangeRepository: push}
+	ctx := context.Background()
+
+	// dry run
+	result, err := h.ImportApplication(ctx, &ImportRequest{AppId: 1, Archive: newArchive("v1"), Strategy: ImportStrategyFail, DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := actions(result); len(got) != 3 || got[0] != ":create" || got[1] != "k1:create" || got[2] != "k2:create" {
+		t.Fatal("unexpected plan:", got)
+	}
+	if len(repo.namespaces) != 0 || len(repo.configures) != 0 {
+		t.Fatal("dry run should not apply")
+	}
+
+	// create
+	if _, err := h.ImportApplication(ctx, &ImportRequest{AppId: 1, Archive: newArchive("v1"), Strategy: ImportStrategyFail}); err != nil {
+		t.Fatal(err)
+	}
+	if len(repo.namespaces) != 1 || len(repo.configures) != 2 {
+		t.Fatal("import not applied")
+	}
+
+	// conflict
+	result, err = h.ImportApplication(ctx, &ImportRequest{AppId: 1, Archive: newArchive("v1-new"), Strategy: ImportStrategyFail})
+	if !errors.Is(err, ErrImportConflict) {
+		t.Fatal("conflict expected:", err)
+	}
+	if got := actions(result); got[0] != "k1:conflict" || got[1] != "k2:unchanged" {
+		t.Fatal("unexpected plan:", got)
+	}
+	result, err = h.ImportApplication(ctx, &ImportRequest{AppId: 1, Archive: newArchive("v1-new"), Strategy: ImportStrategySkip})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := actions(result); got[0] != "k1:skip" || repo.configures[0].Content != "v1" {
+		t.Fatal("existing configure should be skipped:", got)
+	}
+	result, err = h.ImportApplication(ctx, &ImportRequest{AppId: 1, Archive: newArchive("v1-new"), Strategy: ImportStrategyOverwrite})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := actions(result); got[0] != "k1:update" || repo.configures[0].Content != "v1-new" {
+		t.Fatal("existing configure should be overwritten:", got)
+	}
+	if len(push.pushed) != 3 || push.pushed[2] != "k1=v1-new" {
+		t.Fatal("changes should be pushed:", push.pushed)
+	}
+
+	// export and import back
+	archive, err := h.ExportApplication(ctx, 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, format := range []string{ArchiveFormatJson, ArchiveFormatYaml} {
+		data, err := archive.Encode(format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded, err := DecodeConfigureArchive(data, format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(decoded.Configures) != 2 || decoded.Configures[0].Content != "v1-new" || decoded.Namespaces[0].Name != "ns1" {
+			t.Fatal("unexpected archive:", string(data))
+		}
+		result, err := h.ImportApplication(ctx, &ImportRequest{AppId: 1, Archive: decoded, Strategy: ImportStrategyFail})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := actions(result); len(got) != 2 || got[0] != "k1:unchanged" || got[1] != "k2:unchanged" {
+			t.Fatal("unexpected plan:", got)
+		}
+	}
+}
+
+func TestImportApplicationInvalid(t *testing.T) {
+	repo := newMemConfigureRepository()
+	repo.namespaces["other"] = &Namespace{Name: "other", Type: "application", OwnerAppId: 2}
+	h := &ConfigureHandler{ConfigureRepository: repo, PushChangeRepository: new(memPushChangeRepository)}
+
+	cases := map[string]func(a *ConfigureArchive){
+		"version":         func(a *ConfigureArchive) { a.ArchiveVersion = 2 },
+		"content type":    func(a *ConfigureArchive) { a.Configures[0].ContentType = "json" },
+		"duplicated":      func(a *ConfigureArchive) { a.Configures[1].Key = "k1" },
+		"missing ns":      func(a *ConfigureArchive) { a.Namespaces = nil },
+		"other app ns":    func(a *ConfigureArchive) { a.Namespaces[0].Name = "other"; a.Configures = nil },
+		"unlinked env dc": func(a *ConfigureArchive) { a.Configures[0].Dc = "dc2" },
+	}
+	for name, modify := range cases {
+		archive := newArchive("v1")
+		modify(archive)
+		if _, err := h.ImportApplication(context.Background(), &ImportRequest{AppId: 1, Archive: archive, Strategy: ImportStrategySkip}); !errors.Is(err, ErrInvalidArchive) {
+			t.Fatal(name, "invalid archive expected:", err)
+		}
+		if len(repo.configures) != 0 || len(repo.namespaces) != 1 {
+			t.Fatal(name, "invalid archive should not be applied")
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package domains
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrVersionNotFound = errors.New("configure version not found")
+
+// ConfigureHistory is a saved version of the configure
+type ConfigureHistory struct {
+	HistoryId     int64
+	ConfigId      int64
+	ConfigVersion string
+	ContentType   string
+	Content       string
+	// ReleaseId is the release publishing the version, 0 if published alone
+	ReleaseId   int64
+	TimeCreated int64
+	// Secret history is encrypted at rest
+	Secret bool
+}
+
+func (c *ConfigureHandler) addConfigureHistory(ctx context.Context, cfg *Configure, releaseId int64) error {
+	return c.ConfigureRepository.AddConfigureHistory(ctx, &ConfigureHistory{
+		ConfigId:      cfg.ConfigId,
+		ConfigVersion: cfg.ConfigVersion,
+		ContentType:   cfg.ContentType,
+		Content:       cfg.Content,
+		ReleaseId:     releaseId,
+		TimeCreated:   time.Now().UnixMilli(),
+		Secret:        cfg.Secret,
+	})
+}
+
+// QueryConfigureHistory returns the versions of the configure, the latest first.
+// The contents of a secret configure are masked unless revealed.
+func (c *ConfigureHandler) QueryConfigureHistory(ctx context.Context, cfgId int64) ([]*ConfigureHistory, error) {
+	cfg, err := c.ConfigureRepository.LoadConfigureById(ctx, cfgId)
+	if err != nil {
+		return nil, err
+	}
+	list, err := c.ConfigureRepository.LoadConfigureHistoryList(ctx, cfgId)
+	if err != nil {
+		return nil, err
+	}
+	if revealed, err := c.revealSecret(ctx, cfg, RevealActionHistory); err != nil {
+		return nil, err
+	} else if revealed {
+		return list, nil
+	}
+	masked := make([]*ConfigureHistory, 0, len(list))
+	for _, h := range list {
+		h := *h
+		h.Content = MaskedContent
+		masked = append(masked, &h)
+	}
+	return masked, nil
+}
+
+func diffLabel(cfg *Configure, version string) string {
+	return fmt.Sprintf("%s/%s/%s/%s@%s", cfg.ConfigEnv, cfg.ConfigDc, cfg.ConfigNamespace, cfg.ConfigKey, version)
+}
+
+// configureVersion returns the content of the version, the current version if empty
+func (c *ConfigureHandler) configureVersion(ctx context.Context, cfg *Configure, version string) (*DiffSide, error) {
+	if version == "" || version == cfg.ConfigVersion {
+		return &DiffSide{
+			Label:       diffLabel(cfg, cfg.ConfigVersion),
+			Version:     cfg.ConfigVersion,
+			ContentType: cfg.ContentType,
+			Content:     cfg.Content,
+		}, nil
+	}
+	h, err := c.ConfigureRepository.LoadConfigureHistory(ctx, cfg.ConfigId, version)
+	if err != nil {
+		return nil, err
+	}
+	if h == nil {
+		return nil, fmt.Errorf("%w: %s", ErrVersionNotFound, version)
+	}
+	return &DiffSide{
+		Label:       diffLabel(cfg, h.ConfigVersion),
+		Version:     h.ConfigVersion,
+		ContentType: h.ContentType,
+		Content:     h.Content,
+	}, nil
+}
+
+// DiffConfigureVersions compares two versions of the configure, toVersion is the current version if empty.
+// format overrides the content type to choose the structural diff, see DiffContent.
+func (c *ConfigureHandler) DiffConfigureVersions(ctx context.Context, cfgId int64, fromVersion, toVersion, format string) (*ConfigureDiff, error) {
+	cfg, err := c.ConfigureRepository.LoadConfigureById(ctx, cfgId)
+	if err != nil {
+		return nil, err
+	}
+	from, err := c.configureVersion(ctx, cfg, fromVersion)
+	if err != nil {
+		return nil, err
+	}
+	to, err := c.configureVersion(ctx, cfg, toVersion)
+	if err != nil {
+		return nil, err
+	}
+	return c.maskedDiff(ctx, DiffContent(from, to, format), cfg)
+}
+
+// maskedDiff masks the diff if any of the configures is secret unless revealed
+func (c *ConfigureHandler) maskedDiff(ctx context.Context, diff *ConfigureDiff, cfgs ...*Configure) (*ConfigureDiff, error) {
+	for _, cfg := range cfgs {
+		if revealed, err := c.revealSecret(ctx, cfg, RevealActionDiff); err != nil {
+			return nil, err
+		} else if !revealed {
+			diff.mask()
+			return diff, nil
+		}
+	}
+	return diff, nil
+}
+
+type EnvDcDiffRequest struct {
+	AppId     int64
+	Namespace string
+	Key       string
+	FromEnv   string
+	FromDc    string
+	ToEnv     string
+	ToDc      string
+	Format    string
+}
+
+// DiffConfigureEnvDc compares the same namespace and key across two env/dc combinations of the application.
+// A missing configure on one side is compared as empty content with empty version.
+func (c *ConfigureHandler) DiffConfigureEnvDc(ctx context.Context, req *EnvDcDiffRequest) (*ConfigureDiff, error) {
+	app, err := c.ConfigureRepository.LoadApplicationById(ctx, req.AppId)
+	if err != nil {
+		return nil, err
+	}
+	ns, err := c.ConfigureRepository.LoadNamespace(ctx, req.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	if !ns.IsOwnerApp(app) {
+		return nil, errors.New("not owner app")
+	}
+	var cfgs []*Configure
+	load := func(envName, dcName string) (*DiffSide, bool, error) {
+		env, dc, err := c.loadAppEnvDc(ctx, app, ArchiveEnvDc{Env: envName, Dc: dcName})
+		if err != nil {
+			return nil, false, err
+		}
+		list, err := c.ConfigureRepository.LoadAppConfigList(ctx, app, env, dc)
+		if err != nil {
+			return nil, false, err
+		}
+		for _, cfg := range list {
+			if cfg.ConfigNamespace == ns.Name && cfg.ConfigKey == req.Key {
+				cfgs = append(cfgs, cfg)
+				side, err := c.configureVersion(ctx, cfg, "")
+				return side, true, err
+			}
+		}
+		missing := &Configure{ConfigEnv: env.EnvName, ConfigDc: dc.DatacenterName, ConfigNamespace: ns.Name, ConfigKey: req.Key}
+		return &DiffSide{Label: diffLabel(missing, "")}, false, nil
+	}
+	from, fromFound, err := load(req.FromEnv, req.FromDc)
+	if err != nil {
+		return nil, err
+	}
+	to, toFound, err := load(req.ToEnv, req.ToDc)
+	if err != nil {
+		return nil, err
+	}
+	if !fromFound && !toFound {
+		return nil, fmt.Errorf("%w: %s/%s not found in both env and dc", ErrVersionNotFound, ns.Name, req.Key)
+	}
+	// the missing side follows the content type of the other one
+	if !fromFound {
+		from.ContentType = to.ContentType
+	}
+	if !toFound {
+		to.ContentType = from.ContentType
+	}
+	return c.maskedDiff(ctx, DiffContent(from, to, req.Format), cfgs...)
+}
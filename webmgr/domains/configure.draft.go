@@ -0,0 +1,245 @@
+package domains
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+)
+
+var (
+	ErrNoDraft      = errors.New("configure has no draft")
+	ErrInvalidDraft = errors.New("invalid draft")
+)
+
+// ContentValidation is the result of validating the content as the format
+type ContentValidation struct {
+	Valid  bool   `json:"valid"`
+	Format string `json:"format"`
+	// Message is the parsing error if not valid
+	Message string `json:"message,omitempty"`
+}
+
+// ValidateContent checks the content can be parsed as the format, format is the content type if empty.
+// Content types without structure are always valid.
+func ValidateContent(contentType, content, format string) *ContentValidation {
+	if format == "" {
+		format = contentType
+	}
+	result := &ContentValidation{Valid: true, Format: format}
+	if !isStructuredContentType(format) {
+		return result
+	}
+	if _, err := parseStructuredContent(format, content); err != nil {
+		result.Valid = false
+		result.Message = err.Error()
+	}
+	return result
+}
+
+// loadDraftConfigure checks the request and loads the configure with its application.
+// The configure is locked till the end of the transaction, and checked against the version of the request if provided.
+func (c *ConfigureHandler) loadDraftConfigure(ctx context.Context, req *UpdateConfigurationRequest) (*Configure, *Application, error) {
+	if err := c.ConfigureRepository.LockConfigure(ctx, req.ConfigId); err != nil {
+		return nil, nil, err
+	}
+	cfg, err := c.ConfigureRepository.LoadConfigureById(ctx, req.ConfigId)
+	if err != nil {
+		return nil, nil, err
+	}
+	if req.ConfigVersion != "" && req.ConfigVersion != cfg.ConfigVersion {
+		return nil, nil, &ConflictError{Current: cfg.masked()}
+	}
+	if cfg.Secret && req.Content == MaskedContent {
+		return nil, nil, ErrMaskedContent
+	}
+	switch req.ContentType {
+	case "general":
+	default:
+		log.Println("invalid format of content type:", req.ContentType)
+		return nil, nil, errors.New("invalid format of content type:" + req.ContentType)
+	}
+	app, err := c.loadConfigureApp(ctx, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cfg, app, nil
+}
+
+// loadConfigureApp loads the owner application of the configure and checks they are online
+func (c *ConfigureHandler) loadConfigureApp(ctx context.Context, cfg *Configure) (*Application, error) {
+	ns, err := c.ConfigureRepository.LoadNamespace(ctx, cfg.ConfigNamespace)
+	if err != nil {
+		return nil, err
+	}
+	app, err := c.ConfigureRepository.LoadApplicationById(ctx, ns.OwnerAppId)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.checkOnline(ctx, app, ns.Name, cfg.ConfigEnv, cfg.ConfigDc); err != nil {
+		return nil, err
+	}
+	return app, nil
+}
+
+// SaveConfigureDraft saves the content as the draft without publishing, the previous draft is replaced
+func (c *ConfigureHandler) SaveConfigureDraft(ctx context.Context, req *UpdateConfigurationRequest) error {
+	cfg, _, err := c.loadDraftConfigure(ctx, req)
+	if err != nil {
+		return err
+	}
+	cfg.DraftContentType = req.ContentType
+	cfg.DraftContent = req.Content
+	cfg.TimeDrafted = time.Now().UnixMilli()
+	return c.ConfigureRepository.UpdateConfigureDraft(ctx, cfg)
+}
+
+// QueryConfigureDraft returns the configure with the draft for previewing, masked if secret unless revealed
+func (c *ConfigureHandler) QueryConfigureDraft(ctx context.Context, cfgId int64) (*Configure, error) {
+	cfg, err := c.loadConfigureDraft(ctx, cfgId)
+	if err != nil {
+		return nil, err
+	}
+	return c.maskedConfigure(ctx, cfg, RevealActionDraft)
+}
+
+func (c *ConfigureHandler) loadConfigureDraft(ctx context.Context, cfgId int64) (*Configure, error) {
+	cfg, err := c.ConfigureRepository.LoadConfigureById(ctx, cfgId)
+	if err != nil {
+		return nil, err
+	}
+	if !cfg.HasDraft() {
+		return nil, fmt.Errorf("%w: %d", ErrNoDraft, cfgId)
+	}
+	return cfg, nil
+}
+
+func (c *ConfigureHandler) DiscardConfigureDraft(ctx context.Context, cfgId int64) error {
+	cfg, err := c.loadConfigureDraft(ctx, cfgId)
+	if err != nil {
+		return err
+	}
+	cfg.DraftContentType = ""
+	cfg.DraftContent = ""
+	cfg.TimeDrafted = 0
+	return c.ConfigureRepository.UpdateConfigureDraft(ctx, cfg)
+}
+
+// DiffConfigureDraft compares the published content with the draft
+func (c *ConfigureHandler) DiffConfigureDraft(ctx context.Context, cfgId int64, format string) (*ConfigureDiff, error) {
+	cfg, err := c.loadConfigureDraft(ctx, cfgId)
+	if err != nil {
+		return nil, err
+	}
+	from, err := c.configureVersion(ctx, cfg, "")
+	if err != nil {
+		return nil, err
+	}
+	to := &DiffSide{
+		Label:       diffLabel(cfg, "draft"),
+		ContentType: cfg.DraftContentType,
+		Content:     cfg.DraftContent,
+	}
+	return c.maskedDiff(ctx, DiffContent(from, to, format), cfg)
+}
+
+// ValidateConfigureDraft validates the draft as the format, see ValidateContent
+func (c *ConfigureHandler) ValidateConfigureDraft(ctx context.Context, cfgId int64, format string) (*ContentValidation, error) {
+	cfg, err := c.loadConfigureDraft(ctx, cfgId)
+	if err != nil {
+		return nil, err
+	}
+	return ValidateContent(cfg.DraftContentType, cfg.DraftContent, format), nil
+}
+
+// saveVersion saves the content of the configure as the version and records it in the history
+func (c *ConfigureHandler) saveVersion(ctx context.Context, cfg *Configure, version string, releaseId int64) error {
+	cfg.ConfigVersion = version
+	cfg.TimeUpdated = time.Now().UnixMilli()
+	if err := c.ConfigureRepository.UpdateConfiguration(ctx, cfg); err != nil {
+		return err
+	}
+	return c.addConfigureHistory(ctx, cfg, releaseId)
+}
+
+// takeDraft makes the draft the content of the configure and clears the draft
+func (c *Configure) takeDraft() {
+	c.ContentType = c.DraftContentType
+	c.Content = c.DraftContent
+	c.DraftContentType = ""
+	c.DraftContent = ""
+	c.TimeDrafted = 0
+}
+
+// publishDraft makes the draft the new version of the configure, pushing to the clients is left to the caller
+func (c *ConfigureHandler) publishDraft(ctx context.Context, cfg *Configure, version string, releaseId int64) error {
+	cfg.takeDraft()
+	if err := c.saveVersion(ctx, cfg, version, releaseId); err != nil {
+		return err
+	}
+	return c.ConfigureRepository.UpdateConfigureDraft(ctx, cfg)
+}
+
+type PublishRequest struct {
+	AppId     int64
+	ConfigIds []int64
+	// Format validates the drafts as the format instead of their content types
+	Format string
+}
+
+// PublishConfigures publishes the drafts of the configures in the same env and dc of the application as a release.
+// All the drafts are checked and validated before any of them is published.
+func (c *ConfigureHandler) PublishConfigures(ctx context.Context, req *PublishRequest) (*Release, error) {
+	app, cfgs, err := c.loadPublishDrafts(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	release := newRelease(app, cfgs[0], ReleaseTypePublish)
+	if err := c.publishRelease(ctx, app, release, cfgs, func(i int, cfg *Configure) {
+		cfg.takeDraft()
+	}); err != nil {
+		return nil, err
+	}
+	for _, cfg := range cfgs {
+		if err := c.ConfigureRepository.UpdateConfigureDraft(ctx, cfg); err != nil {
+			return nil, err
+		}
+	}
+	return release, nil
+}
+
+// loadPublishDrafts loads the configures of the request with the drafts checked and validated
+func (c *ConfigureHandler) loadPublishDrafts(ctx context.Context, req *PublishRequest) (*Application, []*Configure, error) {
+	ids := slices.Compact(slices.Sorted(slices.Values(req.ConfigIds)))
+	if len(ids) == 0 {
+		return nil, nil, errors.New("no configure to publish")
+	}
+	app, err := c.ConfigureRepository.LoadApplicationById(ctx, req.AppId)
+	if err != nil {
+		return nil, nil, err
+	}
+	var cfgs []*Configure
+	for _, id := range ids {
+		cfg, err := c.loadConfigureDraft(ctx, id)
+		if err != nil {
+			return nil, nil, err
+		}
+		owner, err := c.loadConfigureApp(ctx, cfg)
+		if err != nil {
+			return nil, nil, err
+		}
+		if owner.ApplicationId != app.ApplicationId {
+			return nil, nil, fmt.Errorf("configure %d not owned by application %s", id, app.ApplicationName)
+		}
+		if len(cfgs) > 0 && (cfg.ConfigEnv != cfgs[0].ConfigEnv || cfg.ConfigDc != cfgs[0].ConfigDc) {
+			return nil, nil, fmt.Errorf("%w: %s/%s and %s/%s", ErrReleaseEnvDc, cfgs[0].ConfigEnv, cfgs[0].ConfigDc, cfg.ConfigEnv, cfg.ConfigDc)
+		}
+		if v := ValidateContent(cfg.DraftContentType, cfg.DraftContent, req.Format); !v.Valid {
+			return nil, nil, fmt.Errorf("%w: %s: %s", ErrInvalidDraft, diffLabel(cfg, "draft"), v.Message)
+		}
+		cfgs = append(cfgs, cfg)
+	}
+	return app, cfgs, nil
+}
@@ -0,0 +1,120 @@
+package domains
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func (m *memConfigureRepository) UpdateConfigureDraft(ctx context.Context, cfg *Configure) error {
+	for _, v := range m.configures {
+		if v.ConfigId == cfg.ConfigId {
+			v.DraftContentType = cfg.DraftContentType
+			v.DraftContent = cfg.DraftContent
+			v.TimeDrafted = cfg.TimeDrafted
+			return nil
+		}
+	}
+	return errors.New("config not found")
+}
+
+func TestDraftPublish(t *testing.T) {
+	repo := newMemConfigureRepository()
+	push := new(memPushChangeRepository)
+	h := &ConfigureHandler{ConfigureRepository: repo, PushChangeRepository: push}
+	ctx := context.Background()
+	if err := h.AddApplicationNamespace(ctx, 1, "ns1", "application"); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"host", "password"} {
+		if err := h.AddConfiguration(ctx, &AddConfigurationRequest{AppId: 1, Env: "PROD", Dc: "dc1", Namespace: "ns1", Key: key, ContentType: "general", Content: "old"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	push.pushed = nil
+
+	if _, err := h.QueryConfigureDraft(ctx, 1); !errors.Is(err, ErrNoDraft) {
+		t.Fatal("no draft expected:", err)
+	}
+	for id, content := range map[int64]string{1: "new-host", 2: `{"a":1}`} {
+		if err := h.SaveConfigureDraft(ctx, &UpdateConfigurationRequest{ConfigId: id, ContentType: "general", Content: content}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cfg, err := h.QueryConfigureDraft(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Content != "old" || cfg.DraftContent != "new-host" || len(push.pushed) != 0 {
+		t.Fatal("draft should not be published:", cfg, push.pushed)
+	}
+	diff, err := h.DiffConfigureDraft(ctx, 1, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff.Unified != "--- PROD/dc1/ns1/host@"+cfg.ConfigVersion+"\n+++ PROD/dc1/ns1/host@draft\n@@ -1 +1 @@\n-old\n\\ No newline at end of file\n+new-host\n\\ No newline at end of file\n" {
+		t.Fatal("unexpected diff:", diff.Unified)
+	}
+	if v, err := h.ValidateConfigureDraft(ctx, 1, "json"); err != nil || v.Valid {
+		t.Fatal("invalid json expected:", v, err)
+	}
+	if v, err := h.ValidateConfigureDraft(ctx, 2, "json"); err != nil || !v.Valid {
+		t.Fatal("valid json expected:", v, err)
+	}
+
+	// all the drafts are validated before publishing
+	if _, err := h.PublishConfigures(ctx, &PublishRequest{AppId: 1, ConfigIds: []int64{1, 2}, Format: "json"}); !errors.Is(err, ErrInvalidDraft) {
+		t.Fatal("invalid draft expected:", err)
+	}
+	if len(push.pushed) != 0 {
+		t.Fatal("nothing should be published:", push.pushed)
+	}
+	release, err := h.PublishConfigures(ctx, &PublishRequest{AppId: 1, ConfigIds: []int64{2, 1, 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(release.Items) != 2 || len(push.pushed) != 2 || push.pushed[0] != "host=new-host" || push.pushed[1] != `password={"a":1}` {
+		t.Fatal("unexpected published:", push.pushed)
+	}
+	cfg, err = h.QueryConfigureById(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.HasDraft() || cfg.Content != "new-host" || cfg.ConfigVersion == diff.From.Version {
+		t.Fatal("draft should be published:", cfg)
+	}
+	if history, _ := h.QueryConfigureHistory(ctx, 1); len(history) != 2 || history[0].Content != "new-host" {
+		t.Fatal("unexpected history:", history)
+	}
+	if _, err := h.PublishConfigures(ctx, &PublishRequest{AppId: 1, ConfigIds: []int64{1}}); !errors.Is(err, ErrNoDraft) {
+		t.Fatal("no draft expected:", err)
+	}
+
+	// discard
+	if err := h.SaveConfigureDraft(ctx, &UpdateConfigurationRequest{ConfigId: 1, ContentType: "general", Content: "x"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.DiscardConfigureDraft(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if cfg, _ := h.QueryConfigureById(ctx, 1); cfg.HasDraft() || cfg.Content != "new-host" {
+		t.Fatal("draft should be discarded:", cfg)
+	}
+
+	// updating is rejected while a different draft is pending, and publishes at once after it is discarded
+	if err := h.SaveConfigureDraft(ctx, &UpdateConfigurationRequest{ConfigId: 1, ContentType: "general", Content: "x"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.UpdateConfigurationById(ctx, &UpdateConfigurationRequest{ConfigId: 1, ContentType: "general", Content: "y", ConfigVersion: repo.version(1)}); !errors.Is(err, ErrDraftPending) {
+		t.Fatal("draft pending expected:", err)
+	}
+	if err := h.DiscardConfigureDraft(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.UpdateConfigurationById(ctx, &UpdateConfigurationRequest{ConfigId: 1, ContentType: "general", Content: "y", ConfigVersion: repo.version(1)}); err != nil {
+		t.Fatal(err)
+	}
+	if cfg, _ := h.QueryConfigureById(ctx, 1); cfg.HasDraft() || cfg.Content != "y" || push.pushed[len(push.pushed)-1] != "host=y" {
+		t.Fatal("update should publish:", cfg)
+	}
+}
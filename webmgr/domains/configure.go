@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"log"
+	"slices"
 	"time"
 
 	"github.com/meidoworks/nekoq-component/configure/configapi"
@@ -30,8 +31,10 @@ type Application struct {
 	ApplicationName              string
 	ApplicationDescription       string
 	ApplicationOwnerOrganization *Org
-	TimeCreated                  int64
-	TimeUpdated                  int64
+	// RecipientKey is the public key in hex the secret configures are sealed for before pushed, empty if not sealed
+	RecipientKey string
+	TimeCreated  int64
+	TimeUpdated  int64
 }
 
 type Namespace struct {
@@ -59,6 +62,17 @@ type Configure struct {
 	ConfigStatus    int64
 	TimeCreated     int64
 	TimeUpdated     int64
+	// Secret contents are encrypted at rest and masked in the responses unless revealed, see WithReveal
+	Secret bool
+
+	// DraftContentType and DraftContent are the unpublished edit, valid only if TimeDrafted is not 0
+	DraftContentType string
+	DraftContent     string
+	TimeDrafted      int64
+}
+
+func (c *Configure) HasDraft() bool {
+	return c.TimeDrafted != 0
 }
 
 func (c *Configure) UpdateConfigVersion(seq int64) {
@@ -83,6 +97,13 @@ func (c *Configure) GenerateSelectors(app *Application) *configapi.Selectors {
 type ConfigureHandler struct {
 	ConfigureRepository  ConfigureRepository
 	PushChangeRepository PushChangeRepository
+	// WebhookRepository queues the changes for the webhooks, changes are not notified if nil
+	WebhookRepository WebhookRepository
+	// SecretRepository checks and audits the reveals, secret configures cannot be revealed if nil
+	SecretRepository SecretRepository
+
+	// DecommissionGracePeriod is DefaultDecommissionGracePeriod if not set
+	DecommissionGracePeriod time.Duration
 }
 
 func (c *ConfigureHandler) AddEnvDc(ctx context.Context, addType, addName string) error {
@@ -127,6 +148,16 @@ func (c *ConfigureHandler) LoadEnvAndDcList(ctx context.Context) ([]*Datacenter,
 	if err != nil {
 		return nil, nil, err
 	}
+	offline, err := c.loadOfflineEntities(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	dcs = slices.DeleteFunc(dcs, func(dc *Datacenter) bool {
+		return offline.has(DecommissionTypeDatacenter, dc.DatacenterName)
+	})
+	envs = slices.DeleteFunc(envs, func(env *Environment) bool {
+		return offline.has(DecommissionTypeEnvironment, env.EnvName)
+	})
 	return dcs, envs, nil
 }
 
@@ -135,6 +166,7 @@ type ApplicationItem struct {
 	ApplicationName              string
 	ApplicationDescription       string
 	ApplicationOwnerOrganization *Org
+	RecipientKey                 string
 	TimeCreated                  int64
 	TimeUpdated                  int64
 
@@ -145,18 +177,28 @@ type ApplicationItem struct {
 }
 
 func (c *ConfigureHandler) LoadApplicationsByOrganizationIdList(ctx context.Context, orgList []*Org) (result []*ApplicationItem, rerr error) {
+	offline, err := c.loadOfflineEntities(ctx)
+	if err != nil {
+		return nil, err
+	}
 	for _, org := range orgList {
 		applications, err := c.ConfigureRepository.LoadApplicationsByOrganizationId(ctx, org.OrgId)
 		if err != nil {
 			return nil, err
 		}
 		for _, app := range applications {
+			if offline.has(DecommissionTypeApplication, app.ApplicationName) {
+				continue
+			}
 			envAndDcList, err := c.ConfigureRepository.LoadEnvAndDcListByAppId(ctx, app.ApplicationId)
 			if err != nil {
 				return nil, err
 			}
 			envAndDcMap := map[string][]string{}
 			for _, item := range envAndDcList {
+				if offline.has(DecommissionTypeEnvironment, item.EnvName) || offline.has(DecommissionTypeDatacenter, item.DcName) {
+					continue
+				}
 				arr, ok := envAndDcMap[item.EnvName]
 				if !ok {
 					arr = []string{item.DcName}
@@ -182,6 +224,7 @@ func (c *ConfigureHandler) LoadApplicationsByOrganizationIdList(ctx context.Cont
 				ApplicationName:              app.ApplicationName,
 				ApplicationDescription:       app.ApplicationDescription,
 				ApplicationOwnerOrganization: app.ApplicationOwnerOrganization,
+				RecipientKey:                 app.RecipientKey,
 				TimeCreated:                  app.TimeCreated,
 				TimeUpdated:                  app.TimeUpdated,
 				EnvAndDcList:                 envAndDcResult,
@@ -217,6 +260,9 @@ func (c *ConfigureHandler) LinkEnvAndDcToApp(ctx context.Context, envName, dcNam
 	if err != nil {
 		return err
 	}
+	if err := c.checkOnline(ctx, app, "", env.EnvName, dc.DatacenterName); err != nil {
+		return err
+	}
 	has, err := c.ConfigureRepository.ExistsAppEnvDcMapping(ctx, env, dc, app)
 	if err != nil {
 		return err
@@ -240,6 +286,9 @@ func (c *ConfigureHandler) AddApplicationNamespace(ctx context.Context, appId in
 		return err
 	}
 
+	if err := c.checkOnline(ctx, app, nsName, "", ""); err != nil {
+		return err
+	}
 	if has, err := c.ConfigureRepository.ExistsApplicationNamespace(ctx, app, nsName); err != nil {
 		return err
 	} else if has {
@@ -263,7 +312,17 @@ func (c *ConfigureHandler) QueryApplicationNamespaces(ctx context.Context, appId
 	if err != nil {
 		return nil, err
 	}
-	return c.ConfigureRepository.LoadAppNamespaces(ctx, app)
+	nsList, err := c.ConfigureRepository.LoadAppNamespaces(ctx, app)
+	if err != nil {
+		return nil, err
+	}
+	offline, err := c.loadOfflineEntities(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(nsList, func(ns *Namespace) bool {
+		return offline.has(DecommissionTypeNamespace, ns.Name)
+	}), nil
 }
 
 type AddConfigurationRequest struct {
@@ -274,6 +333,7 @@ type AddConfigurationRequest struct {
 	Key         string
 	ContentType string `json:"content_type"`
 	Content     string `json:"content"`
+	Secret      bool   `json:"secret"`
 }
 
 func (c *ConfigureHandler) AddConfiguration(ctx context.Context, req *AddConfigurationRequest) error {
@@ -308,6 +368,9 @@ func (c *ConfigureHandler) AddConfiguration(ctx context.Context, req *AddConfigu
 	if !ns.IsOwnerApp(app) {
 		return errors.New("not owner app")
 	}
+	if err := c.checkOnline(ctx, app, ns.Name, env.EnvName, dc.DatacenterName); err != nil {
+		return err
+	}
 	if exists, err := c.ConfigureRepository.ExistsConfigure(ctx, app, env, dc, ns, req.Key); err != nil {
 		return err
 	} else if exists {
@@ -324,6 +387,7 @@ func (c *ConfigureHandler) AddConfiguration(ctx context.Context, req *AddConfigu
 		ConfigStatus:    0,
 		TimeCreated:     now.UnixMilli(),
 		TimeUpdated:     now.UnixMilli(),
+		Secret:          req.Secret,
 	}
 	if seq, err := c.ConfigureRepository.NextConfigVersionSeq(ctx); err != nil {
 		return err
@@ -333,10 +397,13 @@ func (c *ConfigureHandler) AddConfiguration(ctx context.Context, req *AddConfigu
 	if err := c.ConfigureRepository.AddConfiguration(ctx, cfg); err != nil {
 		return err
 	}
+	if err := c.addConfigureHistory(ctx, cfg, 0); err != nil {
+		return err
+	}
 	if err := ApplyConfigureChange(ctx, c.PushChangeRepository, cfg, app); err != nil {
 		return err
 	}
-	return nil
+	return c.notifyChanges(ctx, app, []*ConfigureChange{{After: cfg}})
 }
 
 func (c *ConfigureHandler) QueryAppConfigList(ctx context.Context, appId int64, env, dc string) (map[string][]*Configure, error) {
@@ -356,8 +423,15 @@ func (c *ConfigureHandler) QueryAppConfigList(ctx context.Context, appId int64,
 	if err != nil {
 		return nil, err
 	}
+	offline, err := c.loadOfflineEntities(ctx)
+	if err != nil {
+		return nil, err
+	}
 	result := map[string][]*Configure{}
 	for _, config := range list {
+		if offline.has(DecommissionTypeNamespace, config.ConfigNamespace) {
+			continue
+		}
 		nsList := result[config.ConfigNamespace]
 		nsList = append(nsList, config)
 		result[config.ConfigNamespace] = nsList
@@ -365,53 +439,58 @@ func (c *ConfigureHandler) QueryAppConfigList(ctx context.Context, appId int64,
 	return result, nil
 }
 
+// QueryConfigureById returns the configure, masked if secret unless revealed, see WithReveal
 func (c *ConfigureHandler) QueryConfigureById(ctx context.Context, cfgId int64) (*Configure, error) {
 	cfg, err := c.ConfigureRepository.LoadConfigureById(ctx, cfgId)
 	if err != nil {
 		return nil, err
 	}
-	return cfg, nil
+	return c.maskedConfigure(ctx, cfg, RevealActionView)
 }
 
 type UpdateConfigurationRequest struct {
 	ConfigId    int64
 	ContentType string `json:"ct"`
 	Content     string `json:"content"`
+	// ConfigVersion is the version the change is based on, required by updating and optional for drafts
+	ConfigVersion string `json:"cfg_version"`
+	// Secret turns the configure into secret by updating, a secret configure cannot be turned back
+	Secret bool `json:"secret"`
 }
 
+// UpdateConfigurationById saves the content and publishes it at once, the same as saving a draft then publishing it.
+// It fails with ConflictError if the configure has been changed since the version of the request, or with
+// ErrDraftPending if the configure has a draft different from the content, which is kept for its editor.
 func (c *ConfigureHandler) UpdateConfigurationById(ctx context.Context, req *UpdateConfigurationRequest) error {
-	cfg, err := c.ConfigureRepository.LoadConfigureById(ctx, req.ConfigId)
+	if req.ConfigVersion == "" {
+		return ErrVersionRequired
+	}
+	cfg, app, err := c.loadDraftConfigure(ctx, req)
 	if err != nil {
 		return err
 	}
-	switch req.ContentType {
-	case "general":
-	default:
-		log.Println("invalid format of content type:", req.ContentType)
-		return errors.New("invalid format of content type:" + req.ContentType)
-	}
-	cfg.ContentType = req.ContentType
-	cfg.Content = req.Content
-	if seq, err := c.ConfigureRepository.NextConfigVersionSeq(ctx); err != nil {
-		return err
-	} else {
-		cfg.UpdateConfigVersion(seq)
+	if cfg.HasDraft() && (cfg.DraftContentType != req.ContentType || cfg.DraftContent != req.Content) {
+		return &ConflictError{Current: cfg.masked(), Err: ErrDraftPending}
 	}
-	now := time.Now()
-	cfg.TimeUpdated = now.UnixMilli()
-	if err := c.ConfigureRepository.UpdateConfiguration(ctx, cfg); err != nil {
-		return err
+	cfg.DraftContentType = req.ContentType
+	cfg.DraftContent = req.Content
+	cfg.TimeDrafted = time.Now().UnixMilli()
+	before := *cfg
+	if req.Secret && !cfg.Secret {
+		cfg.Secret = true
+		if err := c.ConfigureRepository.EncryptConfigureHistory(ctx, cfg.ConfigId); err != nil {
+			return err
+		}
 	}
-	ns, err := c.ConfigureRepository.LoadNamespace(ctx, cfg.ConfigNamespace)
+	seq, err := c.ConfigureRepository.NextConfigVersionSeq(ctx)
 	if err != nil {
 		return err
 	}
-	app, err := c.ConfigureRepository.LoadApplicationById(ctx, ns.OwnerAppId)
-	if err != nil {
+	if err := c.publishDraft(ctx, cfg, tools.VersionToString(seq), 0); err != nil {
 		return err
 	}
 	if err := ApplyConfigureChange(ctx, c.PushChangeRepository, cfg, app); err != nil {
 		return err
 	}
-	return nil
+	return c.notifyChanges(ctx, app, []*ConfigureChange{{Before: &before, After: cfg}})
 }
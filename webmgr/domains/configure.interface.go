@@ -15,6 +15,16 @@ type ConfigureRepository interface {
 	AddApplicationNamespace(ctx context.Context, app *Application, ns *Namespace) error
 	AddConfiguration(ctx context.Context, cfg *Configure) error
 	UpdateConfiguration(ctx context.Context, cfg *Configure) error
+	// LockConfigure locks the configure till the end of the transaction
+	LockConfigure(ctx context.Context, cfgId int64) error
+	// UpdateApplication saves the description if the application is still at the update time, returns false if not
+	UpdateApplication(ctx context.Context, app *Application, prevTimeUpdated int64) (bool, error)
+	// UpdateApplicationRecipientKey saves the recipient key if the application is still at the update time, returns false if not
+	UpdateApplicationRecipientKey(ctx context.Context, app *Application, prevTimeUpdated int64) (bool, error)
+	// UpdateNamespace saves the description if the namespace is still at the update time, returns false if not
+	UpdateNamespace(ctx context.Context, ns *Namespace, prevTimeUpdated int64) (bool, error)
+	// UpdateConfigureDraft saves the draft fields only, including clearing them
+	UpdateConfigureDraft(ctx context.Context, cfg *Configure) error
 	NextConfigVersionSeq(ctx context.Context) (int64, error)
 
 	LoadDcList(ctx context.Context) ([]*Datacenter, error)
@@ -31,7 +41,51 @@ type ConfigureRepository interface {
 	ExistsApplicationNamespace(ctx context.Context, app *Application, nsName string) (bool, error)
 	LoadAppNamespaces(ctx context.Context, app *Application) ([]*Namespace, error)
 	LoadNamespace(ctx context.Context, nsName string) (*Namespace, error)
+	ExistsNamespace(ctx context.Context, nsName string) (bool, error)
 	ExistsConfigure(ctx context.Context, app *Application, env *Environment, dc *Datacenter, ns *Namespace, key string) (bool, error)
 	LoadAppConfigList(ctx context.Context, app *Application, env *Environment, dc *Datacenter) ([]*Configure, error)
 	LoadConfigureById(ctx context.Context, cfgId int64) (*Configure, error)
+
+	LoadApplicationByName(ctx context.Context, appName string) (*Application, error)
+	LoadConfiguresByNamespace(ctx context.Context, nsName string) ([]*Configure, error)
+	LoadConfiguresByEnvironment(ctx context.Context, env string) ([]*Configure, error)
+	LoadConfiguresByDatacenter(ctx context.Context, dc string) ([]*Configure, error)
+	LoadAppIdsByEnvironment(ctx context.Context, env string) ([]int64, error)
+	LoadAppIdsByDatacenter(ctx context.Context, dc string) ([]int64, error)
+	// LoadAppIdsByNamespace returns the applications linking the namespace
+	LoadAppIdsByNamespace(ctx context.Context, nsName string) ([]int64, error)
+	LoadDecommissions(ctx context.Context) ([]*Decommission, error)
+	AddDecommission(ctx context.Context, d *Decommission) error
+	RemoveDecommission(ctx context.Context, entityType, entityName string) error
+	DeleteConfigures(ctx context.Context, cfgIds []int64) error
+	DeleteNamespace(ctx context.Context, nsName string) error
+	DeleteApplication(ctx context.Context, app *Application) error
+	DeleteEnvironment(ctx context.Context, env string) error
+	DeleteDatacenter(ctx context.Context, dc string) error
+
+	AddConfigureHistory(ctx context.Context, h *ConfigureHistory) error
+	LoadConfigureHistoryList(ctx context.Context, cfgId int64) ([]*ConfigureHistory, error)
+	// LoadConfigureHistory returns nil if the version not found
+	LoadConfigureHistory(ctx context.Context, cfgId int64, version string) (*ConfigureHistory, error)
+	// EncryptConfigureHistory encrypts the saved history of the configure turned into secret
+	EncryptConfigureHistory(ctx context.Context, cfgId int64) error
+
+	// AddRelease saves the release with the items and sets the id
+	AddRelease(ctx context.Context, release *Release) error
+	LoadReleases(ctx context.Context, app *Application, env, dc string) ([]*Release, error)
+	// LoadRelease returns nil if not found
+	LoadRelease(ctx context.Context, releaseId int64) (*Release, error)
+	UpdateReleaseRolledBack(ctx context.Context, release *Release) error
+
+	// LockSchedules locks the schedules till the end of the transaction, returns false if not wait and locked by others
+	LockSchedules(ctx context.Context, wait bool) (bool, error)
+	// AddSchedule saves the schedule with the items and sets the id
+	AddSchedule(ctx context.Context, s *Schedule) error
+	LoadSchedules(ctx context.Context, app *Application, env, dc string) ([]*Schedule, error)
+	// LoadSchedule returns nil if not found
+	LoadSchedule(ctx context.Context, scheduleId int64) (*Schedule, error)
+	// LoadDueSchedule returns the earliest schedule due at the time, nil if none
+	LoadDueSchedule(ctx context.Context, now int64) (*Schedule, error)
+	// UpdateSchedule saves the status fields of the schedule
+	UpdateSchedule(ctx context.Context, s *Schedule) error
 }
@@ -0,0 +1,131 @@
+package domains
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrVersionRequired is returned when updating without the version the change is based on
+	ErrVersionRequired = errors.New("version required")
+	// ErrConflict is returned when the entity has been changed since the version the change is based on
+	ErrConflict = errors.New("changed since the version edited")
+	// ErrDraftPending is returned when updating a configure which has a different draft pending, it is an ErrConflict
+	ErrDraftPending = fmt.Errorf("%w: a different draft is pending", ErrConflict)
+)
+
+// ConflictError carries the current entity on conflict: *Configure, *Application or *Namespace
+type ConflictError struct {
+	Current any
+	// Err is the reason of the conflict, ErrConflict if nil
+	Err error
+}
+
+func (e *ConflictError) Error() string {
+	reason := e.Unwrap()
+	switch current := e.Current.(type) {
+	case *Configure:
+		return fmt.Sprintf("%s: configure %d is at version %s", reason, current.ConfigId, current.ConfigVersion)
+	case *Application:
+		return fmt.Sprintf("%s: application %s is at version %d", reason, current.ApplicationName, current.TimeUpdated)
+	case *Namespace:
+		return fmt.Sprintf("%s: namespace %s is at version %d", reason, current.Name, current.TimeUpdated)
+	default:
+		return reason.Error()
+	}
+}
+
+func (e *ConflictError) Unwrap() error {
+	if e.Err != nil {
+		return e.Err
+	}
+	return ErrConflict
+}
+
+// nextTimeUpdated returns the new update time which is always different from the previous one,
+// since the update time is used as the version of applications and namespaces
+func nextTimeUpdated(prev int64) int64 {
+	return max(time.Now().UnixMilli(), prev+1)
+}
+
+type UpdateApplicationRequest struct {
+	AppId       int64
+	Description string `json:"app_desc"`
+	// TimeUpdated is the version of the application the change is based on
+	TimeUpdated int64 `json:"time_updated"`
+}
+
+// UpdateApplication updates the description of the application if it has not been changed since the version edited
+func (c *ConfigureHandler) UpdateApplication(ctx context.Context, req *UpdateApplicationRequest) (*Application, error) {
+	if req.TimeUpdated <= 0 {
+		return nil, ErrVersionRequired
+	}
+	app, err := c.ConfigureRepository.LoadApplicationById(ctx, req.AppId)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.checkOnline(ctx, app, "", "", ""); err != nil {
+		return nil, err
+	}
+	if app.TimeUpdated != req.TimeUpdated {
+		return nil, &ConflictError{Current: app}
+	}
+	updatedApp := *app
+	updatedApp.ApplicationDescription = req.Description
+	updatedApp.TimeUpdated = nextTimeUpdated(req.TimeUpdated)
+	if updated, err := c.ConfigureRepository.UpdateApplication(ctx, &updatedApp, req.TimeUpdated); err != nil {
+		return nil, err
+	} else if !updated {
+		// changed by a concurrent update
+		current, err := c.ConfigureRepository.LoadApplicationById(ctx, req.AppId)
+		if err != nil {
+			return nil, err
+		}
+		return nil, &ConflictError{Current: current}
+	}
+	return &updatedApp, nil
+}
+
+type UpdateNamespaceRequest struct {
+	Name        string
+	Description string `json:"ns_desc"`
+	// TimeUpdated is the version of the namespace the change is based on
+	TimeUpdated int64 `json:"time_updated"`
+}
+
+func (c *ConfigureHandler) QueryNamespace(ctx context.Context, nsName string) (*Namespace, error) {
+	return c.ConfigureRepository.LoadNamespace(ctx, nsName)
+}
+
+// UpdateNamespace updates the description of the namespace if it has not been changed since the version edited
+func (c *ConfigureHandler) UpdateNamespace(ctx context.Context, req *UpdateNamespaceRequest) (*Namespace, error) {
+	if req.TimeUpdated <= 0 {
+		return nil, ErrVersionRequired
+	}
+	ns, err := c.ConfigureRepository.LoadNamespace(ctx, req.Name)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.checkOnline(ctx, nil, ns.Name, "", ""); err != nil {
+		return nil, err
+	}
+	if ns.TimeUpdated != req.TimeUpdated {
+		return nil, &ConflictError{Current: ns}
+	}
+	updatedNs := *ns
+	updatedNs.Description = req.Description
+	updatedNs.TimeUpdated = nextTimeUpdated(req.TimeUpdated)
+	if updated, err := c.ConfigureRepository.UpdateNamespace(ctx, &updatedNs, req.TimeUpdated); err != nil {
+		return nil, err
+	} else if !updated {
+		// changed by a concurrent update
+		current, err := c.ConfigureRepository.LoadNamespace(ctx, req.Name)
+		if err != nil {
+			return nil, err
+		}
+		return nil, &ConflictError{Current: current}
+	}
+	return &updatedNs, nil
+}
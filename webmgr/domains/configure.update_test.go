@@ -0,0 +1,168 @@
+package domains
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func (m *memConfigureRepository) LockConfigure(ctx context.Context, cfgId int64) error {
+	return nil
+}
+
+func (m *memConfigureRepository) UpdateApplication(ctx context.Context, app *Application, prevTimeUpdated int64) (bool, error) {
+	if m.app.ApplicationId != app.ApplicationId || m.app.TimeUpdated != prevTimeUpdated {
+		return false, nil
+	}
+	v := *app
+	m.app = &v
+	return true, nil
+}
+
+func (m *memConfigureRepository) UpdateNamespace(ctx context.Context, ns *Namespace, prevTimeUpdated int64) (bool, error) {
+	current, ok := m.namespaces[ns.Name]
+	if !ok || current.TimeUpdated != prevTimeUpdated {
+		return false, nil
+	}
+	v := *ns
+	m.namespaces[ns.Name] = &v
+	return true, nil
+}
+
+// version returns the current version of the configure
+func (m *memConfigureRepository) version(cfgId int64) string {
+	for _, cfg := range m.configures {
+		if cfg.ConfigId == cfgId {
+			return cfg.ConfigVersion
+		}
+	}
+	return ""
+}
+
+func TestUpdateConfigurationConflict(t *testing.T) {
+	repo := newMemConfigureRepository()
+	push := new(memPushChangeRepository)
+	h := &ConfigureHandler{ConfigureRepository: repo, PushChangeRepository: push}
+	ctx := context.Background()
+	if err := h.AddApplicationNamespace(ctx, 1, "ns1", "application"); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.AddConfiguration(ctx, &AddConfigurationRequest{AppId: 1, Env: "PROD", Dc: "dc1", Namespace: "ns1", Key: "k1", ContentType: "general", Content: "v1"}); err != nil {
+		t.Fatal(err)
+	}
+	edited := repo.version(1)
+
+	if err := h.UpdateConfigurationById(ctx, &UpdateConfigurationRequest{ConfigId: 1, ContentType: "general", Content: "v2"}); !errors.Is(err, ErrVersionRequired) {
+		t.Fatal("version should be required:", err)
+	}
+	// the first editor wins
+	if err := h.UpdateConfigurationById(ctx, &UpdateConfigurationRequest{ConfigId: 1, ContentType: "general", Content: "v2", ConfigVersion: edited}); err != nil {
+		t.Fatal(err)
+	}
+	// the second editor of the same version is rejected with the current content
+	err := h.UpdateConfigurationById(ctx, &UpdateConfigurationRequest{ConfigId: 1, ContentType: "general", Content: "v3", ConfigVersion: edited})
+	var conflict *ConflictError
+	if !errors.Is(err, ErrConflict) || !errors.As(err, &conflict) {
+		t.Fatal("conflict expected:", err)
+	}
+	if current := conflict.Current.(*Configure); current.Content != "v2" || current.ConfigVersion != repo.version(1) || current.ConfigVersion == edited {
+		t.Fatal("unexpected current configure:", current)
+	}
+	if len(push.pushed) != 2 || push.pushed[1] != "k1=v2" {
+		t.Fatal("conflicting update should not be pushed:", push.pushed)
+	}
+	// drafts are checked only when the version is provided
+	if err := h.SaveConfigureDraft(ctx, &UpdateConfigurationRequest{ConfigId: 1, ContentType: "general", Content: "v3", ConfigVersion: edited}); !errors.Is(err, ErrConflict) {
+		t.Fatal("conflict expected:", err)
+	}
+	if err := h.SaveConfigureDraft(ctx, &UpdateConfigurationRequest{ConfigId: 1, ContentType: "general", Content: "v3"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUpdateApplicationAndNamespaceConflict(t *testing.T) {
+	repo := newMemConfigureRepository()
+	repo.app.TimeUpdated = 100
+	h := &ConfigureHandler{ConfigureRepository: repo, PushChangeRepository: new(memPushChangeRepository)}
+	ctx := context.Background()
+
+	if _, err := h.UpdateApplication(ctx, &UpdateApplicationRequest{AppId: 1, Description: "d1"}); !errors.Is(err, ErrVersionRequired) {
+		t.Fatal("version should be required:", err)
+	}
+	app, err := h.UpdateApplication(ctx, &UpdateApplicationRequest{AppId: 1, Description: "d1", TimeUpdated: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if app.TimeUpdated <= 100 || repo.app.ApplicationDescription != "d1" {
+		t.Fatal("unexpected application:", app)
+	}
+	_, err = h.UpdateApplication(ctx, &UpdateApplicationRequest{AppId: 1, Description: "d2", TimeUpdated: 100})
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) || conflict.Current.(*Application).ApplicationDescription != "d1" {
+		t.Fatal("conflict expected:", err)
+	}
+
+	if err := h.AddApplicationNamespace(ctx, 1, "ns1", "application"); err != nil {
+		t.Fatal(err)
+	}
+	ns, _ := h.QueryNamespace(ctx, "ns1")
+	edited := ns.TimeUpdated
+	if _, err := h.UpdateNamespace(ctx, &UpdateNamespaceRequest{Name: "ns1", Description: "n1", TimeUpdated: edited}); err != nil {
+		t.Fatal(err)
+	}
+	_, err = h.UpdateNamespace(ctx, &UpdateNamespaceRequest{Name: "ns1", Description: "n2", TimeUpdated: edited})
+	if !errors.As(err, &conflict) || conflict.Current.(*Namespace).Description != "n1" {
+		t.Fatal("conflict expected:", err)
+	}
+	if ns, _ := h.QueryNamespace(ctx, "ns1"); ns.Description != "n1" || ns.TimeUpdated == edited {
+		t.Fatal("unexpected namespace:", ns)
+	}
+}
+
+func TestUpdateConfigurationDraftPending(t *testing.T) {
+	repo := newMemConfigureRepository()
+	push := new(memPushChangeRepository)
+	h := &ConfigureHandler{ConfigureRepository: repo, PushChangeRepository: push}
+	ctx := context.Background()
+	if err := h.AddApplicationNamespace(ctx, 1, "ns1", "application"); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.AddConfiguration(ctx, &AddConfigurationRequest{AppId: 1, Env: "PROD", Dc: "dc1", Namespace: "ns1", Key: "k1", ContentType: "general", Content: "v1"}); err != nil {
+		t.Fatal(err)
+	}
+	edited := repo.version(1)
+	if err := h.SaveConfigureDraft(ctx, &UpdateConfigurationRequest{ConfigId: 1, ContentType: "general", Content: "draft"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the draft of another editor is neither published nor wiped
+	err := h.UpdateConfigurationById(ctx, &UpdateConfigurationRequest{ConfigId: 1, ContentType: "general", Content: "v2", ConfigVersion: edited})
+	var conflict *ConflictError
+	if !errors.Is(err, ErrDraftPending) || !errors.Is(err, ErrConflict) || !errors.As(err, &conflict) {
+		t.Fatal("draft pending expected:", err)
+	}
+	if current := conflict.Current.(*Configure); !current.HasDraft() || current.DraftContent != "draft" {
+		t.Fatal("unexpected current configure:", current)
+	}
+	cfg, err := h.QueryConfigureDraft(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Content != "v1" || cfg.DraftContent != "draft" || cfg.ConfigVersion != edited {
+		t.Fatal("configure should be unchanged:", cfg)
+	}
+	if len(push.pushed) != 1 {
+		t.Fatal("rejected update should not be pushed:", push.pushed)
+	}
+
+	// updating with the same content of the draft publishes it
+	if err := h.UpdateConfigurationById(ctx, &UpdateConfigurationRequest{ConfigId: 1, ContentType: "general", Content: "draft", ConfigVersion: edited}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.QueryConfigureDraft(ctx, 1); !errors.Is(err, ErrNoDraft) {
+		t.Fatal("draft should be published:", err)
+	}
+	if len(push.pushed) != 2 || push.pushed[1] != "k1=draft" {
+		t.Fatal("unexpected pushed:", push.pushed)
+	}
+}
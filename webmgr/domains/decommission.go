@@ -0,0 +1,426 @@
+package domains
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	DecommissionTypeApplication = "app"
+	DecommissionTypeEnvironment = "env"
+	DecommissionTypeDatacenter  = "dc"
+	DecommissionTypeNamespace   = "ns"
+)
+
+// DefaultDecommissionGracePeriod is the minimum time between marking an entity as offline and deleting it
+const DefaultDecommissionGracePeriod = 7 * 24 * time.Hour
+
+var (
+	ErrUnknownDecommissionType = errors.New("unknown decommission type")
+	ErrEntityOffline           = errors.New("entity is offline")
+	ErrEntityNotOffline        = errors.New("entity is not offline")
+	ErrGracePeriodNotPassed    = errors.New("decommission grace period not passed")
+	ErrNamespaceLinked         = errors.New("namespace linked by other applications")
+)
+
+// Decommission is the offline marker of an application, an environment, a datacenter or a namespace
+type Decommission struct {
+	EntityType  string
+	EntityName  string
+	TimeOffline int64
+}
+
+// DecommissionDependent is a configure removed together with the decommissioned entity
+type DecommissionDependent struct {
+	Configure   *Configure
+	Application *Application
+	// Published is the configuration served by the read servers, nil if never published
+	Published *PublishedConfiguration
+	// TimeFetched is the last time the clients requested the published configuration recorded by the read servers,
+	// 0 if never
+	TimeFetched int64
+}
+
+type DecommissionReport struct {
+	EntityType string
+	EntityName string
+	// Decommission is nil if the entity is online
+	Decommission  *Decommission
+	TimeDeletable int64
+
+	// Namespaces of the application
+	Namespaces []string
+	// Applications linked to the environment or the datacenter
+	Applications []string
+	// LinkingApplications are the applications other than the owner linking the namespaces to be deleted,
+	// the deletion is rejected until they are unlinked
+	LinkingApplications []string
+	Dependents          []*DecommissionDependent
+}
+
+// offlineEntities is the set of the offline entities by type and name
+type offlineEntities map[string]map[string]*Decommission
+
+func (o offlineEntities) get(entityType, entityName string) *Decommission {
+	return o[entityType][entityName]
+}
+
+func (o offlineEntities) has(entityType, entityName string) bool {
+	return o.get(entityType, entityName) != nil
+}
+
+func (c *ConfigureHandler) loadOfflineEntities(ctx context.Context) (offlineEntities, error) {
+	list, err := c.ConfigureRepository.LoadDecommissions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := offlineEntities{}
+	for _, d := range list {
+		m, ok := result[d.EntityType]
+		if !ok {
+			m = map[string]*Decommission{}
+			result[d.EntityType] = m
+		}
+		m[d.EntityName] = d
+	}
+	return result, nil
+}
+
+// checkOnline returns ErrEntityOffline if any of the application, namespace, environment and datacenter is offline
+func (c *ConfigureHandler) checkOnline(ctx context.Context, app *Application, nsName, envName, dcName string) error {
+	offline, err := c.loadOfflineEntities(ctx)
+	if err != nil {
+		return err
+	}
+	if app != nil && offline.has(DecommissionTypeApplication, app.ApplicationName) {
+		return fmt.Errorf("%w: application %s", ErrEntityOffline, app.ApplicationName)
+	}
+	if nsName != "" && offline.has(DecommissionTypeNamespace, nsName) {
+		return fmt.Errorf("%w: namespace %s", ErrEntityOffline, nsName)
+	}
+	if envName != "" && offline.has(DecommissionTypeEnvironment, envName) {
+		return fmt.Errorf("%w: environment %s", ErrEntityOffline, envName)
+	}
+	if dcName != "" && offline.has(DecommissionTypeDatacenter, dcName) {
+		return fmt.Errorf("%w: datacenter %s", ErrEntityOffline, dcName)
+	}
+	return nil
+}
+
+func (c *ConfigureHandler) gracePeriod() time.Duration {
+	if c.DecommissionGracePeriod > 0 {
+		return c.DecommissionGracePeriod
+	}
+	return DefaultDecommissionGracePeriod
+}
+
+// checkEntity checks the type and the existence of the entity
+func (c *ConfigureHandler) checkEntity(ctx context.Context, entityType, entityName string) error {
+	switch entityType {
+	case DecommissionTypeApplication:
+		_, err := c.ConfigureRepository.LoadApplicationByName(ctx, entityName)
+		return err
+	case DecommissionTypeEnvironment:
+		_, err := c.ConfigureRepository.LoadEnvironment(ctx, entityName)
+		return err
+	case DecommissionTypeDatacenter:
+		_, err := c.ConfigureRepository.LoadDatacenter(ctx, entityName)
+		return err
+	case DecommissionTypeNamespace:
+		_, err := c.ConfigureRepository.LoadNamespace(ctx, entityName)
+		return err
+	default:
+		return fmt.Errorf("%w: %s", ErrUnknownDecommissionType, entityType)
+	}
+}
+
+// OfflineEntity marks the entity as offline. Offline entities are hidden from the lists and the changes on them are
+// rejected with ErrEntityOffline. Marking an offline entity again keeps the original offline time.
+func (c *ConfigureHandler) OfflineEntity(ctx context.Context, entityType, entityName string, now time.Time) error {
+	if err := c.checkEntity(ctx, entityType, entityName); err != nil {
+		return err
+	}
+	offline, err := c.loadOfflineEntities(ctx)
+	if err != nil {
+		return err
+	}
+	if offline.has(entityType, entityName) {
+		return nil
+	}
+	return c.ConfigureRepository.AddDecommission(ctx, &Decommission{
+		EntityType:  entityType,
+		EntityName:  entityName,
+		TimeOffline: now.UnixMilli(),
+	})
+}
+
+// OnlineEntity cancels the decommission of the entity
+func (c *ConfigureHandler) OnlineEntity(ctx context.Context, entityType, entityName string) error {
+	if err := c.checkEntity(ctx, entityType, entityName); err != nil {
+		return err
+	}
+	offline, err := c.loadOfflineEntities(ctx)
+	if err != nil {
+		return err
+	}
+	if !offline.has(entityType, entityName) {
+		return ErrEntityNotOffline
+	}
+	return c.ConfigureRepository.RemoveDecommission(ctx, entityType, entityName)
+}
+
+// QueryDecommissions returns the offline entities ordered by the offline time
+func (c *ConfigureHandler) QueryDecommissions(ctx context.Context) ([]*Decommission, time.Duration, error) {
+	list, err := c.ConfigureRepository.LoadDecommissions(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	slices.SortFunc(list, func(a, b *Decommission) int {
+		return cmp.Or(cmp.Compare(a.TimeOffline, b.TimeOffline), strings.Compare(a.EntityType, b.EntityType), strings.Compare(a.EntityName, b.EntityName))
+	})
+	return list, c.gracePeriod(), nil
+}
+
+// QueryDecommissionDependents reports the configures to be removed with the entity, when they were published and
+// fetched by the clients
+func (c *ConfigureHandler) QueryDecommissionDependents(ctx context.Context, entityType, entityName string) (*DecommissionReport, error) {
+	if err := c.checkEntity(ctx, entityType, entityName); err != nil {
+		return nil, err
+	}
+	report := &DecommissionReport{
+		EntityType: entityType,
+		EntityName: entityName,
+		Dependents: []*DecommissionDependent{},
+	}
+	offline, err := c.loadOfflineEntities(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if d := offline.get(entityType, entityName); d != nil {
+		report.Decommission = d
+		report.TimeDeletable = d.TimeOffline + c.gracePeriod().Milliseconds()
+	}
+
+	var configures []*Configure
+	switch entityType {
+	case DecommissionTypeApplication:
+		app, err := c.ConfigureRepository.LoadApplicationByName(ctx, entityName)
+		if err != nil {
+			return nil, err
+		}
+		nsList, err := c.ConfigureRepository.LoadAppNamespaces(ctx, app)
+		if err != nil {
+			return nil, err
+		}
+		for _, ns := range nsList {
+			report.Namespaces = append(report.Namespaces, ns.Name)
+			list, err := c.ConfigureRepository.LoadConfiguresByNamespace(ctx, ns.Name)
+			if err != nil {
+				return nil, err
+			}
+			configures = append(configures, list...)
+		}
+		slices.Sort(report.Namespaces)
+		if report.LinkingApplications, err = c.linkingApplications(ctx, app.ApplicationId, report.Namespaces); err != nil {
+			return nil, err
+		}
+	case DecommissionTypeNamespace:
+		list, err := c.ConfigureRepository.LoadConfiguresByNamespace(ctx, entityName)
+		if err != nil {
+			return nil, err
+		}
+		configures = list
+		ns, err := c.ConfigureRepository.LoadNamespace(ctx, entityName)
+		if err != nil {
+			return nil, err
+		}
+		if report.LinkingApplications, err = c.linkingApplications(ctx, ns.OwnerAppId, []string{entityName}); err != nil {
+			return nil, err
+		}
+	case DecommissionTypeEnvironment:
+		list, err := c.ConfigureRepository.LoadConfiguresByEnvironment(ctx, entityName)
+		if err != nil {
+			return nil, err
+		}
+		configures = list
+		appIds, err := c.ConfigureRepository.LoadAppIdsByEnvironment(ctx, entityName)
+		if err != nil {
+			return nil, err
+		}
+		if report.Applications, err = c.applicationNames(ctx, appIds); err != nil {
+			return nil, err
+		}
+	case DecommissionTypeDatacenter:
+		list, err := c.ConfigureRepository.LoadConfiguresByDatacenter(ctx, entityName)
+		if err != nil {
+			return nil, err
+		}
+		configures = list
+		appIds, err := c.ConfigureRepository.LoadAppIdsByDatacenter(ctx, entityName)
+		if err != nil {
+			return nil, err
+		}
+		if report.Applications, err = c.applicationNames(ctx, appIds); err != nil {
+			return nil, err
+		}
+	}
+
+	namespaces := map[string]*Namespace{}
+	apps := map[int64]*Application{}
+	for _, cfg := range configures {
+		ns, ok := namespaces[cfg.ConfigNamespace]
+		if !ok {
+			ns, err = c.ConfigureRepository.LoadNamespace(ctx, cfg.ConfigNamespace)
+			if err != nil {
+				return nil, err
+			}
+			namespaces[cfg.ConfigNamespace] = ns
+		}
+		app, ok := apps[ns.OwnerAppId]
+		if !ok {
+			app, err = c.ConfigureRepository.LoadApplicationById(ctx, ns.OwnerAppId)
+			if err != nil {
+				return nil, err
+			}
+			apps[ns.OwnerAppId] = app
+		}
+		published, err := c.PushChangeRepository.LoadPublishedConfiguration(ctx, cfg, app)
+		if err != nil {
+			return nil, err
+		}
+		var timeFetched int64
+		if published != nil {
+			if timeFetched, err = c.PushChangeRepository.LoadFetchTime(ctx, published); err != nil {
+				return nil, err
+			}
+		}
+		report.Dependents = append(report.Dependents, &DecommissionDependent{
+			Configure:   cfg,
+			Application: app,
+			Published:   published,
+			TimeFetched: timeFetched,
+		})
+	}
+	slices.SortFunc(report.Dependents, func(a, b *DecommissionDependent) int {
+		return cmp.Compare(a.Configure.ConfigId, b.Configure.ConfigId)
+	})
+	return report, nil
+}
+
+// applicationNames returns the sorted names of the applications without duplicates
+func (c *ConfigureHandler) applicationNames(ctx context.Context, appIds []int64) ([]string, error) {
+	var result []string
+	for _, appId := range appIds {
+		app, err := c.ConfigureRepository.LoadApplicationById(ctx, appId)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, app.ApplicationName)
+	}
+	slices.Sort(result)
+	return slices.Compact(result), nil
+}
+
+// linkingApplications returns the names of the applications other than the owner linking any of the namespaces
+func (c *ConfigureHandler) linkingApplications(ctx context.Context, ownerAppId int64, nsNames []string) ([]string, error) {
+	var appIds []int64
+	for _, nsName := range nsNames {
+		linked, err := c.ConfigureRepository.LoadAppIdsByNamespace(ctx, nsName)
+		if err != nil {
+			return nil, err
+		}
+		for _, appId := range linked {
+			if appId != ownerAppId {
+				appIds = append(appIds, appId)
+			}
+		}
+	}
+	return c.applicationNames(ctx, appIds)
+}
+
+// DeleteEntity deletes the offline entity after the grace period, together with the env/dc links, the namespaces and
+// the configures. The published configurations are marked as deleted so the read servers drop them by the incremental
+// changes, `cleanjob` purges them after its retention.
+// It fails with ErrNamespaceLinked if any namespace to be deleted is still linked by other applications.
+func (c *ConfigureHandler) DeleteEntity(ctx context.Context, entityType, entityName string, now time.Time) (*DecommissionReport, error) {
+	report, err := c.QueryDecommissionDependents(ctx, entityType, entityName)
+	if err != nil {
+		return nil, err
+	}
+	if report.Decommission == nil {
+		return nil, ErrEntityNotOffline
+	}
+	if now.UnixMilli() < report.TimeDeletable {
+		return nil, fmt.Errorf("%w: deletable after %s", ErrGracePeriodNotPassed, time.UnixMilli(report.TimeDeletable).Format(time.RFC3339))
+	}
+	if len(report.LinkingApplications) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNamespaceLinked, strings.Join(report.LinkingApplications, ", "))
+	}
+
+	var cfgIds []int64
+	for _, dep := range report.Dependents {
+		cfgIds = append(cfgIds, dep.Configure.ConfigId)
+		if dep.Published == nil || dep.Published.Status != PublishedStatusValid {
+			continue
+		}
+		if err := c.unpublishConfiguration(ctx, dep.Published); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.ConfigureRepository.DeleteConfigures(ctx, cfgIds); err != nil {
+		return nil, err
+	}
+
+	switch entityType {
+	case DecommissionTypeApplication:
+		app, err := c.ConfigureRepository.LoadApplicationByName(ctx, entityName)
+		if err != nil {
+			return nil, err
+		}
+		for _, ns := range report.Namespaces {
+			if err := c.ConfigureRepository.DeleteNamespace(ctx, ns); err != nil {
+				return nil, err
+			}
+			if err := c.ConfigureRepository.RemoveDecommission(ctx, DecommissionTypeNamespace, ns); err != nil {
+				return nil, err
+			}
+		}
+		if err := c.ConfigureRepository.DeleteApplication(ctx, app); err != nil {
+			return nil, err
+		}
+	case DecommissionTypeNamespace:
+		if err := c.ConfigureRepository.DeleteNamespace(ctx, entityName); err != nil {
+			return nil, err
+		}
+	case DecommissionTypeEnvironment:
+		if err := c.ConfigureRepository.DeleteEnvironment(ctx, entityName); err != nil {
+			return nil, err
+		}
+	case DecommissionTypeDatacenter:
+		if err := c.ConfigureRepository.DeleteDatacenter(ctx, entityName); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.ConfigureRepository.RemoveDecommission(ctx, entityType, entityName); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// unpublishConfiguration marks the published configuration as deleted.
+// Retry 10 times the same as ApplyConfigureChange since the sequence lock may be held by the concurrent publishing.
+func (c *ConfigureHandler) unpublishConfiguration(ctx context.Context, p *PublishedConfiguration) error {
+	for i := 0; i < 10; i++ {
+		if deleted, err := c.PushChangeRepository.MarkConfigurationDeleted(ctx, p.ConfigId); err != nil {
+			return err
+		} else if deleted {
+			return nil
+		}
+	}
+	return errors.New("max retry exceeded while deleting configuration")
+}
@@ -0,0 +1,318 @@
+package domains
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func (m *memConfigureRepository) LoadApplicationByName(ctx context.Context, appName string) (*Application, error) {
+	if m.app == nil || appName != m.app.ApplicationName {
+		return nil, errors.New("application not found")
+	}
+	return m.app, nil
+}
+
+func (m *memConfigureRepository) LoadConfiguresByNamespace(ctx context.Context, nsName string) (result []*Configure, rerr error) {
+	for _, cfg := range m.configures {
+		if cfg.ConfigNamespace == nsName {
+			result = append(result, cfg)
+		}
+	}
+	return
+}
+
+func (m *memConfigureRepository) LoadConfiguresByEnvironment(ctx context.Context, env string) (result []*Configure, rerr error) {
+	for _, cfg := range m.configures {
+		if cfg.ConfigEnv == env {
+			result = append(result, cfg)
+		}
+	}
+	return
+}
+
+func (m *memConfigureRepository) LoadConfiguresByDatacenter(ctx context.Context, dc string) (result []*Configure, rerr error) {
+	for _, cfg := range m.configures {
+		if cfg.ConfigDc == dc {
+			result = append(result, cfg)
+		}
+	}
+	return
+}
+
+func (m *memConfigureRepository) LoadAppIdsByEnvironment(ctx context.Context, env string) ([]int64, error) {
+	if m.app != nil && (env == "PROD" || env == "UAT") {
+		return []int64{m.app.ApplicationId}, nil
+	}
+	return nil, nil
+}
+
+func (m *memConfigureRepository) LoadAppIdsByDatacenter(ctx context.Context, dc string) ([]int64, error) {
+	if m.app != nil && dc == "dc1" {
+		return []int64{m.app.ApplicationId}, nil
+	}
+	return nil, nil
+}
+
+func (m *memConfigureRepository) LoadAppIdsByNamespace(ctx context.Context, nsName string) (result []int64, rerr error) {
+	if _, ok := m.namespaces[nsName]; ok && m.app != nil {
+		result = append(result, m.app.ApplicationId)
+	}
+	for _, app := range m.linkingApps[nsName] {
+		result = append(result, app.ApplicationId)
+	}
+	return
+}
+
+func (m *memConfigureRepository) LoadDecommissions(ctx context.Context) ([]*Decommission, error) {
+	return slices.Clone(m.decommissions), nil
+}
+
+func (m *memConfigureRepository) AddDecommission(ctx context.Context, d *Decommission) error {
+	m.decommissions = append(m.decommissions, d)
+	return nil
+}
+
+func (m *memConfigureRepository) RemoveDecommission(ctx context.Context, entityType, entityName string) error {
+	m.decommissions = slices.DeleteFunc(m.decommissions, func(d *Decommission) bool {
+		return d.EntityType == entityType && d.EntityName == entityName
+	})
+	return nil
+}
+
+func (m *memConfigureRepository) DeleteConfigures(ctx context.Context, cfgIds []int64) error {
+	m.configures = slices.DeleteFunc(m.configures, func(cfg *Configure) bool {
+		return slices.Contains(cfgIds, cfg.ConfigId)
+	})
+	return nil
+}
+
+func (m *memConfigureRepository) DeleteNamespace(ctx context.Context, nsName string) error {
+	delete(m.namespaces, nsName)
+	return nil
+}
+
+func (m *memConfigureRepository) DeleteApplication(ctx context.Context, app *Application) error {
+	m.app = nil
+	return nil
+}
+
+func (m *memConfigureRepository) DeleteEnvironment(ctx context.Context, env string) error {
+	return nil
+}
+
+func (m *memConfigureRepository) DeleteDatacenter(ctx context.Context, dc string) error {
+	return nil
+}
+
+func TestDecommission(t *testing.T) {
+	repo := newMemConfigureRepository()
+	push := new(memPushChangeRepository)
+	h := &ConfigureHandler{ConfigureRepository: repo, PushChangeRepository: push, DecommissionGracePeriod: time.Hour}
+	ctx := context.Background()
+	now := time.Now()
+
+	for _, ns := range []string{"ns1", "ns2"} {
+		if err := h.AddApplicationNamespace(ctx, 1, ns, "application"); err != nil {
+			t.Fatal(err)
+		}
+		if err := h.AddConfiguration(ctx, &AddConfigurationRequest{AppId: 1, Env: "PROD", Dc: "dc1", Namespace: ns, Key: "k1", ContentType: "general", Content: "v1"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := h.OfflineEntity(ctx, "org", "org1", now); !errors.Is(err, ErrUnknownDecommissionType) {
+		t.Fatal("unknown type expected:", err)
+	}
+	if err := h.OnlineEntity(ctx, DecommissionTypeNamespace, "ns1"); !errors.Is(err, ErrEntityNotOffline) {
+		t.Fatal("not offline expected:", err)
+	}
+	if _, err := h.DeleteEntity(ctx, DecommissionTypeNamespace, "ns1", now); !errors.Is(err, ErrEntityNotOffline) {
+		t.Fatal("online entity should not be deleted:", err)
+	}
+
+	// offline entities are hidden and the changes are rejected
+	if err := h.OfflineEntity(ctx, DecommissionTypeNamespace, "ns1", now); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.OfflineEntity(ctx, DecommissionTypeNamespace, "ns1", now.Add(time.Minute)); err != nil || len(repo.decommissions) != 1 || repo.decommissions[0].TimeOffline != now.UnixMilli() {
+		t.Fatal("offline again should keep the offline time:", err)
+	}
+	if nsList, err := h.QueryApplicationNamespaces(ctx, 1); err != nil || len(nsList) != 1 || nsList[0].Name != "ns2" {
+		t.Fatal("offline namespace should be hidden:", nsList, err)
+	}
+	if cfgs, err := h.QueryAppConfigList(ctx, 1, "PROD", "dc1"); err != nil || len(cfgs) != 1 || cfgs["ns2"] == nil {
+		t.Fatal("configures of offline namespace should be hidden:", cfgs, err)
+	}
+	if err := h.AddConfiguration(ctx, &AddConfigurationRequest{AppId: 1, Env: "PROD", Dc: "dc1", Namespace: "ns1", Key: "k2", ContentType: "general", Content: "v2"}); !errors.Is(err, ErrEntityOffline) {
+		t.Fatal("add to offline namespace should be rejected:", err)
+	}
+	if err := h.UpdateConfigurationById(ctx, &UpdateConfigurationRequest{ConfigId: 1, ContentType: "general", Content: "v3", ConfigVersion: repo.version(1)}); !errors.Is(err, ErrEntityOffline) {
+		t.Fatal("update in offline namespace should be rejected:", err)
+	}
+	if len(push.pushed) != 2 {
+		t.Fatal("nothing should be pushed:", push.pushed)
+	}
+
+	// dependents
+	push.fetched = map[int64]int64{1: 1700000000000}
+	report, err := h.QueryDecommissionDependents(ctx, DecommissionTypeNamespace, "ns1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Decommission == nil || report.TimeDeletable != now.Add(time.Hour).UnixMilli() ||
+		len(report.Dependents) != 1 || report.Dependents[0].Configure.ConfigKey != "k1" || report.Dependents[0].Published == nil ||
+		report.Dependents[0].TimeFetched != 1700000000000 {
+		t.Fatal("unexpected report:", report)
+	}
+	report, err = h.QueryDecommissionDependents(ctx, DecommissionTypeDatacenter, "dc1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Decommission != nil || len(report.Dependents) != 2 || !slices.Equal(report.Applications, []string{"app1"}) {
+		t.Fatal("unexpected report:", report)
+	}
+
+	// hard delete after the grace period
+	if _, err := h.DeleteEntity(ctx, DecommissionTypeNamespace, "ns1", now.Add(time.Minute)); !errors.Is(err, ErrGracePeriodNotPassed) {
+		t.Fatal("grace period should be required:", err)
+	}
+	if _, err := h.DeleteEntity(ctx, DecommissionTypeNamespace, "ns1", now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := repo.namespaces["ns1"]; ok || len(repo.configures) != 1 || len(repo.decommissions) != 0 {
+		t.Fatal("namespace and configures should be deleted")
+	}
+	if push.published[0].Status != PublishedStatusDeleted || push.published[1].Status != PublishedStatusValid {
+		t.Fatal("only the published configuration of the namespace should be marked as deleted")
+	}
+
+	// cancel and delete the application
+	if err := h.OfflineEntity(ctx, DecommissionTypeApplication, "app1", now); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.LinkEnvAndDcToApp(ctx, "UAT", "dc1", 1); !errors.Is(err, ErrEntityOffline) {
+		t.Fatal("link to offline application should be rejected:", err)
+	}
+	if err := h.OnlineEntity(ctx, DecommissionTypeApplication, "app1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.UpdateConfigurationById(ctx, &UpdateConfigurationRequest{ConfigId: 2, ContentType: "general", Content: "v3", ConfigVersion: repo.version(2)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.OfflineEntity(ctx, DecommissionTypeApplication, "app1", now); err != nil {
+		t.Fatal(err)
+	}
+	report, err = h.DeleteEntity(ctx, DecommissionTypeApplication, "app1", now.Add(2*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(report.Namespaces, []string{"ns2"}) || repo.app != nil || len(repo.namespaces) != 0 || len(repo.configures) != 0 {
+		t.Fatal("application should be deleted with its namespaces and configures")
+	}
+	if push.published[1].Status != PublishedStatusDeleted {
+		t.Fatal("published configuration should be marked as deleted")
+	}
+}
+
+func TestDecommissionEnvironment(t *testing.T) {
+	repo := newMemConfigureRepository()
+	push := new(memPushChangeRepository)
+	h := &ConfigureHandler{ConfigureRepository: repo, PushChangeRepository: push, DecommissionGracePeriod: time.Hour}
+	ctx := context.Background()
+	now := time.Now()
+
+	if err := h.AddApplicationNamespace(ctx, 1, "ns1", "application"); err != nil {
+		t.Fatal(err)
+	}
+	for _, env := range []string{"PROD", "UAT"} {
+		if err := h.AddConfiguration(ctx, &AddConfigurationRequest{AppId: 1, Env: env, Dc: "dc1", Namespace: "ns1", Key: "k1", ContentType: "general", Content: "v1"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := h.OfflineEntity(ctx, DecommissionTypeEnvironment, "UAT", now); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.LinkEnvAndDcToApp(ctx, "UAT", "dc1", 1); !errors.Is(err, ErrEntityOffline) {
+		t.Fatal("link to offline environment should be rejected:", err)
+	}
+	if err := h.AddConfiguration(ctx, &AddConfigurationRequest{AppId: 1, Env: "UAT", Dc: "dc1", Namespace: "ns1", Key: "k2", ContentType: "general", Content: "v2"}); !errors.Is(err, ErrEntityOffline) {
+		t.Fatal("add to offline environment should be rejected:", err)
+	}
+	if err := h.UpdateConfigurationById(ctx, &UpdateConfigurationRequest{ConfigId: 2, ContentType: "general", Content: "v2", ConfigVersion: repo.version(2)}); !errors.Is(err, ErrEntityOffline) {
+		t.Fatal("update in offline environment should be rejected:", err)
+	}
+	if err := h.UpdateConfigurationById(ctx, &UpdateConfigurationRequest{ConfigId: 1, ContentType: "general", Content: "v2", ConfigVersion: repo.version(1)}); err != nil {
+		t.Fatal("update in online environment should be accepted:", err)
+	}
+
+	report, err := h.QueryDecommissionDependents(ctx, DecommissionTypeEnvironment, "UAT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Decommission == nil || len(report.Dependents) != 1 || report.Dependents[0].Configure.ConfigEnv != "UAT" ||
+		!slices.Equal(report.Applications, []string{"app1"}) {
+		t.Fatal("unexpected report:", report)
+	}
+	if _, err := h.DeleteEntity(ctx, DecommissionTypeEnvironment, "UAT", now.Add(time.Minute)); !errors.Is(err, ErrGracePeriodNotPassed) {
+		t.Fatal("grace period should be required:", err)
+	}
+	if _, err := h.DeleteEntity(ctx, DecommissionTypeEnvironment, "UAT", now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if len(repo.configures) != 1 || repo.configures[0].ConfigEnv != "PROD" || len(repo.decommissions) != 0 {
+		t.Fatal("configures of the environment should be deleted")
+	}
+	if push.published[0].Status != PublishedStatusValid || push.published[1].Status != PublishedStatusDeleted {
+		t.Fatal("only the published configuration of the environment should be marked as deleted")
+	}
+}
+
+func TestDecommissionLinkedNamespace(t *testing.T) {
+	repo := newMemConfigureRepository()
+	push := new(memPushChangeRepository)
+	h := &ConfigureHandler{ConfigureRepository: repo, PushChangeRepository: push, DecommissionGracePeriod: time.Hour}
+	ctx := context.Background()
+	now := time.Now()
+
+	for _, ns := range []string{"pub1", "ns1"} {
+		if err := h.AddApplicationNamespace(ctx, 1, ns, "public"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	repo.linkingApps = map[string][]*Application{"pub1": {{ApplicationId: 2, ApplicationName: "app2"}}}
+
+	for _, entity := range []struct{ entityType, entityName string }{{DecommissionTypeNamespace, "pub1"}, {DecommissionTypeApplication, "app1"}} {
+		if err := h.OfflineEntity(ctx, entity.entityType, entity.entityName, now); err != nil {
+			t.Fatal(err)
+		}
+		report, err := h.QueryDecommissionDependents(ctx, entity.entityType, entity.entityName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(report.LinkingApplications, []string{"app2"}) {
+			t.Fatal("linking applications should be reported:", report.LinkingApplications)
+		}
+		if _, err := h.DeleteEntity(ctx, entity.entityType, entity.entityName, now.Add(time.Hour)); !errors.Is(err, ErrNamespaceLinked) {
+			t.Fatal("namespace linked by other applications should not be deleted:", err)
+		}
+		if len(repo.namespaces) != 2 || repo.app == nil {
+			t.Fatal("nothing should be deleted")
+		}
+	}
+	if report, err := h.QueryDecommissionDependents(ctx, DecommissionTypeNamespace, "ns1"); err != nil || len(report.LinkingApplications) != 0 {
+		t.Fatal("the owner should not be reported as linking:", report, err)
+	}
+
+	// deletable once unlinked
+	repo.linkingApps = nil
+	if _, err := h.DeleteEntity(ctx, DecommissionTypeApplication, "app1", now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if len(repo.namespaces) != 0 || repo.app != nil {
+		t.Fatal("application should be deleted with its namespaces")
+	}
+}
@@ -0,0 +1,425 @@
+package domains
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/magiconair/properties"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	DiffTypeUnified    = "unified"
+	DiffTypeStructural = "structural"
+
+	DiffOpAdded   = "added"
+	DiffOpRemoved = "removed"
+	DiffOpChanged = "changed"
+
+	// diffContextLines is the number of unchanged lines around the changes in unified diff
+	diffContextLines = 3
+	// diffMaxTraceSize bounds the memory of the myers diff, the changed part is replaced as a whole beyond it
+	diffMaxTraceSize = 1 << 24
+)
+
+// DiffSide is one side of the comparison
+type DiffSide struct {
+	Label       string `json:"label"`
+	Version     string `json:"version"`
+	ContentType string `json:"content_type"`
+	Content     string `json:"-"`
+}
+
+// StructuralChange is the change of a key path, values are in json format
+type StructuralChange struct {
+	Path string `json:"path"`
+	Op   string `json:"op"`
+	From string `json:"from,omitempty"`
+	To   string `json:"to,omitempty"`
+}
+
+type ConfigureDiff struct {
+	Type      string    `json:"type"`
+	From      *DiffSide `json:"from"`
+	To        *DiffSide `json:"to"`
+	Identical bool      `json:"identical"`
+	// Unified is the unified diff of the contents for DiffTypeUnified
+	Unified string `json:"unified,omitempty"`
+	// Changes are the changed key paths ordered by path for DiffTypeStructural
+	Changes []*StructuralChange `json:"changes,omitempty"`
+	// Message is the reason of falling back to unified diff if the contents cannot be parsed
+	Message string `json:"message,omitempty"`
+	// Masked reports the changed contents are hidden because of a secret configure
+	Masked bool `json:"masked,omitempty"`
+}
+
+// DiffContent compares the contents. Structural diff is used when both sides have the same structured content type,
+// which can be overridden by format. Otherwise, or if any side cannot be parsed, the unified diff is produced.
+func DiffContent(from, to *DiffSide, format string) *ConfigureDiff {
+	result := &ConfigureDiff{
+		Type:      DiffTypeUnified,
+		From:      from,
+		To:        to,
+		Identical: from.Content == to.Content,
+	}
+	if format == "" && from.ContentType == to.ContentType {
+		format = from.ContentType
+	}
+	if isStructuredContentType(format) {
+		fromTree, fromErr := parseStructuredContent(format, from.Content)
+		toTree, toErr := parseStructuredContent(format, to.Content)
+		if err := errors.Join(fromErr, toErr); err != nil {
+			result.Message = fmt.Sprintf("cannot parse as %s: %s", format, err)
+		} else {
+			result.Type = DiffTypeStructural
+			result.Changes = structuralDiff(flattenContent(format, fromTree), flattenContent(format, toTree))
+			return result
+		}
+	}
+	result.Unified = UnifiedDiff(from.Label, to.Label, from.Content, to.Content)
+	return result
+}
+
+func isStructuredContentType(ct string) bool {
+	switch ct {
+	case "json", "yaml", "toml", "properties":
+		return true
+	default:
+		return false
+	}
+}
+
+// parseStructuredContent parses the content into maps, slices and scalars. Empty content is an empty document.
+func parseStructuredContent(contentType, content string) (any, error) {
+	if strings.TrimSpace(content) == "" {
+		return map[string]any{}, nil
+	}
+	var tree any
+	switch contentType {
+	case "json":
+		d := json.NewDecoder(strings.NewReader(content))
+		d.UseNumber()
+		if err := d.Decode(&tree); err != nil {
+			return nil, err
+		}
+		if d.More() {
+			return nil, errors.New("unexpected content after the json value")
+		}
+	case "yaml":
+		if err := yaml.Unmarshal([]byte(content), &tree); err != nil {
+			return nil, err
+		}
+	case "toml":
+		var m map[string]any
+		if err := toml.Unmarshal([]byte(content), &m); err != nil {
+			return nil, err
+		}
+		tree = m
+	case "properties":
+		p, err := properties.LoadString(content)
+		if err != nil {
+			return nil, err
+		}
+		m := map[string]any{}
+		for k, v := range p.Map() {
+			m[k] = v
+		}
+		tree = m
+	default:
+		return nil, errors.New("unsupported content type: " + contentType)
+	}
+	return tree, nil
+}
+
+// flattenContent returns the leaf values by key path. The keys of properties are paths already.
+func flattenContent(contentType string, tree any) map[string]string {
+	result := map[string]string{}
+	if contentType == "properties" {
+		for k, v := range tree.(map[string]any) {
+			data, _ := json.Marshal(v)
+			result[k] = string(data)
+		}
+		return result
+	}
+	flattenPaths("", tree, result)
+	return result
+}
+
+func structuralDiff(fromPaths, toPaths map[string]string) []*StructuralChange {
+	changes := []*StructuralChange{}
+	for _, path := range slices.Sorted(maps.Keys(fromPaths)) {
+		toVal, ok := toPaths[path]
+		switch {
+		case !ok:
+			changes = append(changes, &StructuralChange{Path: path, Op: DiffOpRemoved, From: fromPaths[path]})
+		case toVal != fromPaths[path]:
+			changes = append(changes, &StructuralChange{Path: path, Op: DiffOpChanged, From: fromPaths[path], To: toVal})
+		}
+	}
+	for path, toVal := range toPaths {
+		if _, ok := fromPaths[path]; !ok {
+			changes = append(changes, &StructuralChange{Path: path, Op: DiffOpAdded, To: toVal})
+		}
+	}
+	slices.SortFunc(changes, func(a, b *StructuralChange) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+	return changes
+}
+
+// flattenPaths collects the leaf values by key path, e.g. {"db":{"hosts":["a"]}} to db.hosts[0]="a".
+// Empty maps and slices are leaves so that adding or removing them is visible.
+func flattenPaths(path string, v any, result map[string]string) {
+	switch val := v.(type) {
+	case map[string]any:
+		if len(val) == 0 {
+			result[path] = "{}"
+		}
+		for k, sub := range val {
+			flattenPaths(joinPath(path, k), sub, result)
+		}
+	case map[any]any:
+		if len(val) == 0 {
+			result[path] = "{}"
+		}
+		for k, sub := range val {
+			flattenPaths(joinPath(path, fmt.Sprint(k)), sub, result)
+		}
+	case []any:
+		if len(val) == 0 {
+			result[path] = "[]"
+		}
+		for i, sub := range val {
+			flattenPaths(path+"["+strconv.Itoa(i)+"]", sub, result)
+		}
+	case []map[string]any:
+		// arrays of tables in toml
+		if len(val) == 0 {
+			result[path] = "[]"
+		}
+		for i, sub := range val {
+			flattenPaths(path+"["+strconv.Itoa(i)+"]", sub, result)
+		}
+	default:
+		data, err := json.Marshal(val)
+		if err != nil {
+			result[path] = fmt.Sprint(val)
+		} else {
+			result[path] = string(data)
+		}
+	}
+}
+
+// joinPath appends the key to the path, the keys with separators are quoted
+func joinPath(path, key string) string {
+	if key == "" || strings.ContainsAny(key, ".[]\"") {
+		return path + "[" + strconv.Quote(key) + "]"
+	}
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+type diffEdit struct {
+	op    byte // ' ', '-' or '+'
+	aLine int
+	bLine int
+}
+
+// splitLines splits the content into lines with the newlines kept, so the last line without newline differs from
+// the same line with newline
+func splitLines(content string) []string {
+	lines := strings.SplitAfter(content, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// lineEdits returns the edit script from a to b. The common prefix and suffix are excluded from myersDiff.
+func lineEdits(a, b []string) []diffEdit {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	var edits []diffEdit
+	for i := 0; i < prefix; i++ {
+		edits = append(edits, diffEdit{op: ' ', aLine: i, bLine: i})
+	}
+	midA, midB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	mid, ok := myersDiff(midA, midB)
+	if !ok {
+		// too different, replace as a whole
+		mid = nil
+		for i := range midA {
+			mid = append(mid, diffEdit{op: '-', aLine: i, bLine: 0})
+		}
+		for i := range midB {
+			mid = append(mid, diffEdit{op: '+', aLine: len(midA), bLine: i})
+		}
+	}
+	for _, e := range mid {
+		edits = append(edits, diffEdit{op: e.op, aLine: e.aLine + prefix, bLine: e.bLine + prefix})
+	}
+	for i := 0; i < suffix; i++ {
+		edits = append(edits, diffEdit{op: ' ', aLine: len(a) - suffix + i, bLine: len(b) - suffix + i})
+	}
+	return edits
+}
+
+// myersDiff returns the shortest edit script from a to b, false if the trace exceeds diffMaxTraceSize
+func myersDiff(a, b []string) ([]diffEdit, bool) {
+	n, m := len(a), len(b)
+	offset := n + m + 1
+	v := make([]int, 2*offset+1)
+	var trace [][]int
+	found := false
+	for d := 0; d <= n+m && !found; d++ {
+		if (d+1)*len(v) > diffMaxTraceSize {
+			return nil, false
+		}
+		trace = append(trace, slices.Clone(v))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				found = true
+				break
+			}
+		}
+	}
+
+	// backtrack
+	var edits []diffEdit
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			edits = append(edits, diffEdit{op: ' ', aLine: x, bLine: y})
+		}
+		if d > 0 {
+			if x == prevX {
+				edits = append(edits, diffEdit{op: '+', aLine: x, bLine: prevY})
+			} else {
+				edits = append(edits, diffEdit{op: '-', aLine: prevX, bLine: y})
+			}
+		}
+		x, y = prevX, prevY
+	}
+	slices.Reverse(edits)
+	return edits, true
+}
+
+// UnifiedDiff returns the line-level unified diff of the contents, empty if identical
+func UnifiedDiff(fromLabel, toLabel, from, to string) string {
+	if from == to {
+		return ""
+	}
+	a := splitLines(from)
+	b := splitLines(to)
+	edits := lineEdits(a, b)
+
+	buf := new(bytes.Buffer)
+	_, _ = fmt.Fprintf(buf, "--- %s\n+++ %s\n", fromLabel, toLabel)
+	for i := 0; i < len(edits); {
+		// find the next change
+		for i < len(edits) && edits[i].op == ' ' {
+			i++
+		}
+		if i == len(edits) {
+			break
+		}
+		start := max(i-diffContextLines, 0)
+		end := i
+		// extend the hunk while the gap between changes is covered by the context
+		for end < len(edits) {
+			if edits[end].op != ' ' {
+				end++
+				continue
+			}
+			next := end
+			for next < len(edits) && edits[next].op == ' ' {
+				next++
+			}
+			if next == len(edits) || next-end > 2*diffContextLines {
+				end = min(end+diffContextLines, len(edits))
+				break
+			}
+			end = next
+		}
+		writeHunk(buf, edits[start:end], a, b)
+		i = end
+	}
+	return buf.String()
+}
+
+func writeHunk(buf *bytes.Buffer, hunk []diffEdit, a, b []string) {
+	aCount, bCount := 0, 0
+	for _, e := range hunk {
+		if e.op != '+' {
+			aCount++
+		}
+		if e.op != '-' {
+			bCount++
+		}
+	}
+	_, _ = fmt.Fprintf(buf, "@@ -%s +%s @@\n", hunkRange(hunk[0].aLine, aCount), hunkRange(hunk[0].bLine, bCount))
+	for _, e := range hunk {
+		var line string
+		if e.op == '+' {
+			line = b[e.bLine]
+		} else {
+			line = a[e.aLine]
+		}
+		buf.WriteByte(e.op)
+		buf.WriteString(line)
+		if !strings.HasSuffix(line, "\n") {
+			buf.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
+
+// hunkRange formats the range of the hunk header, the start is the line before the hunk if the count is 0
+func hunkRange(start, count int) string {
+	switch count {
+	case 0:
+		return strconv.Itoa(start) + ",0"
+	case 1:
+		return strconv.Itoa(start + 1)
+	default:
+		return strconv.Itoa(start+1) + "," + strconv.Itoa(count)
+	}
+}
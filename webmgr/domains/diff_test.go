@@ -0,0 +1,177 @@
+package domains
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func (m *memConfigureRepository) AddConfigureHistory(ctx context.Context, h *ConfigureHistory) error {
+	h.HistoryId = int64(len(m.histories) + 1)
+	m.histories = append(m.histories, h)
+	return nil
+}
+
+func (m *memConfigureRepository) LoadConfigureHistoryList(ctx context.Context, cfgId int64) (result []*ConfigureHistory, rerr error) {
+	for i := len(m.histories) - 1; i >= 0; i-- {
+		if m.histories[i].ConfigId == cfgId {
+			result = append(result, m.histories[i])
+		}
+	}
+	return
+}
+
+func (m *memConfigureRepository) LoadConfigureHistory(ctx context.Context, cfgId int64, version string) (*ConfigureHistory, error) {
+	for _, h := range m.histories {
+		if h.ConfigId == cfgId && h.ConfigVersion == version {
+			return h, nil
+		}
+	}
+	return nil, nil
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	cases := []struct {
+		name     string
+		from, to string
+		expected string
+	}{
+		{"identical", "a\nb\n", "a\nb\n", ""},
+		{"add to empty", "", "a\nb\n", "--- f\n+++ t\n@@ -0,0 +1,2 @@\n+a\n+b\n"},
+		{"remove all", "a\n", "", "--- f\n+++ t\n@@ -1 +0,0 @@\n-a\n"},
+		{"change", "1\n2\n3\n4\n5\n6\n7\n8\n9\n", "1\n2\n3\n4\nfive\n6\n7\n8\n9\n",
+			"--- f\n+++ t\n@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n"},
+		{"separated hunks", "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n", "one\n2\n3\n4\n5\n6\n7\n8\n9\nten\n",
+			"--- f\n+++ t\n@@ -1,4 +1,4 @@\n-1\n+one\n 2\n 3\n 4\n@@ -7,4 +7,4 @@\n 7\n 8\n 9\n-10\n+ten\n"},
+		{"merged hunks", "1\n2\n3\n4\n5\n6\n7\n", "one\n2\n3\n4\n5\n6\nseven\n",
+			"--- f\n+++ t\n@@ -1,7 +1,7 @@\n-1\n+one\n 2\n 3\n 4\n 5\n 6\n-7\n+seven\n"},
+		{"no newline at end", "a\nb", "a\nb\n", "--- f\n+++ t\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+b\n"},
+	}
+	for _, c := range cases {
+		if got := UnifiedDiff("f", "t", c.from, c.to); got != c.expected {
+			t.Fatalf("%s: unexpected diff:\n%s\nexpected:\n%s", c.name, got, c.expected)
+		}
+	}
+
+	// too different contents are replaced as a whole
+	from, to := new(strings.Builder), new(strings.Builder)
+	for i := 0; i < 5000; i++ {
+		_, _ = fmt.Fprintf(from, "a%d\n", i)
+		_, _ = fmt.Fprintf(to, "b%d\n", i)
+	}
+	got := UnifiedDiff("f", "t", "same\n"+from.String(), "same\n"+to.String())
+	if !strings.HasPrefix(got, "--- f\n+++ t\n@@ -1,5001 +1,5001 @@\n same\n-a0\n") || strings.Count(got, "\n-") != 5000 || strings.Count(got, "\n+") != 5001 {
+		t.Fatal("unexpected diff:", got[:100])
+	}
+}
+
+func TestStructuralDiff(t *testing.T) {
+	cases := map[string][2]string{
+		"json":       {`{"db":{"host":"a","port":1},"tags":["x"],"old":true}`, `{"db":{"host":"b","port":1},"tags":["x","y"],"a.b":{}}`},
+		"yaml":       {"db:\n  host: a\n  port: 1\ntags: [x]\nold: true\n", "db:\n  host: b\n  port: 1\ntags: [x, y]\na.b: {}\n"},
+		"toml":       {"old = true\ntags = [\"x\"]\n[db]\nhost = \"a\"\nport = 1\n", "tags = [\"x\", \"y\"]\n[\"a.b\"]\n[db]\nhost = \"b\"\nport = 1\n"},
+		"properties": {"db.host=a\ndb.port=1\ntags=x\nold=true\n", "db.host=b\ndb.port=1\ntags=x,y\na.b={}\n"},
+	}
+	for format, contents := range cases {
+		result := DiffContent(&DiffSide{ContentType: format, Content: contents[0]}, &DiffSide{ContentType: format, Content: contents[1]}, "")
+		if result.Type != DiffTypeStructural {
+			t.Fatal(format, "structural diff expected:", result.Message)
+		}
+		var got []string
+		for _, c := range result.Changes {
+			got = append(got, c.Op+" "+c.Path+" "+c.From+" "+c.To)
+		}
+		expected := []string{
+			`added ["a.b"]  {}`,
+			`changed db.host "a" "b"`,
+			`removed old true `,
+			`added tags[1]  "y"`,
+		}
+		if format == "properties" {
+			expected = []string{
+				`added a.b  "{}"`,
+				`changed db.host "a" "b"`,
+				`removed old "true" `,
+				`changed tags "x" "x,y"`,
+			}
+		}
+		if len(got) != len(expected) {
+			t.Fatalf("%s: unexpected changes: %q", format, got)
+		}
+		for i := range got {
+			if got[i] != expected[i] {
+				t.Fatalf("%s: unexpected changes: %q", format, got)
+			}
+		}
+	}
+
+	// fallback to unified diff
+	result := DiffContent(&DiffSide{ContentType: "general", Content: "{"}, &DiffSide{ContentType: "general", Content: "{}"}, "json")
+	if result.Type != DiffTypeUnified || result.Message == "" || result.Unified == "" {
+		t.Fatal("unified diff expected for invalid content:", result)
+	}
+	result = DiffContent(&DiffSide{ContentType: "general", Content: `{"a":1}`}, &DiffSide{ContentType: "general", Content: `{"a":2}`}, "json")
+	if result.Type != DiffTypeStructural || len(result.Changes) != 1 {
+		t.Fatal("format should override the content type:", result)
+	}
+}
+
+func TestDiffConfigure(t *testing.T) {
+	repo := newMemConfigureRepository()
+	h := &ConfigureHandler{ConfigureRepository: repo, PushChangeRepository: new(memPushChangeRepository)}
+	ctx := context.Background()
+	if err := h.AddApplicationNamespace(ctx, 1, "ns1", "application"); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.AddConfiguration(ctx, &AddConfigurationRequest{AppId: 1, Env: "PROD", Dc: "dc1", Namespace: "ns1", Key: "k1", ContentType: "general", Content: "a\n"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.UpdateConfigurationById(ctx, &UpdateConfigurationRequest{ConfigId: 1, ContentType: "general", Content: "b\n", ConfigVersion: repo.version(1)}); err != nil {
+		t.Fatal(err)
+	}
+
+	history, err := h.QueryConfigureHistory(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 || history[0].Content != "b\n" || history[1].Content != "a\n" {
+		t.Fatal("unexpected history:", history)
+	}
+	result, err := h.DiffConfigureVersions(ctx, 1, history[1].ConfigVersion, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "--- PROD/dc1/ns1/k1@" + history[1].ConfigVersion + "\n+++ PROD/dc1/ns1/k1@" + history[0].ConfigVersion + "\n@@ -1 +1 @@\n-a\n+b\n"
+	if result.Unified != expected || result.Identical {
+		t.Fatal("unexpected diff:", result.Unified)
+	}
+	if _, err := h.DiffConfigureVersions(ctx, 1, "missing", "", ""); !errors.Is(err, ErrVersionNotFound) {
+		t.Fatal("version not found expected:", err)
+	}
+
+	// across env and dc
+	req := &EnvDcDiffRequest{AppId: 1, Namespace: "ns1", Key: "k1", FromEnv: "PROD", FromDc: "dc1", ToEnv: "UAT", ToDc: "dc1"}
+	result, err = h.DiffConfigureEnvDc(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.To.Version != "" || result.Unified != "--- PROD/dc1/ns1/k1@"+history[0].ConfigVersion+"\n+++ UAT/dc1/ns1/k1@\n@@ -1 +0,0 @@\n-b\n" {
+		t.Fatal("missing side should be empty:", result.Unified)
+	}
+	if err := h.AddConfiguration(ctx, &AddConfigurationRequest{AppId: 1, Env: "UAT", Dc: "dc1", Namespace: "ns1", Key: "k1", ContentType: "general", Content: "b\n"}); err != nil {
+		t.Fatal(err)
+	}
+	if result, err = h.DiffConfigureEnvDc(ctx, req); err != nil || !result.Identical {
+		t.Fatal("should be identical:", result, err)
+	}
+	req.ToDc = "dc2"
+	if _, err := h.DiffConfigureEnvDc(ctx, req); err == nil {
+		t.Fatal("unlinked env and dc should fail")
+	}
+	req.Key, req.ToDc = "k2", "dc1"
+	if _, err := h.DiffConfigureEnvDc(ctx, req); !errors.Is(err, ErrVersionNotFound) {
+		t.Fatal("not found expected:", err)
+	}
+}
@@ -43,3 +43,15 @@ func ApplyConfigureChange(ctx context.Context, repo PushChangeRepository, cfg *C
 	}
 	return errors.New("max retry exceeded while applying configure")
 }
+
+// ApplyConfigureChanges pushes the changes together in the current transaction.
+// The sequence lock is held till the end of the transaction once acquired by the first change,
+// so the changes get contiguous sequences and become visible to the read servers at the same time.
+func ApplyConfigureChanges(ctx context.Context, repo PushChangeRepository, cfgs []*Configure, app *Application) error {
+	for _, cfg := range cfgs {
+		if err := ApplyConfigureChange(ctx, repo, cfg, app); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -8,4 +8,9 @@ type PushChangeRepository interface {
 	InsertNewConfigure(ctx context.Context, cfg *Configure, app *Application) (int64, error)
 	UpdateConfigurationSequence(ctx context.Context, cfg *Configure, app *Application, configId int64) (bool, error)
 	UpdateConfigure(ctx context.Context, cfg *Configure, app *Application) (bool, error)
+
+	LoadPublishedConfiguration(ctx context.Context, cfg *Configure, app *Application) (*PublishedConfiguration, error)
+	MarkConfigurationDeleted(ctx context.Context, configId int64) (bool, error)
+	// LoadFetchTime returns the last time the clients requested the configuration recorded by the read servers, 0 if never
+	LoadFetchTime(ctx context.Context, p *PublishedConfiguration) (int64, error)
 }
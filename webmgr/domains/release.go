@@ -0,0 +1,183 @@
+package domains
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/goodplayer/onlyconfig/webmgr/tools"
+)
+
+const (
+	ReleaseTypePublish   = "publish"
+	ReleaseTypeRollback  = "rollback"
+	ReleaseTypeScheduled = "scheduled"
+)
+
+var (
+	ErrReleaseNotFound = errors.New("release not found")
+	ErrReleaseEnvDc    = errors.New("configures of a release should be in the same env and dc")
+	// ErrReleaseConflict is returned when rolling back a release whose configures have been changed since
+	ErrReleaseConflict = errors.New("configures changed after the release")
+)
+
+// Release is a group of configure changes in an env and dc of the application published together.
+// All the items share the same version so that the clients are able to tell whether they get the whole release.
+type Release struct {
+	ReleaseId      int64
+	AppId          int64
+	Env            string
+	Dc             string
+	ReleaseType    string
+	ReleaseVersion string
+	// RollbackOf is the release rolled back by this one, 0 if not a rollback
+	RollbackOf int64
+	// RolledBackBy is the release rolling back this one, 0 if not rolled back
+	RolledBackBy int64
+	TimeCreated  int64
+	TimeUpdated  int64
+
+	Items []*ReleaseItem
+}
+
+type ReleaseItem struct {
+	ConfigId        int64
+	ConfigNamespace string
+	ConfigKey       string
+	// FromVersion is the version before the release
+	FromVersion string
+	ToVersion   string
+}
+
+func newRelease(app *Application, cfg *Configure, releaseType string) *Release {
+	now := time.Now()
+	return &Release{
+		AppId:       app.ApplicationId,
+		Env:         cfg.ConfigEnv,
+		Dc:          cfg.ConfigDc,
+		ReleaseType: releaseType,
+		TimeCreated: now.UnixMilli(),
+		TimeUpdated: now.UnixMilli(),
+	}
+}
+
+// addItem adds the configure before it is changed to the release
+func (r *Release) addItem(cfg *Configure) {
+	r.Items = append(r.Items, &ReleaseItem{
+		ConfigId:        cfg.ConfigId,
+		ConfigNamespace: cfg.ConfigNamespace,
+		ConfigKey:       cfg.ConfigKey,
+		FromVersion:     cfg.ConfigVersion,
+		ToVersion:       r.ReleaseVersion,
+	})
+}
+
+// publishRelease saves the release of the configures and publishes them with the same version in the transaction.
+// setContent sets the new content of the i-th configure.
+func (c *ConfigureHandler) publishRelease(ctx context.Context, app *Application, release *Release, cfgs []*Configure, setContent func(i int, cfg *Configure)) error {
+	seq, err := c.ConfigureRepository.NextConfigVersionSeq(ctx)
+	if err != nil {
+		return err
+	}
+	release.ReleaseVersion = tools.VersionToString(seq)
+	for _, cfg := range cfgs {
+		release.addItem(cfg)
+	}
+	if err := c.ConfigureRepository.AddRelease(ctx, release); err != nil {
+		return err
+	}
+	var changes []*ConfigureChange
+	for i, cfg := range cfgs {
+		before := *cfg
+		setContent(i, cfg)
+		if err := c.saveVersion(ctx, cfg, release.ReleaseVersion, release.ReleaseId); err != nil {
+			return err
+		}
+		changes = append(changes, &ConfigureChange{Before: &before, After: cfg, ReleaseId: release.ReleaseId})
+	}
+	if err := ApplyConfigureChanges(ctx, c.PushChangeRepository, cfgs, app); err != nil {
+		return err
+	}
+	return c.notifyChanges(ctx, app, changes)
+}
+
+// QueryReleases returns the releases of the env and dc of the application, the latest first
+func (c *ConfigureHandler) QueryReleases(ctx context.Context, appId int64, env, dc string) ([]*Release, error) {
+	app, err := c.ConfigureRepository.LoadApplicationById(ctx, appId)
+	if err != nil {
+		return nil, err
+	}
+	return c.ConfigureRepository.LoadReleases(ctx, app, env, dc)
+}
+
+func (c *ConfigureHandler) QueryRelease(ctx context.Context, releaseId int64) (*Release, error) {
+	release, err := c.ConfigureRepository.LoadRelease(ctx, releaseId)
+	if err != nil {
+		return nil, err
+	}
+	if release == nil {
+		return nil, fmt.Errorf("%w: %d", ErrReleaseNotFound, releaseId)
+	}
+	return release, nil
+}
+
+// RollbackRelease publishes the versions before the release as a new release of type rollback.
+// It fails with ErrReleaseConflict if any configure of the release has been changed after it.
+func (c *ConfigureHandler) RollbackRelease(ctx context.Context, releaseId int64) (*Release, error) {
+	release, err := c.QueryRelease(ctx, releaseId)
+	if err != nil {
+		return nil, err
+	}
+	app, err := c.ConfigureRepository.LoadApplicationById(ctx, release.AppId)
+	if err != nil {
+		return nil, err
+	}
+	var cfgs []*Configure
+	var histories []*ConfigureHistory
+	var conflicts []string
+	for _, item := range release.Items {
+		cfg, err := c.ConfigureRepository.LoadConfigureById(ctx, item.ConfigId)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := c.loadConfigureApp(ctx, cfg); err != nil {
+			return nil, err
+		}
+		if cfg.ConfigVersion != item.ToVersion {
+			conflicts = append(conflicts, fmt.Sprintf("%s/%s@%s", cfg.ConfigNamespace, cfg.ConfigKey, cfg.ConfigVersion))
+			continue
+		}
+		h, err := c.ConfigureRepository.LoadConfigureHistory(ctx, cfg.ConfigId, item.FromVersion)
+		if err != nil {
+			return nil, err
+		}
+		if h == nil {
+			return nil, fmt.Errorf("%w: %s", ErrVersionNotFound, diffLabel(cfg, item.FromVersion))
+		}
+		cfgs = append(cfgs, cfg)
+		histories = append(histories, h)
+	}
+	if len(conflicts) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrReleaseConflict, strings.Join(conflicts, ", "))
+	}
+	if len(cfgs) == 0 {
+		return nil, fmt.Errorf("%w: release %d has no item", ErrReleaseNotFound, releaseId)
+	}
+
+	rollback := newRelease(app, cfgs[0], ReleaseTypeRollback)
+	rollback.RollbackOf = release.ReleaseId
+	if err := c.publishRelease(ctx, app, rollback, cfgs, func(i int, cfg *Configure) {
+		cfg.ContentType = histories[i].ContentType
+		cfg.Content = histories[i].Content
+	}); err != nil {
+		return nil, err
+	}
+	release.RolledBackBy = rollback.ReleaseId
+	release.TimeUpdated = rollback.TimeCreated
+	if err := c.ConfigureRepository.UpdateReleaseRolledBack(ctx, release); err != nil {
+		return nil, err
+	}
+	return rollback, nil
+}
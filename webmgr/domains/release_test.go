@@ -0,0 +1,111 @@
+package domains
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func (m *memConfigureRepository) AddRelease(ctx context.Context, release *Release) error {
+	release.ReleaseId = int64(len(m.releases) + 1)
+	m.releases = append(m.releases, release)
+	return nil
+}
+
+func (m *memConfigureRepository) LoadReleases(ctx context.Context, app *Application, env, dc string) (result []*Release, rerr error) {
+	for i := len(m.releases) - 1; i >= 0; i-- {
+		if r := m.releases[i]; r.AppId == app.ApplicationId && r.Env == env && r.Dc == dc {
+			result = append(result, r)
+		}
+	}
+	return
+}
+
+func (m *memConfigureRepository) LoadRelease(ctx context.Context, releaseId int64) (*Release, error) {
+	for _, r := range m.releases {
+		if r.ReleaseId == releaseId {
+			return r, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *memConfigureRepository) UpdateReleaseRolledBack(ctx context.Context, release *Release) error {
+	return nil
+}
+
+func TestReleaseRollback(t *testing.T) {
+	repo := newMemConfigureRepository()
+	push := new(memPushChangeRepository)
+	h := &ConfigureHandler{ConfigureRepository: repo, PushChangeRepository: push}
+	ctx := context.Background()
+	if err := h.AddApplicationNamespace(ctx, 1, "db", "application"); err != nil {
+		t.Fatal(err)
+	}
+	for _, req := range []*AddConfigurationRequest{
+		{AppId: 1, Env: "PROD", Dc: "dc1", Namespace: "db", Key: "host", ContentType: "general", Content: "db1"},
+		{AppId: 1, Env: "PROD", Dc: "dc1", Namespace: "db", Key: "password", ContentType: "general", Content: "p1"},
+		{AppId: 1, Env: "UAT", Dc: "dc1", Namespace: "db", Key: "host", ContentType: "general", Content: "db1"},
+	} {
+		if err := h.AddConfiguration(ctx, req); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for id, content := range map[int64]string{1: "db2", 2: "p2", 3: "db2"} {
+		if err := h.SaveConfigureDraft(ctx, &UpdateConfigurationRequest{ConfigId: id, ContentType: "general", Content: content}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := h.PublishConfigures(ctx, &PublishRequest{AppId: 1, ConfigIds: []int64{1, 3}}); !errors.Is(err, ErrReleaseEnvDc) {
+		t.Fatal("different env and dc should fail:", err)
+	}
+	push.pushed = nil
+	release, err := h.PublishConfigures(ctx, &PublishRequest{AppId: 1, ConfigIds: []int64{1, 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	host, _ := h.QueryConfigureById(ctx, 1)
+	password, _ := h.QueryConfigureById(ctx, 2)
+	if release.ReleaseType != ReleaseTypePublish || release.Env != "PROD" || release.Dc != "dc1" || len(release.Items) != 2 ||
+		host.ConfigVersion != release.ReleaseVersion || password.ConfigVersion != release.ReleaseVersion {
+		t.Fatal("items should share the release version:", release, host.ConfigVersion, password.ConfigVersion)
+	}
+	if release.Items[0].FromVersion == release.ReleaseVersion || release.Items[0].ToVersion != release.ReleaseVersion {
+		t.Fatal("unexpected release item:", release.Items[0])
+	}
+	if history, _ := h.QueryConfigureHistory(ctx, 1); history[0].ReleaseId != release.ReleaseId || history[1].ReleaseId != 0 {
+		t.Fatal("history should record the release:", history)
+	}
+
+	rollback, err := h.RollbackRelease(ctx, release.ReleaseId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	host, _ = h.QueryConfigureById(ctx, 1)
+	password, _ = h.QueryConfigureById(ctx, 2)
+	if rollback.ReleaseType != ReleaseTypeRollback || rollback.RollbackOf != release.ReleaseId || release.RolledBackBy != rollback.ReleaseId ||
+		host.Content != "db1" || password.Content != "p1" || host.ConfigVersion != rollback.ReleaseVersion || password.ConfigVersion != rollback.ReleaseVersion {
+		t.Fatal("unexpected rollback:", rollback, host, password)
+	}
+	if len(push.pushed) != 4 || push.pushed[2] != "host=db1" || push.pushed[3] != "password=p1" {
+		t.Fatal("unexpected pushed:", push.pushed)
+	}
+	if list, _ := h.QueryReleases(ctx, 1, "PROD", "dc1"); len(list) != 2 || list[0] != rollback {
+		t.Fatal("unexpected releases:", list)
+	}
+
+	// rolled back already
+	if _, err := h.RollbackRelease(ctx, release.ReleaseId); !errors.Is(err, ErrReleaseConflict) {
+		t.Fatal("conflict expected:", err)
+	}
+	// changed after the release
+	if err := h.UpdateConfigurationById(ctx, &UpdateConfigurationRequest{ConfigId: 2, ContentType: "general", Content: "p3", ConfigVersion: repo.version(2)}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.RollbackRelease(ctx, rollback.ReleaseId); !errors.Is(err, ErrReleaseConflict) {
+		t.Fatal("conflict expected:", err)
+	}
+	if _, err := h.RollbackRelease(ctx, 100); !errors.Is(err, ErrReleaseNotFound) {
+		t.Fatal("not found expected:", err)
+	}
+}
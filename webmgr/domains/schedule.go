@@ -0,0 +1,343 @@
+package domains
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+const (
+	ScheduleStatusPending   = "pending"
+	ScheduleStatusPublished = "published"
+	ScheduleStatusReverted  = "reverted"
+	ScheduleStatusFailed    = "failed"
+	ScheduleStatusCanceled  = "canceled"
+)
+
+var (
+	ErrScheduleNotFound = errors.New("schedule not found")
+	// ErrScheduleDone is returned when canceling a schedule which has nothing left to run
+	ErrScheduleDone = errors.New("schedule already done")
+	// ErrScheduleConflict is returned when the configures have been changed after scheduled
+	ErrScheduleConflict = errors.New("configures changed after scheduled")
+)
+
+// Schedule publishes the contents of the items as a release at TimePublish, and optionally reverts the release
+// after RevertAfter. The contents are taken from the drafts when scheduled.
+type Schedule struct {
+	ScheduleId int64
+	AppId      int64
+	Env        string
+	Dc         string
+	Status     string
+	// TimePublish is the time to publish in milliseconds
+	TimePublish int64
+	// RevertAfter is the duration in milliseconds to revert after published, 0 if no revert
+	RevertAfter int64
+	// TimeRevert is the time to revert in milliseconds, set when published, 0 if no revert pending
+	TimeRevert      int64
+	ReleaseId       int64
+	RevertReleaseId int64
+	// Message is the reason of the failure
+	Message     string
+	TimeCreated int64
+	TimeUpdated int64
+
+	Items []*ScheduleItem
+}
+
+type ScheduleItem struct {
+	ConfigId        int64
+	ConfigNamespace string
+	ConfigKey       string
+	// FromVersion is the version when scheduled, publishing fails if the configure has been changed since
+	FromVersion string
+	ContentType string
+	Content     string
+	// Secret items are encrypted at rest and masked in the responses
+	Secret bool
+}
+
+// due reports whether the schedule has a step to run at the time
+func (s *Schedule) due(now int64) bool {
+	switch s.Status {
+	case ScheduleStatusPending:
+		return s.TimePublish <= now
+	case ScheduleStatusPublished:
+		return s.TimeRevert > 0 && s.TimeRevert <= now
+	default:
+		return false
+	}
+}
+
+type ScheduleRequest struct {
+	PublishRequest
+	TimePublish int64
+	RevertAfter int64
+}
+
+// SchedulePublish schedules publishing the drafts of the configures as a release.
+// The drafts are checked and validated the same as publishing at once, and then moved to the schedule.
+func (c *ConfigureHandler) SchedulePublish(ctx context.Context, req *ScheduleRequest) (*Schedule, error) {
+	if req.TimePublish <= 0 {
+		return nil, errors.New("publish time required")
+	}
+	if req.RevertAfter < 0 {
+		return nil, errors.New("negative revert duration")
+	}
+	app, cfgs, err := c.loadPublishDrafts(ctx, &req.PublishRequest)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UnixMilli()
+	s := &Schedule{
+		AppId:       app.ApplicationId,
+		Env:         cfgs[0].ConfigEnv,
+		Dc:          cfgs[0].ConfigDc,
+		Status:      ScheduleStatusPending,
+		TimePublish: req.TimePublish,
+		RevertAfter: req.RevertAfter,
+		TimeCreated: now,
+		TimeUpdated: now,
+	}
+	for _, cfg := range cfgs {
+		s.Items = append(s.Items, &ScheduleItem{
+			ConfigId:        cfg.ConfigId,
+			ConfigNamespace: cfg.ConfigNamespace,
+			ConfigKey:       cfg.ConfigKey,
+			FromVersion:     cfg.ConfigVersion,
+			ContentType:     cfg.DraftContentType,
+			Content:         cfg.DraftContent,
+			Secret:          cfg.Secret,
+		})
+		cfg.DraftContentType = ""
+		cfg.DraftContent = ""
+		cfg.TimeDrafted = 0
+		if err := c.ConfigureRepository.UpdateConfigureDraft(ctx, cfg); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.ConfigureRepository.AddSchedule(ctx, s); err != nil {
+		return nil, err
+	}
+	return s.masked(), nil
+}
+
+// QuerySchedules returns the schedules of the env and dc of the application, the latest first
+func (c *ConfigureHandler) QuerySchedules(ctx context.Context, appId int64, env, dc string) ([]*Schedule, error) {
+	app, err := c.ConfigureRepository.LoadApplicationById(ctx, appId)
+	if err != nil {
+		return nil, err
+	}
+	list, err := c.ConfigureRepository.LoadSchedules(ctx, app, env, dc)
+	if err != nil {
+		return nil, err
+	}
+	for i, s := range list {
+		list[i] = s.masked()
+	}
+	return list, nil
+}
+
+// QuerySchedule returns the schedule with the contents of the secret items masked
+func (c *ConfigureHandler) QuerySchedule(ctx context.Context, scheduleId int64) (*Schedule, error) {
+	s, err := c.loadSchedule(ctx, scheduleId)
+	if err != nil {
+		return nil, err
+	}
+	return s.masked(), nil
+}
+
+func (c *ConfigureHandler) loadSchedule(ctx context.Context, scheduleId int64) (*Schedule, error) {
+	s, err := c.ConfigureRepository.LoadSchedule(ctx, scheduleId)
+	if err != nil {
+		return nil, err
+	}
+	if s == nil {
+		return nil, fmt.Errorf("%w: %d", ErrScheduleNotFound, scheduleId)
+	}
+	return s, nil
+}
+
+// CancelSchedule cancels publishing of a pending schedule, or the revert of a published one
+func (c *ConfigureHandler) CancelSchedule(ctx context.Context, scheduleId int64) (*Schedule, error) {
+	if _, err := c.ConfigureRepository.LockSchedules(ctx, true); err != nil {
+		return nil, err
+	}
+	s, err := c.loadSchedule(ctx, scheduleId)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case s.Status == ScheduleStatusPending:
+		s.Status = ScheduleStatusCanceled
+	case s.Status == ScheduleStatusPublished && s.TimeRevert > 0:
+		s.TimeRevert = 0
+	default:
+		return nil, fmt.Errorf("%w: %d is %s", ErrScheduleDone, scheduleId, s.Status)
+	}
+	s.TimeUpdated = time.Now().UnixMilli()
+	if err := c.ConfigureRepository.UpdateSchedule(ctx, s); err != nil {
+		return nil, err
+	}
+	return s.masked(), nil
+}
+
+// RunDueSchedule runs the next step of a due schedule: publishing or reverting.
+// It returns nil if nothing is due, or the schedules are being run by another instance.
+// On error, the returned schedule is the one failed, the transaction should be rolled back and FailSchedule called.
+func (c *ConfigureHandler) RunDueSchedule(ctx context.Context, now time.Time) (*Schedule, error) {
+	if locked, err := c.ConfigureRepository.LockSchedules(ctx, false); err != nil {
+		return nil, err
+	} else if !locked {
+		return nil, nil
+	}
+	s, err := c.ConfigureRepository.LoadDueSchedule(ctx, now.UnixMilli())
+	if err != nil || s == nil {
+		return nil, err
+	}
+	// the state before running is returned on error, which is the one to be marked failed
+	due := *s
+	if s.Status == ScheduleStatusPending {
+		err = c.runSchedulePublish(ctx, s, now)
+	} else {
+		err = c.runScheduleRevert(ctx, s, now)
+	}
+	if err != nil {
+		return &due, err
+	}
+	s.TimeUpdated = now.UnixMilli()
+	if err := c.ConfigureRepository.UpdateSchedule(ctx, s); err != nil {
+		return &due, err
+	}
+	return s, nil
+}
+
+func (c *ConfigureHandler) runSchedulePublish(ctx context.Context, s *Schedule, now time.Time) error {
+	app, err := c.ConfigureRepository.LoadApplicationById(ctx, s.AppId)
+	if err != nil {
+		return err
+	}
+	var cfgs []*Configure
+	var conflicts []string
+	for _, item := range s.Items {
+		cfg, err := c.ConfigureRepository.LoadConfigureById(ctx, item.ConfigId)
+		if err != nil {
+			return err
+		}
+		if _, err := c.loadConfigureApp(ctx, cfg); err != nil {
+			return err
+		}
+		if cfg.ConfigVersion != item.FromVersion {
+			conflicts = append(conflicts, fmt.Sprintf("%s/%s@%s", cfg.ConfigNamespace, cfg.ConfigKey, cfg.ConfigVersion))
+			continue
+		}
+		cfgs = append(cfgs, cfg)
+	}
+	if len(conflicts) > 0 {
+		return fmt.Errorf("%w: %s", ErrScheduleConflict, strings.Join(conflicts, ", "))
+	}
+	if len(cfgs) == 0 {
+		return fmt.Errorf("%w: schedule %d has no item", ErrScheduleNotFound, s.ScheduleId)
+	}
+	release := newRelease(app, cfgs[0], ReleaseTypeScheduled)
+	if err := c.publishRelease(ctx, app, release, cfgs, func(i int, cfg *Configure) {
+		cfg.ContentType = s.Items[i].ContentType
+		cfg.Content = s.Items[i].Content
+	}); err != nil {
+		return err
+	}
+	s.Status = ScheduleStatusPublished
+	s.ReleaseId = release.ReleaseId
+	if s.RevertAfter > 0 {
+		s.TimeRevert = now.UnixMilli() + s.RevertAfter
+	}
+	return nil
+}
+
+func (c *ConfigureHandler) runScheduleRevert(ctx context.Context, s *Schedule, now time.Time) error {
+	rollback, err := c.RollbackRelease(ctx, s.ReleaseId)
+	if err != nil {
+		return err
+	}
+	s.Status = ScheduleStatusReverted
+	s.TimeRevert = 0
+	s.RevertReleaseId = rollback.ReleaseId
+	return nil
+}
+
+// FailSchedule marks the schedule failed with the cause, unless it has been run by another instance meanwhile
+func (c *ConfigureHandler) FailSchedule(ctx context.Context, failed *Schedule, cause error, now time.Time) error {
+	if _, err := c.ConfigureRepository.LockSchedules(ctx, true); err != nil {
+		return err
+	}
+	s, err := c.loadSchedule(ctx, failed.ScheduleId)
+	if err != nil {
+		return err
+	}
+	if s.Status != failed.Status || s.TimeRevert != failed.TimeRevert || !s.due(now.UnixMilli()) {
+		return nil
+	}
+	s.Status = ScheduleStatusFailed
+	s.TimeRevert = 0
+	s.Message = cause.Error()
+	s.TimeUpdated = now.UnixMilli()
+	return c.ConfigureRepository.UpdateSchedule(ctx, s)
+}
+
+// Scheduler runs the due schedules on the worker periodically
+type Scheduler struct {
+	ConfigureHandler *ConfigureHandler
+	Worker           *Worker
+	// RunInTxn runs fn in a new transaction, and commits only if fn succeeds
+	RunInTxn func(fn func(ctx context.Context) error) error
+	Interval time.Duration
+}
+
+// Start checks the due schedules every interval till ctx is done
+func (s *Scheduler) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(s.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			<-s.Worker.SubmitFnTask(func(result chan<- any) {
+				result <- s.RunOnce(time.Now())
+			})
+		}
+	}()
+}
+
+// RunOnce runs the due schedules one by one, each in its own transaction, and returns the number of the steps run
+func (s *Scheduler) RunOnce(now time.Time) int {
+	cnt := 0
+	for {
+		var schedule *Schedule
+		err := s.RunInTxn(func(ctx context.Context) (err error) {
+			schedule, err = s.ConfigureHandler.RunDueSchedule(WithAuthor(ctx, SchedulerAuthor), now)
+			return err
+		})
+		if err != nil && schedule != nil {
+			log.Println("run schedule failed:", schedule.ScheduleId, err)
+			if ferr := s.RunInTxn(func(ctx context.Context) error {
+				return s.ConfigureHandler.FailSchedule(ctx, schedule, err, now)
+			}); ferr != nil {
+				log.Println("mark schedule failed failed:", schedule.ScheduleId, ferr)
+				return cnt
+			}
+		} else if err != nil {
+			log.Println("run schedules failed:", err)
+			return cnt
+		} else if schedule == nil {
+			return cnt
+		}
+		cnt++
+	}
+}
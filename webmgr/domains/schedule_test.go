@@ -0,0 +1,171 @@
+package domains
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func (m *memConfigureRepository) LockSchedules(ctx context.Context, wait bool) (bool, error) {
+	return wait || !m.lockedByOthers, nil
+}
+
+func (m *memConfigureRepository) AddSchedule(ctx context.Context, s *Schedule) error {
+	s.ScheduleId = int64(len(m.schedules) + 1)
+	m.schedules = append(m.schedules, s)
+	return nil
+}
+
+func (m *memConfigureRepository) LoadSchedules(ctx context.Context, app *Application, env, dc string) (result []*Schedule, rerr error) {
+	for i := len(m.schedules) - 1; i >= 0; i-- {
+		if s := m.schedules[i]; s.AppId == app.ApplicationId && s.Env == env && s.Dc == dc {
+			result = append(result, s)
+		}
+	}
+	return
+}
+
+func (m *memConfigureRepository) LoadSchedule(ctx context.Context, scheduleId int64) (*Schedule, error) {
+	for _, s := range m.schedules {
+		if s.ScheduleId == scheduleId {
+			return s, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *memConfigureRepository) LoadDueSchedule(ctx context.Context, now int64) (*Schedule, error) {
+	for _, s := range m.schedules {
+		if s.due(now) {
+			return s, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *memConfigureRepository) UpdateSchedule(ctx context.Context, s *Schedule) error {
+	return nil
+}
+
+func TestSchedulePublish(t *testing.T) {
+	repo := newMemConfigureRepository()
+	push := new(memPushChangeRepository)
+	h := &ConfigureHandler{ConfigureRepository: repo, PushChangeRepository: push}
+	ctx := context.Background()
+	scheduler := &Scheduler{ConfigureHandler: h, RunInTxn: func(fn func(ctx context.Context) error) error {
+		return fn(ctx)
+	}}
+	if err := h.AddApplicationNamespace(ctx, 1, "db", "application"); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"host", "password"} {
+		if err := h.AddConfiguration(ctx, &AddConfigurationRequest{AppId: 1, Env: "PROD", Dc: "dc1", Namespace: "db", Key: key, ContentType: "general", Content: "old"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for id, content := range map[int64]string{1: "new-host", 2: "new-password"} {
+		if err := h.SaveConfigureDraft(ctx, &UpdateConfigurationRequest{ConfigId: id, ContentType: "general", Content: content}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	push.pushed = nil
+
+	now := time.Now()
+	at := now.Add(time.Hour)
+	s, err := h.SchedulePublish(ctx, &ScheduleRequest{
+		PublishRequest: PublishRequest{AppId: 1, ConfigIds: []int64{1, 2}},
+		TimePublish:    at.UnixMilli(),
+		RevertAfter:    (30 * time.Minute).Milliseconds(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Status != ScheduleStatusPending || len(s.Items) != 2 || s.Items[0].Content != "new-host" {
+		t.Fatal("unexpected schedule:", s)
+	}
+	if cfg, _ := h.QueryConfigureById(ctx, 1); cfg.HasDraft() || cfg.Content != "old" {
+		t.Fatal("draft should be moved to the schedule:", cfg)
+	}
+
+	// not due
+	if cnt := scheduler.RunOnce(now); cnt != 0 || len(push.pushed) != 0 {
+		t.Fatal("nothing should be run:", cnt, push.pushed)
+	}
+	// run by another instance
+	repo.lockedByOthers = true
+	if cnt := scheduler.RunOnce(at); cnt != 0 || s.Status != ScheduleStatusPending {
+		t.Fatal("nothing should be run without the lock:", cnt, s)
+	}
+	repo.lockedByOthers = false
+
+	if cnt := scheduler.RunOnce(at); cnt != 1 {
+		t.Fatal("publishing should be run:", cnt)
+	}
+	release, _ := h.QueryRelease(ctx, s.ReleaseId)
+	if s.Status != ScheduleStatusPublished || release.ReleaseType != ReleaseTypeScheduled || s.TimeRevert != at.Add(30*time.Minute).UnixMilli() {
+		t.Fatal("unexpected published schedule:", s, release)
+	}
+	if len(push.pushed) != 2 || push.pushed[0] != "host=new-host" || push.pushed[1] != "password=new-password" {
+		t.Fatal("unexpected pushed:", push.pushed)
+	}
+	if history, _ := h.QueryConfigureHistory(ctx, 1); history[0].ReleaseId != release.ReleaseId || history[0].Content != "new-host" {
+		t.Fatal("history should record the release:", history)
+	}
+
+	// auto revert
+	if cnt := scheduler.RunOnce(at.Add(30 * time.Minute)); cnt != 1 {
+		t.Fatal("revert should be run:", cnt)
+	}
+	host, _ := h.QueryConfigureById(ctx, 1)
+	if s.Status != ScheduleStatusReverted || s.RevertReleaseId == 0 || host.Content != "old" || host.ConfigVersion == release.ReleaseVersion {
+		t.Fatal("unexpected reverted schedule:", s, host)
+	}
+
+	// changed after scheduled
+	if err := h.SaveConfigureDraft(ctx, &UpdateConfigurationRequest{ConfigId: 1, ContentType: "general", Content: "x"}); err != nil {
+		t.Fatal(err)
+	}
+	s, err = h.SchedulePublish(ctx, &ScheduleRequest{PublishRequest: PublishRequest{AppId: 1, ConfigIds: []int64{1}}, TimePublish: at.UnixMilli()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.UpdateConfigurationById(ctx, &UpdateConfigurationRequest{ConfigId: 1, ContentType: "general", Content: "y", ConfigVersion: repo.version(1)}); err != nil {
+		t.Fatal(err)
+	}
+	pushed := len(push.pushed)
+	if cnt := scheduler.RunOnce(at); cnt != 1 {
+		t.Fatal("publishing should be run:", cnt)
+	}
+	if s.Status != ScheduleStatusFailed || !strings.Contains(s.Message, ErrScheduleConflict.Error()) || len(push.pushed) != pushed {
+		t.Fatal("schedule should fail:", s)
+	}
+	if cnt := scheduler.RunOnce(at); cnt != 0 {
+		t.Fatal("failed schedule should not be run again:", cnt)
+	}
+
+	// cancel
+	if _, err := h.SchedulePublish(ctx, &ScheduleRequest{PublishRequest: PublishRequest{AppId: 1, ConfigIds: []int64{1}}, TimePublish: at.UnixMilli()}); !errors.Is(err, ErrNoDraft) {
+		t.Fatal("no draft expected:", err)
+	}
+	if err := h.SaveConfigureDraft(ctx, &UpdateConfigurationRequest{ConfigId: 1, ContentType: "general", Content: "z"}); err != nil {
+		t.Fatal(err)
+	}
+	s, err = h.SchedulePublish(ctx, &ScheduleRequest{PublishRequest: PublishRequest{AppId: 1, ConfigIds: []int64{1}}, TimePublish: at.UnixMilli()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.CancelSchedule(ctx, s.ScheduleId); err != nil || s.Status != ScheduleStatusCanceled {
+		t.Fatal("schedule should be canceled:", s, err)
+	}
+	if _, err := h.CancelSchedule(ctx, s.ScheduleId); !errors.Is(err, ErrScheduleDone) {
+		t.Fatal("done expected:", err)
+	}
+	if cnt := scheduler.RunOnce(at); cnt != 0 {
+		t.Fatal("canceled schedule should not be run:", cnt)
+	}
+	if list, _ := h.QuerySchedules(ctx, 1, "PROD", "dc1"); len(list) != 3 || list[0] != s {
+		t.Fatal("unexpected schedules:", list)
+	}
+}
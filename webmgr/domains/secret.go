@@ -0,0 +1,262 @@
+package domains
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/goodplayer/onlyconfig/secrets"
+	"github.com/goodplayer/onlyconfig/webmgr/tools"
+)
+
+const (
+	// MaskedContent replaces the contents of secret configures unless revealed
+	MaskedContent = "******"
+
+	RevealActionView    = "view"
+	RevealActionDraft   = "draft"
+	RevealActionHistory = "history"
+	RevealActionDiff    = "diff"
+	RevealActionExport  = "export"
+)
+
+var (
+	ErrRevealForbidden       = errors.New("not permitted to reveal the secret configure")
+	ErrMaskedContent         = errors.New("the masked content of the secret configure cannot be saved")
+	ErrRecipientKeyForbidden = errors.New("not permitted to change the recipient key")
+)
+
+// RevealAudit records a user reading the contents of a secret configure
+type RevealAudit struct {
+	AuditId     int64
+	ConfigId    int64
+	AppId       int64
+	Username    string
+	Action      string
+	TimeCreated int64
+}
+
+type revealContextKey struct{}
+
+// WithReveal requests the contents of the secret configures in the context instead of the masked ones.
+// The contents are revealed only if the author of the context is permitted, and every reveal is audited.
+func WithReveal(ctx context.Context) context.Context {
+	return context.WithValue(ctx, revealContextKey{}, true)
+}
+
+func revealRequested(ctx context.Context) bool {
+	reveal, _ := ctx.Value(revealContextKey{}).(bool)
+	return reveal
+}
+
+// masked returns a copy with the contents masked if secret, otherwise the configure itself
+func (c *Configure) masked() *Configure {
+	if !c.Secret {
+		return c
+	}
+	cfg := *c
+	cfg.Content = MaskedContent
+	if cfg.HasDraft() {
+		cfg.DraftContent = MaskedContent
+	}
+	return &cfg
+}
+
+// masked returns a copy with the contents of the secret items masked, otherwise the schedule itself
+func (s *Schedule) masked() *Schedule {
+	if !slices.ContainsFunc(s.Items, func(item *ScheduleItem) bool { return item.Secret }) {
+		return s
+	}
+	schedule := *s
+	schedule.Items = nil
+	for _, item := range s.Items {
+		if item.Secret {
+			masked := *item
+			masked.Content = MaskedContent
+			item = &masked
+		}
+		schedule.Items = append(schedule.Items, item)
+	}
+	return &schedule
+}
+
+// mask hides the changed contents, keeping whether changed and the changed paths
+func (d *ConfigureDiff) mask() {
+	d.Masked = true
+	d.Unified = ""
+	for i, change := range d.Changes {
+		d.Changes[i] = &StructuralChange{Path: change.Path, Op: change.Op}
+	}
+}
+
+// revealSecret reports whether the contents of the configure can be responded.
+// A secret configure is revealed only if requested by WithReveal and the author is an owner of the organization of
+// the application, the reveal is audited in the transaction.
+func (c *ConfigureHandler) revealSecret(ctx context.Context, cfg *Configure, action string) (bool, error) {
+	if !cfg.Secret {
+		return true, nil
+	}
+	if !revealRequested(ctx) {
+		return false, nil
+	}
+	ns, err := c.ConfigureRepository.LoadNamespace(ctx, cfg.ConfigNamespace)
+	if err != nil {
+		return false, err
+	}
+	if err := c.checkAppOrgOwner(ctx, ns.OwnerAppId, ErrRevealForbidden); err != nil {
+		return false, err
+	}
+	if err := c.SecretRepository.AddRevealAudit(ctx, &RevealAudit{
+		ConfigId:    cfg.ConfigId,
+		AppId:       ns.OwnerAppId,
+		Username:    AuthorFrom(ctx),
+		Action:      action,
+		TimeCreated: time.Now().UnixMilli(),
+	}); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// checkAppOrgOwner fails with the forbidden error unless the author is an owner of the organization of the application
+func (c *ConfigureHandler) checkAppOrgOwner(ctx context.Context, appId int64, forbidden error) error {
+	username := AuthorFrom(ctx)
+	if username == "" || c.SecretRepository == nil {
+		return forbidden
+	}
+	if owner, err := c.SecretRepository.IsAppOrgOwner(ctx, appId, username); err != nil {
+		return err
+	} else if !owner {
+		return fmt.Errorf("%w: %s", forbidden, username)
+	}
+	return nil
+}
+
+// maskedConfigure returns the configure masked unless revealed, see revealSecret
+func (c *ConfigureHandler) maskedConfigure(ctx context.Context, cfg *Configure, action string) (*Configure, error) {
+	if revealed, err := c.revealSecret(ctx, cfg, action); err != nil {
+		return nil, err
+	} else if !revealed {
+		return cfg.masked(), nil
+	}
+	return cfg, nil
+}
+
+// QueryRevealAudits returns the latest reveals of the configure, the latest first
+func (c *ConfigureHandler) QueryRevealAudits(ctx context.Context, cfgId int64, limit int) ([]*RevealAudit, error) {
+	if _, err := c.ConfigureRepository.LoadConfigureById(ctx, cfgId); err != nil {
+		return nil, err
+	}
+	if c.SecretRepository == nil {
+		return nil, nil
+	}
+	return c.SecretRepository.LoadRevealAudits(ctx, cfgId, limit)
+}
+
+type UpdateRecipientKeyRequest struct {
+	AppId int64
+	// RecipientKey is the public key in hex generated by secrets.GenerateRecipientKey, empty to stop sealing
+	RecipientKey string `json:"recipient_key"`
+	// TimeUpdated is the version of the application the change is based on
+	TimeUpdated int64 `json:"time_updated"`
+}
+
+// UpdateRecipientKey changes the key the secret configures of the application are sealed for, so that only the clients
+// holding the private key are able to read them. It is permitted only to the owners of the organization of the
+// application, the same as revealing. The secret configures are published again with a new version once the key is
+// changed, since the clients are updated only by a new version.
+func (c *ConfigureHandler) UpdateRecipientKey(ctx context.Context, req *UpdateRecipientKeyRequest) (*Application, error) {
+	if req.TimeUpdated <= 0 {
+		return nil, ErrVersionRequired
+	}
+	recipientKey := strings.TrimSpace(req.RecipientKey)
+	if recipientKey != "" {
+		if _, err := secrets.ParseRecipientKey(recipientKey); err != nil {
+			return nil, err
+		}
+	}
+	app, err := c.ConfigureRepository.LoadApplicationById(ctx, req.AppId)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.checkOnline(ctx, app, "", "", ""); err != nil {
+		return nil, err
+	}
+	if err := c.checkAppOrgOwner(ctx, app.ApplicationId, ErrRecipientKeyForbidden); err != nil {
+		return nil, err
+	}
+	if app.TimeUpdated != req.TimeUpdated {
+		return nil, &ConflictError{Current: app}
+	}
+	updatedApp := *app
+	updatedApp.RecipientKey = recipientKey
+	updatedApp.TimeUpdated = nextTimeUpdated(req.TimeUpdated)
+	if updated, err := c.ConfigureRepository.UpdateApplicationRecipientKey(ctx, &updatedApp, req.TimeUpdated); err != nil {
+		return nil, err
+	} else if !updated {
+		// changed by a concurrent update
+		current, err := c.ConfigureRepository.LoadApplicationById(ctx, req.AppId)
+		if err != nil {
+			return nil, err
+		}
+		return nil, &ConflictError{Current: current}
+	}
+	if recipientKey != app.RecipientKey {
+		if err := c.republishSecrets(ctx, &updatedApp); err != nil {
+			return nil, err
+		}
+	}
+	return &updatedApp, nil
+}
+
+// republishSecrets publishes the online secret configures of the application again with the same new version
+func (c *ConfigureHandler) republishSecrets(ctx context.Context, app *Application) error {
+	linked, err := c.ConfigureRepository.LoadEnvAndDcListByAppId(ctx, app.ApplicationId)
+	if err != nil {
+		return err
+	}
+	offline, err := c.loadOfflineEntities(ctx)
+	if err != nil {
+		return err
+	}
+	var version string
+	for _, item := range linked {
+		if offline.has(DecommissionTypeEnvironment, item.EnvName) || offline.has(DecommissionTypeDatacenter, item.DcName) {
+			continue
+		}
+		env, err := c.ConfigureRepository.LoadEnvironment(ctx, item.EnvName)
+		if err != nil {
+			return err
+		}
+		dc, err := c.ConfigureRepository.LoadDatacenter(ctx, item.DcName)
+		if err != nil {
+			return err
+		}
+		list, err := c.ConfigureRepository.LoadAppConfigList(ctx, app, env, dc)
+		if err != nil {
+			return err
+		}
+		for _, cfg := range list {
+			if !cfg.Secret || offline.has(DecommissionTypeNamespace, cfg.ConfigNamespace) {
+				continue
+			}
+			if version == "" {
+				seq, err := c.ConfigureRepository.NextConfigVersionSeq(ctx)
+				if err != nil {
+					return err
+				}
+				version = tools.VersionToString(seq)
+			}
+			if err := c.saveVersion(ctx, cfg, version, 0); err != nil {
+				return err
+			}
+			if err := ApplyConfigureChange(ctx, c.PushChangeRepository, cfg, app); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
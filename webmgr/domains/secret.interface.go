@@ -0,0 +1,11 @@
+package domains
+
+import "context"
+
+type SecretRepository interface {
+	// IsAppOrgOwner reports whether the user is an owner of the organization owning the application
+	IsAppOrgOwner(ctx context.Context, appId int64, username string) (bool, error)
+	AddRevealAudit(ctx context.Context, audit *RevealAudit) error
+	// LoadRevealAudits returns the latest reveals of the configure, the latest first
+	LoadRevealAudits(ctx context.Context, cfgId int64, limit int) ([]*RevealAudit, error)
+}
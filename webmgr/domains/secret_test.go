@@ -0,0 +1,198 @@
+package domains
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/goodplayer/onlyconfig/secrets"
+)
+
+func (m *memConfigureRepository) EncryptConfigureHistory(ctx context.Context, cfgId int64) error {
+	for _, h := range m.histories {
+		if h.ConfigId == cfgId {
+			h.Secret = true
+		}
+	}
+	return nil
+}
+
+func (m *memConfigureRepository) UpdateApplicationRecipientKey(ctx context.Context, app *Application, prevTimeUpdated int64) (bool, error) {
+	return m.UpdateApplication(ctx, app, prevTimeUpdated)
+}
+
+// memSecretRepository has the owners of the application 1
+type memSecretRepository struct {
+	owners []string
+	audits []*RevealAudit
+}
+
+func (m *memSecretRepository) IsAppOrgOwner(ctx context.Context, appId int64, username string) (bool, error) {
+	for _, owner := range m.owners {
+		if appId == 1 && owner == username {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *memSecretRepository) AddRevealAudit(ctx context.Context, audit *RevealAudit) error {
+	audit.AuditId = int64(len(m.audits) + 1)
+	m.audits = append(m.audits, audit)
+	return nil
+}
+
+func (m *memSecretRepository) LoadRevealAudits(ctx context.Context, cfgId int64, limit int) (result []*RevealAudit, rerr error) {
+	for i := len(m.audits) - 1; i >= 0 && len(result) < limit; i-- {
+		if m.audits[i].ConfigId == cfgId {
+			result = append(result, m.audits[i])
+		}
+	}
+	return
+}
+
+func TestSecretConfigure(t *testing.T) {
+	repo := newMemConfigureRepository()
+	secrets := &memSecretRepository{owners: []string{"alice"}}
+	h := &ConfigureHandler{ConfigureRepository: repo, PushChangeRepository: new(memPushChangeRepository), SecretRepository: secrets}
+	ctx := context.Background()
+	if err := h.AddApplicationNamespace(ctx, 1, "ns1", "application"); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.AddConfiguration(ctx, &AddConfigurationRequest{AppId: 1, Env: "PROD", Dc: "dc1", Namespace: "ns1", Key: "password", ContentType: "general", Content: "s3cret", Secret: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.AddConfiguration(ctx, &AddConfigurationRequest{AppId: 1, Env: "PROD", Dc: "dc1", Namespace: "ns1", Key: "host", ContentType: "general", Content: "localhost"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// masked unless revealed
+	if cfg, err := h.QueryConfigureById(ctx, 1); err != nil || cfg.Content != MaskedContent || !cfg.Secret {
+		t.Fatal("masked content expected:", cfg, err)
+	}
+	if cfg, err := h.QueryConfigureById(WithReveal(ctx), 2); err != nil || cfg.Content != "localhost" {
+		t.Fatal("non-secret content expected:", cfg, err)
+	}
+	if _, err := h.QueryConfigureById(WithReveal(ctx), 1); !errors.Is(err, ErrRevealForbidden) {
+		t.Fatal("anonymous reveal should be forbidden:", err)
+	}
+	if _, err := h.QueryConfigureById(WithReveal(WithAuthor(ctx, "bob")), 1); !errors.Is(err, ErrRevealForbidden) {
+		t.Fatal("reveal by non-owner should be forbidden:", err)
+	}
+	if len(secrets.audits) != 0 {
+		t.Fatal("no reveal expected:", secrets.audits)
+	}
+	owner := WithReveal(WithAuthor(ctx, "alice"))
+	if cfg, err := h.QueryConfigureById(owner, 1); err != nil || cfg.Content != "s3cret" {
+		t.Fatal("revealed content expected:", cfg, err)
+	}
+	if audits, err := h.QueryRevealAudits(ctx, 1, 10); err != nil || len(audits) != 1 || audits[0].Username != "alice" || audits[0].Action != RevealActionView {
+		t.Fatal("reveal should be audited:", audits, err)
+	}
+
+	// the masked content cannot be saved back
+	version := repo.configures[0].ConfigVersion
+	if err := h.UpdateConfigurationById(ctx, &UpdateConfigurationRequest{ConfigId: 1, ContentType: "general", Content: MaskedContent, ConfigVersion: version}); !errors.Is(err, ErrMaskedContent) {
+		t.Fatal("masked content should be rejected:", err)
+	}
+	if err := h.UpdateConfigurationById(ctx, &UpdateConfigurationRequest{ConfigId: 1, ContentType: "general", Content: "n3w", ConfigVersion: version}); err != nil {
+		t.Fatal(err)
+	}
+	if list, err := h.QueryConfigureHistory(ctx, 1); err != nil || len(list) != 2 || list[0].Content != MaskedContent || repo.histories[0].Content != "s3cret" {
+		t.Fatal("masked history expected:", list, err)
+	}
+	diff, err := h.DiffConfigureVersions(ctx, 1, version, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !diff.Masked || diff.Unified != "" || diff.Identical {
+		t.Fatal("masked diff expected:", diff)
+	}
+	if diff, err := h.DiffConfigureVersions(owner, 1, version, "", ""); err != nil || diff.Masked || diff.Unified == "" {
+		t.Fatal("revealed diff expected:", diff, err)
+	}
+
+	// export masks, and the masked content keeps the existing one on import
+	archive, err := h.ExportApplication(ctx, 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := archive.Configures[1]; c.Key != "password" || c.Content != MaskedContent || !c.Secret {
+		t.Fatal("masked export expected:", c)
+	}
+	result, err := h.ImportApplication(ctx, &ImportRequest{AppId: 1, Archive: archive, Strategy: ImportStrategyOverwrite})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := actions(result); len(got) != 2 || got[1] != "password:unchanged" {
+		t.Fatal("unexpected import:", got)
+	}
+	if repo.configures[0].Content != "n3w" {
+		t.Fatal("secret content should be kept:", repo.configures[0].Content)
+	}
+	archive.Configures[1].Key = "token"
+	if _, err := h.ImportApplication(ctx, &ImportRequest{AppId: 1, Archive: archive, Strategy: ImportStrategyOverwrite}); !errors.Is(err, ErrInvalidArchive) {
+		t.Fatal("masked new secret should be invalid:", err)
+	}
+
+	// turning into secret encrypts the history
+	version = repo.configures[1].ConfigVersion
+	if err := h.UpdateConfigurationById(ctx, &UpdateConfigurationRequest{ConfigId: 2, ContentType: "general", Content: "db.internal", ConfigVersion: version, Secret: true}); err != nil {
+		t.Fatal(err)
+	}
+	if !repo.configures[1].Secret || !repo.histories[1].Secret || !repo.histories[len(repo.histories)-1].Secret {
+		t.Fatal("secret history expected:", repo.configures[1], repo.histories)
+	}
+}
+
+func TestUpdateRecipientKey(t *testing.T) {
+	repo := newMemConfigureRepository()
+	repo.app.TimeUpdated = 100
+	push := new(memPushChangeRepository)
+	h := &ConfigureHandler{ConfigureRepository: repo, PushChangeRepository: push, SecretRepository: &memSecretRepository{owners: []string{"alice"}}}
+	ctx := context.Background()
+	if err := h.AddApplicationNamespace(ctx, 1, "ns1", "application"); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.AddConfiguration(ctx, &AddConfigurationRequest{AppId: 1, Env: "PROD", Dc: "dc1", Namespace: "ns1", Key: "password", ContentType: "general", Content: "s3cret", Secret: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.AddConfiguration(ctx, &AddConfigurationRequest{AppId: 1, Env: "PROD", Dc: "dc1", Namespace: "ns1", Key: "host", ContentType: "general", Content: "localhost"}); err != nil {
+		t.Fatal(err)
+	}
+	_, publicKey, err := secrets.GenerateRecipientKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	owner := WithAuthor(ctx, "alice")
+	if _, err := h.UpdateRecipientKey(WithAuthor(ctx, "bob"), &UpdateRecipientKeyRequest{AppId: 1, RecipientKey: publicKey, TimeUpdated: 100}); !errors.Is(err, ErrRecipientKeyForbidden) {
+		t.Fatal("non-owner should be forbidden:", err)
+	}
+	if _, err := h.UpdateRecipientKey(owner, &UpdateRecipientKeyRequest{AppId: 1, RecipientKey: "not hex", TimeUpdated: 100}); !errors.Is(err, secrets.ErrInvalidRecipientKey) {
+		t.Fatal("invalid key should be rejected:", err)
+	}
+	if _, err := h.UpdateRecipientKey(owner, &UpdateRecipientKeyRequest{AppId: 1, RecipientKey: publicKey, TimeUpdated: 99}); !errors.Is(err, ErrConflict) {
+		t.Fatal("stale version should conflict:", err)
+	}
+
+	version := repo.configures[0].ConfigVersion
+	push.pushed = nil
+	app, err := h.UpdateRecipientKey(owner, &UpdateRecipientKeyRequest{AppId: 1, RecipientKey: publicKey, TimeUpdated: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if app.RecipientKey != publicKey || repo.app.RecipientKey != publicKey || app.TimeUpdated <= 100 {
+		t.Fatal("recipient key should be saved:", app, repo.app)
+	}
+	// only the secret configure is published again with a new version
+	if len(push.pushed) != 1 || push.pushed[0] != "password=s3cret" || repo.configures[0].ConfigVersion == version {
+		t.Fatal("secret configure should be published again:", push.pushed, repo.configures[0].ConfigVersion)
+	}
+
+	// unchanged key publishes nothing
+	push.pushed = nil
+	if _, err := h.UpdateRecipientKey(owner, &UpdateRecipientKeyRequest{AppId: 1, RecipientKey: publicKey, TimeUpdated: app.TimeUpdated}); err != nil || len(push.pushed) != 0 {
+		t.Fatal("nothing should be published:", push.pushed, err)
+	}
+}
@@ -0,0 +1,462 @@
+package domains
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	WebhookEventConfigureChanged = "configure.changed"
+
+	DeliveryStatusPending   = "pending"
+	DeliveryStatusSucceeded = "succeeded"
+	DeliveryStatusFailed    = "failed"
+
+	// WebhookSignatureHeader carries "sha256=" and the hex HMAC-SHA256 of the body keyed by the secret of the webhook
+	WebhookSignatureHeader = "X-OnlyConfig-Signature"
+	WebhookEventHeader     = "X-OnlyConfig-Event"
+	WebhookDeliveryHeader  = "X-OnlyConfig-Delivery"
+
+	// SchedulerAuthor is the author of the changes made by the scheduled publishing
+	SchedulerAuthor = "scheduler"
+
+	DefaultWebhookMaxAttempts  = 8
+	DefaultWebhookRetryBackoff = 30 * time.Second
+	// webhookMaxRetryBackoff caps the doubled backoff
+	webhookMaxRetryBackoff = time.Hour
+	// webhookDiffMaxPaths bounds the changed paths in the diff summary
+	webhookDiffMaxPaths = 20
+	// webhookMaxResponseMessage bounds the response body recorded in the delivery log
+	webhookMaxResponseMessage = 256
+)
+
+var (
+	ErrWebhookNotFound  = errors.New("webhook not found")
+	ErrInvalidWebhook   = errors.New("invalid webhook")
+	ErrDeliveryNotFound = errors.New("webhook delivery not found")
+)
+
+// Webhook subscribes the configure changes of an application, or of all the applications of an organization if AppId is 0
+type Webhook struct {
+	WebhookId   int64
+	OrgId       string
+	AppId       int64
+	Url         string
+	Secret      string
+	Description string
+	TimeCreated int64
+	TimeUpdated int64
+}
+
+// WebhookDelivery is a payload queued for a webhook and the log of delivering it
+type WebhookDelivery struct {
+	DeliveryId int64
+	WebhookId  int64
+	Event      string
+	Payload    string
+	Status     string
+	Attempts   int
+	// TimeNextAttempt is the time of the next attempt in milliseconds while pending
+	TimeNextAttempt int64
+	// ResponseStatus is the http status of the last attempt, 0 if no response
+	ResponseStatus int
+	// Message is the error or the response body of the last failed attempt
+	Message     string
+	TimeCreated int64
+	TimeUpdated int64
+}
+
+// WebhookPayload is the json body posted to the webhooks. The contents are never included, only the diff summary.
+type WebhookPayload struct {
+	Event     string `json:"event"`
+	AppId     int64  `json:"app_id"`
+	AppName   string `json:"app"`
+	Env       string `json:"env"`
+	Dc        string `json:"dc"`
+	Namespace string `json:"namespace"`
+	Key       string `json:"key"`
+	ConfigId  string `json:"cfg_id"`
+	// OldVersion is empty if the configure is created
+	OldVersion string `json:"old_version"`
+	NewVersion string `json:"new_version"`
+	// ReleaseId is set if published by a release
+	ReleaseId string       `json:"release_id,omitempty"`
+	Author    string       `json:"author"`
+	Diff      *WebhookDiff `json:"diff"`
+	Timestamp int64        `json:"timestamp"`
+}
+
+// WebhookDiff counts the changed lines, or the changed key paths of structured contents
+type WebhookDiff struct {
+	Type    string   `json:"type"`
+	Added   int      `json:"added"`
+	Removed int      `json:"removed"`
+	Changed int      `json:"changed"`
+	Paths   []string `json:"paths,omitempty"`
+	Summary string   `json:"summary"`
+}
+
+// ConfigureChange is a published change of a configure
+type ConfigureChange struct {
+	// Before is nil if the configure is created
+	Before    *Configure
+	After     *Configure
+	ReleaseId int64
+}
+
+type authorContextKey struct{}
+
+// WithAuthor sets the author of the changes made in the context
+func WithAuthor(ctx context.Context, author string) context.Context {
+	return context.WithValue(ctx, authorContextKey{}, author)
+}
+
+// AuthorFrom returns the author set by WithAuthor, empty if not set
+func AuthorFrom(ctx context.Context) string {
+	author, _ := ctx.Value(authorContextKey{}).(string)
+	return author
+}
+
+// SignWebhookPayload returns the value of WebhookSignatureHeader
+func SignWebhookPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// summarizeDiff compares the contents without exposing them
+func summarizeDiff(from, to *DiffSide) *WebhookDiff {
+	d := DiffContent(from, to, "")
+	result := &WebhookDiff{Type: d.Type}
+	if d.Type == DiffTypeStructural {
+		for _, change := range d.Changes {
+			switch change.Op {
+			case DiffOpAdded:
+				result.Added++
+			case DiffOpRemoved:
+				result.Removed++
+			default:
+				result.Changed++
+			}
+			if len(result.Paths) < webhookDiffMaxPaths {
+				result.Paths = append(result.Paths, change.Path)
+			}
+		}
+		result.Summary = fmt.Sprintf("%d added, %d removed, %d changed", result.Added, result.Removed, result.Changed)
+		if len(result.Paths) > 0 {
+			result.Summary += ": " + strings.Join(result.Paths, ", ")
+			if len(d.Changes) > len(result.Paths) {
+				result.Summary += ", ..."
+			}
+		}
+		return result
+	}
+	for _, e := range lineEdits(splitLines(from.Content), splitLines(to.Content)) {
+		switch e.op {
+		case '+':
+			result.Added++
+		case '-':
+			result.Removed++
+		}
+	}
+	result.Summary = fmt.Sprintf("%d lines added, %d lines removed", result.Added, result.Removed)
+	return result
+}
+
+func newWebhookPayload(app *Application, change *ConfigureChange, author string, now time.Time) *WebhookPayload {
+	after := change.After
+	payload := &WebhookPayload{
+		Event:      WebhookEventConfigureChanged,
+		AppId:      app.ApplicationId,
+		AppName:    app.ApplicationName,
+		Env:        after.ConfigEnv,
+		Dc:         after.ConfigDc,
+		Namespace:  after.ConfigNamespace,
+		Key:        after.ConfigKey,
+		ConfigId:   fmt.Sprint(after.ConfigId),
+		NewVersion: after.ConfigVersion,
+		Author:     author,
+		Timestamp:  now.UnixMilli(),
+	}
+	if change.ReleaseId > 0 {
+		payload.ReleaseId = fmt.Sprint(change.ReleaseId)
+	}
+	from := &DiffSide{ContentType: after.ContentType}
+	if change.Before != nil {
+		payload.OldVersion = change.Before.ConfigVersion
+		from = &DiffSide{ContentType: change.Before.ContentType, Content: change.Before.Content}
+	}
+	payload.Diff = summarizeDiff(from, &DiffSide{ContentType: after.ContentType, Content: after.Content})
+	return payload
+}
+
+// notifyChanges queues the changes for the webhooks of the application in the current transaction,
+// so they are delivered only after the changes are committed
+func (c *ConfigureHandler) notifyChanges(ctx context.Context, app *Application, changes []*ConfigureChange) error {
+	if c.WebhookRepository == nil || len(changes) == 0 {
+		return nil
+	}
+	webhooks, err := c.WebhookRepository.LoadAppWebhooks(ctx, app)
+	if err != nil {
+		return err
+	}
+	if len(webhooks) == 0 {
+		return nil
+	}
+	author := AuthorFrom(ctx)
+	now := time.Now()
+	var deliveries []*WebhookDelivery
+	for _, change := range changes {
+		data, err := json.Marshal(newWebhookPayload(app, change, author, now))
+		if err != nil {
+			return err
+		}
+		for _, webhook := range webhooks {
+			deliveries = append(deliveries, &WebhookDelivery{
+				WebhookId:       webhook.WebhookId,
+				Event:           WebhookEventConfigureChanged,
+				Payload:         string(data),
+				Status:          DeliveryStatusPending,
+				TimeNextAttempt: now.UnixMilli(),
+				TimeCreated:     now.UnixMilli(),
+				TimeUpdated:     now.UnixMilli(),
+			})
+		}
+	}
+	return c.WebhookRepository.AddWebhookDeliveries(ctx, deliveries)
+}
+
+type AddWebhookRequest struct {
+	OrgId       string `json:"org_id"`
+	AppId       int64  `json:"app_id"`
+	Url         string `json:"url"`
+	Secret      string `json:"secret"`
+	Description string `json:"description"`
+}
+
+// AddWebhook subscribes the organization or the application, one of which is required.
+// A random secret is generated if not provided.
+func (c *ConfigureHandler) AddWebhook(ctx context.Context, req *AddWebhookRequest) (*Webhook, error) {
+	if (req.OrgId == "") == (req.AppId <= 0) {
+		return nil, fmt.Errorf("%w: one of org_id and app_id is required", ErrInvalidWebhook)
+	}
+	if u, err := url.Parse(req.Url); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("%w: url should be http or https: %s", ErrInvalidWebhook, req.Url)
+	}
+	if req.AppId > 0 {
+		if _, err := c.ConfigureRepository.LoadApplicationById(ctx, req.AppId); err != nil {
+			return nil, err
+		}
+	}
+	secret := req.Secret
+	if secret == "" {
+		buf := make([]byte, 16)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, err
+		}
+		secret = hex.EncodeToString(buf)
+	}
+	now := time.Now()
+	webhook := &Webhook{
+		OrgId:       req.OrgId,
+		AppId:       req.AppId,
+		Url:         req.Url,
+		Secret:      secret,
+		Description: req.Description,
+		TimeCreated: now.UnixMilli(),
+		TimeUpdated: now.UnixMilli(),
+	}
+	if err := c.WebhookRepository.AddWebhook(ctx, webhook); err != nil {
+		return nil, err
+	}
+	return webhook, nil
+}
+
+func (c *ConfigureHandler) QueryWebhooks(ctx context.Context, orgId string, appId int64) ([]*Webhook, error) {
+	return c.WebhookRepository.LoadWebhooks(ctx, orgId, appId)
+}
+
+func (c *ConfigureHandler) DeleteWebhook(ctx context.Context, webhookId int64) error {
+	if webhook, err := c.WebhookRepository.LoadWebhook(ctx, webhookId); err != nil {
+		return err
+	} else if webhook == nil {
+		return ErrWebhookNotFound
+	}
+	return c.WebhookRepository.DeleteWebhook(ctx, webhookId)
+}
+
+// QueryWebhookDeliveries returns the delivery log of the webhook, the latest first
+func (c *ConfigureHandler) QueryWebhookDeliveries(ctx context.Context, webhookId int64, limit int) ([]*WebhookDelivery, error) {
+	if webhook, err := c.WebhookRepository.LoadWebhook(ctx, webhookId); err != nil {
+		return nil, err
+	} else if webhook == nil {
+		return nil, ErrWebhookNotFound
+	}
+	return c.WebhookRepository.LoadWebhookDeliveries(ctx, webhookId, limit)
+}
+
+// RedeliverWebhook queues the delivery again with the attempts reset
+func (c *ConfigureHandler) RedeliverWebhook(ctx context.Context, deliveryId int64) (*WebhookDelivery, error) {
+	delivery, err := c.WebhookRepository.LoadWebhookDelivery(ctx, deliveryId)
+	if err != nil {
+		return nil, err
+	}
+	if delivery == nil {
+		return nil, ErrDeliveryNotFound
+	}
+	now := time.Now().UnixMilli()
+	delivery.Status = DeliveryStatusPending
+	delivery.Attempts = 0
+	delivery.TimeNextAttempt = now
+	delivery.TimeUpdated = now
+	if err := c.WebhookRepository.UpdateWebhookDelivery(ctx, delivery); err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+// WebhookDispatcher delivers the queued payloads and retries the failed ones with doubled backoff
+type WebhookDispatcher struct {
+	WebhookRepository WebhookRepository
+	Worker            *Worker
+	// RunInTxn runs fn in a new transaction, and commits only if fn succeeds
+	RunInTxn func(fn func(ctx context.Context) error) error
+	Client   *http.Client
+	Interval time.Duration
+	// MaxAttempts is DefaultWebhookMaxAttempts if not set, the delivery fails after that
+	MaxAttempts int
+	// RetryBackoff is DefaultWebhookRetryBackoff if not set, doubled for each retry
+	RetryBackoff time.Duration
+}
+
+// Start delivers the due payloads every interval till ctx is done
+func (d *WebhookDispatcher) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(d.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			<-d.Worker.SubmitFnTask(func(result chan<- any) {
+				result <- d.RunOnce(time.Now())
+			})
+		}
+	}()
+}
+
+// RunOnce makes an attempt for each due delivery, each in its own transaction, and returns the number of the attempts
+func (d *WebhookDispatcher) RunOnce(now time.Time) int {
+	cnt := 0
+	for {
+		var delivery *WebhookDelivery
+		if err := d.RunInTxn(func(ctx context.Context) (err error) {
+			delivery, err = d.deliverDue(ctx, now)
+			return err
+		}); err != nil {
+			log.Println("deliver webhook failed:", err)
+			return cnt
+		} else if delivery == nil {
+			return cnt
+		}
+		cnt++
+	}
+}
+
+// deliverDue makes an attempt for a due delivery and records the result, returns nil if none
+func (d *WebhookDispatcher) deliverDue(ctx context.Context, now time.Time) (*WebhookDelivery, error) {
+	delivery, err := d.WebhookRepository.LoadDueWebhookDelivery(ctx, now.UnixMilli())
+	if err != nil || delivery == nil {
+		return nil, err
+	}
+	webhook, err := d.WebhookRepository.LoadWebhook(ctx, delivery.WebhookId)
+	if err != nil {
+		return nil, err
+	}
+	delivery.Attempts++
+	delivery.TimeUpdated = now.UnixMilli()
+	if webhook == nil {
+		delivery.Status = DeliveryStatusFailed
+		delivery.ResponseStatus = 0
+		delivery.Message = ErrWebhookNotFound.Error()
+	} else if status, err := d.post(webhook, delivery); err != nil {
+		delivery.ResponseStatus = status
+		delivery.Message = err.Error()
+		if delivery.Attempts >= d.maxAttempts() {
+			delivery.Status = DeliveryStatusFailed
+		} else {
+			delivery.TimeNextAttempt = now.Add(d.backoff(delivery.Attempts)).UnixMilli()
+		}
+	} else {
+		delivery.Status = DeliveryStatusSucceeded
+		delivery.ResponseStatus = status
+		delivery.Message = ""
+	}
+	if err := d.WebhookRepository.UpdateWebhookDelivery(ctx, delivery); err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+// post sends the signed payload and returns the response status, error if not 2xx
+func (d *WebhookDispatcher) post(webhook *Webhook, delivery *WebhookDelivery) (int, error) {
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequest(http.MethodPost, webhook.Url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookEventHeader, delivery.Event)
+	req.Header.Set(WebhookDeliveryHeader, fmt.Sprint(delivery.DeliveryId))
+	req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(webhook.Secret, body))
+	client := d.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer func(body io.ReadCloser) {
+		_ = body.Close()
+	}(resp.Body)
+	if resp.StatusCode/100 == 2 {
+		return resp.StatusCode, nil
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, webhookMaxResponseMessage))
+	return resp.StatusCode, fmt.Errorf("status %d: %s", resp.StatusCode, data)
+}
+
+func (d *WebhookDispatcher) maxAttempts() int {
+	if d.MaxAttempts > 0 {
+		return d.MaxAttempts
+	}
+	return DefaultWebhookMaxAttempts
+}
+
+// backoff returns the delay before the retry after the attempts
+func (d *WebhookDispatcher) backoff(attempts int) time.Duration {
+	backoff := d.RetryBackoff
+	if backoff <= 0 {
+		backoff = DefaultWebhookRetryBackoff
+	}
+	for i := 1; i < attempts && backoff < webhookMaxRetryBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, webhookMaxRetryBackoff)
+}
@@ -0,0 +1,25 @@
+package domains
+
+import "context"
+
+type WebhookRepository interface {
+	AddWebhook(ctx context.Context, webhook *Webhook) error
+	// LoadWebhooks returns the webhooks of the organization if orgId is not empty and of the application if appId is positive
+	LoadWebhooks(ctx context.Context, orgId string, appId int64) ([]*Webhook, error)
+	// LoadWebhook returns nil if not found
+	LoadWebhook(ctx context.Context, webhookId int64) (*Webhook, error)
+	// DeleteWebhook deletes the webhook with its deliveries
+	DeleteWebhook(ctx context.Context, webhookId int64) error
+	// LoadAppWebhooks returns the webhooks of the application and of its owner organization
+	LoadAppWebhooks(ctx context.Context, app *Application) ([]*Webhook, error)
+
+	AddWebhookDeliveries(ctx context.Context, deliveries []*WebhookDelivery) error
+	// LoadWebhookDeliveries returns the latest deliveries of the webhook, the latest first
+	LoadWebhookDeliveries(ctx context.Context, webhookId int64, limit int) ([]*WebhookDelivery, error)
+	// LoadWebhookDelivery returns nil if not found
+	LoadWebhookDelivery(ctx context.Context, deliveryId int64) (*WebhookDelivery, error)
+	// LoadDueWebhookDelivery locks a pending delivery due at the time till the end of the transaction,
+	// skipping the ones locked by others. Returns nil if none.
+	LoadDueWebhookDelivery(ctx context.Context, now int64) (*WebhookDelivery, error)
+	UpdateWebhookDelivery(ctx context.Context, delivery *WebhookDelivery) error
+}
@@ -0,0 +1,276 @@
+package domains
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memWebhookRepository is an in-memory WebhookRepository, all the applications are owned by org1
+type memWebhookRepository struct {
+	webhooks   []*Webhook
+	deliveries []*WebhookDelivery
+}
+
+func (m *memWebhookRepository) AddWebhook(ctx context.Context, webhook *Webhook) error {
+	webhook.WebhookId = int64(len(m.webhooks) + 1)
+	m.webhooks = append(m.webhooks, webhook)
+	return nil
+}
+
+func (m *memWebhookRepository) LoadWebhooks(ctx context.Context, orgId string, appId int64) (result []*Webhook, rerr error) {
+	for _, w := range m.webhooks {
+		if (orgId == "" || w.OrgId == orgId) && (appId <= 0 || w.AppId == appId) {
+			result = append(result, w)
+		}
+	}
+	return
+}
+
+func (m *memWebhookRepository) LoadWebhook(ctx context.Context, webhookId int64) (*Webhook, error) {
+	for _, w := range m.webhooks {
+		if w.WebhookId == webhookId {
+			return w, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *memWebhookRepository) DeleteWebhook(ctx context.Context, webhookId int64) error {
+	m.webhooks = slices.DeleteFunc(m.webhooks, func(w *Webhook) bool {
+		return w.WebhookId == webhookId
+	})
+	m.deliveries = slices.DeleteFunc(m.deliveries, func(d *WebhookDelivery) bool {
+		return d.WebhookId == webhookId
+	})
+	return nil
+}
+
+func (m *memWebhookRepository) LoadAppWebhooks(ctx context.Context, app *Application) (result []*Webhook, rerr error) {
+	for _, w := range m.webhooks {
+		if w.AppId == app.ApplicationId || (w.AppId == 0 && w.OrgId == "org1") {
+			result = append(result, w)
+		}
+	}
+	return
+}
+
+func (m *memWebhookRepository) AddWebhookDeliveries(ctx context.Context, deliveries []*WebhookDelivery) error {
+	for _, d := range deliveries {
+		d.DeliveryId = int64(len(m.deliveries) + 1)
+		m.deliveries = append(m.deliveries, d)
+	}
+	return nil
+}
+
+func (m *memWebhookRepository) LoadWebhookDeliveries(ctx context.Context, webhookId int64, limit int) (result []*WebhookDelivery, rerr error) {
+	for i := len(m.deliveries) - 1; i >= 0 && len(result) < limit; i-- {
+		if m.deliveries[i].WebhookId == webhookId {
+			result = append(result, m.deliveries[i])
+		}
+	}
+	return
+}
+
+func (m *memWebhookRepository) LoadWebhookDelivery(ctx context.Context, deliveryId int64) (*WebhookDelivery, error) {
+	for _, d := range m.deliveries {
+		if d.DeliveryId == deliveryId {
+			return d, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *memWebhookRepository) LoadDueWebhookDelivery(ctx context.Context, now int64) (*WebhookDelivery, error) {
+	for _, d := range m.deliveries {
+		if d.Status == DeliveryStatusPending && d.TimeNextAttempt <= now {
+			return d, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *memWebhookRepository) UpdateWebhookDelivery(ctx context.Context, delivery *WebhookDelivery) error {
+	return nil
+}
+
+// webhookReceiver is a local http receiver verifying the signatures
+type webhookReceiver struct {
+	secret string
+
+	lock     sync.Mutex
+	failing  bool
+	payloads []*WebhookPayload
+}
+
+func (r *webhookReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	body, _ := io.ReadAll(req.Body)
+	if req.Header.Get(WebhookSignatureHeader) != SignWebhookPayload(r.secret, body) || req.Header.Get(WebhookEventHeader) != WebhookEventConfigureChanged {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if r.failing {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte("try later"))
+		return
+	}
+	payload := new(WebhookPayload)
+	_ = json.Unmarshal(body, payload)
+	r.payloads = append(r.payloads, payload)
+}
+
+func TestWebhookNotification(t *testing.T) {
+	repo := newMemConfigureRepository()
+	webhooks := new(memWebhookRepository)
+	h := &ConfigureHandler{ConfigureRepository: repo, PushChangeRepository: new(memPushChangeRepository), WebhookRepository: webhooks}
+	ctx := WithAuthor(context.Background(), "alice")
+	receiver := &webhookReceiver{secret: "s3cret"}
+	srv := httptest.NewServer(receiver)
+	defer srv.Close()
+	dispatcher := &WebhookDispatcher{WebhookRepository: webhooks, Client: srv.Client(), MaxAttempts: 3, RetryBackoff: time.Minute,
+		RunInTxn: func(fn func(ctx context.Context) error) error {
+			return fn(ctx)
+		}}
+
+	if _, err := h.AddWebhook(ctx, &AddWebhookRequest{OrgId: "org1", AppId: 1, Url: srv.URL}); err == nil {
+		t.Fatal("one of org and app should be required")
+	}
+	if _, err := h.AddWebhook(ctx, &AddWebhookRequest{AppId: 1, Url: "ftp://x"}); err == nil {
+		t.Fatal("http url should be required")
+	}
+	webhook, err := h.AddWebhook(ctx, &AddWebhookRequest{AppId: 1, Url: srv.URL, Secret: "s3cret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the other organization
+	if _, err := h.AddWebhook(ctx, &AddWebhookRequest{OrgId: "org2", Url: srv.URL + "/other"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := h.AddApplicationNamespace(ctx, 1, "db", "application"); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.AddConfiguration(ctx, &AddConfigurationRequest{AppId: 1, Env: "PROD", Dc: "dc1", Namespace: "db", Key: "conf", ContentType: "general", Content: "host=a\npassword=hunter2\n"}); err != nil {
+		t.Fatal(err)
+	}
+	version := repo.version(1)
+	if err := h.UpdateConfigurationById(ctx, &UpdateConfigurationRequest{ConfigId: 1, ContentType: "general", Content: "host=b\npassword=hunter2\nport=1\n", ConfigVersion: version}); err != nil {
+		t.Fatal(err)
+	}
+	if len(webhooks.deliveries) != 2 {
+		t.Fatal("changes should be queued for the application only:", len(webhooks.deliveries))
+	}
+
+	now := time.Now()
+	if cnt := dispatcher.RunOnce(now); cnt != 2 || len(receiver.payloads) != 2 {
+		t.Fatal("changes should be delivered:", cnt, len(receiver.payloads))
+	}
+	created, updated := receiver.payloads[0], receiver.payloads[1]
+	if created.OldVersion != "" || created.AppName != "app1" || created.Author != "alice" || created.Diff.Added != 2 {
+		t.Fatal("unexpected payload of creation:", created, created.Diff)
+	}
+	if updated.OldVersion != created.NewVersion || updated.NewVersion != repo.version(1) || updated.Env != "PROD" || updated.Dc != "dc1" ||
+		updated.Namespace != "db" || updated.Key != "conf" || updated.Diff.Type != DiffTypeUnified ||
+		updated.Diff.Added != 2 || updated.Diff.Removed != 1 || updated.Diff.Summary != "2 lines added, 1 lines removed" {
+		t.Fatal("unexpected payload of update:", updated, updated.Diff)
+	}
+	for _, d := range webhooks.deliveries {
+		if strings.Contains(d.Payload, "hunter2") {
+			t.Fatal("contents should not be delivered:", d.Payload)
+		}
+	}
+	if list, _ := h.QueryWebhookDeliveries(ctx, webhook.WebhookId, 10); len(list) != 2 || list[0].Status != DeliveryStatusSucceeded || list[0].ResponseStatus != http.StatusOK {
+		t.Fatal("unexpected delivery log:", list)
+	}
+
+	// retry with backoff till max attempts
+	receiver.failing = true
+	if err := h.UpdateConfigurationById(ctx, &UpdateConfigurationRequest{ConfigId: 1, ContentType: "general", Content: "", ConfigVersion: repo.version(1)}); err != nil {
+		t.Fatal(err)
+	}
+	delivery := webhooks.deliveries[2]
+	now = time.Now()
+	if cnt := dispatcher.RunOnce(now); cnt != 1 || delivery.Status != DeliveryStatusPending || delivery.Attempts != 1 ||
+		delivery.ResponseStatus != http.StatusServiceUnavailable || !strings.Contains(delivery.Message, "try later") ||
+		delivery.TimeNextAttempt != now.Add(time.Minute).UnixMilli() {
+		t.Fatal("delivery should be retried later:", cnt, delivery)
+	}
+	if cnt := dispatcher.RunOnce(now); cnt != 0 {
+		t.Fatal("retry should not be due:", cnt)
+	}
+	if cnt := dispatcher.RunOnce(now.Add(time.Minute)); cnt != 1 || delivery.TimeNextAttempt != now.Add(3*time.Minute).UnixMilli() {
+		t.Fatal("backoff should be doubled:", cnt, delivery)
+	}
+	if cnt := dispatcher.RunOnce(now.Add(3 * time.Minute)); cnt != 1 || delivery.Status != DeliveryStatusFailed || delivery.Attempts != 3 {
+		t.Fatal("delivery should fail after max attempts:", cnt, delivery)
+	}
+
+	// redeliver
+	receiver.failing = false
+	if _, err := h.RedeliverWebhook(ctx, delivery.DeliveryId); err != nil {
+		t.Fatal(err)
+	}
+	if cnt := dispatcher.RunOnce(time.Now()); cnt != 1 || delivery.Status != DeliveryStatusSucceeded || len(receiver.payloads) != 3 {
+		t.Fatal("redelivery should succeed:", cnt, delivery)
+	}
+	if receiver.payloads[2].Diff.Removed != 3 || receiver.payloads[2].Author != "alice" {
+		t.Fatal("unexpected payload:", receiver.payloads[2], receiver.payloads[2].Diff)
+	}
+
+	if err := h.DeleteWebhook(ctx, webhook.WebhookId); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.QueryWebhookDeliveries(ctx, webhook.WebhookId, 10); err != ErrWebhookNotFound {
+		t.Fatal("webhook should be deleted:", err)
+	}
+}
+
+func TestWebhookReleaseNotification(t *testing.T) {
+	repo := newMemConfigureRepository()
+	webhooks := new(memWebhookRepository)
+	h := &ConfigureHandler{ConfigureRepository: repo, PushChangeRepository: new(memPushChangeRepository), WebhookRepository: webhooks}
+	ctx := context.Background()
+	if err := h.AddApplicationNamespace(ctx, 1, "db", "application"); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"host", "port"} {
+		if err := h.AddConfiguration(ctx, &AddConfigurationRequest{AppId: 1, Env: "PROD", Dc: "dc1", Namespace: "db", Key: key, ContentType: "general", Content: "a\nb\n"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// subscribed by the organization
+	if _, err := h.AddWebhook(ctx, &AddWebhookRequest{OrgId: "org1", Url: "http://127.0.0.1/hook"}); err != nil {
+		t.Fatal(err)
+	}
+	for id := int64(1); id <= 2; id++ {
+		if err := h.SaveConfigureDraft(ctx, &UpdateConfigurationRequest{ConfigId: id, ContentType: "general", Content: "a\nc\nd\n"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	release, err := h.PublishConfigures(ctx, &PublishRequest{AppId: 1, ConfigIds: []int64{1, 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(webhooks.deliveries) != 2 {
+		t.Fatal("each change of the release should be queued:", len(webhooks.deliveries))
+	}
+	for _, d := range webhooks.deliveries {
+		payload := new(WebhookPayload)
+		if err := json.Unmarshal([]byte(d.Payload), payload); err != nil {
+			t.Fatal(err)
+		}
+		if payload.ReleaseId != "1" || payload.NewVersion != release.ReleaseVersion || payload.Author != "" ||
+			payload.Diff.Type != DiffTypeUnified || payload.Diff.Added != 2 || payload.Diff.Removed != 1 {
+			t.Fatal("unexpected payload:", payload, payload.Diff)
+		}
+	}
+}
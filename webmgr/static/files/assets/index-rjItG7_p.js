@@ -16,4 +16,4 @@ atomicContainer, _ := ca.RegisterJsonContainer("${c.ns}", "${c.key}", new(Config
 
 OnlyAgent flags:
 -sel app=${c.app},dc=${c.dc},env=${c.env} -group ${c.ns} -key ${c.key}
-                                            `})})]})]})]}),e("div",{className:"modal-footer",children:e("button",{type:"button",className:"btn btn-secondary","data-bs-dismiss":"modal",children:"Close"})})]})})}),e("div",{className:"modal fade",id:"addNamespace","data-bs-backdrop":"static","data-bs-keyboard":"false",tabIndex:"-1","aria-labelledby":"addNamespaceLabel","aria-hidden":"true",children:e("div",{className:"modal-dialog modal-lg",children:e("div",{className:"modal-content",children:[e("div",{className:"modal-header",children:[e("h1",{className:"modal-title fs-5",id:"addNamespaceLabel",children:"Add namespace"}),e("button",{type:"button",className:"btn-close","data-bs-dismiss":"modal","aria-label":"Close"})]}),e("div",{className:"modal-body",children:e("form",{onSubmit:_=>{_.preventDefault(),h(_,t.app_id)},children:[e("h4",{children:["Current application:[",e("strong",{style:"color:red;",children:t.app}),"]"]}),e("h5",{children:"Namespace"}),e("input",{type:"text",className:"form-control",name:"namespace",autoComplete:"off"}),e("h5",{children:"Namespace type"}),e("div",{className:"form-check form-check-inline",children:[e("input",{className:"form-check-input",type:"radio",name:"nstype",value:"application",id:"nstype_application",checked:!0}),e("label",{className:"form-check-label",htmlFor:"nstype_application",children:"Application"})]}),e("div",{className:"form-check form-check-inline",children:[e("input",{className:"form-check-input",type:"radio",name:"nstype",value:"public",id:"nstype_public"}),e("label",{className:"form-check-label",htmlFor:"nstype_public",children:"Public"})]}),e("hr",{}),e("button",{type:"submit",className:"btn btn-primary",children:"Add"})]})}),e("div",{className:"modal-footer",children:e("button",{type:"button",className:"btn btn-secondary","data-bs-dismiss":"modal",children:"Close"})})]})})}),e("div",{className:"modal fade",id:"addConfiguration","data-bs-backdrop":"static","data-bs-keyboard":"false",tabIndex:"-1","aria-labelledby":"addConfigurationLabel","aria-hidden":"true",children:e("div",{className:"modal-dialog modal-xl",children:e("div",{className:"modal-content",children:[e("div",{className:"modal-header",children:[e("h1",{className:"modal-title fs-5",id:"addConfigurationLabel",children:"Add configuration"}),e("button",{type:"button",className:"btn-close","data-bs-dismiss":"modal","aria-label":"Close"})]}),e("div",{className:"modal-body",children:e("form",{onSubmit:_=>{_.preventDefault(),u(_)},children:[!r&&e("div",{children:"Loading..."}),r&&e(k,{children:[e("h5",{children:"Namespace"}),e("select",{className:"form-select",name:"ns_name",children:r.map(_=>e("option",{value:_,children:_}))}),e("h5",{children:"Key"}),e("input",{type:"text",className:"form-control",name:"key",autoComplete:"off"}),e("h5",{children:"Content type"}),e("div",{children:e("div",{className:"form-check form-check-inline",children:[e("input",{className:"form-check-input",type:"radio",name:"ct",value:"general",id:"ct_general",checked:!0}),e("label",{className:"form-check-label",htmlFor:"ct_general",children:"General Text"})]})}),e("h5",{children:"Configure content"}),e("textarea",{className:"form-control",name:"content",rows:"20",style:"font-family: Consolas, Monaco, monospace;"}),e("hr",{}),e("button",{type:"submit",className:"btn btn-primary",children:"Add"})]})]})}),e("div",{className:"modal-footer",children:e("button",{type:"button",className:"btn btn-secondary","data-bs-dismiss":"modal",children:"Close"})})]})})}),e(ft,{selected:c,modal_id:"editConfigurationEach"}),e("h4",{style:"margin: 5px 5px",children:"Configurations"}),e("h4",{style:"margin: 5px 5px",children:["Current Application:[",e("strong",{style:"color:red",children:t.app}),"]"]}),e("h4",{style:"margin: 5px 5px",children:["Current Environment:[",e("strong",{style:"color:red",children:t.env}),"], Datacenter:[",e("strong",{style:"color:red",children:t.dc}),"]"]}),e("div",{className:"btn-group",role:"group",children:[e("button",{type:"button",className:"btn btn-outline-primary","data-bs-toggle":"modal","data-bs-target":"#addConfiguration",children:"Add Configure"}),e("button",{type:"button",className:"btn btn-outline-primary","data-bs-toggle":"modal","data-bs-target":"#addNamespace",children:"Add Namespace"}),e("button",{type:"button",className:"btn btn-outline-primary",disabled:!0,children:"Link Namespace"})]}),e("hr",{}),l&&l.map(_=>e("div",{className:"card",style:"margin-bottom: 5px;",children:[e("div",{className:"card-header",children:["Namespace: ",e("strong",{children:_.namespace})]}),e("div",{className:"card-body",children:e("ul",{className:"list-group list-group-flush",children:_.configure_list&&_.configure_list.map(p=>e("li",{className:"list-group-item",children:[e("div",{children:e("h5",{children:["Key: ",e("strong",{children:p.key})]})}),e("div",{className:"btn-group",role:"group",children:[e("button",{type:"button",className:"btn btn-outline-primary","data-bs-toggle":"modal","data-bs-target":"#editConfigurationEach",onClick:()=>{i(null),i({app:t.app,env:t.env,dc:t.dc,ns:_.namespace,key:p.key,cfg_id:p.configure_id})},children:"Edit"}),e("button",{type:"button",className:"btn btn-outline-primary","data-bs-toggle":"modal","data-bs-target":"#displayUsageInCodeModel",onClick:()=>{i(null),i({app:t.app,env:t.env,dc:t.dc,ns:_.namespace,key:p.key,cfg_id:p.configure_id})},children:"View usage in code"}),e("button",{type:"button",className:"btn btn-outline-primary",disabled:!0,children:"History"}),e("button",{type:"button",className:"btn btn-outline-danger",disabled:!0,children:"Rollback"})]})]}))})})]})),!l&&e("div",{children:"Loading..."})]})}function ft(t){if(!H())return e(E,{to:"/login"});let[n,a]=C(!1);if(n)return e(E,{to:"/logout"});let[r,o]=C(null);B(()=>{let s=function(d){o(null);let h=`/configures/configure/${t.selected.cfg_id}`;fetch(A(h),{headers:{"content-type":"application/json; charset=UTF-8",Authorization:"Bearer "+U()},method:"get"}).then(async u=>{if(u.status===401){a(!0);return}if(u.status!==200){console.log("status:",u.status),alert("Get previous configure data error");return}let c=await u.json();console.log("fetch previous configure data:",c.result),c.result?o(c.result):o({})}).catch(u=>{console.log("fetch previous configure data failed:",u),alert("Get previous configure data error")})};t.selected&&s()},[t.selected]);let l=function(s){let d=t.selected.cfg_id,h=s.target.ct.value,u=s.target.content.value;console.log("edit request:",d,h,u);let c=`/configures/configure/${d}`;fetch(A(c),{headers:{"content-type":"application/json; charset=UTF-8",Authorization:"Bearer "+U()},method:"put",body:JSON.stringify({ct:h,content:u})}).then(async i=>{if(console.log("created status:",i.status),i.status===401){a(!0);return}if(i.status!==200){console.log("status:",i.status),alert("put edit configuration error");return}bootstrap.Modal.getInstance(document.getElementById(t.modal_id)).hide()}).catch(i=>{console.log("put edit configuration failed:",i),alert("put edit configuration error")})};return e(k,{children:e("div",{className:"modal fade",id:t.modal_id,"data-bs-backdrop":"static","data-bs-keyboard":"false",tabIndex:"-1","aria-labelledby":t.modal_id+"Label","aria-hidden":"true",children:e("div",{className:"modal-dialog modal-xl",children:e("div",{className:"modal-content",children:[e("div",{className:"modal-header",children:[e("h1",{className:"modal-title fs-5",id:t.modal_id+"Label",children:"Edit configuration"}),e("button",{type:"button",className:"btn-close","data-bs-dismiss":"modal","aria-label":"Close"})]}),e("div",{className:"modal-body",children:e("form",{onSubmit:s=>{s.preventDefault(),l(s)},children:[!r&&e("div",{children:"Loading..."}),r&&e(k,{children:[e("h5",{children:"Namespace"}),e("input",{type:"text",className:"form-control",name:"ns_name",autoComplete:"off",disabled:!0,value:r.cfg_ns}),e("h5",{children:"Key"}),e("input",{type:"text",className:"form-control",name:"key",autoComplete:"off",disabled:!0,value:r.cfg_key}),e("h5",{children:"Content type"}),e("div",{children:e("div",{className:"form-check form-check-inline",children:[e("input",{className:"form-check-input",type:"radio",name:"ct",value:"general",id:"ct_general",checked:r.cfg_ct==="general"}),e("label",{className:"form-check-label",htmlFor:"ct_general",children:"General Text"})]})}),e("h5",{children:"Configure content"}),e("textarea",{className:"form-control",name:"content",rows:"20",style:"font-family: Consolas, Monaco, monospace;",children:r.cfg_content}),e("hr",{}),e("button",{type:"submit",className:"btn btn-primary",children:"Add"})]})]})}),e("div",{className:"modal-footer",children:e("button",{type:"button",className:"btn btn-secondary","data-bs-dismiss":"modal",children:"Close"})})]})})})})}function gt(){return H()?e("div",{children:e(ht,{})}):e(E,{to:"/login"})}function bt(){return pt(),e(E,{to:"/"})}function vt(){let[t,n]=C({}),[a,r]=C(""),[o,l]=C(!1),s=c=>{console.log("submit!!!!"),c.submitter.disabled=!0,c.preventDefault(),r(""),fetch(A("/auth/user/login"),{headers:{"content-type":"application/json; charset=UTF-8"},method:"post",body:JSON.stringify({username:t.username,password:t.password})}).then(async i=>{if(i.status===401){r("Invalid username or password"),c.submitter.disabled=!1;return}if(i.status!==200){console.log("status:",i.status),c.submitter.disabled=!1;return}let _=await i.json();_t(_.token),l(!0)}).catch(async i=>{console.log("send request err:",i),i.submitter.disabled=!1})},d=c=>{var i=t;i[c.target.name]=c.target.value,n(i)};if(o)return e(E,{to:"/"});let[h,u]=C(null);return h?e(E,{to:"/register"}):e("div",{className:"position-absolute top-50 start-50 translate-middle shadow border rounded",style:"padding: 10px 10px",children:e("form",{onSubmit:s,children:[e("div",{children:e("h4",{children:"OnlyConfig Web Manager Login"})}),e("div",{children:[e("label",{htmlFor:"login_username",className:"form-label",children:"Username"}),e("input",{className:"form-control",type:"text",id:"login_username",value:t.username,name:"username",autoComplete:"off",onChange:d})]}),e("div",{children:[e("label",{htmlFor:"login_password",className:"form-label",children:"Password"}),e("input",{className:"form-control",type:"password",id:"login_password",value:t.password,name:"password",autoComplete:"off",onChange:d})]}),a!==""&&e("div",{children:e("div",{children:e("label",{style:"color: red;",children:a})})}),e("div",{style:"margin-top: 10px;",children:[e("button",{type:"submit",className:"btn btn-primary",style:"width: 100px",children:"Login"}),e("span",{style:"padding: 0 10px",children:e("a",{href:"#",onClick:()=>{u(!0)},children:"Register New User"})})]})]})})}function yt(){let[t,n]=C(null),[a,r]=C(null);return a?e(E,{to:"/login"}):e("div",{className:"position-absolute top-50 start-50 translate-middle shadow border rounded",style:"padding: 10px 10px",children:e("form",{onSubmit:function(l){l.preventDefault(),n(null),l.submitter.disabled=!0;let s=l.target.username.value,d=l.target.password.value,h=l.target.confirm_password.value,u=l.target.email.value,c=l.target.display_name.value;if(d!==h){n("password mismatch"),l.submitter.disabled=!1;return}fetch(A("/user/new_user"),{headers:{"content-type":"application/json; charset=UTF-8"},method:"post",body:JSON.stringify({username:s,password:d,email:u,display_name:c})}).then(async i=>{if(i.status!==200){console.log("status:",i.status),n("register failed"),l.submitter.disabled=!1;return}r(!0)}).catch(async i=>{console.log("send request err:",i),l.submitter.disabled=!1})},children:[e("div",{children:e("h4",{children:"OnlyConfig Web Manager Register"})}),e("div",{children:[e("label",{htmlFor:"reg_username",className:"form-label",children:"Username"}),e("input",{className:"form-control",type:"text",id:"reg_username",name:"username",autoComplete:"off"})]}),e("div",{children:[e("label",{htmlFor:"reg_password",className:"form-label",children:"Password"}),e("input",{className:"form-control",type:"password",id:"reg_password",name:"password",autoComplete:"off"})]}),e("div",{children:[e("label",{htmlFor:"reg_confirmed_password",className:"form-label",children:"Confirm Password"}),e("input",{className:"form-control",type:"password",id:"reg_confirmed_password",name:"confirm_password",autoComplete:"off"})]}),e("div",{children:[e("label",{htmlFor:"reg_email",className:"form-label",children:"Email"}),e("input",{className:"form-control",type:"email",id:"reg_email",name:"email",autoComplete:"off"})]}),e("div",{children:[e("label",{htmlFor:"reg_display_name",className:"form-label",children:"Display Name"}),e("input",{className:"form-control",type:"text",id:"reg_display_name",name:"display_name",autoComplete:"off"})]}),t!==""&&e("div",{children:e("div",{children:e("label",{style:"color: red;",children:t})})}),e("div",{style:"margin-top: 10px;",children:[e("button",{type:"submit",className:"btn btn-primary",style:"width: 100px",children:"Register"}),e("span",{style:"padding: 0 10px",children:e("a",{href:"#",onClick:()=>{r(!0)},children:"Back to login"})})]})]})})}function Nt(){if(!H())return e(E,{to:"/login"});let[t,n]=C(!1);if(t)return e(E,{to:"/logout"});let[a,r]=C(null);return e(k,{children:[e(J,{}),e("div",{className:"row",style:"padding: 10px 0",children:[e("div",{className:"col-1"}),e("div",{className:"col-10 border rounded",children:[e("h1",{children:"Change password"}),e("div",{className:"row",children:[e("div",{className:"col-1"}),e("div",{className:"col-10",children:e("form",{onSubmit:function(l){l.preventDefault(),r(null);let s=l.target.old.value,d=l.target.new.value,h=l.target.confirm.value;d!==h&&r("password mismatch"),l.submitter.disabled=!0,fetch(A("/user/change_password"),{headers:{"content-type":"application/json; charset=UTF-8",Authorization:"Bearer "+U()},method:"post",body:JSON.stringify({old:s,new:d})}).then(async u=>{if(u.status!==200){console.log("status:",u.status),r("change password failed"),l.submitter.disabled=!1;return}l.target.old.value="",l.target.new.value="",l.target.confirm.value=""}).catch(async u=>{console.log("send request err:",u),l.submitter.disabled=!1})},children:[e("div",{children:"Old password"}),e("div",{className:"btn-group",style:"padding: 10px 0",children:e("input",{className:"form-control form-control-lg",type:"password",name:"old",autoComplete:"off"})}),e("div",{children:"New password"}),e("div",{className:"btn-group",style:"padding: 10px 0",children:e("input",{className:"form-control form-control-lg",type:"password",name:"new",autoComplete:"off"})}),e("div",{children:"Confirm password"}),e("div",{className:"btn-group",style:"padding: 10px 0",children:e("input",{className:"form-control form-control-lg",type:"password",name:"confirm",autoComplete:"off"})}),a&&e("div",{children:e("div",{style:"color: red;",children:a})}),e("div",{style:"margin: 10px 0px",children:e("button",{type:"submit",className:"btn btn-primary",children:"Change"})})]})}),e("div",{className:"col-1"})]})]}),e("div",{className:"col-1"})]})]})}function wt(){return e("section",{children:[e("h1",{children:"404: Not Found"}),e("p",{children:"It's gone :("})]})}function Ct(){if(!H())return e(E,{to:"/login"});let[t,n]=C(!1);if(t)return e(E,{to:"/logout"});let[a,r]=C(null),o=function(){fetch(A("/configures/env_dc_list"),{headers:{"content-type":"application/json; charset=UTF-8",Authorization:"Bearer "+U()},method:"get"}).then(async s=>{if(s.status===401){n(!0);return}if(s.status!==200){console.log("status:",s.status),alert("Get env_dc_list error");return}let d=await s.json();r(d)}).catch(s=>{console.log("fetch env_dc_list failed:",s),alert("Get env_dc_list error")})};if(B(()=>(o(),()=>{}),[]),!a)return e(k,{children:[e(J,{}),e("div",{children:"loading...."})]});let l=function(s,d){fetch(A("/configures/env_and_dc/"+encodeURI(s)+"/"+encodeURI(d)),{headers:{"content-type":"application/json; charset=UTF-8",Authorization:"Bearer "+U()},method:"put"}).then(async h=>{if(h.status===401){n(!0);return}if(h.status!==200){console.log("status:",h.status),alert("put env or dc error");return}o()}).catch(h=>{console.log("put env or dc failed:",h),alert("put env or dc error")})};return e(k,{children:[e(J,{}),e("div",{className:"row",style:"padding: 10px 0",children:[e("div",{className:"col-1"}),e("div",{className:"col-10 border rounded",children:[e("h1",{children:"Add environment"}),e("div",{className:"row",children:[e("div",{className:"col-1"}),e("div",{className:"col-10",children:[e("strong",{children:"Current environment:"}),e("ul",{children:a.env&&a.env.map(s=>e("li",{children:s}))}),e("form",{onSubmit:s=>{s.preventDefault(),l("env",s.target.env.value),s.target.env.value=""},children:e("div",{className:"btn-group",style:"padding: 10px 0",children:[e("input",{className:"form-control form-control-lg",type:"text",name:"env",autocomplete:"off"}),e("button",{type:"submit",className:"btn btn-primary",children:"Add"})]})})]}),e("div",{className:"col-1"})]})]}),e("div",{className:"col-1"})]}),e("div",{className:"row",style:"padding: 10px 0",children:[e("div",{className:"col-1"}),e("div",{className:"col-10 border rounded",children:[e("h1",{children:"Add datacenter"}),e("div",{className:"row",children:[e("div",{className:"col-1"}),e("div",{className:"col-10",children:[e("strong",{children:"Current datacenter:"}),e("ul",{children:a.dc&&a.dc.map(s=>e("li",{children:s}))}),e("form",{onSubmit:s=>{s.preventDefault(),l("dc",s.target.dc.value),s.target.dc.value=""},children:e("div",{className:"btn-group",style:"padding: 10px 0",children:[e("input",{className:"form-control form-control-lg",type:"text",name:"dc",autocomplete:"off"}),e("button",{type:"submit",className:"btn btn-primary",children:"Add"})]})})]}),e("div",{className:"col-1"})]})]}),e("div",{className:"col-1"})]})]})}function xt(){if(!H())return e(E,{to:"/login"});let[t,n]=C(!1);if(t)return e(E,{to:"/logout"});let[a,r]=C(null),[o,l]=C(null),s=function(){r(null),l(null)},d=function(){s(),fetch(A("/user/organizations"),{headers:{"content-type":"application/json; charset=UTF-8",Authorization:"Bearer "+U()},method:"get"}).then(async c=>{if(c.status===401){n(!0);return}if(c.status!==200){console.log("status:",c.status),alert("Get organizations data error");return}let i=await c.json();console.log("fetch organizations data:",i.result),i.result?r(i.result):r([])}).catch(c=>{console.log("fetch organizations data failed:",c),alert("Get organizations data error")})},h=function(c){let i=encodeURI(c.target.org.value);c.target.org.value="";let _=`/organization/${i}`;fetch(A(_),{headers:{"content-type":"application/json; charset=UTF-8",Authorization:"Bearer "+U()},method:"put"}).then(async p=>{if(p.status===401){n(!0);return}if(p.status!==200){console.log("status:",p.status),alert("put new organization error");return}d()}).catch(p=>{console.log("put new organization failed:",p),alert("put new organization error")})},u=function(c){let i=o.org_name,_=c.target.username.value,p=`/organization/${i}/owner/${_}`;fetch(A(p),{headers:{"content-type":"application/json; charset=UTF-8",Authorization:"Bearer "+U()},method:"put"}).then(async b=>{if(b.status===401){n(!0);return}if(b.status!==200){console.log("status:",b.status),alert("put add user to org error");return}d()}).catch(b=>{console.log("put add user to org failed:",b),alert("put add user to org error")})};return B(()=>{d()},[]),e(k,{children:[e(J,{}),e("div",{className:"row",style:"margin:5px 0px;",children:[e("div",{className:"col-1"}),e("div",{className:"col-10 border rounded",children:[e("h1",{children:"Manage owned organization"}),e("div",{className:"row border rounded",style:"margin: 10px 0px; padding: 5px 5px;",children:[e("h4",{children:"Create new organization"}),e("form",{onSubmit:c=>{c.preventDefault(),h(c)},children:[e("h5",{children:"Organization name:"}),e("input",{type:"text",className:"form-control",name:"org",autocomplete:"off"}),e("hr",{}),e("button",{type:"submit",className:"btn btn-primary",children:"Add"})]})]}),e("div",{className:"row border rounded",style:"margin: 10px 0px; padding: 5px 5px;",children:[e("h4",{children:"Manage organization"}),!a&&e(k,{children:"Loading..."}),a&&e(k,{children:e("select",{className:"form-select",onChange:c=>{l(a[c.target.value])},children:[e("option",{disabled:!0,selected:!0,value:!0,children:" -- select an organization --"}),a.map((c,i)=>e("option",{value:i,children:c.org_name}))]})}),o&&e(k,{children:[e("div",{className:"row",style:"margin: 5px 0px;",children:[e("div",{className:"col-6",children:[e("h5",{children:"Owner list"}),e("ul",{className:"list-group",children:o.owner_list&&o.owner_list.map(c=>e("li",{className:"list-group-item",children:c}))})]}),e("div",{className:"col-6",children:[e("h5",{children:"User list"}),e("ul",{className:"list-group",children:o.user_list&&o.user_list.map(c=>e("li",{className:"list-group-item",children:c}))})]})]}),e("div",{className:"row",style:"margin: 5px 0px;",children:[e("div",{className:"col-6",children:[e("hr",{}),e("span",{children:"Add owner:"}),e("form",{onSubmit:c=>{c.preventDefault(),u(c)},children:[e("input",{type:"text",className:"form-control",name:"username",autocomplete:"off"}),e("button",{type:"submit",className:"btn btn-primary",style:"margin: 5px 0px;",children:"Add"})]})]}),e("div",{className:"col-6",children:[e("hr",{}),e("span",{children:"Add user:"}),e("input",{type:"text",className:"form-control",name:"username",autocomplete:"off",disabled:!0}),e("button",{type:"button",className:"btn btn-primary",style:"margin: 5px 0px;",disabled:!0,children:"Add"})]})]})]})]})]}),e("div",{className:"col-1"})]})]})}function kt(){return e(V,{children:e("main",{children:e(Ze,{children:[e(M,{path:"/",component:gt}),e(M,{path:"/login",component:vt}),e(M,{path:"/logout",component:bt}),e(M,{path:"/register",component:yt}),e(M,{path:"/change_password",component:Nt}),e(M,{path:"/env_and_dc",component:Ct}),e(M,{path:"/org_mgr",component:xt}),e(M,{default:!0,component:wt})]})})})}rt(e(kt,{}),document.getElementById("app"));
+                                            `})})]})]})]}),e("div",{className:"modal-footer",children:e("button",{type:"button",className:"btn btn-secondary","data-bs-dismiss":"modal",children:"Close"})})]})})}),e("div",{className:"modal fade",id:"addNamespace","data-bs-backdrop":"static","data-bs-keyboard":"false",tabIndex:"-1","aria-labelledby":"addNamespaceLabel","aria-hidden":"true",children:e("div",{className:"modal-dialog modal-lg",children:e("div",{className:"modal-content",children:[e("div",{className:"modal-header",children:[e("h1",{className:"modal-title fs-5",id:"addNamespaceLabel",children:"Add namespace"}),e("button",{type:"button",className:"btn-close","data-bs-dismiss":"modal","aria-label":"Close"})]}),e("div",{className:"modal-body",children:e("form",{onSubmit:_=>{_.preventDefault(),h(_,t.app_id)},children:[e("h4",{children:["Current application:[",e("strong",{style:"color:red;",children:t.app}),"]"]}),e("h5",{children:"Namespace"}),e("input",{type:"text",className:"form-control",name:"namespace",autoComplete:"off"}),e("h5",{children:"Namespace type"}),e("div",{className:"form-check form-check-inline",children:[e("input",{className:"form-check-input",type:"radio",name:"nstype",value:"application",id:"nstype_application",checked:!0}),e("label",{className:"form-check-label",htmlFor:"nstype_application",children:"Application"})]}),e("div",{className:"form-check form-check-inline",children:[e("input",{className:"form-check-input",type:"radio",name:"nstype",value:"public",id:"nstype_public"}),e("label",{className:"form-check-label",htmlFor:"nstype_public",children:"Public"})]}),e("hr",{}),e("button",{type:"submit",className:"btn btn-primary",children:"Add"})]})}),e("div",{className:"modal-footer",children:e("button",{type:"button",className:"btn btn-secondary","data-bs-dismiss":"modal",children:"Close"})})]})})}),e("div",{className:"modal fade",id:"addConfiguration","data-bs-backdrop":"static","data-bs-keyboard":"false",tabIndex:"-1","aria-labelledby":"addConfigurationLabel","aria-hidden":"true",children:e("div",{className:"modal-dialog modal-xl",children:e("div",{className:"modal-content",children:[e("div",{className:"modal-header",children:[e("h1",{className:"modal-title fs-5",id:"addConfigurationLabel",children:"Add configuration"}),e("button",{type:"button",className:"btn-close","data-bs-dismiss":"modal","aria-label":"Close"})]}),e("div",{className:"modal-body",children:e("form",{onSubmit:_=>{_.preventDefault(),u(_)},children:[!r&&e("div",{children:"Loading..."}),r&&e(k,{children:[e("h5",{children:"Namespace"}),e("select",{className:"form-select",name:"ns_name",children:r.map(_=>e("option",{value:_,children:_}))}),e("h5",{children:"Key"}),e("input",{type:"text",className:"form-control",name:"key",autoComplete:"off"}),e("h5",{children:"Content type"}),e("div",{children:e("div",{className:"form-check form-check-inline",children:[e("input",{className:"form-check-input",type:"radio",name:"ct",value:"general",id:"ct_general",checked:!0}),e("label",{className:"form-check-label",htmlFor:"ct_general",children:"General Text"})]})}),e("h5",{children:"Configure content"}),e("textarea",{className:"form-control",name:"content",rows:"20",style:"font-family: Consolas, Monaco, monospace;"}),e("hr",{}),e("button",{type:"submit",className:"btn btn-primary",children:"Add"})]})]})}),e("div",{className:"modal-footer",children:e("button",{type:"button",className:"btn btn-secondary","data-bs-dismiss":"modal",children:"Close"})})]})})}),e(ft,{selected:c,modal_id:"editConfigurationEach"}),e("h4",{style:"margin: 5px 5px",children:"Configurations"}),e("h4",{style:"margin: 5px 5px",children:["Current Application:[",e("strong",{style:"color:red",children:t.app}),"]"]}),e("h4",{style:"margin: 5px 5px",children:["Current Environment:[",e("strong",{style:"color:red",children:t.env}),"], Datacenter:[",e("strong",{style:"color:red",children:t.dc}),"]"]}),e("div",{className:"btn-group",role:"group",children:[e("button",{type:"button",className:"btn btn-outline-primary","data-bs-toggle":"modal","data-bs-target":"#addConfiguration",children:"Add Configure"}),e("button",{type:"button",className:"btn btn-outline-primary","data-bs-toggle":"modal","data-bs-target":"#addNamespace",children:"Add Namespace"}),e("button",{type:"button",className:"btn btn-outline-primary",disabled:!0,children:"Link Namespace"})]}),e("hr",{}),l&&l.map(_=>e("div",{className:"card",style:"margin-bottom: 5px;",children:[e("div",{className:"card-header",children:["Namespace: ",e("strong",{children:_.namespace})]}),e("div",{className:"card-body",children:e("ul",{className:"list-group list-group-flush",children:_.configure_list&&_.configure_list.map(p=>e("li",{className:"list-group-item",children:[e("div",{children:e("h5",{children:["Key: ",e("strong",{children:p.key})]})}),e("div",{className:"btn-group",role:"group",children:[e("button",{type:"button",className:"btn btn-outline-primary","data-bs-toggle":"modal","data-bs-target":"#editConfigurationEach",onClick:()=>{i(null),i({app:t.app,env:t.env,dc:t.dc,ns:_.namespace,key:p.key,cfg_id:p.configure_id})},children:"Edit"}),e("button",{type:"button",className:"btn btn-outline-primary","data-bs-toggle":"modal","data-bs-target":"#displayUsageInCodeModel",onClick:()=>{i(null),i({app:t.app,env:t.env,dc:t.dc,ns:_.namespace,key:p.key,cfg_id:p.configure_id})},children:"View usage in code"}),e("button",{type:"button",className:"btn btn-outline-primary",disabled:!0,children:"History"}),e("button",{type:"button",className:"btn btn-outline-danger",disabled:!0,children:"Rollback"})]})]}))})})]})),!l&&e("div",{children:"Loading..."})]})}function ft(t){if(!H())return e(E,{to:"/login"});let[n,a]=C(!1);if(n)return e(E,{to:"/logout"});let[r,o]=C(null);B(()=>{let s=function(d){o(null);let h=`/configures/configure/${t.selected.cfg_id}`;fetch(A(h),{headers:{"content-type":"application/json; charset=UTF-8",Authorization:"Bearer "+U()},method:"get"}).then(async u=>{if(u.status===401){a(!0);return}if(u.status!==200){console.log("status:",u.status),alert("Get previous configure data error");return}let c=await u.json();console.log("fetch previous configure data:",c.result),c.result?o(c.result):o({})}).catch(u=>{console.log("fetch previous configure data failed:",u),alert("Get previous configure data error")})};t.selected&&s()},[t.selected]);let l=function(s){let d=t.selected.cfg_id,h=s.target.ct.value,u=s.target.content.value;console.log("edit request:",d,h,u);let c=`/configures/configure/${d}`;fetch(A(c),{headers:{"content-type":"application/json; charset=UTF-8",Authorization:"Bearer "+U()},method:"put",body:JSON.stringify({ct:h,content:u,cfg_version:r&&r.cfg_version})}).then(async i=>{if(console.log("created status:",i.status),i.status===401){a(!0);return}if(i.status===409){let c=await i.json();console.log("configuration conflict:",c.error),alert("The configuration has been changed by others meanwhile, the latest version is loaded. Merge the edit and submit again."),o(c.current||{});return}if(i.status!==200){console.log("status:",i.status),alert("put edit configuration error");return}bootstrap.Modal.getInstance(document.getElementById(t.modal_id)).hide()}).catch(i=>{console.log("put edit configuration failed:",i),alert("put edit configuration error")})};return e(k,{children:e("div",{className:"modal fade",id:t.modal_id,"data-bs-backdrop":"static","data-bs-keyboard":"false",tabIndex:"-1","aria-labelledby":t.modal_id+"Label","aria-hidden":"true",children:e("div",{className:"modal-dialog modal-xl",children:e("div",{className:"modal-content",children:[e("div",{className:"modal-header",children:[e("h1",{className:"modal-title fs-5",id:t.modal_id+"Label",children:"Edit configuration"}),e("button",{type:"button",className:"btn-close","data-bs-dismiss":"modal","aria-label":"Close"})]}),e("div",{className:"modal-body",children:e("form",{onSubmit:s=>{s.preventDefault(),l(s)},children:[!r&&e("div",{children:"Loading..."}),r&&e(k,{children:[e("h5",{children:"Namespace"}),e("input",{type:"text",className:"form-control",name:"ns_name",autoComplete:"off",disabled:!0,value:r.cfg_ns}),e("h5",{children:"Key"}),e("input",{type:"text",className:"form-control",name:"key",autoComplete:"off",disabled:!0,value:r.cfg_key}),e("h5",{children:"Content type"}),e("div",{children:e("div",{className:"form-check form-check-inline",children:[e("input",{className:"form-check-input",type:"radio",name:"ct",value:"general",id:"ct_general",checked:r.cfg_ct==="general"}),e("label",{className:"form-check-label",htmlFor:"ct_general",children:"General Text"})]})}),e("h5",{children:"Configure content"}),e("textarea",{className:"form-control",name:"content",rows:"20",style:"font-family: Consolas, Monaco, monospace;",children:r.cfg_content}),e("hr",{}),e("button",{type:"submit",className:"btn btn-primary",children:"Add"})]})]})}),e("div",{className:"modal-footer",children:e("button",{type:"button",className:"btn btn-secondary","data-bs-dismiss":"modal",children:"Close"})})]})})})})}function gt(){return H()?e("div",{children:e(ht,{})}):e(E,{to:"/login"})}function bt(){return pt(),e(E,{to:"/"})}function vt(){let[t,n]=C({}),[a,r]=C(""),[o,l]=C(!1),s=c=>{console.log("submit!!!!"),c.submitter.disabled=!0,c.preventDefault(),r(""),fetch(A("/auth/user/login"),{headers:{"content-type":"application/json; charset=UTF-8"},method:"post",body:JSON.stringify({username:t.username,password:t.password})}).then(async i=>{if(i.status===401){r("Invalid username or password"),c.submitter.disabled=!1;return}if(i.status!==200){console.log("status:",i.status),c.submitter.disabled=!1;return}let _=await i.json();_t(_.token),l(!0)}).catch(async i=>{console.log("send request err:",i),i.submitter.disabled=!1})},d=c=>{var i=t;i[c.target.name]=c.target.value,n(i)};if(o)return e(E,{to:"/"});let[h,u]=C(null);return h?e(E,{to:"/register"}):e("div",{className:"position-absolute top-50 start-50 translate-middle shadow border rounded",style:"padding: 10px 10px",children:e("form",{onSubmit:s,children:[e("div",{children:e("h4",{children:"OnlyConfig Web Manager Login"})}),e("div",{children:[e("label",{htmlFor:"login_username",className:"form-label",children:"Username"}),e("input",{className:"form-control",type:"text",id:"login_username",value:t.username,name:"username",autoComplete:"off",onChange:d})]}),e("div",{children:[e("label",{htmlFor:"login_password",className:"form-label",children:"Password"}),e("input",{className:"form-control",type:"password",id:"login_password",value:t.password,name:"password",autoComplete:"off",onChange:d})]}),a!==""&&e("div",{children:e("div",{children:e("label",{style:"color: red;",children:a})})}),e("div",{style:"margin-top: 10px;",children:[e("button",{type:"submit",className:"btn btn-primary",style:"width: 100px",children:"Login"}),e("span",{style:"padding: 0 10px",children:e("a",{href:"#",onClick:()=>{u(!0)},children:"Register New User"})})]})]})})}function yt(){let[t,n]=C(null),[a,r]=C(null);return a?e(E,{to:"/login"}):e("div",{className:"position-absolute top-50 start-50 translate-middle shadow border rounded",style:"padding: 10px 10px",children:e("form",{onSubmit:function(l){l.preventDefault(),n(null),l.submitter.disabled=!0;let s=l.target.username.value,d=l.target.password.value,h=l.target.confirm_password.value,u=l.target.email.value,c=l.target.display_name.value;if(d!==h){n("password mismatch"),l.submitter.disabled=!1;return}fetch(A("/user/new_user"),{headers:{"content-type":"application/json; charset=UTF-8"},method:"post",body:JSON.stringify({username:s,password:d,email:u,display_name:c})}).then(async i=>{if(i.status!==200){console.log("status:",i.status),n("register failed"),l.submitter.disabled=!1;return}r(!0)}).catch(async i=>{console.log("send request err:",i),l.submitter.disabled=!1})},children:[e("div",{children:e("h4",{children:"OnlyConfig Web Manager Register"})}),e("div",{children:[e("label",{htmlFor:"reg_username",className:"form-label",children:"Username"}),e("input",{className:"form-control",type:"text",id:"reg_username",name:"username",autoComplete:"off"})]}),e("div",{children:[e("label",{htmlFor:"reg_password",className:"form-label",children:"Password"}),e("input",{className:"form-control",type:"password",id:"reg_password",name:"password",autoComplete:"off"})]}),e("div",{children:[e("label",{htmlFor:"reg_confirmed_password",className:"form-label",children:"Confirm Password"}),e("input",{className:"form-control",type:"password",id:"reg_confirmed_password",name:"confirm_password",autoComplete:"off"})]}),e("div",{children:[e("label",{htmlFor:"reg_email",className:"form-label",children:"Email"}),e("input",{className:"form-control",type:"email",id:"reg_email",name:"email",autoComplete:"off"})]}),e("div",{children:[e("label",{htmlFor:"reg_display_name",className:"form-label",children:"Display Name"}),e("input",{className:"form-control",type:"text",id:"reg_display_name",name:"display_name",autoComplete:"off"})]}),t!==""&&e("div",{children:e("div",{children:e("label",{style:"color: red;",children:t})})}),e("div",{style:"margin-top: 10px;",children:[e("button",{type:"submit",className:"btn btn-primary",style:"width: 100px",children:"Register"}),e("span",{style:"padding: 0 10px",children:e("a",{href:"#",onClick:()=>{r(!0)},children:"Back to login"})})]})]})})}function Nt(){if(!H())return e(E,{to:"/login"});let[t,n]=C(!1);if(t)return e(E,{to:"/logout"});let[a,r]=C(null);return e(k,{children:[e(J,{}),e("div",{className:"row",style:"padding: 10px 0",children:[e("div",{className:"col-1"}),e("div",{className:"col-10 border rounded",children:[e("h1",{children:"Change password"}),e("div",{className:"row",children:[e("div",{className:"col-1"}),e("div",{className:"col-10",children:e("form",{onSubmit:function(l){l.preventDefault(),r(null);let s=l.target.old.value,d=l.target.new.value,h=l.target.confirm.value;d!==h&&r("password mismatch"),l.submitter.disabled=!0,fetch(A("/user/change_password"),{headers:{"content-type":"application/json; charset=UTF-8",Authorization:"Bearer "+U()},method:"post",body:JSON.stringify({old:s,new:d})}).then(async u=>{if(u.status!==200){console.log("status:",u.status),r("change password failed"),l.submitter.disabled=!1;return}l.target.old.value="",l.target.new.value="",l.target.confirm.value=""}).catch(async u=>{console.log("send request err:",u),l.submitter.disabled=!1})},children:[e("div",{children:"Old password"}),e("div",{className:"btn-group",style:"padding: 10px 0",children:e("input",{className:"form-control form-control-lg",type:"password",name:"old",autoComplete:"off"})}),e("div",{children:"New password"}),e("div",{className:"btn-group",style:"padding: 10px 0",children:e("input",{className:"form-control form-control-lg",type:"password",name:"new",autoComplete:"off"})}),e("div",{children:"Confirm password"}),e("div",{className:"btn-group",style:"padding: 10px 0",children:e("input",{className:"form-control form-control-lg",type:"password",name:"confirm",autoComplete:"off"})}),a&&e("div",{children:e("div",{style:"color: red;",children:a})}),e("div",{style:"margin: 10px 0px",children:e("button",{type:"submit",className:"btn btn-primary",children:"Change"})})]})}),e("div",{className:"col-1"})]})]}),e("div",{className:"col-1"})]})]})}function wt(){return e("section",{children:[e("h1",{children:"404: Not Found"}),e("p",{children:"It's gone :("})]})}function Ct(){if(!H())return e(E,{to:"/login"});let[t,n]=C(!1);if(t)return e(E,{to:"/logout"});let[a,r]=C(null),o=function(){fetch(A("/configures/env_dc_list"),{headers:{"content-type":"application/json; charset=UTF-8",Authorization:"Bearer "+U()},method:"get"}).then(async s=>{if(s.status===401){n(!0);return}if(s.status!==200){console.log("status:",s.status),alert("Get env_dc_list error");return}let d=await s.json();r(d)}).catch(s=>{console.log("fetch env_dc_list failed:",s),alert("Get env_dc_list error")})};if(B(()=>(o(),()=>{}),[]),!a)return e(k,{children:[e(J,{}),e("div",{children:"loading...."})]});let l=function(s,d){fetch(A("/configures/env_and_dc/"+encodeURI(s)+"/"+encodeURI(d)),{headers:{"content-type":"application/json; charset=UTF-8",Authorization:"Bearer "+U()},method:"put"}).then(async h=>{if(h.status===401){n(!0);return}if(h.status!==200){console.log("status:",h.status),alert("put env or dc error");return}o()}).catch(h=>{console.log("put env or dc failed:",h),alert("put env or dc error")})};return e(k,{children:[e(J,{}),e("div",{className:"row",style:"padding: 10px 0",children:[e("div",{className:"col-1"}),e("div",{className:"col-10 border rounded",children:[e("h1",{children:"Add environment"}),e("div",{className:"row",children:[e("div",{className:"col-1"}),e("div",{className:"col-10",children:[e("strong",{children:"Current environment:"}),e("ul",{children:a.env&&a.env.map(s=>e("li",{children:s}))}),e("form",{onSubmit:s=>{s.preventDefault(),l("env",s.target.env.value),s.target.env.value=""},children:e("div",{className:"btn-group",style:"padding: 10px 0",children:[e("input",{className:"form-control form-control-lg",type:"text",name:"env",autocomplete:"off"}),e("button",{type:"submit",className:"btn btn-primary",children:"Add"})]})})]}),e("div",{className:"col-1"})]})]}),e("div",{className:"col-1"})]}),e("div",{className:"row",style:"padding: 10px 0",children:[e("div",{className:"col-1"}),e("div",{className:"col-10 border rounded",children:[e("h1",{children:"Add datacenter"}),e("div",{className:"row",children:[e("div",{className:"col-1"}),e("div",{className:"col-10",children:[e("strong",{children:"Current datacenter:"}),e("ul",{children:a.dc&&a.dc.map(s=>e("li",{children:s}))}),e("form",{onSubmit:s=>{s.preventDefault(),l("dc",s.target.dc.value),s.target.dc.value=""},children:e("div",{className:"btn-group",style:"padding: 10px 0",children:[e("input",{className:"form-control form-control-lg",type:"text",name:"dc",autocomplete:"off"}),e("button",{type:"submit",className:"btn btn-primary",children:"Add"})]})})]}),e("div",{className:"col-1"})]})]}),e("div",{className:"col-1"})]})]})}function xt(){if(!H())return e(E,{to:"/login"});let[t,n]=C(!1);if(t)return e(E,{to:"/logout"});let[a,r]=C(null),[o,l]=C(null),s=function(){r(null),l(null)},d=function(){s(),fetch(A("/user/organizations"),{headers:{"content-type":"application/json; charset=UTF-8",Authorization:"Bearer "+U()},method:"get"}).then(async c=>{if(c.status===401){n(!0);return}if(c.status!==200){console.log("status:",c.status),alert("Get organizations data error");return}let i=await c.json();console.log("fetch organizations data:",i.result),i.result?r(i.result):r([])}).catch(c=>{console.log("fetch organizations data failed:",c),alert("Get organizations data error")})},h=function(c){let i=encodeURI(c.target.org.value);c.target.org.value="";let _=`/organization/${i}`;fetch(A(_),{headers:{"content-type":"application/json; charset=UTF-8",Authorization:"Bearer "+U()},method:"put"}).then(async p=>{if(p.status===401){n(!0);return}if(p.status!==200){console.log("status:",p.status),alert("put new organization error");return}d()}).catch(p=>{console.log("put new organization failed:",p),alert("put new organization error")})},u=function(c){let i=o.org_name,_=c.target.username.value,p=`/organization/${i}/owner/${_}`;fetch(A(p),{headers:{"content-type":"application/json; charset=UTF-8",Authorization:"Bearer "+U()},method:"put"}).then(async b=>{if(b.status===401){n(!0);return}if(b.status!==200){console.log("status:",b.status),alert("put add user to org error");return}d()}).catch(b=>{console.log("put add user to org failed:",b),alert("put add user to org error")})};return B(()=>{d()},[]),e(k,{children:[e(J,{}),e("div",{className:"row",style:"margin:5px 0px;",children:[e("div",{className:"col-1"}),e("div",{className:"col-10 border rounded",children:[e("h1",{children:"Manage owned organization"}),e("div",{className:"row border rounded",style:"margin: 10px 0px; padding: 5px 5px;",children:[e("h4",{children:"Create new organization"}),e("form",{onSubmit:c=>{c.preventDefault(),h(c)},children:[e("h5",{children:"Organization name:"}),e("input",{type:"text",className:"form-control",name:"org",autocomplete:"off"}),e("hr",{}),e("button",{type:"submit",className:"btn btn-primary",children:"Add"})]})]}),e("div",{className:"row border rounded",style:"margin: 10px 0px; padding: 5px 5px;",children:[e("h4",{children:"Manage organization"}),!a&&e(k,{children:"Loading..."}),a&&e(k,{children:e("select",{className:"form-select",onChange:c=>{l(a[c.target.value])},children:[e("option",{disabled:!0,selected:!0,value:!0,children:" -- select an organization --"}),a.map((c,i)=>e("option",{value:i,children:c.org_name}))]})}),o&&e(k,{children:[e("div",{className:"row",style:"margin: 5px 0px;",children:[e("div",{className:"col-6",children:[e("h5",{children:"Owner list"}),e("ul",{className:"list-group",children:o.owner_list&&o.owner_list.map(c=>e("li",{className:"list-group-item",children:c}))})]}),e("div",{className:"col-6",children:[e("h5",{children:"User list"}),e("ul",{className:"list-group",children:o.user_list&&o.user_list.map(c=>e("li",{className:"list-group-item",children:c}))})]})]}),e("div",{className:"row",style:"margin: 5px 0px;",children:[e("div",{className:"col-6",children:[e("hr",{}),e("span",{children:"Add owner:"}),e("form",{onSubmit:c=>{c.preventDefault(),u(c)},children:[e("input",{type:"text",className:"form-control",name:"username",autocomplete:"off"}),e("button",{type:"submit",className:"btn btn-primary",style:"margin: 5px 0px;",children:"Add"})]})]}),e("div",{className:"col-6",children:[e("hr",{}),e("span",{children:"Add user:"}),e("input",{type:"text",className:"form-control",name:"username",autocomplete:"off",disabled:!0}),e("button",{type:"button",className:"btn btn-primary",style:"margin: 5px 0px;",disabled:!0,children:"Add"})]})]})]})]})]}),e("div",{className:"col-1"})]})]})}function kt(){return e(V,{children:e("main",{children:e(Ze,{children:[e(M,{path:"/",component:gt}),e(M,{path:"/login",component:vt}),e(M,{path:"/logout",component:bt}),e(M,{path:"/register",component:yt}),e(M,{path:"/change_password",component:Nt}),e(M,{path:"/env_and_dc",component:Ct}),e(M,{path:"/org_mgr",component:xt}),e(M,{default:!0,component:wt})]})})})}rt(e(kt,{}),document.getElementById("app"));
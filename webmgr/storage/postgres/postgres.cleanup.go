@@ -0,0 +1,155 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/goodplayer/onlyconfig/webmgr/domains"
+	"github.com/goodplayer/onlyconfig/webmgr/storage/dbtxn"
+)
+
+type CleanupRepositoryImpl struct{}
+
+func (c *CleanupRepositoryImpl) LoadPublishedConfigurations(ctx context.Context) (result []*domains.PublishedConfiguration, rerr error) {
+	var list []*Configuration
+	sess := dbtxn.GetTxn(ctx)
+	// raw_cfg_value is not required
+	if err := sess.Cols("cfg_id", "selectors", "optional_selectors", "cfg_group", "cfg_key", "cfg_version", "cfg_status", "time_updated").OrderBy("cfg_id").Find(&list); err != nil {
+		return nil, err
+	}
+	for _, cfg := range list {
+		result = append(result, &domains.PublishedConfiguration{
+			ConfigId:          cfg.ConfigId,
+			Selectors:         cfg.Selectors,
+			OptionalSelectors: cfg.OptionalSelectors,
+			Group:             cfg.ConfigGroup,
+			Key:               cfg.ConfigKey,
+			Version:           cfg.ConfigVersion,
+			Status:            cfg.ConfigStatus,
+			TimeUpdated:       cfg.TimeUpdated,
+		})
+	}
+	return
+}
+
+func (c *CleanupRepositoryImpl) LoadAllConfigures(ctx context.Context) (result []*domains.Configure, rerr error) {
+	var list []*Configure
+	sess := dbtxn.GetTxn(ctx)
+	// config_content is not required
+	if err := sess.Cols("config_id", "config_key", "config_namespace", "config_env", "config_datacenter", "config_content_type", "config_version", "config_status", "time_created", "time_updated").Find(&list); err != nil {
+		return nil, err
+	}
+	for _, cfg := range list {
+		result = append(result, &domains.Configure{
+			ConfigId:        cfg.ConfigId,
+			ConfigKey:       cfg.ConfigKey,
+			ConfigNamespace: cfg.ConfigNamespace,
+			ConfigEnv:       cfg.ConfigEnv,
+			ConfigDc:        cfg.ConfigDc,
+			ContentType:     cfg.ContentType,
+			ConfigVersion:   cfg.ConfigVersion,
+			ConfigStatus:    cfg.ConfigStatus,
+			TimeCreated:     cfg.TimeCreated,
+			TimeUpdated:     cfg.TimeUpdated,
+		})
+	}
+	return
+}
+
+func (c *CleanupRepositoryImpl) LoadAllNamespaces(ctx context.Context) (result []*domains.Namespace, rerr error) {
+	var list []*Namespace
+	sess := dbtxn.GetTxn(ctx)
+	if err := sess.Find(&list); err != nil {
+		return nil, err
+	}
+	for _, ns := range list {
+		result = append(result, &domains.Namespace{
+			Name:        ns.Name,
+			Description: ns.Description,
+			Type:        ns.Type,
+			OwnerAppId:  ns.AppId,
+			TimeCreated: ns.TimeCreated,
+			TimeUpdated: ns.TimeUpdated,
+		})
+	}
+	return
+}
+
+func (c *CleanupRepositoryImpl) LoadAllApplications(ctx context.Context) (result []*domains.Application, rerr error) {
+	var list []*Application
+	sess := dbtxn.GetTxn(ctx)
+	if err := sess.Find(&list); err != nil {
+		return nil, err
+	}
+	for _, app := range list {
+		result = append(result, &domains.Application{
+			ApplicationId:          app.AppId,
+			ApplicationName:        app.AppName,
+			ApplicationDescription: app.AppDesc,
+			TimeCreated:            app.TimeCreated,
+			TimeUpdated:            app.TimeUpdated,
+		})
+	}
+	return
+}
+
+func (c *CleanupRepositoryImpl) LoadAllAppEnvAndDc(ctx context.Context) (r []struct {
+	AppId   int64
+	EnvName string
+	DcName  string
+}, rerr error) {
+	var list []*AppDetail
+	sess := dbtxn.GetTxn(ctx)
+	if err := sess.Find(&list); err != nil {
+		return nil, err
+	}
+	for _, detail := range list {
+		r = append(r, struct {
+			AppId   int64
+			EnvName string
+			DcName  string
+		}{AppId: detail.AppId, EnvName: detail.EnvName, DcName: detail.DcName})
+	}
+	return
+}
+
+func (c *CleanupRepositoryImpl) MarkConfigurationDeleted(ctx context.Context, cfgId int64) (bool, error) {
+	return markConfigurationDeleted(ctx, cfgId)
+}
+
+func markConfigurationDeleted(ctx context.Context, cfgId int64) (bool, error) {
+	sess := dbtxn.GetTxn(ctx)
+	now := time.Now()
+	// the same as the deletion of configure server, a new sequence makes the read servers drop the configuration
+	if r, err := sess.Exec("update configuration set cfg_status = 1, time_updated = ?, sequence = nextval('cfg_seq') where cfg_id = ? and cfg_status = 0 and pg_try_advisory_xact_lock(-1000)", now.UnixMilli(), cfgId); err != nil {
+		return false, err
+	} else {
+		if rowcnt, err := r.RowsAffected(); err != nil {
+			return false, err
+		} else if rowcnt == 0 {
+			return false, nil
+		} else {
+			return true, nil
+		}
+	}
+}
+
+func (c *CleanupRepositoryImpl) PurgeConfiguration(ctx context.Context, cfgId int64, deletedBefore int64) (bool, error) {
+	sess := dbtxn.GetTxn(ctx)
+	// the fetches recorded by the read servers go with the configuration
+	if _, err := sess.Exec("delete from configuration_fetch f using configuration c where c.cfg_id = ? and c.cfg_status = 1 and c.time_updated < ? and "+
+		"f.selectors = c.selectors and f.optional_selectors = c.optional_selectors and f.cfg_group = c.cfg_group and f.cfg_key = c.cfg_key", cfgId, deletedBefore); err != nil {
+		return false, err
+	}
+	if r, err := sess.Exec("delete from configuration where cfg_id = ? and cfg_status = 1 and time_updated < ?", cfgId, deletedBefore); err != nil {
+		return false, err
+	} else {
+		if rowcnt, err := r.RowsAffected(); err != nil {
+			return false, err
+		} else if rowcnt == 0 {
+			return false, nil
+		} else {
+			return true, nil
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/goodplayer/onlyconfig/secrets"
 	"github.com/goodplayer/onlyconfig/webmgr/domains"
 	"github.com/goodplayer/onlyconfig/webmgr/storage/dbtxn"
 )
@@ -37,6 +38,7 @@ type Application struct {
 	AppName       string `xorm:"'application_name'"`
 	AppDesc       string `xorm:"'application_description'"`
 	AppOwnerOrgId string `xorm:"'application_owner_org'"`
+	RecipientKey  string `xorm:"'application_recipient_key'"`
 	TimeCreated   int64  `xorm:"'time_created'"`
 	TimeUpdated   int64  `xorm:"'time_updated'"`
 }
@@ -83,6 +85,11 @@ type Configure struct {
 	ConfigStatus    int64  `xorm:"'config_status'"`
 	TimeCreated     int64  `xorm:"'time_created'"`
 	TimeUpdated     int64  `xorm:"'time_updated'"`
+
+	DraftContentType string `xorm:"'draft_content_type'"`
+	DraftContent     string `xorm:"'draft_content'"`
+	TimeDrafted      int64  `xorm:"'time_drafted'"`
+	Secret           bool   `xorm:"'config_secret'"`
 }
 
 func (u *Configure) TableName() string {
@@ -90,6 +97,49 @@ func (u *Configure) TableName() string {
 }
 
 type ConfigureStoreImpl struct {
+	// Keys encrypts the contents of the secret configures, secret configures are not supported if nil
+	Keys secrets.KeyProvider
+}
+
+// toDomainConfigure converts the configure with the contents decrypted
+func (c *ConfigureStoreImpl) toDomainConfigure(cfg *Configure) (*domains.Configure, error) {
+	content, err := openContent(c.Keys, cfg.Secret, cfg.Content)
+	if err != nil {
+		return nil, err
+	}
+	draftContent, err := openContent(c.Keys, cfg.Secret, cfg.DraftContent)
+	if err != nil {
+		return nil, err
+	}
+	return &domains.Configure{
+		ConfigId:        cfg.ConfigId,
+		ConfigKey:       cfg.ConfigKey,
+		ConfigNamespace: cfg.ConfigNamespace,
+		ConfigEnv:       cfg.ConfigEnv,
+		ConfigDc:        cfg.ConfigDc,
+		ContentType:     cfg.ContentType,
+		Content:         content,
+		ConfigVersion:   cfg.ConfigVersion,
+		ConfigStatus:    cfg.ConfigStatus,
+		TimeCreated:     cfg.TimeCreated,
+		TimeUpdated:     cfg.TimeUpdated,
+		Secret:          cfg.Secret,
+
+		DraftContentType: cfg.DraftContentType,
+		DraftContent:     draftContent,
+		TimeDrafted:      cfg.TimeDrafted,
+	}, nil
+}
+
+func (c *ConfigureStoreImpl) toDomainConfigures(list []*Configure) (result []*domains.Configure, rerr error) {
+	for _, cfg := range list {
+		v, err := c.toDomainConfigure(cfg)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, v)
+	}
+	return
 }
 
 func (c *ConfigureStoreImpl) AddDc(ctx context.Context, dc *domains.Datacenter) error {
@@ -190,6 +240,7 @@ func (c *ConfigureStoreImpl) LoadApplicationsByOrganizationId(ctx context.Contex
 			ApplicationName:              app.AppName,
 			ApplicationDescription:       app.AppDesc,
 			ApplicationOwnerOrganization: nil, //FIXME fill in the owner org
+			RecipientKey:                 app.RecipientKey,
 			TimeCreated:                  app.TimeCreated,
 			TimeUpdated:                  app.TimeUpdated,
 		})
@@ -242,6 +293,7 @@ func (c *ConfigureStoreImpl) LoadApplicationById(ctx context.Context, applicatio
 			ApplicationName:              app.AppName,
 			ApplicationDescription:       app.AppDesc,
 			ApplicationOwnerOrganization: nil, //FIXME fill in the owner org
+			RecipientKey:                 app.RecipientKey,
 			TimeCreated:                  app.TimeCreated,
 			TimeUpdated:                  app.TimeUpdated,
 		}, nil
@@ -357,22 +409,28 @@ func (c *ConfigureStoreImpl) LoadAppNamespaces(ctx context.Context, app *domains
 }
 
 func (c *ConfigureStoreImpl) AddConfiguration(ctx context.Context, cfg *domains.Configure) error {
+	content, err := sealContent(c.Keys, cfg.Secret, cfg.Content)
+	if err != nil {
+		return err
+	}
 	configure := &Configure{
 		ConfigKey:       cfg.ConfigKey,
 		ConfigNamespace: cfg.ConfigNamespace,
 		ConfigEnv:       cfg.ConfigEnv,
 		ConfigDc:        cfg.ConfigDc,
 		ContentType:     cfg.ContentType,
-		Content:         cfg.Content,
+		Content:         content,
 		ConfigStatus:    cfg.ConfigStatus,
 		ConfigVersion:   cfg.ConfigVersion,
 		TimeCreated:     cfg.TimeCreated,
 		TimeUpdated:     cfg.TimeUpdated,
+		Secret:          cfg.Secret,
 	}
 	sess := dbtxn.GetTxn(ctx)
 	if _, err := sess.Insert(configure); err != nil {
 		return err
 	}
+	cfg.ConfigId = configure.ConfigId
 	return nil
 }
 
@@ -394,6 +452,16 @@ func (c *ConfigureStoreImpl) LoadNamespace(ctx context.Context, nsName string) (
 	}, nil
 }
 
+func (c *ConfigureStoreImpl) ExistsNamespace(ctx context.Context, nsName string) (bool, error) {
+	ns := new(Namespace)
+	sess := dbtxn.GetTxn(ctx)
+	if has, err := sess.Where("namespace_name = ?", nsName).Get(ns); err != nil {
+		return false, err
+	} else {
+		return has, nil
+	}
+}
+
 func (c *ConfigureStoreImpl) ExistsConfigure(ctx context.Context, app *domains.Application, env *domains.Environment, dc *domains.Datacenter, ns *domains.Namespace, key string) (bool, error) {
 	cfg := new(Configure)
 	sess := dbtxn.GetTxn(ctx)
@@ -418,22 +486,7 @@ func (c *ConfigureStoreImpl) LoadAppConfigList(ctx context.Context, app *domains
 	if err := sess.Where("config_env = ? and config_datacenter = ?", env.EnvName, dc.DatacenterName).In("config_namespace", namespaceNames).Find(&list); err != nil {
 		return nil, err
 	}
-	for _, cfg := range list {
-		result = append(result, &domains.Configure{
-			ConfigId:        cfg.ConfigId,
-			ConfigKey:       cfg.ConfigKey,
-			ConfigNamespace: cfg.ConfigNamespace,
-			ConfigEnv:       cfg.ConfigEnv,
-			ConfigDc:        cfg.ConfigDc,
-			ContentType:     cfg.ContentType,
-			Content:         cfg.Content,
-			ConfigVersion:   cfg.ConfigVersion,
-			ConfigStatus:    cfg.ConfigStatus,
-			TimeCreated:     cfg.TimeCreated,
-			TimeUpdated:     cfg.TimeUpdated,
-		})
-	}
-	return
+	return c.toDomainConfigures(list)
 }
 
 func (c *ConfigureStoreImpl) LoadConfigureById(ctx context.Context, cfgId int64) (*domains.Configure, error) {
@@ -444,22 +497,14 @@ func (c *ConfigureStoreImpl) LoadConfigureById(ctx context.Context, cfgId int64)
 	} else if !has {
 		return nil, errors.New("config not found")
 	}
-	return &domains.Configure{
-		ConfigId:        cfg.ConfigId,
-		ConfigKey:       cfg.ConfigKey,
-		ConfigNamespace: cfg.ConfigNamespace,
-		ConfigEnv:       cfg.ConfigEnv,
-		ConfigDc:        cfg.ConfigDc,
-		ContentType:     cfg.ContentType,
-		Content:         cfg.Content,
-		ConfigVersion:   cfg.ConfigVersion,
-		ConfigStatus:    cfg.ConfigStatus,
-		TimeCreated:     cfg.TimeCreated,
-		TimeUpdated:     cfg.TimeUpdated,
-	}, nil
+	return c.toDomainConfigure(cfg)
 }
 
 func (c *ConfigureStoreImpl) UpdateConfiguration(ctx context.Context, cfg *domains.Configure) error {
+	content, err := sealContent(c.Keys, cfg.Secret, cfg.Content)
+	if err != nil {
+		return err
+	}
 	configure := &Configure{
 		ConfigId:        cfg.ConfigId,
 		ConfigKey:       cfg.ConfigKey,
@@ -467,11 +512,12 @@ func (c *ConfigureStoreImpl) UpdateConfiguration(ctx context.Context, cfg *domai
 		ConfigEnv:       cfg.ConfigEnv,
 		ConfigDc:        cfg.ConfigDc,
 		ContentType:     cfg.ContentType,
-		Content:         cfg.Content,
+		Content:         content,
 		ConfigStatus:    cfg.ConfigStatus,
 		ConfigVersion:   cfg.ConfigVersion,
 		TimeCreated:     cfg.TimeCreated,
 		TimeUpdated:     cfg.TimeUpdated,
+		Secret:          cfg.Secret,
 	}
 	sess := dbtxn.GetTxn(ctx)
 	if _, err := sess.ID(configure.ConfigId).Update(configure); err != nil {
@@ -480,6 +526,66 @@ func (c *ConfigureStoreImpl) UpdateConfiguration(ctx context.Context, cfg *domai
 	return nil
 }
 
+func (c *ConfigureStoreImpl) LockConfigure(ctx context.Context, cfgId int64) error {
+	sess := dbtxn.GetTxn(ctx)
+	if _, err := sess.Exec("select config_id from onlyconfig_config where config_id = ? for update", cfgId); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (c *ConfigureStoreImpl) UpdateApplication(ctx context.Context, app *domains.Application, prevTimeUpdated int64) (bool, error) {
+	sess := dbtxn.GetTxn(ctx)
+	affected, err := sess.Where("application_id = ? and time_updated = ?", app.ApplicationId, prevTimeUpdated).Cols("application_description", "time_updated").Update(&Application{
+		AppDesc:     app.ApplicationDescription,
+		TimeUpdated: app.TimeUpdated,
+	})
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func (c *ConfigureStoreImpl) UpdateApplicationRecipientKey(ctx context.Context, app *domains.Application, prevTimeUpdated int64) (bool, error) {
+	sess := dbtxn.GetTxn(ctx)
+	affected, err := sess.Where("application_id = ? and time_updated = ?", app.ApplicationId, prevTimeUpdated).Cols("application_recipient_key", "time_updated").Update(&Application{
+		RecipientKey: app.RecipientKey,
+		TimeUpdated:  app.TimeUpdated,
+	})
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func (c *ConfigureStoreImpl) UpdateNamespace(ctx context.Context, ns *domains.Namespace, prevTimeUpdated int64) (bool, error) {
+	sess := dbtxn.GetTxn(ctx)
+	affected, err := sess.Where("namespace_name = ? and time_updated = ?", ns.Name, prevTimeUpdated).Cols("namespace_description", "time_updated").Update(&Namespace{
+		Description: ns.Description,
+		TimeUpdated: ns.TimeUpdated,
+	})
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func (c *ConfigureStoreImpl) UpdateConfigureDraft(ctx context.Context, cfg *domains.Configure) error {
+	draftContent, err := sealContent(c.Keys, cfg.Secret, cfg.DraftContent)
+	if err != nil {
+		return err
+	}
+	sess := dbtxn.GetTxn(ctx)
+	if _, err := sess.ID(cfg.ConfigId).Cols("draft_content_type", "draft_content", "time_drafted").Update(&Configure{
+		DraftContentType: cfg.DraftContentType,
+		DraftContent:     draftContent,
+		TimeDrafted:      cfg.TimeDrafted,
+	}); err != nil {
+		return err
+	}
+	return nil
+}
+
 func (c *ConfigureStoreImpl) NextConfigVersionSeq(ctx context.Context) (int64, error) {
 	sess := dbtxn.GetTxn(ctx)
 	result, err := sess.QueryString(`select nextval('onlyconfig_version_seq') as seq`)
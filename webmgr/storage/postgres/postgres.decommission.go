@@ -0,0 +1,235 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"github.com/goodplayer/onlyconfig/webmgr/domains"
+	"github.com/goodplayer/onlyconfig/webmgr/storage/dbtxn"
+)
+
+type Decommission struct {
+	EntityType  string `xorm:"'entity_type' pk"`
+	EntityName  string `xorm:"'entity_name' pk"`
+	TimeCreated int64  `xorm:"'time_created'"`
+	TimeUpdated int64  `xorm:"'time_updated'"`
+}
+
+func (d *Decommission) TableName() string {
+	return "onlyconfig_decommission"
+}
+
+type AppNsLink struct {
+	MappingId   int64  `xorm:"'mapping_id' pk autoincr"`
+	AppId       int64  `xorm:"'application_id'"`
+	Namespace   string `xorm:"'namespace_name'"`
+	TimeCreated int64  `xorm:"'time_created'"`
+	TimeUpdated int64  `xorm:"'time_updated'"`
+}
+
+func (a *AppNsLink) TableName() string {
+	return "onlyconfig_app_ns_link"
+}
+
+func (c *ConfigureStoreImpl) LoadApplicationByName(ctx context.Context, appName string) (*domains.Application, error) {
+	app := new(Application)
+	sess := dbtxn.GetTxn(ctx)
+	if has, err := sess.Where("application_name = ?", appName).Get(app); err != nil {
+		return nil, err
+	} else if !has {
+		return nil, errors.New("application not found")
+	}
+	return &domains.Application{
+		ApplicationId:          app.AppId,
+		ApplicationName:        app.AppName,
+		ApplicationDescription: app.AppDesc,
+		RecipientKey:           app.RecipientKey,
+		TimeCreated:            app.TimeCreated,
+		TimeUpdated:            app.TimeUpdated,
+	}, nil
+}
+
+func (c *ConfigureStoreImpl) LoadConfiguresByNamespace(ctx context.Context, nsName string) ([]*domains.Configure, error) {
+	var list []*Configure
+	sess := dbtxn.GetTxn(ctx)
+	if err := sess.Where("config_namespace = ?", nsName).OrderBy("config_id").Find(&list); err != nil {
+		return nil, err
+	}
+	return c.toDomainConfigures(list)
+}
+
+func (c *ConfigureStoreImpl) LoadConfiguresByEnvironment(ctx context.Context, env string) ([]*domains.Configure, error) {
+	var list []*Configure
+	sess := dbtxn.GetTxn(ctx)
+	if err := sess.Where("config_env = ?", env).OrderBy("config_id").Find(&list); err != nil {
+		return nil, err
+	}
+	return c.toDomainConfigures(list)
+}
+
+func (c *ConfigureStoreImpl) LoadConfiguresByDatacenter(ctx context.Context, dc string) ([]*domains.Configure, error) {
+	var list []*Configure
+	sess := dbtxn.GetTxn(ctx)
+	if err := sess.Where("config_datacenter = ?", dc).OrderBy("config_id").Find(&list); err != nil {
+		return nil, err
+	}
+	return c.toDomainConfigures(list)
+}
+
+func (c *ConfigureStoreImpl) LoadAppIdsByEnvironment(ctx context.Context, env string) (result []int64, rerr error) {
+	var list []*AppDetail
+	sess := dbtxn.GetTxn(ctx)
+	if err := sess.Where("env_name = ?", env).Find(&list); err != nil {
+		return nil, err
+	}
+	for _, detail := range list {
+		result = append(result, detail.AppId)
+	}
+	return
+}
+
+func (c *ConfigureStoreImpl) LoadAppIdsByDatacenter(ctx context.Context, dc string) (result []int64, rerr error) {
+	var list []*AppDetail
+	sess := dbtxn.GetTxn(ctx)
+	if err := sess.Where("datacenter_name = ?", dc).Find(&list); err != nil {
+		return nil, err
+	}
+	for _, detail := range list {
+		result = append(result, detail.AppId)
+	}
+	return
+}
+
+func (c *ConfigureStoreImpl) LoadAppIdsByNamespace(ctx context.Context, nsName string) (result []int64, rerr error) {
+	var list []*AppNsLink
+	sess := dbtxn.GetTxn(ctx)
+	if err := sess.Where("namespace_name = ?", nsName).Find(&list); err != nil {
+		return nil, err
+	}
+	for _, link := range list {
+		result = append(result, link.AppId)
+	}
+	return
+}
+
+func (c *ConfigureStoreImpl) LoadDecommissions(ctx context.Context) (result []*domains.Decommission, rerr error) {
+	var list []*Decommission
+	sess := dbtxn.GetTxn(ctx)
+	if err := sess.Find(&list); err != nil {
+		return nil, err
+	}
+	for _, d := range list {
+		result = append(result, &domains.Decommission{
+			EntityType:  d.EntityType,
+			EntityName:  d.EntityName,
+			TimeOffline: d.TimeCreated,
+		})
+	}
+	return
+}
+
+func (c *ConfigureStoreImpl) AddDecommission(ctx context.Context, d *domains.Decommission) error {
+	sess := dbtxn.GetTxn(ctx)
+	if _, err := sess.Insert(&Decommission{
+		EntityType:  d.EntityType,
+		EntityName:  d.EntityName,
+		TimeCreated: d.TimeOffline,
+		TimeUpdated: d.TimeOffline,
+	}); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (c *ConfigureStoreImpl) RemoveDecommission(ctx context.Context, entityType, entityName string) error {
+	sess := dbtxn.GetTxn(ctx)
+	if _, err := sess.Where("entity_type = ? and entity_name = ?", entityType, entityName).Delete(new(Decommission)); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (c *ConfigureStoreImpl) DeleteConfigures(ctx context.Context, cfgIds []int64) error {
+	if len(cfgIds) == 0 {
+		return nil
+	}
+	sess := dbtxn.GetTxn(ctx)
+	if _, err := sess.In("config_id", cfgIds).Delete(new(ConfigureHistory)); err != nil {
+		return err
+	}
+	if _, err := sess.In("config_id", cfgIds).Delete(new(ReleaseItem)); err != nil {
+		return err
+	}
+	if _, err := sess.In("config_id", cfgIds).Delete(new(ScheduleItem)); err != nil {
+		return err
+	}
+	if _, err := sess.In("config_id", cfgIds).Delete(new(Configure)); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (c *ConfigureStoreImpl) DeleteNamespace(ctx context.Context, nsName string) error {
+	sess := dbtxn.GetTxn(ctx)
+	if _, err := sess.Where("namespace_name = ?", nsName).Delete(new(AppNsLink)); err != nil {
+		return err
+	}
+	if _, err := sess.Where("namespace_name = ?", nsName).Delete(new(Namespace)); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (c *ConfigureStoreImpl) DeleteApplication(ctx context.Context, app *domains.Application) error {
+	sess := dbtxn.GetTxn(ctx)
+	if _, err := sess.Where("application_id = ?", app.ApplicationId).Delete(new(AppNsLink)); err != nil {
+		return err
+	}
+	if _, err := sess.Where("application_id = ?", app.ApplicationId).Delete(new(AppDetail)); err != nil {
+		return err
+	}
+	if _, err := sess.Where("release_id in (select release_id from onlyconfig_release where application_id = ?)", app.ApplicationId).Delete(new(ReleaseItem)); err != nil {
+		return err
+	}
+	if _, err := sess.Where("application_id = ?", app.ApplicationId).Delete(new(Release)); err != nil {
+		return err
+	}
+	if _, err := sess.Where("schedule_id in (select schedule_id from onlyconfig_schedule where application_id = ?)", app.ApplicationId).Delete(new(ScheduleItem)); err != nil {
+		return err
+	}
+	if _, err := sess.Where("application_id = ?", app.ApplicationId).Delete(new(Schedule)); err != nil {
+		return err
+	}
+	if _, err := sess.Where("webhook_id in (select webhook_id from onlyconfig_webhook where application_id = ?)", app.ApplicationId).Delete(new(WebhookDelivery)); err != nil {
+		return err
+	}
+	if _, err := sess.Where("application_id = ?", app.ApplicationId).Delete(new(Webhook)); err != nil {
+		return err
+	}
+	if _, err := sess.Where("application_id = ?", app.ApplicationId).Delete(new(Application)); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (c *ConfigureStoreImpl) DeleteEnvironment(ctx context.Context, env string) error {
+	sess := dbtxn.GetTxn(ctx)
+	if _, err := sess.Where("env_name = ?", env).Delete(new(AppDetail)); err != nil {
+		return err
+	}
+	if _, err := sess.Where("env_name = ?", env).Delete(new(Environment)); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (c *ConfigureStoreImpl) DeleteDatacenter(ctx context.Context, dc string) error {
+	sess := dbtxn.GetTxn(ctx)
+	if _, err := sess.Where("datacenter_name = ?", dc).Delete(new(AppDetail)); err != nil {
+		return err
+	}
+	if _, err := sess.Where("datacenter_name = ?", dc).Delete(new(Datacenter)); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/goodplayer/onlyconfig/secrets"
+	"github.com/goodplayer/onlyconfig/webmgr/domains"
+	"github.com/goodplayer/onlyconfig/webmgr/storage/dbtxn"
+)
+
+type ConfigureHistory struct {
+	HistoryId     int64  `xorm:"'history_id' pk autoincr"`
+	ConfigId      int64  `xorm:"'config_id'"`
+	ConfigVersion string `xorm:"'config_version'"`
+	ContentType   string `xorm:"'config_content_type'"`
+	Content       string `xorm:"'config_content'"`
+	ReleaseId     int64  `xorm:"'release_id'"`
+	TimeCreated   int64  `xorm:"'time_created'"`
+	Secret        bool   `xorm:"'config_secret'"`
+}
+
+func (h *ConfigureHistory) TableName() string {
+	return "onlyconfig_config_history"
+}
+
+func (h *ConfigureHistory) toDomain(keys secrets.KeyProvider) (*domains.ConfigureHistory, error) {
+	content, err := openContent(keys, h.Secret, h.Content)
+	if err != nil {
+		return nil, err
+	}
+	return &domains.ConfigureHistory{
+		HistoryId:     h.HistoryId,
+		ConfigId:      h.ConfigId,
+		ConfigVersion: h.ConfigVersion,
+		ContentType:   h.ContentType,
+		Content:       content,
+		ReleaseId:     h.ReleaseId,
+		TimeCreated:   h.TimeCreated,
+		Secret:        h.Secret,
+	}, nil
+}
+
+func (c *ConfigureStoreImpl) AddConfigureHistory(ctx context.Context, h *domains.ConfigureHistory) error {
+	content, err := sealContent(c.Keys, h.Secret, h.Content)
+	if err != nil {
+		return err
+	}
+	history := &ConfigureHistory{
+		ConfigId:      h.ConfigId,
+		ConfigVersion: h.ConfigVersion,
+		ContentType:   h.ContentType,
+		Content:       content,
+		ReleaseId:     h.ReleaseId,
+		TimeCreated:   h.TimeCreated,
+		Secret:        h.Secret,
+	}
+	sess := dbtxn.GetTxn(ctx)
+	if _, err := sess.Insert(history); err != nil {
+		return err
+	}
+	h.HistoryId = history.HistoryId
+	return nil
+}
+
+func (c *ConfigureStoreImpl) LoadConfigureHistoryList(ctx context.Context, cfgId int64) (result []*domains.ConfigureHistory, rerr error) {
+	var list []*ConfigureHistory
+	sess := dbtxn.GetTxn(ctx)
+	if err := sess.Where("config_id = ?", cfgId).Desc("history_id").Find(&list); err != nil {
+		return nil, err
+	}
+	for _, h := range list {
+		v, err := h.toDomain(c.Keys)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, v)
+	}
+	return
+}
+
+func (c *ConfigureStoreImpl) LoadConfigureHistory(ctx context.Context, cfgId int64, version string) (*domains.ConfigureHistory, error) {
+	h := new(ConfigureHistory)
+	sess := dbtxn.GetTxn(ctx)
+	if has, err := sess.Where("config_id = ? and config_version = ?", cfgId, version).Get(h); err != nil {
+		return nil, err
+	} else if !has {
+		return nil, nil
+	}
+	return h.toDomain(c.Keys)
+}
+
+func (c *ConfigureStoreImpl) EncryptConfigureHistory(ctx context.Context, cfgId int64) error {
+	var list []*ConfigureHistory
+	sess := dbtxn.GetTxn(ctx)
+	if err := sess.Where("config_id = ? and config_secret = false", cfgId).Find(&list); err != nil {
+		return err
+	}
+	for _, h := range list {
+		content, err := sealContent(c.Keys, true, h.Content)
+		if err != nil {
+			return err
+		}
+		if _, err := sess.ID(h.HistoryId).Cols("config_content", "config_secret").Update(&ConfigureHistory{Content: content, Secret: true}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/fxamacker/cbor/v2"
 	"github.com/meidoworks/nekoq-component/configure/configapi"
 
+	"github.com/goodplayer/onlyconfig/secrets"
 	"github.com/goodplayer/onlyconfig/webmgr/domains"
 	"github.com/goodplayer/onlyconfig/webmgr/storage/dbtxn"
 )
@@ -26,13 +27,51 @@ type Configuration struct {
 	TimeCreated       int64  `xorm:"'time_created'"`
 	TimeUpdated       int64  `xorm:"'time_updated'"`
 	Sequence          int64  `xorm:"'sequence'"`
+	// ConfigSecret tells the read server the value is secret, see configurationValue
+	ConfigSecret bool `xorm:"'cfg_secret'"`
 }
 
 func (c *Configuration) TableName() string {
 	return "configuration"
 }
 
-type PushChangeRepositoryImpl struct{}
+// ConfigurationFetch is saved by the read servers, see datapump.PostgresFetchStore
+type ConfigurationFetch struct {
+	Selectors         string `xorm:"'selectors' pk"`
+	OptionalSelectors string `xorm:"'optional_selectors' pk"`
+	ConfigGroup       string `xorm:"'cfg_group' pk"`
+	ConfigKey         string `xorm:"'cfg_key' pk"`
+	TimeFetched       int64  `xorm:"'time_fetched'"`
+}
+
+func (c *ConfigurationFetch) TableName() string {
+	return "configuration_fetch"
+}
+
+type PushChangeRepositoryImpl struct {
+	// Keys encrypts the values of the secret configures, which are decrypted by the read server
+	Keys secrets.KeyProvider
+}
+
+// configurationValue returns the value pushed to the clients if secret: sealed for the recipient key of the application
+// which is opened only by the clients, otherwise the envelope of the content decrypted by the read server
+func (p *PushChangeRepositoryImpl) configurationValue(cfg *domains.Configure, app *domains.Application) ([]byte, error) {
+	if !cfg.Secret {
+		return []byte(cfg.Content), nil
+	}
+	if app.RecipientKey != "" {
+		sealed, err := secrets.SealFor(app.RecipientKey, []byte(cfg.Content))
+		if err != nil {
+			return nil, err
+		}
+		return []byte(sealed), nil
+	}
+	envelope, err := secrets.Encrypt(p.Keys, []byte(cfg.Content))
+	if err != nil {
+		return nil, err
+	}
+	return []byte(envelope), nil
+}
 
 func (p *PushChangeRepositoryImpl) getSelectorsString(cfg *domains.Configure, app *domains.Application) string {
 	return cfg.GenerateSelectorsString(app)
@@ -63,13 +102,17 @@ func (p *PushChangeRepositoryImpl) ExistsConfiguration(ctx context.Context, cfg
 func (p *PushChangeRepositoryImpl) InsertNewConfigure(ctx context.Context, cfg *domains.Configure, app *domains.Application) (int64, error) {
 	sess := dbtxn.GetTxn(ctx)
 
+	value, err := p.configurationValue(cfg, app)
+	if err != nil {
+		return 0, err
+	}
 	now := time.Now()
-	sig := sha256.Sum256([]byte(cfg.Content))
+	sig := sha256.Sum256(value)
 	apiCfg := &configapi.Configuration{
 		Group:             cfg.ConfigNamespace,
 		Key:               cfg.ConfigKey,
 		Version:           cfg.ConfigVersion,
-		Value:             []byte(cfg.Content),
+		Value:             value,
 		Signature:         "sha256:" + hex.EncodeToString(sig[:]),
 		Selectors:         p.getSelectors(cfg, app),
 		OptionalSelectors: p.getOptSelectors(cfg, app),
@@ -90,6 +133,7 @@ func (p *PushChangeRepositoryImpl) InsertNewConfigure(ctx context.Context, cfg *
 		TimeCreated:       now.UnixMilli(),
 		TimeUpdated:       now.UnixMilli(),
 		Sequence:          0,
+		ConfigSecret:      cfg.Secret,
 	}
 	if _, err := sess.Insert(configuration); err != nil {
 		return 0, err
@@ -108,13 +152,17 @@ func (p *PushChangeRepositoryImpl) UpdateConfigure(ctx context.Context, cfg *dom
 		return false, errors.New("configuration not found")
 	}
 
+	value, err := p.configurationValue(cfg, app)
+	if err != nil {
+		return false, err
+	}
 	now := time.Now()
-	sig := sha256.Sum256([]byte(cfg.Content))
+	sig := sha256.Sum256(value)
 	apiCfg := &configapi.Configuration{
 		Group:             cfg.ConfigNamespace,
 		Key:               cfg.ConfigKey,
 		Version:           cfg.ConfigVersion,
-		Value:             []byte(cfg.Content),
+		Value:             value,
 		Signature:         "sha256:" + hex.EncodeToString(sig[:]),
 		Selectors:         p.getSelectors(cfg, app),
 		OptionalSelectors: p.getOptSelectors(cfg, app),
@@ -124,7 +172,7 @@ func (p *PushChangeRepositoryImpl) UpdateConfigure(ctx context.Context, cfg *dom
 	if err != nil {
 		return false, err
 	}
-	if r, err := sess.Exec("update configuration set cfg_version = ?, raw_cfg_value = ?, time_updated = ?, sequence = nextval('cfg_seq') where cfg_id = ? and pg_try_advisory_xact_lock(-1000)", cfg.ConfigVersion, data, now.UnixMilli(), configuration.ConfigId); err != nil {
+	if r, err := sess.Exec("update configuration set cfg_version = ?, raw_cfg_value = ?, cfg_status = 0, time_updated = ?, sequence = nextval('cfg_seq'), cfg_secret = ? where cfg_id = ? and pg_try_advisory_xact_lock(-1000)", cfg.ConfigVersion, data, now.UnixMilli(), cfg.Secret, configuration.ConfigId); err != nil {
 		return false, err
 	} else {
 		if rowcnt, err := r.RowsAffected(); err != nil {
@@ -152,3 +200,39 @@ func (p *PushChangeRepositoryImpl) UpdateConfigurationSequence(ctx context.Conte
 		}
 	}
 }
+
+func (p *PushChangeRepositoryImpl) LoadPublishedConfiguration(ctx context.Context, cfg *domains.Configure, app *domains.Application) (*domains.PublishedConfiguration, error) {
+	configuration := new(Configuration)
+	sess := dbtxn.GetTxn(ctx)
+	// raw_cfg_value is not required
+	if has, err := sess.Cols("cfg_id", "selectors", "optional_selectors", "cfg_group", "cfg_key", "cfg_version", "cfg_status", "time_updated").Where("selectors = ? and optional_selectors = ? and cfg_group = ? and cfg_key = ?", p.getSelectorsString(cfg, app), p.getOptSelectorsString(cfg, app), cfg.ConfigNamespace, cfg.ConfigKey).Get(configuration); err != nil {
+		return nil, err
+	} else if !has {
+		return nil, nil
+	}
+	return &domains.PublishedConfiguration{
+		ConfigId:          configuration.ConfigId,
+		Selectors:         configuration.Selectors,
+		OptionalSelectors: configuration.OptionalSelectors,
+		Group:             configuration.ConfigGroup,
+		Key:               configuration.ConfigKey,
+		Version:           configuration.ConfigVersion,
+		Status:            configuration.ConfigStatus,
+		TimeUpdated:       configuration.TimeUpdated,
+	}, nil
+}
+
+func (p *PushChangeRepositoryImpl) LoadFetchTime(ctx context.Context, published *domains.PublishedConfiguration) (int64, error) {
+	fetch := new(ConfigurationFetch)
+	sess := dbtxn.GetTxn(ctx)
+	if has, err := sess.Where("selectors = ? and optional_selectors = ? and cfg_group = ? and cfg_key = ?", published.Selectors, published.OptionalSelectors, published.Group, published.Key).Get(fetch); err != nil {
+		return 0, err
+	} else if !has {
+		return 0, nil
+	}
+	return fetch.TimeFetched, nil
+}
+
+func (p *PushChangeRepositoryImpl) MarkConfigurationDeleted(ctx context.Context, configId int64) (bool, error) {
+	return markConfigurationDeleted(ctx, configId)
+}
@@ -0,0 +1,153 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/goodplayer/onlyconfig/webmgr/domains"
+	"github.com/goodplayer/onlyconfig/webmgr/storage/dbtxn"
+)
+
+type Release struct {
+	ReleaseId      int64  `xorm:"'release_id' pk autoincr"`
+	AppId          int64  `xorm:"'application_id'"`
+	EnvName        string `xorm:"'env_name'"`
+	DcName         string `xorm:"'datacenter_name'"`
+	ReleaseType    string `xorm:"'release_type'"`
+	ReleaseVersion string `xorm:"'release_version'"`
+	RollbackOf     int64  `xorm:"'rollback_of'"`
+	RolledBackBy   int64  `xorm:"'rolled_back_by'"`
+	TimeCreated    int64  `xorm:"'time_created'"`
+	TimeUpdated    int64  `xorm:"'time_updated'"`
+}
+
+func (r *Release) TableName() string {
+	return "onlyconfig_release"
+}
+
+type ReleaseItem struct {
+	ReleaseItemId   int64  `xorm:"'release_item_id' pk autoincr"`
+	ReleaseId       int64  `xorm:"'release_id'"`
+	ConfigId        int64  `xorm:"'config_id'"`
+	ConfigNamespace string `xorm:"'config_namespace'"`
+	ConfigKey       string `xorm:"'config_key'"`
+	FromVersion     string `xorm:"'from_version'"`
+	ToVersion       string `xorm:"'to_version'"`
+}
+
+func (r *ReleaseItem) TableName() string {
+	return "onlyconfig_release_item"
+}
+
+func (r *Release) toDomain(items []*ReleaseItem) *domains.Release {
+	release := &domains.Release{
+		ReleaseId:      r.ReleaseId,
+		AppId:          r.AppId,
+		Env:            r.EnvName,
+		Dc:             r.DcName,
+		ReleaseType:    r.ReleaseType,
+		ReleaseVersion: r.ReleaseVersion,
+		RollbackOf:     r.RollbackOf,
+		RolledBackBy:   r.RolledBackBy,
+		TimeCreated:    r.TimeCreated,
+		TimeUpdated:    r.TimeUpdated,
+	}
+	for _, item := range items {
+		release.Items = append(release.Items, &domains.ReleaseItem{
+			ConfigId:        item.ConfigId,
+			ConfigNamespace: item.ConfigNamespace,
+			ConfigKey:       item.ConfigKey,
+			FromVersion:     item.FromVersion,
+			ToVersion:       item.ToVersion,
+		})
+	}
+	return release
+}
+
+func (c *ConfigureStoreImpl) AddRelease(ctx context.Context, release *domains.Release) error {
+	r := &Release{
+		AppId:          release.AppId,
+		EnvName:        release.Env,
+		DcName:         release.Dc,
+		ReleaseType:    release.ReleaseType,
+		ReleaseVersion: release.ReleaseVersion,
+		RollbackOf:     release.RollbackOf,
+		RolledBackBy:   release.RolledBackBy,
+		TimeCreated:    release.TimeCreated,
+		TimeUpdated:    release.TimeUpdated,
+	}
+	sess := dbtxn.GetTxn(ctx)
+	if _, err := sess.Insert(r); err != nil {
+		return err
+	}
+	var items []*ReleaseItem
+	for _, item := range release.Items {
+		items = append(items, &ReleaseItem{
+			ReleaseId:       r.ReleaseId,
+			ConfigId:        item.ConfigId,
+			ConfigNamespace: item.ConfigNamespace,
+			ConfigKey:       item.ConfigKey,
+			FromVersion:     item.FromVersion,
+			ToVersion:       item.ToVersion,
+		})
+	}
+	if len(items) > 0 {
+		if _, err := sess.Insert(&items); err != nil {
+			return err
+		}
+	}
+	release.ReleaseId = r.ReleaseId
+	return nil
+}
+
+func (c *ConfigureStoreImpl) LoadReleases(ctx context.Context, app *domains.Application, env, dc string) (result []*domains.Release, rerr error) {
+	var list []*Release
+	sess := dbtxn.GetTxn(ctx)
+	if err := sess.Where("application_id = ? and env_name = ? and datacenter_name = ?", app.ApplicationId, env, dc).Desc("release_id").Find(&list); err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, nil
+	}
+	var ids []int64
+	for _, r := range list {
+		ids = append(ids, r.ReleaseId)
+	}
+	var items []*ReleaseItem
+	if err := sess.In("release_id", ids).Asc("release_item_id").Find(&items); err != nil {
+		return nil, err
+	}
+	itemMap := map[int64][]*ReleaseItem{}
+	for _, item := range items {
+		itemMap[item.ReleaseId] = append(itemMap[item.ReleaseId], item)
+	}
+	for _, r := range list {
+		result = append(result, r.toDomain(itemMap[r.ReleaseId]))
+	}
+	return
+}
+
+func (c *ConfigureStoreImpl) LoadRelease(ctx context.Context, releaseId int64) (*domains.Release, error) {
+	r := new(Release)
+	sess := dbtxn.GetTxn(ctx)
+	if has, err := sess.Where("release_id = ?", releaseId).Get(r); err != nil {
+		return nil, err
+	} else if !has {
+		return nil, nil
+	}
+	var items []*ReleaseItem
+	if err := sess.Where("release_id = ?", releaseId).Asc("release_item_id").Find(&items); err != nil {
+		return nil, err
+	}
+	return r.toDomain(items), nil
+}
+
+func (c *ConfigureStoreImpl) UpdateReleaseRolledBack(ctx context.Context, release *domains.Release) error {
+	sess := dbtxn.GetTxn(ctx)
+	if _, err := sess.ID(release.ReleaseId).Cols("rolled_back_by", "time_updated").Update(&Release{
+		RolledBackBy: release.RolledBackBy,
+		TimeUpdated:  release.TimeUpdated,
+	}); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/goodplayer/onlyconfig/secrets"
+	"github.com/goodplayer/onlyconfig/webmgr/domains"
+	"github.com/goodplayer/onlyconfig/webmgr/storage/dbtxn"
+)
+
+// scheduleLockKey is the advisory lock of the schedules, different from -1000 of the configuration sequence
+const scheduleLockKey = -1001
+
+type Schedule struct {
+	ScheduleId      int64  `xorm:"'schedule_id' pk autoincr"`
+	AppId           int64  `xorm:"'application_id'"`
+	EnvName         string `xorm:"'env_name'"`
+	DcName          string `xorm:"'datacenter_name'"`
+	Status          string `xorm:"'status'"`
+	TimePublish     int64  `xorm:"'time_publish'"`
+	RevertAfter     int64  `xorm:"'revert_after'"`
+	TimeRevert      int64  `xorm:"'time_revert'"`
+	ReleaseId       int64  `xorm:"'release_id'"`
+	RevertReleaseId int64  `xorm:"'revert_release_id'"`
+	Message         string `xorm:"'message'"`
+	TimeCreated     int64  `xorm:"'time_created'"`
+	TimeUpdated     int64  `xorm:"'time_updated'"`
+}
+
+func (s *Schedule) TableName() string {
+	return "onlyconfig_schedule"
+}
+
+type ScheduleItem struct {
+	ScheduleItemId  int64  `xorm:"'schedule_item_id' pk autoincr"`
+	ScheduleId      int64  `xorm:"'schedule_id'"`
+	ConfigId        int64  `xorm:"'config_id'"`
+	ConfigNamespace string `xorm:"'config_namespace'"`
+	ConfigKey       string `xorm:"'config_key'"`
+	FromVersion     string `xorm:"'from_version'"`
+	ContentType     string `xorm:"'config_content_type'"`
+	Content         string `xorm:"'config_content'"`
+	Secret          bool   `xorm:"'config_secret'"`
+}
+
+func (s *ScheduleItem) TableName() string {
+	return "onlyconfig_schedule_item"
+}
+
+func (s *Schedule) toDomain(keys secrets.KeyProvider, items []*ScheduleItem) (*domains.Schedule, error) {
+	schedule := &domains.Schedule{
+		ScheduleId:      s.ScheduleId,
+		AppId:           s.AppId,
+		Env:             s.EnvName,
+		Dc:              s.DcName,
+		Status:          s.Status,
+		TimePublish:     s.TimePublish,
+		RevertAfter:     s.RevertAfter,
+		TimeRevert:      s.TimeRevert,
+		ReleaseId:       s.ReleaseId,
+		RevertReleaseId: s.RevertReleaseId,
+		Message:         s.Message,
+		TimeCreated:     s.TimeCreated,
+		TimeUpdated:     s.TimeUpdated,
+	}
+	for _, item := range items {
+		content, err := openContent(keys, item.Secret, item.Content)
+		if err != nil {
+			return nil, err
+		}
+		schedule.Items = append(schedule.Items, &domains.ScheduleItem{
+			ConfigId:        item.ConfigId,
+			ConfigNamespace: item.ConfigNamespace,
+			ConfigKey:       item.ConfigKey,
+			FromVersion:     item.FromVersion,
+			ContentType:     item.ContentType,
+			Content:         content,
+			Secret:          item.Secret,
+		})
+	}
+	return schedule, nil
+}
+
+func (c *ConfigureStoreImpl) LockSchedules(ctx context.Context, wait bool) (bool, error) {
+	sess := dbtxn.GetTxn(ctx)
+	if wait {
+		if _, err := sess.Exec("select pg_advisory_xact_lock(?)", scheduleLockKey); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+	var locked bool
+	if _, err := sess.SQL("select pg_try_advisory_xact_lock(?)", scheduleLockKey).Get(&locked); err != nil {
+		return false, err
+	}
+	return locked, nil
+}
+
+func (c *ConfigureStoreImpl) AddSchedule(ctx context.Context, schedule *domains.Schedule) error {
+	s := &Schedule{
+		AppId:       schedule.AppId,
+		EnvName:     schedule.Env,
+		DcName:      schedule.Dc,
+		Status:      schedule.Status,
+		TimePublish: schedule.TimePublish,
+		RevertAfter: schedule.RevertAfter,
+		TimeCreated: schedule.TimeCreated,
+		TimeUpdated: schedule.TimeUpdated,
+	}
+	sess := dbtxn.GetTxn(ctx)
+	if _, err := sess.Insert(s); err != nil {
+		return err
+	}
+	var items []*ScheduleItem
+	for _, item := range schedule.Items {
+		content, err := sealContent(c.Keys, item.Secret, item.Content)
+		if err != nil {
+			return err
+		}
+		items = append(items, &ScheduleItem{
+			ScheduleId:      s.ScheduleId,
+			ConfigId:        item.ConfigId,
+			ConfigNamespace: item.ConfigNamespace,
+			ConfigKey:       item.ConfigKey,
+			FromVersion:     item.FromVersion,
+			ContentType:     item.ContentType,
+			Content:         content,
+			Secret:          item.Secret,
+		})
+	}
+	if len(items) > 0 {
+		if _, err := sess.Insert(&items); err != nil {
+			return err
+		}
+	}
+	schedule.ScheduleId = s.ScheduleId
+	return nil
+}
+
+func (c *ConfigureStoreImpl) LoadSchedules(ctx context.Context, app *domains.Application, env, dc string) (result []*domains.Schedule, rerr error) {
+	var list []*Schedule
+	sess := dbtxn.GetTxn(ctx)
+	if err := sess.Where("application_id = ? and env_name = ? and datacenter_name = ?", app.ApplicationId, env, dc).Desc("schedule_id").Find(&list); err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, nil
+	}
+	var ids []int64
+	for _, s := range list {
+		ids = append(ids, s.ScheduleId)
+	}
+	var items []*ScheduleItem
+	if err := sess.In("schedule_id", ids).Asc("schedule_item_id").Find(&items); err != nil {
+		return nil, err
+	}
+	itemMap := map[int64][]*ScheduleItem{}
+	for _, item := range items {
+		itemMap[item.ScheduleId] = append(itemMap[item.ScheduleId], item)
+	}
+	for _, s := range list {
+		v, err := s.toDomain(c.Keys, itemMap[s.ScheduleId])
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, v)
+	}
+	return
+}
+
+func (c *ConfigureStoreImpl) LoadSchedule(ctx context.Context, scheduleId int64) (*domains.Schedule, error) {
+	return c.loadSchedule(ctx, "schedule_id = ?", scheduleId)
+}
+
+func (c *ConfigureStoreImpl) LoadDueSchedule(ctx context.Context, now int64) (*domains.Schedule, error) {
+	return c.loadSchedule(ctx, "(status = ? and time_publish <= ?) or (status = ? and time_revert > 0 and time_revert <= ?)",
+		domains.ScheduleStatusPending, now, domains.ScheduleStatusPublished, now)
+}
+
+// loadSchedule loads the first schedule matching the condition with the items, nil if not found
+func (c *ConfigureStoreImpl) loadSchedule(ctx context.Context, query string, args ...any) (*domains.Schedule, error) {
+	s := new(Schedule)
+	sess := dbtxn.GetTxn(ctx)
+	if has, err := sess.Where(query, args...).Asc("schedule_id").Get(s); err != nil {
+		return nil, err
+	} else if !has {
+		return nil, nil
+	}
+	var items []*ScheduleItem
+	if err := sess.Where("schedule_id = ?", s.ScheduleId).Asc("schedule_item_id").Find(&items); err != nil {
+		return nil, err
+	}
+	return s.toDomain(c.Keys, items)
+}
+
+func (c *ConfigureStoreImpl) UpdateSchedule(ctx context.Context, schedule *domains.Schedule) error {
+	sess := dbtxn.GetTxn(ctx)
+	if _, err := sess.ID(schedule.ScheduleId).Cols("status", "time_revert", "release_id", "revert_release_id", "message", "time_updated").Update(&Schedule{
+		Status:          schedule.Status,
+		TimeRevert:      schedule.TimeRevert,
+		ReleaseId:       schedule.ReleaseId,
+		RevertReleaseId: schedule.RevertReleaseId,
+		Message:         schedule.Message,
+		TimeUpdated:     schedule.TimeUpdated,
+	}); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/goodplayer/onlyconfig/secrets"
+	"github.com/goodplayer/onlyconfig/webmgr/domains"
+	"github.com/goodplayer/onlyconfig/webmgr/storage/dbtxn"
+)
+
+type RevealAudit struct {
+	AuditId     int64  `xorm:"'audit_id' pk autoincr"`
+	ConfigId    int64  `xorm:"'config_id'"`
+	AppId       int64  `xorm:"'application_id'"`
+	Username    string `xorm:"'username'"`
+	Action      string `xorm:"'reveal_action'"`
+	TimeCreated int64  `xorm:"'time_created'"`
+}
+
+func (r *RevealAudit) TableName() string {
+	return "onlyconfig_secret_reveal"
+}
+
+// sealContent encrypts the content of a secret configure, empty content is kept as is.
+// The secret flag of the row decides, so a plain content looking like an envelope is still encrypted.
+func sealContent(keys secrets.KeyProvider, secret bool, content string) (string, error) {
+	if !secret || content == "" {
+		return content, nil
+	}
+	return secrets.Encrypt(keys, []byte(content))
+}
+
+// openContent decrypts the content sealed by sealContent for the row flagged secret, otherwise returned as is
+func openContent(keys secrets.KeyProvider, secret bool, content string) (string, error) {
+	if !secret || content == "" {
+		return content, nil
+	}
+	plaintext, err := secrets.Decrypt(keys, content)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+type SecretStoreImpl struct {
+}
+
+func (s *SecretStoreImpl) IsAppOrgOwner(ctx context.Context, appId int64, username string) (bool, error) {
+	sess := dbtxn.GetTxn(ctx)
+	result, err := sess.QueryString(`select 1 from onlyconfig_user_org_mapping m join onlyconfig_user u on m.user_id = u.user_id
+where u.username = ? and u.user_status = 0 and m.role_type = 1 and m.org_id = (select application_owner_org from onlyconfig_application where application_id = ?)`, username, appId)
+	if err != nil {
+		return false, err
+	}
+	return len(result) > 0, nil
+}
+
+func (s *SecretStoreImpl) AddRevealAudit(ctx context.Context, audit *domains.RevealAudit) error {
+	a := &RevealAudit{
+		ConfigId:    audit.ConfigId,
+		AppId:       audit.AppId,
+		Username:    audit.Username,
+		Action:      audit.Action,
+		TimeCreated: audit.TimeCreated,
+	}
+	sess := dbtxn.GetTxn(ctx)
+	if _, err := sess.Insert(a); err != nil {
+		return err
+	}
+	audit.AuditId = a.AuditId
+	return nil
+}
+
+func (s *SecretStoreImpl) LoadRevealAudits(ctx context.Context, cfgId int64, limit int) (result []*domains.RevealAudit, rerr error) {
+	var list []*RevealAudit
+	sess := dbtxn.GetTxn(ctx)
+	if err := sess.Where("config_id = ?", cfgId).Desc("audit_id").Limit(limit).Find(&list); err != nil {
+		return nil, err
+	}
+	for _, a := range list {
+		result = append(result, &domains.RevealAudit{
+			AuditId:     a.AuditId,
+			ConfigId:    a.ConfigId,
+			AppId:       a.AppId,
+			Username:    a.Username,
+			Action:      a.Action,
+			TimeCreated: a.TimeCreated,
+		})
+	}
+	return
+}
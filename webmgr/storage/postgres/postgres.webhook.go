@@ -0,0 +1,217 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/goodplayer/onlyconfig/webmgr/domains"
+	"github.com/goodplayer/onlyconfig/webmgr/storage/dbtxn"
+)
+
+type Webhook struct {
+	WebhookId   int64  `xorm:"'webhook_id' pk autoincr"`
+	OrgId       string `xorm:"'org_id'"`
+	AppId       int64  `xorm:"'application_id'"`
+	Url         string `xorm:"'webhook_url'"`
+	Secret      string `xorm:"'webhook_secret'"`
+	Description string `xorm:"'webhook_description'"`
+	TimeCreated int64  `xorm:"'time_created'"`
+	TimeUpdated int64  `xorm:"'time_updated'"`
+}
+
+func (w *Webhook) TableName() string {
+	return "onlyconfig_webhook"
+}
+
+func (w *Webhook) toDomain() *domains.Webhook {
+	return &domains.Webhook{
+		WebhookId:   w.WebhookId,
+		OrgId:       w.OrgId,
+		AppId:       w.AppId,
+		Url:         w.Url,
+		Secret:      w.Secret,
+		Description: w.Description,
+		TimeCreated: w.TimeCreated,
+		TimeUpdated: w.TimeUpdated,
+	}
+}
+
+type WebhookDelivery struct {
+	DeliveryId      int64  `xorm:"'delivery_id' pk autoincr"`
+	WebhookId       int64  `xorm:"'webhook_id'"`
+	Event           string `xorm:"'event'"`
+	Payload         string `xorm:"'payload'"`
+	Status          string `xorm:"'status'"`
+	Attempts        int    `xorm:"'attempts'"`
+	TimeNextAttempt int64  `xorm:"'time_next_attempt'"`
+	ResponseStatus  int    `xorm:"'response_status'"`
+	Message         string `xorm:"'message'"`
+	TimeCreated     int64  `xorm:"'time_created'"`
+	TimeUpdated     int64  `xorm:"'time_updated'"`
+}
+
+func (w *WebhookDelivery) TableName() string {
+	return "onlyconfig_webhook_delivery"
+}
+
+func (w *WebhookDelivery) toDomain() *domains.WebhookDelivery {
+	return &domains.WebhookDelivery{
+		DeliveryId:      w.DeliveryId,
+		WebhookId:       w.WebhookId,
+		Event:           w.Event,
+		Payload:         w.Payload,
+		Status:          w.Status,
+		Attempts:        w.Attempts,
+		TimeNextAttempt: w.TimeNextAttempt,
+		ResponseStatus:  w.ResponseStatus,
+		Message:         w.Message,
+		TimeCreated:     w.TimeCreated,
+		TimeUpdated:     w.TimeUpdated,
+	}
+}
+
+type WebhookStoreImpl struct{}
+
+func (c *WebhookStoreImpl) AddWebhook(ctx context.Context, webhook *domains.Webhook) error {
+	w := &Webhook{
+		OrgId:       webhook.OrgId,
+		AppId:       webhook.AppId,
+		Url:         webhook.Url,
+		Secret:      webhook.Secret,
+		Description: webhook.Description,
+		TimeCreated: webhook.TimeCreated,
+		TimeUpdated: webhook.TimeUpdated,
+	}
+	sess := dbtxn.GetTxn(ctx)
+	if _, err := sess.Insert(w); err != nil {
+		return err
+	}
+	webhook.WebhookId = w.WebhookId
+	return nil
+}
+
+func (c *WebhookStoreImpl) LoadWebhooks(ctx context.Context, orgId string, appId int64) (result []*domains.Webhook, rerr error) {
+	sess := dbtxn.GetTxn(ctx)
+	if orgId != "" {
+		sess = sess.Where("org_id = ?", orgId)
+	}
+	if appId > 0 {
+		sess = sess.Where("application_id = ?", appId)
+	}
+	var list []*Webhook
+	if err := sess.Asc("webhook_id").Find(&list); err != nil {
+		return nil, err
+	}
+	for _, w := range list {
+		result = append(result, w.toDomain())
+	}
+	return
+}
+
+func (c *WebhookStoreImpl) LoadWebhook(ctx context.Context, webhookId int64) (*domains.Webhook, error) {
+	w := new(Webhook)
+	sess := dbtxn.GetTxn(ctx)
+	if has, err := sess.Where("webhook_id = ?", webhookId).Get(w); err != nil {
+		return nil, err
+	} else if !has {
+		return nil, nil
+	}
+	return w.toDomain(), nil
+}
+
+func (c *WebhookStoreImpl) DeleteWebhook(ctx context.Context, webhookId int64) error {
+	sess := dbtxn.GetTxn(ctx)
+	if _, err := sess.Where("webhook_id = ?", webhookId).Delete(new(WebhookDelivery)); err != nil {
+		return err
+	}
+	if _, err := sess.Where("webhook_id = ?", webhookId).Delete(new(Webhook)); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (c *WebhookStoreImpl) LoadAppWebhooks(ctx context.Context, app *domains.Application) (result []*domains.Webhook, rerr error) {
+	var list []*Webhook
+	sess := dbtxn.GetTxn(ctx)
+	if err := sess.Where("application_id = ? or (application_id = 0 and org_id = (select application_owner_org from onlyconfig_application where application_id = ?))",
+		app.ApplicationId, app.ApplicationId).Asc("webhook_id").Find(&list); err != nil {
+		return nil, err
+	}
+	for _, w := range list {
+		result = append(result, w.toDomain())
+	}
+	return
+}
+
+func (c *WebhookStoreImpl) AddWebhookDeliveries(ctx context.Context, deliveries []*domains.WebhookDelivery) error {
+	sess := dbtxn.GetTxn(ctx)
+	for _, delivery := range deliveries {
+		d := &WebhookDelivery{
+			WebhookId:       delivery.WebhookId,
+			Event:           delivery.Event,
+			Payload:         delivery.Payload,
+			Status:          delivery.Status,
+			Attempts:        delivery.Attempts,
+			TimeNextAttempt: delivery.TimeNextAttempt,
+			ResponseStatus:  delivery.ResponseStatus,
+			Message:         delivery.Message,
+			TimeCreated:     delivery.TimeCreated,
+			TimeUpdated:     delivery.TimeUpdated,
+		}
+		if _, err := sess.Insert(d); err != nil {
+			return err
+		}
+		delivery.DeliveryId = d.DeliveryId
+	}
+	return nil
+}
+
+func (c *WebhookStoreImpl) LoadWebhookDeliveries(ctx context.Context, webhookId int64, limit int) (result []*domains.WebhookDelivery, rerr error) {
+	var list []*WebhookDelivery
+	sess := dbtxn.GetTxn(ctx)
+	if err := sess.Where("webhook_id = ?", webhookId).Desc("delivery_id").Limit(limit).Find(&list); err != nil {
+		return nil, err
+	}
+	for _, d := range list {
+		result = append(result, d.toDomain())
+	}
+	return
+}
+
+func (c *WebhookStoreImpl) LoadWebhookDelivery(ctx context.Context, deliveryId int64) (*domains.WebhookDelivery, error) {
+	d := new(WebhookDelivery)
+	sess := dbtxn.GetTxn(ctx)
+	if has, err := sess.Where("delivery_id = ?", deliveryId).Get(d); err != nil {
+		return nil, err
+	} else if !has {
+		return nil, nil
+	}
+	return d.toDomain(), nil
+}
+
+func (c *WebhookStoreImpl) LoadDueWebhookDelivery(ctx context.Context, now int64) (*domains.WebhookDelivery, error) {
+	d := new(WebhookDelivery)
+	sess := dbtxn.GetTxn(ctx)
+	// skip locked so that multiple instances deliver different payloads at the same time
+	if has, err := sess.SQL("select * from onlyconfig_webhook_delivery where status = ? and time_next_attempt <= ? order by time_next_attempt, delivery_id limit 1 for update skip locked",
+		domains.DeliveryStatusPending, now).Get(d); err != nil {
+		return nil, err
+	} else if !has {
+		return nil, nil
+	}
+	return d.toDomain(), nil
+}
+
+func (c *WebhookStoreImpl) UpdateWebhookDelivery(ctx context.Context, delivery *domains.WebhookDelivery) error {
+	sess := dbtxn.GetTxn(ctx)
+	if _, err := sess.ID(delivery.DeliveryId).Cols("status", "attempts", "time_next_attempt", "response_status", "message", "time_updated").Update(&WebhookDelivery{
+		Status:          delivery.Status,
+		Attempts:        delivery.Attempts,
+		TimeNextAttempt: delivery.TimeNextAttempt,
+		ResponseStatus:  delivery.ResponseStatus,
+		Message:         delivery.Message,
+		TimeUpdated:     delivery.TimeUpdated,
+	}); err != nil {
+		return err
+	}
+	return nil
+}
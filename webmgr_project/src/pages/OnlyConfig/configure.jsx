@@ -924,6 +924,8 @@ export function EditConfigureModal(props) {
             body: JSON.stringify({
                 ct: contentType,
                 content: content,
+                // the version the edit is based on, rejected with 409 if changed by others meanwhile
+                cfg_version: prevData && prevData.cfg_version,
             })
         })
             .then(async res => {
@@ -932,6 +934,14 @@ export function EditConfigureModal(props) {
                     setLogout(true);
                     return
                 }
+                if (res.status === 409) {
+                    let conflict = await res.json();
+                    console.log("configuration conflict:", conflict.error)
+                    alert("The configuration has been changed by others meanwhile, the latest version is loaded. Merge the edit and submit again.");
+                    // show the current content and version, so that the edit is merged and submitted again based on it
+                    setPrevData(conflict.current || {});
+                    return
+                }
                 if (res.status !== 200) {
                     console.log("status:", res.status)
                     //FIXME better error information display